@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// maxBenchTasks caps how many tasks one /bench runs
+const maxBenchTasks = 1000
+
+// ExecutorLookup interface for finding a registered executor by name
+type ExecutorLookup interface {
+	GetExecutor(name string) (plugin.Executor, bool)
+}
+
+// init registers the /bench command
+func init() {
+	Register(&plugin.Command{
+		Name:        "bench",
+		Description: "Measure how long an executor takes to run trivial tasks",
+		Usage:       "<executor> <n> [task_type]",
+		Handler:     handleBench,
+		Roles:       []plugin.Role{plugin.RoleAdmin},
+	})
+}
+
+// benchStats summarizes the latencies of a /bench run
+type benchStats struct {
+	min, max, avg, p95, total time.Duration
+}
+
+// handleBench runs n tasks on the named executor one after another, calling
+// it directly and waiting for each, and reports their latency
+// The task type defaults to the executor's name
+func handleBench(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return nil, fmt.Errorf("usage: /bench <executor> <n> [task_type]")
+	}
+	name := args[0]
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 || n > maxBenchTasks {
+		return nil, fmt.Errorf("n must be a number from 1 to %d", maxBenchTasks)
+	}
+	taskType := name
+	if len(args) == 3 {
+		taskType = args[2]
+	}
+
+	lookup, ok := ctx.Value("daemon").(ExecutorLookup)
+	if !ok {
+		return nil, fmt.Errorf("bench not available (daemon context not available)")
+	}
+	executor, ok := lookup.GetExecutor(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown executor: %s", name)
+	}
+	if !executor.CanHandle(taskType) {
+		return nil, fmt.Errorf("executor %s does not handle task type %s (pass one as the third argument)", name, taskType)
+	}
+
+	// Don't compete with a real task for the executor
+	if inspector, ok := ctx.Value("daemon").(TaskInspector); ok && inspector.GetCurrentTask() != nil {
+		return nil, fmt.Errorf("a task is running, try again when the daemon is idle")
+	}
+
+	plugin.Logf(ctx, "[Bench] Running %d %s task(s) on executor %s", n, taskType, name)
+
+	var latencies []time.Duration
+	failed := 0
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		task := &plugin.Task{
+			ID:            fmt.Sprintf("bench-%s-%d", plugin.NewCorrelationID(), i+1),
+			Type:          taskType,
+			Input:         "bench",
+			CorrelationID: plugin.CorrelationID(ctx),
+		}
+
+		start := time.Now()
+		_, err := executor.ExecuteTask(ctx, task)
+		latencies = append(latencies, time.Since(start))
+		if err != nil {
+			failed++
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("bench stopped after %d of %d task(s): %w", len(latencies), n, ctx.Err())
+	}
+
+	stats := summarizeLatencies(latencies)
+	return &plugin.CommandResult{
+		Output: fmt.Sprintf("Executor %s: %d task(s), %d failed\nmin %s  avg %s  p95 %s  max %s  total %s",
+			name, len(latencies), failed,
+			stats.min.Round(time.Microsecond), stats.avg.Round(time.Microsecond), stats.p95.Round(time.Microsecond),
+			stats.max.Round(time.Microsecond), stats.total.Round(time.Microsecond)),
+		Data: map[string]interface{}{
+			"executor": name,
+			"tasks":    len(latencies),
+			"failed":   failed,
+			"min_ms":   milliseconds(stats.min),
+			"avg_ms":   milliseconds(stats.avg),
+			"p95_ms":   milliseconds(stats.p95),
+			"max_ms":   milliseconds(stats.max),
+			"total_ms": milliseconds(stats.total),
+		},
+	}, nil
+}
+
+// summarizeLatencies computes the statistics of a non-empty list of latencies
+// p95 is the nearest-rank 95th percentile
+func summarizeLatencies(latencies []time.Duration) benchStats {
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	var stats benchStats
+	for _, d := range sorted {
+		stats.total += d
+	}
+	stats.min = sorted[0]
+	stats.max = sorted[len(sorted)-1]
+	stats.avg = stats.total / time.Duration(len(sorted))
+	rank := (95*len(sorted) + 99) / 100 // ceil(0.95 * n)
+	stats.p95 = sorted[rank-1]
+	return stats
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// benchDaemon looks up fixed executors and reports a fixed current task
+type benchDaemon struct {
+	executors map[string]plugin.Executor
+	current   *plugin.Task
+}
+
+func (d *benchDaemon) GetExecutor(name string) (plugin.Executor, bool) {
+	executor, ok := d.executors[name]
+	return executor, ok
+}
+
+func (d *benchDaemon) GetCurrentTask() *plugin.Task    { return d.current }
+func (d *benchDaemon) GetRunningTasks() []*plugin.Task { return nil }
+
+func TestSummarizeLatencies(t *testing.T) {
+	var latencies []time.Duration
+	for i := 20; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	got := summarizeLatencies(latencies)
+	want := benchStats{
+		min:   time.Millisecond,
+		max:   20 * time.Millisecond,
+		avg:   10500 * time.Microsecond,
+		p95:   19 * time.Millisecond,
+		total: 210 * time.Millisecond,
+	}
+	if got != want {
+		t.Errorf("summarizeLatencies = %+v, want %+v", got, want)
+	}
+
+	one := summarizeLatencies([]time.Duration{3 * time.Millisecond})
+	if one.min != one.max || one.p95 != 3*time.Millisecond || one.avg != 3*time.Millisecond {
+		t.Errorf("single latency stats = %+v, want all 3ms", one)
+	}
+}
+
+func TestBenchMeasuresExecutor(t *testing.T) {
+	const delay = 2 * time.Millisecond
+	var runs atomic.Int32
+	executor := testutil.NewExecutor("echo")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		time.Sleep(delay)
+		if runs.Add(1)%2 == 0 {
+			return nil, errors.New("every other task fails")
+		}
+		return task.Input, nil
+	}
+	ctx := testutil.NewContext(testutil.WithDaemon(&benchDaemon{executors: map[string]plugin.Executor{"echo": executor}}))
+
+	result, err := handleBench(ctx, []string{"echo", "10"})
+	if err != nil {
+		t.Fatalf("/bench: %v", err)
+	}
+	data := result.Data.(map[string]interface{})
+	if data["tasks"] != 10 || data["failed"] != 5 || runs.Load() != 10 {
+		t.Errorf("data = %v after %d runs, want 10 tasks with 5 failed", data, runs.Load())
+	}
+
+	floor := float64(delay.Microseconds()) / 1000
+	minMs, avgMs, p95Ms, maxMs, totalMs := data["min_ms"].(float64), data["avg_ms"].(float64), data["p95_ms"].(float64), data["max_ms"].(float64), data["total_ms"].(float64)
+	if minMs < floor || minMs > avgMs || avgMs > maxMs || p95Ms < minMs || p95Ms > maxMs || totalMs < 10*floor {
+		t.Errorf("latencies min %v avg %v p95 %v max %v total %v, want ordered and each at least %vms", minMs, avgMs, p95Ms, maxMs, totalMs, floor)
+	}
+	if !strings.HasPrefix(result.Output, "Executor echo: 10 task(s), 5 failed\n") {
+		t.Errorf("output = %q, want the summary", result.Output)
+	}
+}
+
+func TestBenchRejectsBadRequests(t *testing.T) {
+	executor := testutil.NewExecutor("echo")
+	idle := &benchDaemon{executors: map[string]plugin.Executor{"echo": executor}}
+	busy := &benchDaemon{executors: idle.executors, current: &plugin.Task{ID: "real"}}
+
+	tests := []struct {
+		name   string
+		daemon interface{}
+		args   []string
+		want   string
+	}{
+		{"no count", idle, []string{"echo"}, "usage"},
+		{"bad count", idle, []string{"echo", "0"}, "n must be"},
+		{"unknown executor", idle, []string{"nope", "1"}, "unknown executor"},
+		{"unhandled type", idle, []string{"echo", "1", "query"}, "does not handle"},
+		{"busy daemon", busy, []string{"echo", "1"}, "a task is running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testutil.NewContext(testutil.WithDaemon(tt.daemon))
+			if _, err := handleBench(ctx, tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("/bench %v = %v, want an error mentioning %q", tt.args, err, tt.want)
+			}
+		})
+	}
+	if got := len(executor.Executed()); got != 0 {
+		t.Errorf("rejected runs executed %d tasks, want none", got)
+	}
+}
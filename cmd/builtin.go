@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"bicycle/plugin"
@@ -32,6 +34,41 @@ func init() {
 		Usage:       "",
 		Handler:     handleReset,
 		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+
+		SupportsDryRun: true,
+	})
+
+	Register(&plugin.Command{
+		Name:        "log",
+		Description: "Show recent notifications",
+		Usage:       "[count]",
+		Handler:     handleLog,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+	})
+
+	Register(&plugin.Command{
+		Name:        "topics",
+		Description: "List broker topics and their subscriber counts",
+		Usage:       "",
+		Handler:     handleTopics,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+	})
+
+	Register(&plugin.Command{
+		Name:        "broker",
+		Description: "Show broker subscriptions and their buffer usage",
+		Usage:       "",
+		Handler:     handleBroker,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+		Roles:       []plugin.Role{plugin.RoleAdmin},
+	})
+
+	Register(&plugin.Command{
+		Name:        "whoami",
+		Description: "Show your identity and role",
+		Usage:       "",
+		Handler:     handleWhoami,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
 	})
 
 	Register(&plugin.Command{
@@ -41,6 +78,23 @@ func init() {
 		Handler:     handlePlugins,
 		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
 	})
+
+	Register(&plugin.Command{
+		Name:        "deps",
+		Description: "Show plugin dependencies and start order",
+		Usage:       "",
+		Handler:     handleDeps,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+	})
+
+	Register(&plugin.Command{
+		Name:        "check",
+		Description: "Run a plugin's requirement checks without starting it",
+		Usage:       "<plugin>",
+		Handler:     handleCheck,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+		Roles:       []plugin.Role{plugin.RoleAdmin},
+	})
 }
 
 // handleHelp shows help for all commands or a specific command
@@ -50,7 +104,7 @@ func handleHelp(ctx context.Context, args []string) (*plugin.CommandResult, erro
 	// If specific command requested, show its help
 	if len(args) > 0 {
 		cmdName := strings.TrimPrefix(args[0], "/")
-		helpText, err := router.GetCommandHelp(cmdName)
+		helpText, err := router.GetCommandHelp(cmdName, plugin.RoleFromContext(ctx))
 		if err != nil {
 			return nil, err
 		}
@@ -63,7 +117,7 @@ func handleHelp(ctx context.Context, args []string) (*plugin.CommandResult, erro
 		mode = plugin.ModeDaemon // Default to daemon mode
 	}
 
-	helpText := router.GetHelp(mode)
+	helpText := router.GetHelp(mode, plugin.RoleFromContext(ctx))
 	return &plugin.CommandResult{Output: helpText}, nil
 }
 
@@ -92,6 +146,20 @@ func handleReset(ctx context.Context, args []string) (*plugin.CommandResult, err
 		return nil, fmt.Errorf("reset not available (daemon context not available)")
 	}
 
+	if plugin.IsDryRun(ctx) {
+		output := "Would reset daemon to idle state (no task running)"
+		if inspector, ok := daemon.(TaskInspector); ok {
+			switch tasks := inspector.GetRunningTasks(); len(tasks) {
+			case 0:
+			case 1:
+				output = fmt.Sprintf("Would cancel task %s (%s) and reset daemon to idle state", tasks[0].ID, tasks[0].Type)
+			default:
+				output = fmt.Sprintf("Would cancel %d running tasks and reset daemon to idle state", len(tasks))
+			}
+		}
+		return &plugin.CommandResult{Output: output}, nil
+	}
+
 	if err := daemon.Reset(ctx); err != nil {
 		return nil, fmt.Errorf("reset failed: %w", err)
 	}
@@ -102,10 +170,120 @@ func handleReset(ctx context.Context, args []string) (*plugin.CommandResult, err
 	}, nil
 }
 
+// handleLog shows the most recent notifications
+func handleLog(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	daemon, ok := ctx.Value("daemon").(NotificationLog)
+	if !ok {
+		return nil, fmt.Errorf("log not available (daemon context not available)")
+	}
+
+	count := 10
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("usage: /log [count]")
+		}
+		count = n
+	}
+
+	lines := daemon.RecentNotifications(count)
+	if len(lines) == 0 {
+		return &plugin.CommandResult{Output: "No notifications yet"}, nil
+	}
+
+	return &plugin.CommandResult{
+		Output: strings.Join(lines, "\n"),
+		Data:   lines,
+	}, nil
+}
+
+// handleTopics lists broker topics with their subscriber counts
+func handleTopics(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	daemon, ok := ctx.Value("daemon").(TopicLister)
+	if !ok {
+		return nil, fmt.Errorf("topics not available (daemon context not available)")
+	}
+
+	counts := daemon.TopicSubscriberCounts()
+	if len(counts) == 0 {
+		return &plugin.CommandResult{Output: "No subscribers"}, nil
+	}
+
+	topics := make([]string, 0, len(counts))
+	for topic := range counts {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	var sb strings.Builder
+	sb.WriteString("Topics:\n")
+	for _, topic := range topics {
+		sb.WriteString(fmt.Sprintf("  %s: %d subscriber(s)\n", topic, counts[topic]))
+	}
+
+	return &plugin.CommandResult{
+		Output: strings.TrimSuffix(sb.String(), "\n"),
+		Data:   counts,
+	}, nil
+}
+
+// handleBroker shows each subscription's pending messages and high-water mark
+func handleBroker(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	daemon, ok := ctx.Value("daemon").(BrokerInspector)
+	if !ok {
+		return nil, fmt.Errorf("broker stats not available (daemon context not available)")
+	}
+
+	stats := daemon.BrokerStats()
+	if len(stats) == 0 {
+		return &plugin.CommandResult{Output: "No subscribers"}, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Subscriptions:\n")
+	for _, s := range stats {
+		topics := "*"
+		if len(s.Topics) > 0 {
+			topics = strings.Join(s.Topics, ", ")
+		}
+		sb.WriteString(fmt.Sprintf("  %s [%s]: %d/%d pending, high-water %d\n", s.ID, topics, s.Pending, s.BufSize, s.HighWater))
+	}
+
+	return &plugin.CommandResult{
+		Output: strings.TrimSuffix(sb.String(), "\n"),
+		Data:   stats,
+	}, nil
+}
+
+// handleWhoami shows the principal issuing the command
+func handleWhoami(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	principal, ok := plugin.PrincipalFromContext(ctx)
+	if !ok {
+		return &plugin.CommandResult{Output: "Unknown principal"}, nil
+	}
+
+	userID := principal.UserID
+	if userID == "" {
+		userID = "(unknown)"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Source: %s\n", principal.Source))
+	sb.WriteString(fmt.Sprintf("User: %s\n", userID))
+	sb.WriteString(fmt.Sprintf("Role: %s", principal.Role))
+
+	return &plugin.CommandResult{
+		Output: sb.String(),
+		Data:   principal,
+	}, nil
+}
+
 // handlePlugins lists all registered plugins
+// Commands the caller can't run are left out of their plugin's extensions
 func handlePlugins(ctx context.Context, args []string) (*plugin.CommandResult, error) {
 	registry := plugin.GetRegistry()
 	plugins := registry.All()
+	role := plugin.RoleFromContext(ctx)
 
 	if len(plugins) == 0 {
 		return &plugin.CommandResult{
@@ -125,6 +303,9 @@ func handlePlugins(ctx context.Context, args []string) (*plugin.CommandResult, e
 			sb.WriteString(fmt.Sprintf("   Extensions: "))
 			var extNames []string
 			for _, ext := range extensions {
+				if cmdExt, ok := ext.(*plugin.CommandExtension); ok && !cmdExt.Command().AllowsRole(role) {
+					continue
+				}
 				extNames = append(extNames, fmt.Sprintf("%s:%s", ext.Type(), ext.Name()))
 			}
 			sb.WriteString(strings.Join(extNames, ", "))
@@ -137,6 +318,122 @@ func handlePlugins(ctx context.Context, args []string) (*plugin.CommandResult, e
 	}, nil
 }
 
+// handleDeps shows each plugin's dependency tree, the start order, and
+// plugins held back by missing or cyclic dependencies
+func handleDeps(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	daemon, ok := ctx.Value("daemon").(DependencyInspector)
+	if !ok {
+		return nil, fmt.Errorf("deps not available (daemon context not available)")
+	}
+
+	graph := daemon.DependencyGraph()
+	if len(graph.Plugins) == 0 {
+		return &plugin.CommandResult{Output: "No plugins loaded", Data: graph}, nil
+	}
+
+	nodes := make(map[string]plugin.PluginDependencies, len(graph.Plugins))
+	for _, node := range graph.Plugins {
+		nodes[node.Name] = node
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Start order: %s\n\n", strings.Join(graph.StartOrder, " -> ")))
+	for _, node := range graph.Plugins {
+		writeDependencyTree(&sb, nodes, node.Name, 0, nil)
+	}
+
+	var problems []string
+	for _, node := range graph.Plugins {
+		if node.Problem != "" {
+			problems = append(problems, fmt.Sprintf("  %s: %s", node.Name, node.Problem))
+		}
+	}
+	if len(problems) > 0 {
+		sb.WriteString(fmt.Sprintf("\nProblems:\n%s\n", strings.Join(problems, "\n")))
+	}
+
+	return &plugin.CommandResult{
+		Output: sb.String(),
+		Data:   graph,
+	}, nil
+}
+
+// writeDependencyTree writes name and, indented below it, its dependencies
+// path holds the plugins above name, so a cycle is shown once and not followed
+func writeDependencyTree(sb *strings.Builder, nodes map[string]plugin.PluginDependencies, name string, depth int, path []string) {
+	indent := strings.Repeat("  ", depth)
+	node, ok := nodes[name]
+	switch {
+	case !ok:
+		sb.WriteString(fmt.Sprintf("%s%s (missing)\n", indent, name))
+		return
+	case containsString(path, name):
+		sb.WriteString(fmt.Sprintf("%s%s (cycle)\n", indent, name))
+		return
+	}
+
+	state := "not running"
+	if node.Running {
+		state = "running"
+	}
+	sb.WriteString(fmt.Sprintf("%s%s [%s]\n", indent, name, state))
+
+	path = append(path, name)
+	for _, dep := range node.DependsOn {
+		writeDependencyTree(sb, nodes, dep, depth+1, path)
+	}
+}
+
+// handleCheck reports each of a plugin's requirements as passed or failed
+func handleCheck(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: /check <plugin>")
+	}
+
+	daemon, ok := ctx.Value("daemon").(RequirementInspector)
+	if !ok {
+		return nil, fmt.Errorf("check not available (daemon context not available)")
+	}
+
+	report, err := daemon.CheckPlugin(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	if report.Passed {
+		sb.WriteString(fmt.Sprintf("Requirements for %s: passed\n", report.Plugin))
+	} else {
+		sb.WriteString(fmt.Sprintf("Requirements for %s: failed\n", report.Plugin))
+	}
+	for _, result := range report.Results {
+		kind := "optional"
+		if result.Required {
+			kind = "required"
+		}
+		switch {
+		case result.Passed:
+			sb.WriteString(fmt.Sprintf("  ✓ %s (%s)\n", result.Name, kind))
+		case result.Required:
+			sb.WriteString(fmt.Sprintf("  ✗ %s (%s): %s\n", result.Name, kind, result.Error))
+		default:
+			sb.WriteString(fmt.Sprintf("  ⚠ %s (%s): %s\n", result.Name, kind, result.Error))
+		}
+	}
+	if len(report.Results) == 0 {
+		if report.Error != "" {
+			sb.WriteString(fmt.Sprintf("  %s\n", report.Error))
+		} else {
+			sb.WriteString("  No requirements declared\n")
+		}
+	}
+
+	return &plugin.CommandResult{
+		Output: sb.String(),
+		Data:   report,
+	}, nil
+}
+
 // StatusProvider interface for getting daemon status
 type StatusProvider interface {
 	GetStatus(ctx context.Context) string
@@ -146,3 +443,39 @@ type StatusProvider interface {
 type Resettable interface {
 	Reset(ctx context.Context) error
 }
+
+// TaskInspector interface for reading the running tasks
+type TaskInspector interface {
+	GetCurrentTask() *plugin.Task
+	GetRunningTasks() []*plugin.Task
+}
+
+// TaskPreviewer interface for checking whether a task would be accepted
+type TaskPreviewer interface {
+	PreviewTask(task *plugin.Task) error
+}
+
+// TopicLister interface for reading broker topic subscriber counts
+type TopicLister interface {
+	TopicSubscriberCounts() map[string]int
+}
+
+// BrokerInspector interface for reading broker subscription stats
+type BrokerInspector interface {
+	BrokerStats() []plugin.SubscriberStats
+}
+
+// DependencyInspector interface for reading the plugin dependency graph
+type DependencyInspector interface {
+	DependencyGraph() plugin.DependencyGraph
+}
+
+// RequirementInspector interface for running a plugin's requirement checks
+type RequirementInspector interface {
+	CheckPlugin(name string) (*plugin.RequirementReport, error)
+}
+
+// NotificationLog interface for reading recent notifications
+type NotificationLog interface {
+	RecentNotifications(n int) []string
+}
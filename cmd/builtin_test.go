@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestWhoamiReflectsPrincipal(t *testing.T) {
+	principal := plugin.Principal{Source: "telegram:42", UserID: "42", Role: plugin.RoleAdmin}
+	ctx := plugin.WithPrincipal(context.Background(), principal)
+
+	result, err := GetRegistry().Execute(ctx, "whoami", nil)
+	if err != nil {
+		t.Fatalf("whoami: %v", err)
+	}
+	if want := "Source: telegram:42\nUser: 42\nRole: admin"; result.Output != want {
+		t.Errorf("output = %q, want %q", result.Output, want)
+	}
+	if got, ok := result.Data.(plugin.Principal); !ok || got != principal {
+		t.Errorf("data = %+v, want %+v", result.Data, principal)
+	}
+}
+
+func TestWhoamiWithoutUserID(t *testing.T) {
+	ctx := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "tui", Role: plugin.RoleUser})
+
+	result, err := handleWhoami(ctx, nil)
+	if err != nil {
+		t.Fatalf("whoami: %v", err)
+	}
+	if want := "Source: tui\nUser: (unknown)\nRole: user"; result.Output != want {
+		t.Errorf("output = %q, want %q", result.Output, want)
+	}
+}
+
+func TestWhoamiWithoutPrincipal(t *testing.T) {
+	result, err := handleWhoami(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("whoami: %v", err)
+	}
+	if result.Output != "Unknown principal" {
+		t.Errorf("output = %q, want Unknown principal", result.Output)
+	}
+}
+
+func TestDryRunResetLeavesDaemonAlone(t *testing.T) {
+	d := testutil.NewDaemon()
+	ctx := testutil.NewContext(testutil.WithDaemon(d))
+	router := NewRouter()
+
+	result, err := router.Route(ctx, "/reset --dry-run")
+	if err != nil {
+		t.Fatalf("dry-run reset: %v", err)
+	}
+	if !result.DryRun || !strings.HasPrefix(result.Output, "Would reset") || result.Broadcast {
+		t.Errorf("result = %+v, want an unbroadcast dry-run description", result)
+	}
+	if got := d.Resets(); got != 0 {
+		t.Errorf("dry run reset the daemon %d time(s)", got)
+	}
+
+	if _, err := router.Route(ctx, "/reset"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if got := d.Resets(); got != 1 {
+		t.Errorf("reset the daemon %d time(s), want 1", got)
+	}
+}
+
+func TestDryRunRefusedByUnsupportingCommand(t *testing.T) {
+	ctx := testutil.NewContext()
+	_, err := NewRouter().Route(ctx, "/whoami --dry-run")
+	if err == nil || err.Error() != "command /whoami does not support --dry-run" {
+		t.Errorf("error = %v, want dry run refused", err)
+	}
+}
+
+// topicLister is a fake daemon reporting fixed topic counts
+type topicLister map[string]int
+
+func (l topicLister) TopicSubscriberCounts() map[string]int { return l }
+
+func TestTopicsListsCountsInOrder(t *testing.T) {
+	ctx := testutil.NewContext(testutil.WithDaemon(topicLister{"response": 2, "*": 1, "chat": 3}))
+
+	result, err := handleTopics(ctx, nil)
+	if err != nil {
+		t.Fatalf("/topics: %v", err)
+	}
+	want := "Topics:\n  *: 1 subscriber(s)\n  chat: 3 subscriber(s)\n  response: 2 subscriber(s)"
+	if result.Output != want {
+		t.Errorf("output = %q, want %q", result.Output, want)
+	}
+}
+
+// brokerInspector is a fake daemon reporting fixed subscriber stats
+type brokerInspector []plugin.SubscriberStats
+
+func (i brokerInspector) BrokerStats() []plugin.SubscriberStats { return i }
+
+func TestBrokerShowsHighWaterMarks(t *testing.T) {
+	ctx := testutil.NewContext(testutil.WithDaemon(brokerInspector{
+		{ID: "audit", BufSize: 100, HighWater: 7},
+		{ID: "tui", Topics: []string{"chat", "response"}, BufSize: 100, Pending: 3, HighWater: 42},
+	}))
+
+	result, err := handleBroker(ctx, nil)
+	if err != nil {
+		t.Fatalf("/broker: %v", err)
+	}
+	want := "Subscriptions:\n  audit [*]: 0/100 pending, high-water 7\n  tui [chat, response]: 3/100 pending, high-water 42"
+	if result.Output != want {
+		t.Errorf("output = %q, want %q", result.Output, want)
+	}
+}
+
+// dependencyInspector serves a fixed dependency graph
+type dependencyInspector plugin.DependencyGraph
+
+func (i dependencyInspector) DependencyGraph() plugin.DependencyGraph {
+	return plugin.DependencyGraph(i)
+}
+
+func TestDepsRendersTreeAndCycle(t *testing.T) {
+	graph := plugin.DependencyGraph{
+		Plugins: []plugin.PluginDependencies{
+			{Name: "app", DependsOn: []string{"store"}, Running: true},
+			{Name: "store", Running: true},
+			{Name: "ping", DependsOn: []string{"pong"}, Problem: "dependency cycle: ping -> pong -> ping"},
+			{Name: "pong", DependsOn: []string{"ping"}, Problem: "dependency ping unavailable"},
+		},
+		StartOrder: []string{"store", "app"},
+	}
+	ctx := testutil.NewContext(testutil.WithDaemon(dependencyInspector(graph)))
+
+	result, err := NewRouter().Route(ctx, "/deps")
+	if err != nil {
+		t.Fatalf("/deps: %v", err)
+	}
+	for _, want := range []string{
+		"Start order: store -> app\n",
+		"app [running]\n  store [running]\n",
+		"ping [not running]\n  pong [not running]\n    ping (cycle)\n",
+		"Problems:\n  ping: dependency cycle: ping -> pong -> ping\n",
+	} {
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("output missing %q:\n%s", want, result.Output)
+		}
+	}
+	if data, ok := result.Data.(plugin.DependencyGraph); !ok || len(data.StartOrder) != 2 {
+		t.Errorf("data = %#v, want the dependency graph", result.Data)
+	}
+}
+
+// requirementInspector serves a fixed requirement report
+type requirementInspector plugin.RequirementReport
+
+func (i requirementInspector) CheckPlugin(name string) (*plugin.RequirementReport, error) {
+	report := plugin.RequirementReport(i)
+	return &report, nil
+}
+
+func TestCheckReportsEachRequirement(t *testing.T) {
+	ctx := testutil.NewContext(
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleAdmin}),
+		testutil.WithDaemon(requirementInspector{
+			Plugin: "mixed",
+			Error:  "requirement check(s) failed: token: not set",
+			Results: []plugin.RequirementResult{
+				{Name: "config", Required: true, Passed: true},
+				{Name: "cache", Error: "read-only"},
+				{Name: "token", Required: true, Error: "not set"},
+			},
+		}),
+	)
+
+	result, err := NewRouter().Route(ctx, "/check mixed")
+	if err != nil {
+		t.Fatalf("/check: %v", err)
+	}
+	want := "Requirements for mixed: failed\n" +
+		"  ✓ config (required)\n" +
+		"  ⚠ cache (optional): read-only\n" +
+		"  ✗ token (required): not set\n"
+	if result.Output != want {
+		t.Errorf("output =\n%s\nwant\n%s", result.Output, want)
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"bicycle/plugin"
+)
+
+// warnDeprecated returns result with a warning that cmd is deprecated in
+// front of its output, the first time the context's source runs cmd
+// Later results are returned unchanged
+func (cr *CommandRegistry) warnDeprecated(ctx context.Context, cmd *plugin.Command, result *plugin.CommandResult) *plugin.CommandResult {
+	source, _ := ctx.Value("source").(string)
+	key := cmd.Name + "|" + source
+
+	cr.mu.Lock()
+	if cr.warned[key] {
+		cr.mu.Unlock()
+		return result
+	}
+	cr.warned[key] = true
+	cr.mu.Unlock()
+
+	plugin.Logf(ctx, "[CommandRegistry] Deprecated command /%s used by %s", cmd.Name, source)
+
+	warned := *result
+	warned.Output = fmt.Sprintf("Warning: /%s is deprecated: %s", cmd.Name, cmd.Deprecated)
+	if result.Output != "" {
+		warned.Output += "\n\n" + result.Output
+	}
+	return &warned
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// pingTimeout is how long /ping waits for the daemon's loopback reply
+var pingTimeout = 2 * time.Second
+
+// init registers the /ping command
+func init() {
+	Register(&plugin.Command{
+		Name:        "ping",
+		Description: "Measure the broker round-trip latency",
+		Usage:       "",
+		Handler:     handlePing,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+	})
+}
+
+// handlePing publishes a daemon.ping and waits for the matching daemon.pong.
+// A reply means the broker is healthy even if a transport isn't
+func handlePing(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	broker, ok := ctx.Value("broker").(plugin.MessageBroker)
+	if !ok {
+		return nil, fmt.Errorf("ping not available (broker context not available)")
+	}
+
+	id := plugin.NewCorrelationID()
+	subID := "ping:" + id
+	replies := broker.Subscribe(subID, 16, plugin.TopicPong)
+	defer broker.Unsubscribe(subID)
+
+	start := time.Now()
+	if err := broker.Publish(ctx, plugin.Message{
+		Topic:    plugin.TopicPing,
+		Payload:  "ping",
+		Source:   "ping",
+		Metadata: map[string]interface{}{plugin.MetadataCorrelationID: id},
+	}); err != nil {
+		return nil, fmt.Errorf("ping failed: %w", err)
+	}
+
+	timeout := time.NewTimer(pingTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case msg, ok := <-replies:
+			if !ok {
+				return nil, fmt.Errorf("ping failed: subscription closed")
+			}
+			if replyID, _ := msg.Metadata[plugin.MetadataCorrelationID].(string); replyID != id {
+				continue
+			}
+			latency := time.Since(start)
+			return &plugin.CommandResult{
+				Output: fmt.Sprintf("Pong from broker in %s", latency.Round(time.Microsecond)),
+				Data:   map[string]interface{}{"latency_ms": float64(latency.Microseconds()) / 1000},
+			}, nil
+
+		case <-timeout.C:
+			return nil, fmt.Errorf("no reply from the broker loopback within %s", pingTimeout)
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
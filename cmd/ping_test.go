@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// echoPings answers pings on broker the way the daemon's loopback does
+func echoPings(broker *testutil.Broker) {
+	pings := broker.Subscribe("loopback", 16, plugin.TopicPing)
+	go func() {
+		for msg := range pings {
+			broker.Publish(context.Background(), plugin.Message{
+				Topic:    plugin.TopicPong,
+				Payload:  msg.Payload,
+				Metadata: map[string]interface{}{plugin.MetadataCorrelationID: msg.Metadata[plugin.MetadataCorrelationID]},
+			})
+		}
+	}()
+}
+
+func TestPingReportsLatency(t *testing.T) {
+	broker := testutil.NewBroker()
+	echoPings(broker)
+	defer broker.Unsubscribe("loopback")
+
+	result, err := NewRouter().Route(testutil.NewContext(testutil.WithBroker(broker)), "/ping")
+	if err != nil {
+		t.Fatalf("/ping: %v", err)
+	}
+	if !strings.HasPrefix(result.Output, "Pong from broker in ") {
+		t.Errorf("output = %q, want a pong", result.Output)
+	}
+	data, _ := result.Data.(map[string]interface{})
+	if latency, ok := data["latency_ms"].(float64); !ok || latency < 0 {
+		t.Errorf("data = %v, want a latency_ms", result.Data)
+	}
+}
+
+func TestPingTimesOutWithoutResponder(t *testing.T) {
+	defer func(timeout time.Duration) { pingTimeout = timeout }(pingTimeout)
+	pingTimeout = 20 * time.Millisecond
+
+	broker := testutil.NewBroker()
+	_, err := NewRouter().Route(testutil.NewContext(testutil.WithBroker(broker)), "/ping")
+	if err == nil || !strings.Contains(err.Error(), "no reply from the broker loopback") {
+		t.Errorf("/ping = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// checkRateLimit records a command invocation by the context's principal and
+// returns an error if it exceeds the limit configured for the principal's
+// role in daemon.command_rate_limits. Invocations are counted per transport
+// identity (the principal's RateLimitKey), so the same user on two transports
+// has two separate limits
+func (cr *CommandRegistry) checkRateLimit(ctx context.Context) error {
+	principal, ok := plugin.PrincipalFromContext(ctx)
+	key := principal.RateLimitKey()
+	if !ok || key == "" {
+		return nil
+	}
+	cfg, ok := ctx.Value("config").(*config.Config)
+	if !ok {
+		return nil
+	}
+	limit, ok := cfg.Daemon.CommandRateLimits[plugin.RoleFromContext(ctx)]
+	if !ok {
+		return nil
+	}
+	window := time.Duration(limit.Per) * time.Second
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	now := cr.clock.Now()
+
+	// Forget keys idle for longer than any window so the map doesn't grow
+	// without bound
+	longest := window
+	for _, l := range cfg.Daemon.CommandRateLimits {
+		if d := time.Duration(l.Per) * time.Second; d > longest {
+			longest = d
+		}
+	}
+	for k, times := range cr.recent {
+		if now.Sub(times[len(times)-1]) >= longest {
+			delete(cr.recent, k)
+		}
+	}
+
+	// Keep only invocations still inside the window
+	recent := cr.recent[key]
+	kept := recent[:0]
+	for _, at := range recent {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+
+	if len(kept) >= limit.Commands {
+		cr.recent[key] = kept
+		retryIn := kept[0].Add(window).Sub(now)
+		return fmt.Errorf("rate limit exceeded (%d commands per %s), try again in %s", limit.Commands, window, retryIn.Round(time.Second))
+	}
+
+	cr.recent[key] = append(kept, now)
+	return nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// RestartRequester interface for asking the daemon's process to restart it
+type RestartRequester interface {
+	RequestRestart(reason string)
+}
+
+// init registers the /reexec command
+func init() {
+	Register(&plugin.Command{
+		Name:           "reexec",
+		Description:    "Gracefully stop the daemon and start the binary again in place",
+		Usage:          shutdownConfirm,
+		Handler:        handleReexec,
+		Modes:          []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+		Roles:          []plugin.Role{plugin.RoleAdmin},
+		SupportsDryRun: true,
+	})
+}
+
+// handleReexec stops the daemon like /shutdown, once the reply has had time
+// to go out, and has the process re-execute itself afterwards
+func handleReexec(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	daemon, ok := ctx.Value("daemon").(RestartRequester)
+	if !ok {
+		return nil, fmt.Errorf("restart not available (daemon context not available)")
+	}
+
+	if plugin.IsDryRun(ctx) {
+		return &plugin.CommandResult{Output: "Would stop the daemon and start it again"}, nil
+	}
+
+	if len(args) != 1 || args[0] != shutdownConfirm {
+		return nil, fmt.Errorf("usage: /reexec %s (restarts the daemon)", shutdownConfirm)
+	}
+
+	reason := "/reexec"
+	if principal, ok := plugin.PrincipalFromContext(ctx); ok && principal.Source != "" {
+		reason = fmt.Sprintf("/reexec from %s", principal.Source)
+	}
+	time.AfterFunc(shutdownDelay, func() {
+		daemon.RequestRestart(reason)
+	})
+
+	return &plugin.CommandResult{
+		Output: "Daemon restarting",
+	}, nil
+}
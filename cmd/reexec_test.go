@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// restartRecorder records restart requests
+type restartRecorder struct {
+	mu      sync.Mutex
+	reasons []string
+}
+
+func (r *restartRecorder) RequestRestart(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reasons = append(r.reasons, reason)
+}
+
+func (r *restartRecorder) Reasons() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.reasons...)
+}
+
+func TestReexecNeedsAdminAndConfirmation(t *testing.T) {
+	defer func(delay time.Duration) { shutdownDelay = delay }(shutdownDelay)
+	shutdownDelay = 0
+
+	d := &restartRecorder{}
+	router := NewRouter()
+	user := testutil.NewContext(testutil.WithDaemon(d),
+		testutil.WithPrincipal(plugin.Principal{Source: "rest", Role: plugin.RoleUser}))
+	admin := testutil.NewContext(testutil.WithDaemon(d),
+		testutil.WithPrincipal(plugin.Principal{Source: "rest", Role: plugin.RoleAdmin}))
+
+	if _, err := router.Route(user, "/reexec confirm"); err == nil {
+		t.Error("user ran /reexec")
+	}
+	if _, err := router.Route(admin, "/reexec"); err == nil {
+		t.Error("/reexec without confirm succeeded")
+	}
+	if result, err := router.Route(admin, "/reexec confirm --dry-run"); err != nil || !result.DryRun {
+		t.Errorf("dry run = %+v, %v, want a preview", result, err)
+	}
+
+	result, err := router.Route(admin, "/reexec confirm")
+	if err != nil {
+		t.Fatalf("/reexec confirm: %v", err)
+	}
+	if result.Output != "Daemon restarting" {
+		t.Errorf("output = %q, want an acknowledgement", result.Output)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(d.Reasons()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if reasons := d.Reasons(); len(reasons) != 1 || reasons[0] != "/reexec from rest" {
+		t.Errorf("restart reasons = %q, want one from rest", reasons)
+	}
+}
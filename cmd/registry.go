@@ -2,38 +2,79 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"bicycle/internal/clock"
 	"bicycle/plugin"
 )
 
 var (
 	// globalRegistry is the global command registry
-	globalRegistry = &CommandRegistry{
-		commands: make(map[string]*plugin.Command),
-	}
+	globalRegistry = newCommandRegistry()
 )
 
 // CommandRegistry manages command registration and execution
 type CommandRegistry struct {
 	mu       sync.RWMutex
 	commands map[string]*plugin.Command
+
+	// lastRun tracks the last invocation per (command, caller) for cooldowns,
+	// keyed like rate limits
+	lastRun map[string]time.Time
+	clock   clock.Clock
+
+	// recent tracks each principal's invocations within its rate limit window,
+	// keyed by Principal.RateLimitKey
+	recent map[string][]time.Time
+
+	// cache holds results of cacheable commands by command, role and args
+	cache map[string]cachedResult
+
+	// warned records the (command, source) pairs already told a command is
+	// deprecated
+	warned map[string]bool
+}
+
+// cachedResult is a cacheable command's result and when it stops being reused
+type cachedResult struct {
+	result  *plugin.CommandResult
+	expires time.Time
+}
+
+// newCommandRegistry creates an empty command registry
+func newCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]*plugin.Command),
+		lastRun:  make(map[string]time.Time),
+		recent:   make(map[string][]time.Time),
+		cache:    make(map[string]cachedResult),
+		warned:   make(map[string]bool),
+		clock:    clock.Real(),
+	}
 }
 
 // Register adds a command to the global registry
 // This is typically called from plugin init() functions
 func Register(cmd *plugin.Command) {
-	globalRegistry.mu.Lock()
-	defer globalRegistry.mu.Unlock()
+	globalRegistry.register(cmd)
+}
+
+// register adds a command to the registry, panicking on a duplicate name
+func (cr *CommandRegistry) register(cmd *plugin.Command) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
 
-	if _, exists := globalRegistry.commands[cmd.Name]; exists {
+	if _, exists := cr.commands[cmd.Name]; exists {
 		panic(fmt.Sprintf("command %s already registered", cmd.Name))
 	}
 
-	globalRegistry.commands[cmd.Name] = cmd
+	cr.commands[cmd.Name] = cmd
 	log.Printf("[CommandRegistry] Registered command: /%s", cmd.Name)
 }
 
@@ -69,20 +110,21 @@ func (cr *CommandRegistry) All() []*plugin.Command {
 	return commands
 }
 
-// ListCommands returns available commands for the given mode
-func (cr *CommandRegistry) ListCommands(mode plugin.Mode) []*plugin.Command {
+// ListCommands returns the commands a principal with the given role can run
+// in the given mode
+func (cr *CommandRegistry) ListCommands(mode plugin.Mode, role plugin.Role) []*plugin.Command {
 	cr.mu.RLock()
 	defer cr.mu.RUnlock()
 
 	var available []*plugin.Command
 	for _, cmd := range cr.commands {
-		// Skip hidden commands
-		if cmd.Hidden {
+		// Skip hidden and deprecated commands
+		if cmd.Hidden || cmd.Deprecated != "" {
 			continue
 		}
 
-		// Check mode compatibility
-		if len(cmd.Modes) == 0 || containsMode(cmd.Modes, mode) {
+		// Check mode compatibility and permission
+		if (len(cmd.Modes) == 0 || containsMode(cmd.Modes, mode)) && cmd.AllowsRole(role) {
 			available = append(available, cmd)
 		}
 	}
@@ -96,24 +138,203 @@ func (cr *CommandRegistry) ListCommands(mode plugin.Mode) []*plugin.Command {
 }
 
 // Execute dispatches a command to its handler
+// An alias runs the command it stands for, and a deprecated command's first
+// successful result for each source starts with a deprecation warning
 func (cr *CommandRegistry) Execute(ctx context.Context, name string, args []string) (*plugin.CommandResult, error) {
 	cr.mu.RLock()
-	cmd, exists := cr.commands[name]
+	invoked, exists := cr.commands[name]
+	cmd := invoked
+	if exists && invoked.AliasFor != "" {
+		cmd = cr.commands[invoked.AliasFor]
+	}
 	cr.mu.RUnlock()
 
 	if !exists {
-		return nil, fmt.Errorf("unknown command: %s", name)
+		return nil, cr.unknownCommandError(name, plugin.RoleFromContext(ctx))
+	}
+	// Aliases resolve one level only, so a misconfigured pair can't loop
+	if cmd == nil || cmd.AliasFor != "" {
+		return nil, fmt.Errorf("command /%s points to /%s, which is not available", name, invoked.AliasFor)
 	}
 
+	result, err := cr.run(ctx, cmd, args)
+	if err == nil && result != nil && invoked.Deprecated != "" {
+		result = cr.warnDeprecated(ctx, invoked, result)
+	}
+	return result, err
+}
+
+// run checks that the caller may run cmd now and runs it
+func (cr *CommandRegistry) run(ctx context.Context, cmd *plugin.Command, args []string) (*plugin.CommandResult, error) {
+	name := cmd.Name
+
 	// Check mode compatibility
 	mode, ok := ctx.Value("mode").(plugin.Mode)
 	if ok && len(cmd.Modes) > 0 && !containsMode(cmd.Modes, mode) {
 		return nil, fmt.Errorf("command /%s not available in %s mode", name, mode)
 	}
 
+	// Check permission
+	if role := plugin.RoleFromContext(ctx); !cmd.AllowsRole(role) {
+		return nil, fmt.Errorf("command /%s is not available to role %s", name, role)
+	}
+
+	dryRun := plugin.IsDryRun(ctx)
+	if dryRun && !cmd.SupportsDryRun {
+		return nil, fmt.Errorf("command /%s does not support --dry-run", name)
+	}
+
+	// Enforce the caller's rate limit across all commands and transports
+	if !dryRun {
+		if err := cr.checkRateLimit(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Enforce per-caller cooldown (previews don't count as runs)
+	if cmd.Cooldown > 0 && !dryRun {
+		if remaining := cr.checkCooldown(cmd, cooldownKey(ctx)); remaining > 0 {
+			return nil, fmt.Errorf("please wait %s before running /%s again", remaining.Round(time.Second), name)
+		}
+	}
+
+	caching := cmd.Cacheable && cmd.CacheTTL > 0 && !dryRun
+	var key string
+	if caching {
+		key = cacheKey(ctx, cmd, args)
+		if result, ok := cr.cached(key); ok {
+			plugin.Logf(ctx, "[CommandRegistry] Serving cached result for /%s", name)
+			return result, nil
+		}
+	}
+
 	// Execute the command
-	log.Printf("[CommandRegistry] Executing command: /%s with %d arg(s)", name, len(args))
-	return cmd.Handler(ctx, args)
+	plugin.Logf(ctx, "[CommandRegistry] Executing command: /%s with %d arg(s)", name, len(args))
+	result, err := runHandler(ctx, cmd, args)
+	if result != nil && dryRun {
+		result.DryRun = true
+	}
+	if caching && err == nil && result != nil {
+		cr.storeCached(key, result, cmd.CacheTTL)
+	}
+	return result, err
+}
+
+// cacheKey identifies a call of a cacheable command; the role is part of it
+// because a command may answer differently per role
+func cacheKey(ctx context.Context, cmd *plugin.Command, args []string) string {
+	return cmd.Name + "\x00" + string(plugin.RoleFromContext(ctx)) + "\x00" + strings.Join(args, "\x00")
+}
+
+// cached returns a copy of the unexpired result stored under key
+func (cr *CommandRegistry) cached(key string) (*plugin.CommandResult, bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	entry, ok := cr.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !cr.clock.Now().Before(entry.expires) {
+		delete(cr.cache, key)
+		return nil, false
+	}
+	result := *entry.result
+	return &result, true
+}
+
+// storeCached keeps a copy of result under key for ttl
+func (cr *CommandRegistry) storeCached(key string, result *plugin.CommandResult, ttl time.Duration) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	now := cr.clock.Now()
+
+	// Drop expired entries so the map doesn't grow without bound
+	for k, entry := range cr.cache {
+		if !now.Before(entry.expires) {
+			delete(cr.cache, k)
+		}
+	}
+
+	stored := *result
+	cr.cache[key] = cachedResult{result: &stored, expires: now.Add(ttl)}
+}
+
+// runHandler runs a command handler, returning early if the context is done
+// A handler that ignores its context keeps running in the background, but the
+// caller is no longer blocked on it
+func runHandler(ctx context.Context, cmd *plugin.Command, args []string) (*plugin.CommandResult, error) {
+	type outcome struct {
+		result *plugin.CommandResult
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		// A panicking handler fails its command rather than the daemon
+		defer func() {
+			if r := recover(); r != nil {
+				plugin.Logf(ctx, "[CommandRegistry] Command /%s panicked: %v", cmd.Name, r)
+				done <- outcome{nil, fmt.Errorf("command /%s failed: internal error", cmd.Name)}
+			}
+		}()
+
+		result, err := cmd.Handler(ctx, args)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("command /%s timed out", cmd.Name)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// cooldownKey returns who a cooldown applies to: the principal's
+// RateLimitKey, so a client can't dodge it by opening another connection, or
+// the context's source when there is no principal
+func cooldownKey(ctx context.Context) string {
+	if principal, ok := plugin.PrincipalFromContext(ctx); ok && principal.RateLimitKey() != "" {
+		return principal.RateLimitKey()
+	}
+	source, _ := ctx.Value("source").(string)
+	return source
+}
+
+// checkCooldown records an invocation of cmd by caller and returns the time
+// remaining if the caller is still within the command's cooldown
+func (cr *CommandRegistry) checkCooldown(cmd *plugin.Command, caller string) time.Duration {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	now := cr.clock.Now()
+
+	// Drop expired entries so the map doesn't grow without bound
+	for key, expires := range cr.lastRun {
+		if !now.Before(expires) {
+			delete(cr.lastRun, key)
+		}
+	}
+
+	key := cmd.Name + "|" + caller
+	if expires, ok := cr.lastRun[key]; ok {
+		return expires.Sub(now)
+	}
+
+	cr.lastRun[key] = now.Add(cmd.Cooldown)
+	return 0
+}
+
+// SetClock sets the clock used for cooldown and rate limit tracking
+func (cr *CommandRegistry) SetClock(c clock.Clock) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.clock = c
 }
 
 // Count returns the number of registered commands
@@ -129,6 +350,10 @@ func (cr *CommandRegistry) Clear() {
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
 	cr.commands = make(map[string]*plugin.Command)
+	cr.lastRun = make(map[string]time.Time)
+	cr.recent = make(map[string][]time.Time)
+	cr.cache = make(map[string]cachedResult)
+	cr.warned = make(map[string]bool)
 }
 
 // Helper function to check if a mode is in a slice
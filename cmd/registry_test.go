@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// newCooldownRegistry returns a registry on a fake clock holding /slow, which
+// has a one minute cooldown
+func newCooldownRegistry() (*CommandRegistry, *clock.Fake) {
+	cr := newCommandRegistry()
+	fake := clock.NewFake(time.Unix(0, 0))
+	cr.SetClock(fake)
+	cr.register(&plugin.Command{
+		Name:     "slow",
+		Cooldown: time.Minute,
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: "ok"}, nil
+		},
+	})
+	return cr, fake
+}
+
+func TestCooldownFollowsRateLimitKey(t *testing.T) {
+	cr, fake := newCooldownRegistry()
+
+	// Two connections from the same host share a limit key
+	first := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "websocket:conn-1", LimitKey: "websocket:10.0.0.1"})
+	second := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "websocket:conn-2", LimitKey: "websocket:10.0.0.1"})
+	other := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "websocket:conn-3", LimitKey: "websocket:10.0.0.2"})
+
+	if _, err := cr.Execute(first, "slow", nil); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if _, err := cr.Execute(second, "slow", nil); err == nil {
+		t.Error("run from a second connection of the same client succeeded, want the cooldown")
+	}
+	if _, err := cr.Execute(other, "slow", nil); err != nil {
+		t.Errorf("run from another client: %v", err)
+	}
+
+	fake.Advance(time.Minute)
+	if _, err := cr.Execute(second, "slow", nil); err != nil {
+		t.Errorf("run after the cooldown: %v", err)
+	}
+}
+
+func TestCooldownFallsBackToSource(t *testing.T) {
+	cr, _ := newCooldownRegistry()
+
+	local := context.WithValue(context.Background(), "source", "tui")
+	chat := context.WithValue(context.Background(), "source", "telegram:2")
+
+	if _, err := cr.Execute(local, "slow", nil); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if _, err := cr.Execute(local, "slow", nil); err == nil {
+		t.Error("repeat from the same source succeeded, want the cooldown")
+	}
+	if _, err := cr.Execute(chat, "slow", nil); err != nil {
+		t.Errorf("run from another source: %v", err)
+	}
+}
+
+func TestRateLimitPerPrincipal(t *testing.T) {
+	cr := newCommandRegistry()
+	fake := clock.NewFake(time.Unix(0, 0))
+	cr.SetClock(fake)
+	cr.register(&plugin.Command{
+		Name: "fast",
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: "ok"}, nil
+		},
+		SupportsDryRun: true,
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.CommandRateLimits = map[plugin.Role]config.RateLimit{plugin.RoleUser: {Commands: 2, Per: 60}}
+	as := func(source string, role plugin.Role) context.Context {
+		return plugin.WithPrincipal(testutil.NewContext(testutil.WithConfig(cfg)), plugin.Principal{Source: source, Role: role})
+	}
+	abusive, polite, admin := as("telegram:1", plugin.RoleUser), as("websocket:2", plugin.RoleUser), as("tui", plugin.RoleAdmin)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cr.Execute(abusive, "fast", nil); err != nil {
+			t.Fatalf("run %d within the limit: %v", i+1, err)
+		}
+	}
+	if _, err := cr.Execute(abusive, "fast", nil); err == nil || !strings.Contains(err.Error(), "rate limit exceeded") {
+		t.Errorf("third run = %v, want the rate limit", err)
+	}
+
+	// Previews don't count, another user has their own limit and admins have none
+	if _, err := cr.Execute(plugin.WithDryRun(abusive), "fast", nil); err != nil {
+		t.Errorf("dry run over the limit: %v", err)
+	}
+	if _, err := cr.Execute(polite, "fast", nil); err != nil {
+		t.Errorf("run by another user: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := cr.Execute(admin, "fast", nil); err != nil {
+			t.Fatalf("admin run %d: %v", i+1, err)
+		}
+	}
+
+	fake.Advance(time.Minute)
+	if _, err := cr.Execute(abusive, "fast", nil); err != nil {
+		t.Errorf("run after the window: %v", err)
+	}
+}
+
+func TestCacheableCommandRunsOncePerTTL(t *testing.T) {
+	cr := newCommandRegistry()
+	fake := clock.NewFake(time.Unix(0, 0))
+	cr.SetClock(fake)
+
+	runs := make(map[string]int)
+	handler := func(name string) plugin.CommandHandler {
+		return func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			runs[name]++
+			if len(args) > 0 && args[0] == "fail" {
+				return nil, errors.New("failed")
+			}
+			return &plugin.CommandResult{Output: fmt.Sprintf("%s run %d", name, runs[name])}, nil
+		}
+	}
+	cr.register(&plugin.Command{Name: "caps", Handler: handler("caps"), Cacheable: true, CacheTTL: time.Minute})
+	cr.register(&plugin.Command{Name: "fresh", Handler: handler("fresh")})
+
+	ctx := context.Background()
+	run := func(name string, args ...string) string {
+		t.Helper()
+		result, err := cr.Execute(ctx, name, args)
+		if err != nil {
+			return "error"
+		}
+		return result.Output
+	}
+
+	if got := run("caps"); got != "caps run 1" {
+		t.Fatalf("first /caps = %q", got)
+	}
+	fake.Advance(59 * time.Second)
+	if got := run("caps"); got != "caps run 1" {
+		t.Errorf("/caps within the TTL = %q, want the cached result", got)
+	}
+	if got := run("caps", "other"); got != "caps run 2" {
+		t.Errorf("/caps with other args = %q, want a fresh run", got)
+	}
+
+	// Failures aren't cached
+	run("caps", "fail")
+	run("caps", "fail")
+	if runs["caps"] != 4 {
+		t.Errorf("/caps ran %d times, want failures to rerun", runs["caps"])
+	}
+
+	fake.Advance(time.Second)
+	if got := run("caps"); got != "caps run 5" {
+		t.Errorf("/caps after the TTL = %q, want a fresh run", got)
+	}
+
+	// Commands not marked cacheable always run
+	run("fresh")
+	run("fresh")
+	if runs["fresh"] != 2 {
+		t.Errorf("/fresh ran %d times, want 2", runs["fresh"])
+	}
+}
+
+func TestDeprecatedCommandWarnsOncePerSource(t *testing.T) {
+	cr := newCommandRegistry()
+	cr.register(&plugin.Command{
+		Name: "jobs",
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: "2 jobs"}, nil
+		},
+	})
+	cr.register(&plugin.Command{Name: "tasks", AliasFor: "jobs", Deprecated: "use /jobs instead"})
+	cr.register(&plugin.Command{
+		Name:       "legacy",
+		Deprecated: "it is going away",
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: "still here"}, nil
+		},
+	})
+
+	warning := "Warning: /tasks is deprecated: use /jobs instead\n\n"
+	tests := []struct {
+		source string
+		name   string
+		want   string
+	}{
+		{"tui", "tasks", warning + "2 jobs"},
+		{"tui", "tasks", "2 jobs"},
+		{"telegram:1", "tasks", warning + "2 jobs"},
+		{"tui", "jobs", "2 jobs"},
+		{"tui", "legacy", "Warning: /legacy is deprecated: it is going away\n\nstill here"},
+		{"tui", "legacy", "still here"},
+	}
+	for i, tt := range tests {
+		ctx := context.WithValue(context.Background(), "source", tt.source)
+		result, err := cr.Execute(ctx, tt.name, nil)
+		if err != nil {
+			t.Fatalf("%d: /%s from %s: %v", i+1, tt.name, tt.source, err)
+		}
+		if result.Output != tt.want {
+			t.Errorf("%d: /%s from %s = %q, want %q", i+1, tt.name, tt.source, result.Output, tt.want)
+		}
+	}
+
+	var listed []string
+	for _, cmd := range cr.ListCommands(plugin.ModeInteractive, plugin.RoleAdmin) {
+		listed = append(listed, cmd.Name)
+	}
+	if strings.Join(listed, ",") != "jobs" {
+		t.Errorf("listed commands = %v, want only /jobs", listed)
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// truncated holds the full text of the last truncated message per source
+var truncated = struct {
+	mu   sync.Mutex
+	full map[string]string
+}{full: make(map[string]string)}
+
+// init registers the /last command
+func init() {
+	Register(&plugin.Command{
+		Name:        "last",
+		Description: "Show the full text of the last truncated message",
+		Usage:       "",
+		Handler:     handleLast,
+		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+	})
+}
+
+// Truncate shortens text to max characters, ending it with an ellipsis and a
+// note giving the full length. A max of zero or less disables truncation
+func Truncate(text string, max int) (string, bool) {
+	runes := []rune(text)
+	if max <= 0 || len(runes) <= max {
+		return text, false
+	}
+	return fmt.Sprintf("%s… (truncated, %d chars)", string(runes[:max]), len(runes)), true
+}
+
+// Render truncates text for display to source and, if it was cut, keeps the
+// full text so /last can show it
+func Render(source, text string, max int) string {
+	out, cut := Truncate(text, max)
+	if cut {
+		truncated.mu.Lock()
+		truncated.full[source] = text
+		truncated.mu.Unlock()
+	}
+	return out
+}
+
+// LastTruncated returns the full text of the last message truncated for source
+func LastTruncated(source string) (string, bool) {
+	truncated.mu.Lock()
+	defer truncated.mu.Unlock()
+	text, ok := truncated.full[source]
+	return text, ok
+}
+
+// handleLast shows the full text of the last message truncated for the caller
+func handleLast(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	source, _ := ctx.Value("source").(string)
+
+	text, ok := LastTruncated(source)
+	if !ok {
+		return &plugin.CommandResult{Output: "No truncated messages"}, nil
+	}
+
+	return &plugin.CommandResult{
+		Output:      text,
+		Untruncated: true,
+	}, nil
+}
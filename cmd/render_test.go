@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTruncateAtBoundary(t *testing.T) {
+	tests := []struct {
+		text    string
+		max     int
+		want    string
+		wantCut bool
+	}{
+		{"hello", 5, "hello", false},
+		{"hello!", 5, "hello… (truncated, 6 chars)", true},
+		{"héllo wörld", 5, "héllo… (truncated, 11 chars)", true},
+		{"hello!", 0, "hello!", false},
+	}
+	for _, tt := range tests {
+		got, cut := Truncate(tt.text, tt.max)
+		if got != tt.want || cut != tt.wantCut {
+			t.Errorf("Truncate(%q, %d) = %q, %v, want %q, %v", tt.text, tt.max, got, cut, tt.want, tt.wantCut)
+		}
+	}
+}
+
+func TestLastReturnsFullText(t *testing.T) {
+	full := strings.Repeat("a long answer ", 100)
+	if out := Render("render-test", full, 20); len([]rune(out)) >= len(full) {
+		t.Fatalf("Render did not truncate: %q", out)
+	}
+
+	// A short message afterwards doesn't replace what /last shows
+	Render("render-test", "short", 20)
+
+	ctx := context.WithValue(context.Background(), "source", "render-test")
+	result, err := GetRegistry().Execute(ctx, "last", nil)
+	if err != nil {
+		t.Fatalf("/last: %v", err)
+	}
+	if result.Output != full || !result.Untruncated {
+		t.Errorf("/last = %+v, want the full untruncated text", result)
+	}
+
+	other := context.WithValue(context.Background(), "source", "render-other")
+	if result, _ := GetRegistry().Execute(other, "last", nil); result.Output != "No truncated messages" {
+		t.Errorf("/last from another source = %q, want no truncated messages", result.Output)
+	}
+}
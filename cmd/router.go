@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
+	"unicode"
 
 	"bicycle/plugin"
 )
@@ -11,6 +13,9 @@ import (
 // Router handles command parsing and routing
 type Router struct {
 	registry *CommandRegistry
+
+	// timeout bounds each routed command (0 = no limit)
+	timeout time.Duration
 }
 
 // NewRouter creates a new command router
@@ -20,43 +25,143 @@ func NewRouter() *Router {
 	}
 }
 
+// dryRunFlag is the argument that previews a command instead of running it
+const dryRunFlag = "--dry-run"
+
+const (
+	// maxCommandTokens caps the command name plus arguments parsed from one input
+	maxCommandTokens = 256
+
+	// maxCommandNameLength caps the length of a command name
+	maxCommandNameLength = 64
+)
+
 // Route parses and routes a command string to the appropriate handler
 // Supports formats:
 //   - "/command arg1 arg2" (slash prefix)
 //   - "command arg1 arg2" (no slash)
+//   - "/command --dry-run arg1" (preview, for commands that support it)
+//   - "/command "quoted arg" 'another one'" (quotes group words into one argument)
+//
+// Empty input and a bare "/" are no-ops and return a nil result and nil error
+// Malformed input (null bytes, too many arguments, overlong names) is
+// rejected with an error; transports may pass untrusted input straight in
 func (r *Router) Route(ctx context.Context, input string) (*plugin.CommandResult, error) {
-	// Trim whitespace
-	input = strings.TrimSpace(input)
-	if input == "" {
-		return nil, fmt.Errorf("empty command")
-	}
-
 	// Parse command and arguments
-	cmdName, args := r.parseCommand(input)
+	cmdName, args, err := r.parseCommand(strings.TrimSpace(input))
+	if err != nil {
+		return nil, err
+	}
 	if cmdName == "" {
-		return nil, fmt.Errorf("invalid command format")
+		return nil, nil
+	}
+
+	// Pull out the dry-run flag wherever it appears
+	filtered := args[:0]
+	for _, arg := range args {
+		if arg == dryRunFlag {
+			ctx = plugin.WithDryRun(ctx)
+			continue
+		}
+		filtered = append(filtered, arg)
+	}
+	args = filtered
+
+	// Bound the command so a slow handler can't block the caller indefinitely
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
 	}
 
 	// Execute command
 	return r.registry.Execute(ctx, cmdName, args)
 }
 
+// SetTimeout sets the per-command timeout applied by Route (0 = no limit)
+func (r *Router) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
 // parseCommand splits a command string into name and arguments
 // Handles both "/command" and "command" formats
-func (r *Router) parseCommand(input string) (string, []string) {
+func (r *Router) parseCommand(input string) (string, []string, error) {
+	if strings.ContainsRune(input, 0) {
+		return "", nil, fmt.Errorf("invalid command: contains a null byte")
+	}
+
 	// Remove leading slash if present
 	input = strings.TrimPrefix(input, "/")
 
 	// Split into tokens
-	tokens := strings.Fields(input)
+	tokens, err := splitArgs(input, maxCommandTokens)
+	if err != nil {
+		return "", nil, err
+	}
 	if len(tokens) == 0 {
-		return "", nil
+		return "", nil, nil
 	}
 
 	cmdName := tokens[0]
 	args := tokens[1:]
+	if len(cmdName) > maxCommandNameLength {
+		return "", nil, fmt.Errorf("invalid command: name longer than %d characters", maxCommandNameLength)
+	}
+
+	return cmdName, args, nil
+}
+
+// splitArgs splits input on whitespace, keeping quoted text together
+// Single or double quotes at the start of a word group words, so apostrophes
+// inside words stay literal; a backslash escapes the next character outside
+// single quotes. An unterminated quote runs to the end of the input.
+// Input with more than limit tokens is rejected
+func splitArgs(input string, limit int) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inToken := false
+	var quote rune
+	escaped := false
+
+	for _, c := range input {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inToken = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case (c == '"' || c == '\'') && !inToken:
+			quote = c
+			inToken = true
+		case unicode.IsSpace(c):
+			if inToken {
+				if len(args) == limit {
+					return nil, fmt.Errorf("invalid command: more than %d arguments", limit-1)
+				}
+				args = append(args, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(c)
+			inToken = true
+		}
+	}
+	if inToken {
+		if len(args) == limit {
+			return nil, fmt.Errorf("invalid command: more than %d arguments", limit-1)
+		}
+		args = append(args, current.String())
+	}
 
-	return cmdName, args
+	return args, nil
 }
 
 // IsCommand checks if a string looks like a command
@@ -65,9 +170,10 @@ func (r *Router) IsCommand(input string) bool {
 	return strings.HasPrefix(input, "/")
 }
 
-// GetHelp returns help text for all available commands
-func (r *Router) GetHelp(mode plugin.Mode) string {
-	commands := r.registry.ListCommands(mode)
+// GetHelp returns help text for the commands a principal with the given role
+// can run in the given mode
+func (r *Router) GetHelp(mode plugin.Mode, role plugin.Role) string {
+	commands := r.registry.ListCommands(mode, role)
 
 	if len(commands) == 0 {
 		return "No commands available."
@@ -93,15 +199,23 @@ func (r *Router) GetHelp(mode plugin.Mode) string {
 }
 
 // GetCommandHelp returns help text for a specific command
-func (r *Router) GetCommandHelp(cmdName string) (string, error) {
+// Commands the role can't run are reported as unknown
+func (r *Router) GetCommandHelp(cmdName string, role plugin.Role) (string, error) {
 	cmd, exists := r.registry.Get(cmdName)
-	if !exists {
-		return "", fmt.Errorf("unknown command: %s", cmdName)
+	if !exists || !cmd.AllowsRole(role) {
+		return "", r.registry.unknownCommandError(cmdName, role)
 	}
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Command: /%s\n\n", cmd.Name))
 
+	if cmd.Deprecated != "" {
+		sb.WriteString(fmt.Sprintf("Deprecated: %s\n\n", cmd.Deprecated))
+	}
+	if cmd.AliasFor != "" {
+		sb.WriteString(fmt.Sprintf("Runs /%s\n\n", cmd.AliasFor))
+	}
+
 	if cmd.Description != "" {
 		sb.WriteString(fmt.Sprintf("%s\n\n", cmd.Description))
 	}
@@ -114,5 +228,9 @@ func (r *Router) GetCommandHelp(cmdName string) (string, error) {
 		sb.WriteString(fmt.Sprintf("\nAvailable in modes: %v", cmd.Modes))
 	}
 
+	if len(cmd.Roles) > 0 {
+		sb.WriteString(fmt.Sprintf("\nRestricted to roles: %v", cmd.Roles))
+	}
+
 	return sb.String(), nil
 }
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// newTestRouter returns a router over a fresh registry holding an /echo
+// command that returns its arguments
+func newTestRouter() *Router {
+	r := &Router{registry: newCommandRegistry(), timeout: time.Second}
+	r.registry.register(&plugin.Command{
+		Name: "echo",
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: strings.Join(args, " ")}, nil
+		},
+		SupportsDryRun: true,
+	})
+	return r
+}
+
+func FuzzRoute(f *testing.F) {
+	seeds := []string{
+		"",
+		"/",
+		"/echo hello world",
+		"echo hello",
+		"/echo --dry-run hi",
+		"/echo \x00",
+		"/echo a\x00b",
+		"\x00",
+		`/echo "unterminated`,
+		`/echo 'unterminated`,
+		`/echo "a" "b`,
+		`/echo it's fine`,
+		`/echo "say \"hi\"" 'single \ quote'`,
+		`/echo trailing\`,
+		`"""`,
+		"/" + strings.Repeat("x", maxCommandNameLength+1),
+		"/echo" + strings.Repeat(" a", maxCommandTokens),
+		"/echo" + strings.Repeat(" a", maxCommandTokens+1),
+		"/echo " + strings.Repeat(`"a" `, 300),
+		"/nosuchcommand arg",
+		"/echo \t\n\r tabs and spaces",
+		"/echo \xff\xfe invalid utf-8",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	r := newTestRouter()
+	f.Fuzz(func(t *testing.T, input string) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.Route(context.Background(), input)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Route(%q) did not return", input)
+		}
+
+		name, args, err := r.parseCommand(strings.TrimSpace(input))
+		if err != nil {
+			return
+		}
+		if strings.ContainsRune(input, 0) {
+			t.Errorf("parseCommand(%q) accepted a null byte", input)
+		}
+		if len(args)+1 > maxCommandTokens {
+			t.Errorf("parseCommand(%q) returned %d tokens, limit is %d", input, len(args)+1, maxCommandTokens)
+		}
+		if len(name) > maxCommandNameLength {
+			t.Errorf("parseCommand(%q) returned a %d character name", input, len(name))
+		}
+	})
+}
+
+func TestRouteRejectsMalformedInput(t *testing.T) {
+	r := newTestRouter()
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"null byte", "/echo a\x00b"},
+		{"too many tokens", "/echo" + strings.Repeat(" a", maxCommandTokens)},
+		{"overlong name", "/" + strings.Repeat("x", maxCommandNameLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := r.Route(context.Background(), tt.input); err == nil {
+				t.Errorf("Route(%q) succeeded, want an error", tt.input)
+			}
+		})
+	}
+}
+
+func TestRouteUnbalancedQuoteRunsToEnd(t *testing.T) {
+	r := newTestRouter()
+
+	result, err := r.Route(context.Background(), `/echo "two words`)
+	if err != nil {
+		t.Fatalf("Route: %v", err)
+	}
+	if result.Output != "two words" {
+		t.Errorf("output = %q, want %q", result.Output, "two words")
+	}
+
+	// The most arguments accepted, with the command name as the first token
+	result, err = r.Route(context.Background(), "/echo"+strings.Repeat(" a", maxCommandTokens-1))
+	if err != nil {
+		t.Fatalf("Route with %d arguments: %v", maxCommandTokens-1, err)
+	}
+	if got := len(strings.Fields(result.Output)); got != maxCommandTokens-1 {
+		t.Errorf("echoed %d arguments, want %d", got, maxCommandTokens-1)
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bicycle/plugin"
+)
+
+func TestRouteIgnoresEmptyInput(t *testing.T) {
+	r := newTestRouter()
+	for _, input := range []string{"", "   ", "/", " / ", "\t/\n"} {
+		result, err := r.Route(context.Background(), input)
+		if err != nil || result != nil {
+			t.Errorf("Route(%q) = %v, %v, want a silent no-op", input, result, err)
+		}
+	}
+}
+
+func TestRouteSuggestsClosestCommand(t *testing.T) {
+	r := newTestRouter()
+	r.registry.register(&plugin.Command{
+		Name: "status",
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: "ok"}, nil
+		},
+	})
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"/statsu", "unknown command: statsu (did you mean /status?)"},
+		{"/ecoh hi", "unknown command: ecoh (did you mean /echo?)"},
+		{"/frobnicate", "unknown command: frobnicate"},
+	}
+	for _, tt := range tests {
+		_, err := r.Route(context.Background(), tt.input)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Route(%q) error = %v, want %q", tt.input, err, tt.want)
+		}
+	}
+}
+
+func TestSuggestSkipsHiddenCommands(t *testing.T) {
+	cr := newCommandRegistry()
+	cr.register(&plugin.Command{Name: "secret", Hidden: true})
+
+	if got := cr.Suggest("secrte", plugin.RoleAdmin); got != "" {
+		t.Errorf("Suggest(secrte) = %q, want no suggestion of a hidden command", got)
+	}
+}
+
+func TestCommandsFilteredByRole(t *testing.T) {
+	r := newTestRouter()
+	r.registry.register(&plugin.Command{
+		Name:        "purge",
+		Description: "Delete everything",
+		Roles:       []plugin.Role{plugin.RoleAdmin},
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{Output: "purged"}, nil
+		},
+	})
+
+	names := func(role plugin.Role) []string {
+		var out []string
+		for _, c := range r.registry.ListCommands(plugin.ModeDaemon, role) {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+	if got := names(plugin.RoleUser); len(got) != 1 || got[0] != "echo" {
+		t.Errorf("user commands = %v, want [echo]", got)
+	}
+	if got := names(plugin.RoleAdmin); len(got) != 2 {
+		t.Errorf("admin commands = %v, want echo and purge", got)
+	}
+
+	if help := r.GetHelp(plugin.ModeDaemon, plugin.RoleUser); strings.Contains(help, "purge") {
+		t.Errorf("user help lists purge:\n%s", help)
+	}
+	if help := r.GetHelp(plugin.ModeDaemon, plugin.RoleAdmin); !strings.Contains(help, "purge") {
+		t.Errorf("admin help omits purge:\n%s", help)
+	}
+	if _, err := r.GetCommandHelp("purge", plugin.RoleUser); err == nil {
+		t.Error("user got help for purge")
+	}
+
+	user := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "test", Role: plugin.RoleUser})
+	if _, err := r.Route(user, "/purge"); err == nil {
+		t.Error("user ran /purge")
+	}
+	admin := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "test", Role: plugin.RoleAdmin})
+	if result, err := r.Route(admin, "/purge"); err != nil || result.Output != "purged" {
+		t.Errorf("admin /purge = %v, %v, want purged", result, err)
+	}
+}
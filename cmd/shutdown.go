@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// shutdownDelay gives a transport time to deliver the /shutdown reply
+// before the daemon starts stopping plugins
+var shutdownDelay = time.Second
+
+// shutdownConfirm is the argument /shutdown needs to go ahead
+const shutdownConfirm = "confirm"
+
+// ShutdownRequester interface for asking the daemon's process to stop it
+type ShutdownRequester interface {
+	RequestShutdown(reason string)
+}
+
+// init registers the /shutdown command
+func init() {
+	Register(&plugin.Command{
+		Name:           "shutdown",
+		Description:    "Gracefully stop the daemon",
+		Usage:          shutdownConfirm,
+		Handler:        handleShutdown,
+		Modes:          []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+		Roles:          []plugin.Role{plugin.RoleAdmin},
+		SupportsDryRun: true,
+	})
+}
+
+// handleShutdown stops the daemon, the same way SIGTERM does, once the
+// reply has had time to go out
+func handleShutdown(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	daemon, ok := ctx.Value("daemon").(ShutdownRequester)
+	if !ok {
+		return nil, fmt.Errorf("shutdown not available (daemon context not available)")
+	}
+
+	if plugin.IsDryRun(ctx) {
+		return &plugin.CommandResult{Output: "Would stop the daemon"}, nil
+	}
+
+	if len(args) != 1 || args[0] != shutdownConfirm {
+		return nil, fmt.Errorf("usage: /shutdown %s (stops the daemon)", shutdownConfirm)
+	}
+
+	reason := "/shutdown"
+	if principal, ok := plugin.PrincipalFromContext(ctx); ok && principal.Source != "" {
+		reason = fmt.Sprintf("/shutdown from %s", principal.Source)
+	}
+	time.AfterFunc(shutdownDelay, func() {
+		daemon.RequestShutdown(reason)
+	})
+
+	return &plugin.CommandResult{
+		Output: "Daemon shutting down",
+	}, nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestShutdownNeedsAdminAndConfirmation(t *testing.T) {
+	defer func(delay time.Duration) { shutdownDelay = delay }(shutdownDelay)
+	shutdownDelay = 0
+
+	d := testutil.NewDaemon()
+	router := NewRouter()
+	user := testutil.NewContext(testutil.WithDaemon(d),
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleUser}))
+	admin := testutil.NewContext(testutil.WithDaemon(d),
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleAdmin}))
+
+	if _, err := router.Route(user, "/shutdown confirm"); err == nil {
+		t.Error("user ran /shutdown")
+	}
+	if _, err := router.Route(admin, "/shutdown"); err == nil {
+		t.Error("/shutdown without confirm succeeded")
+	}
+
+	result, err := router.Route(admin, "/shutdown confirm")
+	if err != nil {
+		t.Fatalf("/shutdown confirm: %v", err)
+	}
+	if result.Output != "Daemon shutting down" {
+		t.Errorf("output = %q, want an acknowledgement", result.Output)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(d.ShutdownReasons()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	reasons := d.ShutdownReasons()
+	if len(reasons) != 1 || reasons[0] != "/shutdown from tui" {
+		t.Errorf("shutdown reasons = %q, want one from tui", reasons)
+	}
+}
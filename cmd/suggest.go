@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"bicycle/plugin"
+)
+
+// maxSuggestionDistance is the largest edit distance still offered as a suggestion
+const maxSuggestionDistance = 2
+
+// Suggest returns the name of the visible command closest to name that role
+// can run, or "" if none is close enough
+func (cr *CommandRegistry) Suggest(name string, role plugin.Role) string {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	best := ""
+	bestDist := maxSuggestionDistance + 1
+	for _, cmd := range cr.commands {
+		if cmd.Hidden || cmd.Deprecated != "" || !cmd.AllowsRole(role) {
+			continue
+		}
+		d := levenshtein(name, cmd.Name)
+		// Break ties alphabetically for stable output
+		if d < bestDist || (d == bestDist && cmd.Name < best) {
+			best, bestDist = cmd.Name, d
+		}
+	}
+
+	if bestDist > maxSuggestionDistance {
+		return ""
+	}
+	return best
+}
+
+// unknownCommandError builds the error for an unknown command, with a suggestion if one is close
+func (cr *CommandRegistry) unknownCommandError(name string, role plugin.Role) error {
+	if suggestion := cr.Suggest(name, role); suggestion != "" {
+		return fmt.Errorf("unknown command: %s (did you mean /%s?)", name, suggestion)
+	}
+	return fmt.Errorf("unknown command: %s", name)
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
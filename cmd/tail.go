@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"bicycle/plugin"
+)
+
+// TaskResultReader interface for reading finished task results
+type TaskResultReader interface {
+	GetTaskResult(id string) (*plugin.TaskResult, bool)
+}
+
+// init registers the /tail command
+func init() {
+	Register(&plugin.Command{
+		Name:        "tail",
+		Description: "Follow a running task's progress until it finishes",
+		Usage:       "<task_id> | stop <task_id>",
+		Handler:     handleTail,
+		Modes:       []plugin.Mode{plugin.ModeInteractive},
+	})
+}
+
+// handleTail streams a running task's progress and notifications to the
+// caller's message sink, returning the task's outcome once it finishes, the
+// command is cancelled or /tail stop ends it
+func handleTail(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	stop := len(args) == 2 && args[0] == "stop"
+	if len(args) != 1 && !stop {
+		return nil, fmt.Errorf("usage: /tail <task_id> | stop <task_id>")
+	}
+	taskID := args[len(args)-1]
+
+	broker, sink, source, err := watchContext(ctx, "tail")
+	if err != nil {
+		return nil, err
+	}
+	subID := fmt.Sprintf("tail:%s:%s", source, taskID)
+
+	// Closing the subscription ends the /tail following it
+	if stop {
+		broker.Unsubscribe(subID)
+		return &plugin.CommandResult{Output: fmt.Sprintf("Stopped tailing task %s", taskID)}, nil
+	}
+
+	// Subscribe before looking the task up, so no event falls in between
+	ch := broker.Subscribe(subID, watchBufferSize,
+		plugin.TopicTaskProgress, "notification", plugin.TopicTaskCompleted, plugin.TopicTaskFailed)
+	defer broker.Unsubscribe(subID)
+
+	if results, ok := ctx.Value("daemon").(TaskResultReader); ok {
+		if result, ok := results.GetTaskResult(taskID); ok {
+			return tailResult(result, "already finished"), nil
+		}
+	}
+	inspector, ok := ctx.Value("daemon").(TaskInspector)
+	if !ok {
+		return nil, fmt.Errorf("tail not available (daemon context not available)")
+	}
+	var task *plugin.Task
+	for _, running := range inspector.GetRunningTasks() {
+		if running.ID == taskID {
+			task = running
+		}
+	}
+	if task == nil {
+		return nil, fmt.Errorf("unknown task %s (not running or recently finished)", taskID)
+	}
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return &plugin.CommandResult{Output: fmt.Sprintf("Stopped tailing task %s", taskID)}, nil
+			}
+			if !tailedBy(msg, task) {
+				continue
+			}
+			switch msg.Topic {
+			case plugin.TopicTaskCompleted, plugin.TopicTaskFailed:
+				if result, ok := msg.Payload.(*plugin.TaskResult); ok {
+					return tailResult(result, "finished"), nil
+				}
+				return &plugin.CommandResult{Output: fmt.Sprintf("Task %s finished", taskID)}, nil
+			default:
+				sink(msg)
+			}
+
+		case <-ctx.Done():
+			return &plugin.CommandResult{Output: fmt.Sprintf("Stopped tailing task %s", taskID)}, nil
+		}
+	}
+}
+
+// tailedBy reports whether a message belongs to the tailed task: it carries
+// the task's id, or, for notifications without one, its correlation id
+func tailedBy(msg plugin.Message, task *plugin.Task) bool {
+	if id, ok := msg.Metadata["task_id"].(string); ok {
+		return id == task.ID
+	}
+	correlationID, _ := msg.Metadata[plugin.MetadataCorrelationID].(string)
+	return correlationID != "" && correlationID == task.CorrelationID
+}
+
+// tailResult describes a finished task's outcome
+func tailResult(result *plugin.TaskResult, finished string) *plugin.CommandResult {
+	output := fmt.Sprintf("Task %s %s in %s: %s", result.ID, finished, result.Duration, plugin.PayloadText(result.Output))
+	if result.Error != "" {
+		output = fmt.Sprintf("Task %s failed in %s: %s", result.ID, result.Duration, result.Error)
+	}
+	return &plugin.CommandResult{Output: output, Data: result}
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// tailDaemon reports fixed running tasks and finished results
+type tailDaemon struct {
+	running  []*plugin.Task
+	finished map[string]*plugin.TaskResult
+}
+
+func (d *tailDaemon) GetCurrentTask() *plugin.Task { return nil }
+
+func (d *tailDaemon) GetRunningTasks() []*plugin.Task { return d.running }
+
+func (d *tailDaemon) GetTaskResult(id string) (*plugin.TaskResult, bool) {
+	result, ok := d.finished[id]
+	return result, ok
+}
+
+// startTail runs /tail with args in the background on a TUI context over
+// broker, surfacing streamed messages on the returned channel, and waits
+// for it to subscribe
+func startTail(t *testing.T, broker *testutil.Broker, d *tailDaemon, args ...string) (<-chan plugin.Message, <-chan *plugin.CommandResult) {
+	t.Helper()
+
+	streamed := make(chan plugin.Message, 10)
+	ctx := WithMessageSink(testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithDaemon(d),
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleAdmin}),
+	), func(msg plugin.Message) { streamed <- msg })
+
+	results := make(chan *plugin.CommandResult, 1)
+	go func() {
+		result, err := handleTail(ctx, args)
+		if err != nil {
+			t.Errorf("/tail: %v", err)
+		}
+		results <- result
+	}()
+
+	subID := "tail:tui:" + args[len(args)-1]
+	deadline := time.Now().Add(5 * time.Second)
+	for !broker.Subscribed(subID) {
+		if time.Now().After(deadline) {
+			t.Fatal("/tail never subscribed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return streamed, results
+}
+
+func TestTailStreamsUntilTaskFinishes(t *testing.T) {
+	broker := testutil.NewBroker()
+	d := &tailDaemon{running: []*plugin.Task{{ID: "t1"}}}
+	streamed, results := startTail(t, broker, d, "t1")
+
+	for _, msg := range []plugin.Message{
+		{Topic: plugin.TopicTaskProgress, Payload: "50%", Metadata: map[string]interface{}{"task_id": "t1"}},
+		{Topic: plugin.TopicTaskProgress, Payload: "other task", Metadata: map[string]interface{}{"task_id": "t2"}},
+		{Topic: "notification", Payload: "almost there", Metadata: map[string]interface{}{"task_id": "t1"}},
+		{Topic: plugin.TopicTaskCompleted, Payload: &plugin.TaskResult{ID: "t1", Output: "answer", Duration: 2 * time.Second}, Metadata: map[string]interface{}{"task_id": "t1"}},
+	} {
+		broker.Publish(context.Background(), msg)
+	}
+
+	select {
+	case result := <-results:
+		if result == nil || result.Output != "Task t1 finished in 2s: answer" {
+			t.Errorf("/tail result = %+v, want the task's outcome", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("/tail still running after the task finished")
+	}
+	// The sink is called from /tail itself, so everything streamed is in by now
+	var got []string
+	for len(streamed) > 0 {
+		got = append(got, plugin.PayloadText((<-streamed).Payload))
+	}
+	if strings.Join(got, "|") != "50%|almost there" {
+		t.Errorf("streamed %q, want the task's progress and notification", got)
+	}
+	if broker.Subscribed("tail:tui:t1") {
+		t.Error("/tail left its subscription behind")
+	}
+}
+
+func TestTailStop(t *testing.T) {
+	broker := testutil.NewBroker()
+	d := &tailDaemon{running: []*plugin.Task{{ID: "t1"}}}
+	_, results := startTail(t, broker, d, "t1")
+
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleAdmin}),
+	)
+	if _, err := handleTail(WithMessageSink(ctx, func(plugin.Message) {}), []string{"stop", "t1"}); err != nil {
+		t.Fatalf("/tail stop: %v", err)
+	}
+	select {
+	case result := <-results:
+		if result == nil || result.Output != "Stopped tailing task t1" {
+			t.Errorf("/tail result = %+v, want it stopped", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("/tail still running after /tail stop")
+	}
+}
+
+func TestTailUnknownOrFinishedTask(t *testing.T) {
+	d := &tailDaemon{finished: map[string]*plugin.TaskResult{"done": {ID: "done", Output: "answer", Duration: time.Second}}}
+	ctx := WithMessageSink(testutil.NewContext(
+		testutil.WithDaemon(d),
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleAdmin}),
+	), func(plugin.Message) {})
+
+	if _, err := handleTail(ctx, []string{"missing"}); err == nil || !strings.Contains(err.Error(), "unknown task missing") {
+		t.Errorf("/tail missing = %v, want an unknown task error", err)
+	}
+	result, err := handleTail(ctx, []string{"done"})
+	if err != nil || result.Output != "Task done already finished in 1s: answer" {
+		t.Errorf("/tail done = %+v, %v, want its outcome", result, err)
+	}
+}
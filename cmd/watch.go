@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// watchBufferSize is the buffer of a /subscribe debug subscription
+const watchBufferSize = 100
+
+// watches holds the topics each source watches with /subscribe
+var watches = struct {
+	mu     sync.Mutex
+	topics map[string][]string
+}{topics: make(map[string][]string)}
+
+// MessageSink receives the messages a transport watches with /subscribe or /tail
+type MessageSink func(msg plugin.Message)
+
+// init registers the /subscribe and /unsubscribe commands
+func init() {
+	Register(&plugin.Command{
+		Name:        "subscribe",
+		Description: "Watch broker topics and print their messages (debugging)",
+		Usage:       "<topic...>",
+		Handler:     handleSubscribe,
+		Modes:       []plugin.Mode{plugin.ModeInteractive},
+		Roles:       []plugin.Role{plugin.RoleAdmin},
+	})
+
+	Register(&plugin.Command{
+		Name:        "unsubscribe",
+		Description: "Stop watching broker topics (all if none given)",
+		Usage:       "[topic...]",
+		Handler:     handleUnsubscribe,
+		Modes:       []plugin.Mode{plugin.ModeInteractive},
+		Roles:       []plugin.Role{plugin.RoleAdmin},
+	})
+}
+
+// WithMessageSink marks a context so /subscribe delivers watched messages to sink
+func WithMessageSink(ctx context.Context, sink MessageSink) context.Context {
+	return context.WithValue(ctx, "message_sink", sink)
+}
+
+// WatchSubscriptionID returns the broker subscription id /subscribe uses for source
+func WatchSubscriptionID(source string) string {
+	return "debug:" + source
+}
+
+// WatchedTopics returns the topics source watches with /subscribe
+func WatchedTopics(source string) []string {
+	watches.mu.Lock()
+	defer watches.mu.Unlock()
+	return append([]string(nil), watches.topics[source]...)
+}
+
+// handleSubscribe adds topics to the caller's debug subscription
+func handleSubscribe(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("usage: /subscribe <topic...>")
+	}
+
+	broker, sink, source, err := watchContext(ctx, "subscribe")
+	if err != nil {
+		return nil, err
+	}
+
+	watches.mu.Lock()
+	defer watches.mu.Unlock()
+
+	topics := watches.topics[source]
+	for _, topic := range args {
+		if !containsString(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	watches.topics[source] = topics
+
+	// Resubscribing replaces the previous subscription and closes its channel,
+	// which ends its forwarding goroutine
+	ch := broker.Subscribe(WatchSubscriptionID(source), watchBufferSize, topics...)
+	go forwardWatched(ch, sink)
+
+	return &plugin.CommandResult{
+		Output: fmt.Sprintf("Watching: %s", strings.Join(topics, ", ")),
+		Data:   topics,
+	}, nil
+}
+
+// handleUnsubscribe removes topics from the caller's debug subscription
+func handleUnsubscribe(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+	broker, sink, source, err := watchContext(ctx, "subscribe")
+	if err != nil {
+		return nil, err
+	}
+
+	watches.mu.Lock()
+	defer watches.mu.Unlock()
+
+	current := watches.topics[source]
+	if len(current) == 0 {
+		return &plugin.CommandResult{Output: "Not watching any topics"}, nil
+	}
+
+	var topics []string
+	if len(args) > 0 {
+		for _, topic := range current {
+			if !containsString(args, topic) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+
+	if len(topics) == 0 {
+		delete(watches.topics, source)
+		broker.Unsubscribe(WatchSubscriptionID(source))
+		return &plugin.CommandResult{Output: "Stopped watching all topics"}, nil
+	}
+
+	watches.topics[source] = topics
+	ch := broker.Subscribe(WatchSubscriptionID(source), watchBufferSize, topics...)
+	go forwardWatched(ch, sink)
+
+	return &plugin.CommandResult{
+		Output: fmt.Sprintf("Watching: %s", strings.Join(topics, ", ")),
+		Data:   topics,
+	}, nil
+}
+
+// watchContext returns the broker, sink and caller source that /subscribe
+// and /tail (named by command, for errors) need
+func watchContext(ctx context.Context, command string) (plugin.MessageBroker, MessageSink, string, error) {
+	broker, ok := ctx.Value("broker").(plugin.MessageBroker)
+	if !ok {
+		return nil, nil, "", fmt.Errorf("%s not available (broker context not available)", command)
+	}
+	sink, ok := ctx.Value("message_sink").(MessageSink)
+	if !ok {
+		return nil, nil, "", fmt.Errorf("%s not available from this transport", command)
+	}
+	source := "unknown"
+	if principal, ok := plugin.PrincipalFromContext(ctx); ok && principal.Source != "" {
+		source = principal.Source
+	}
+	return broker, sink, source, nil
+}
+
+// forwardWatched passes messages to sink until the subscription is closed
+func forwardWatched(ch <-chan plugin.Message, sink MessageSink) {
+	for msg := range ch {
+		sink(msg)
+	}
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
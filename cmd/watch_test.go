@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestSubscribeSurfacesWatchedTopics(t *testing.T) {
+	broker := testutil.NewBroker()
+	watched := make(chan plugin.Message, 10)
+	ctx := WithMessageSink(testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPrincipal(plugin.Principal{Source: "tui", Role: plugin.RoleAdmin}),
+	), func(msg plugin.Message) { watched <- msg })
+
+	result, err := handleSubscribe(ctx, []string{"chat", "task.progress"})
+	if err != nil {
+		t.Fatalf("/subscribe: %v", err)
+	}
+	if result.Output != "Watching: chat, task.progress" {
+		t.Errorf("output = %q, want both topics", result.Output)
+	}
+	if !broker.Subscribed(WatchSubscriptionID("tui")) {
+		t.Fatal("no broker subscription after /subscribe")
+	}
+
+	broker.Publish(context.Background(), plugin.Message{Topic: "notification", Payload: "unwatched"})
+	broker.Publish(context.Background(), plugin.Message{Topic: "chat", Payload: "hello"})
+	select {
+	case msg := <-watched:
+		if msg.Topic != "chat" || msg.Payload != "hello" {
+			t.Errorf("surfaced %s %v, want the chat message", msg.Topic, msg.Payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("chat message was not surfaced")
+	}
+
+	if result, err = handleUnsubscribe(ctx, []string{"chat"}); err != nil {
+		t.Fatalf("/unsubscribe chat: %v", err)
+	}
+	if got := WatchedTopics("tui"); len(got) != 1 || got[0] != "task.progress" {
+		t.Errorf("watching %v after /unsubscribe chat, want [task.progress]", got)
+	}
+
+	if result, err = handleUnsubscribe(ctx, nil); err != nil {
+		t.Fatalf("/unsubscribe: %v", err)
+	}
+	if result.Output != "Stopped watching all topics" || broker.Subscribed(WatchSubscriptionID("tui")) {
+		t.Errorf("after /unsubscribe got %q and subscribed %v, want the subscription removed", result.Output, broker.Subscribed(WatchSubscriptionID("tui")))
+	}
+}
+
+func TestSubscribeNeedsMessageSink(t *testing.T) {
+	if _, err := handleSubscribe(testutil.NewContext(), []string{"chat"}); err == nil {
+		t.Error("/subscribe without a message sink succeeded")
+	}
+}
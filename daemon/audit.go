@@ -0,0 +1,189 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+const (
+	// auditBufferSize is how many records may wait for the writer before new
+	// ones are dropped
+	auditBufferSize = 1024
+
+	// auditPayloadChars is how much of a payload's text an audit record keeps
+	auditPayloadChars = 200
+)
+
+// auditRecord is one line of the message audit log
+type auditRecord struct {
+	Time          time.Time `json:"time"`
+	Topic         string    `json:"topic,omitempty"`
+	Source        string    `json:"source,omitempty"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Payload       string    `json:"payload,omitempty"`
+	PayloadBytes  int       `json:"payload_bytes,omitempty"`
+
+	// Dropped is set on a record noting messages lost because the writer fell behind
+	Dropped int64 `json:"dropped,omitempty"`
+}
+
+// messageAudit appends a record of every published message to a JSONL file,
+// rotating it by size. Publishers only queue records, so a slow disk never
+// delays delivery; records that don't fit in the queue are counted and the
+// count is written in their place
+type messageAudit struct {
+	cfg     config.AuditConfig
+	file    *os.File
+	size    int64
+	records chan auditRecord
+	dropped atomic.Int64
+	done    chan struct{}
+}
+
+// newMessageAudit opens the audit file and starts its writer
+func newMessageAudit(cfg config.AuditConfig) (*messageAudit, error) {
+	a := &messageAudit{
+		cfg:     cfg,
+		records: make(chan auditRecord, auditBufferSize),
+		done:    make(chan struct{}),
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	go a.run()
+	return a, nil
+}
+
+// record queues a message for the audit log without blocking
+// It is used as the broker's PublishTap
+func (a *messageAudit) record(msg plugin.Message, at time.Time) {
+	text := plugin.PayloadText(msg.Payload)
+	rec := auditRecord{
+		Time:         at,
+		Topic:        msg.Topic,
+		Source:       msg.Source,
+		Payload:      summarize(text, auditPayloadChars),
+		PayloadBytes: len(text),
+	}
+	if data, ok := msg.Payload.([]byte); ok {
+		rec.PayloadBytes = len(data)
+	}
+	if id, ok := msg.Metadata[plugin.MetadataCorrelationID].(string); ok {
+		rec.CorrelationID = id
+	}
+
+	select {
+	case a.records <- rec:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// Close writes the queued records and closes the file
+// The broker must no longer call record
+func (a *messageAudit) Close() error {
+	close(a.records)
+	<-a.done
+	return a.file.Close()
+}
+
+// run writes queued records until Close
+func (a *messageAudit) run() {
+	defer close(a.done)
+	for rec := range a.records {
+		if n := a.dropped.Swap(0); n > 0 {
+			a.write(auditRecord{Time: rec.Time, Dropped: n})
+		}
+		a.write(rec)
+	}
+	if n := a.dropped.Swap(0); n > 0 {
+		a.write(auditRecord{Time: time.Now(), Dropped: n})
+	}
+}
+
+// write appends a record, rotating the file first if it would grow too large
+func (a *messageAudit) write(rec auditRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("[Audit] Error encoding record (topic: %s): %v", rec.Topic, err)
+		return
+	}
+	line = append(line, '\n')
+
+	if a.cfg.MaxBytes > 0 && a.size > 0 && a.size+int64(len(line)) > a.cfg.MaxBytes {
+		if err := a.rotate(); err != nil {
+			log.Printf("[Audit] Error rotating %s: %v", a.cfg.Path, err)
+		}
+	}
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		log.Printf("[Audit] Error writing %s: %v", a.cfg.Path, err)
+	}
+}
+
+// open opens the audit file for appending
+func (a *messageAudit) open() error {
+	f, err := os.OpenFile(a.cfg.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open message audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open message audit file: %w", err)
+	}
+	a.file = f
+	a.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and starts a new
+// file, discarding the oldest beyond MaxBackups
+func (a *messageAudit) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+
+	// Keep writing to some file even if shifting the backups failed
+	err := a.shiftBackups()
+	if openErr := a.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// shiftBackups moves the closed audit file and its backups up one place
+func (a *messageAudit) shiftBackups() error {
+	path := a.cfg.Path
+	if a.cfg.MaxBackups == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	for i := a.cfg.MaxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
+// summarize shortens text to at most max characters
+func summarize(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max]) + "…"
+}
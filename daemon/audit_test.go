@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// readAudit returns the records in an audit file
+func readAudit(t *testing.T, path string) []auditRecord {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening audit file: %v", err)
+	}
+	defer f.Close()
+
+	var records []auditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("decoding audit line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestPublishedMessagesAudited(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.MessageAudit.Path = filepath.Join(t.TempDir(), "audit.jsonl")
+	d := startDaemon(t, cfg)
+
+	messages := []plugin.Message{
+		{Topic: "chat", Source: "tui", Payload: "hello"},
+		{Topic: "notification", Source: "rest", Payload: []byte{1, 2, 3}, Metadata: map[string]interface{}{plugin.MetadataCorrelationID: "c1"}},
+	}
+	for _, msg := range messages {
+		if err := d.broker.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	// Stopping flushes the queued records
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	var records []auditRecord
+	for _, rec := range readAudit(t, cfg.Daemon.MessageAudit.Path) {
+		if rec.Topic == "chat" || rec.Topic == "notification" {
+			records = append(records, rec)
+		}
+	}
+	if len(records) != 2 {
+		t.Fatalf("audited %+v, want the chat and notification messages", records)
+	}
+	if rec := records[0]; rec.Source != "tui" || rec.Payload != "hello" || rec.PayloadBytes != 5 || rec.Time.IsZero() {
+		t.Errorf("chat record = %+v, want tui's hello with its time", rec)
+	}
+	if rec := records[1]; rec.Source != "rest" || rec.CorrelationID != "c1" || rec.PayloadBytes != 3 {
+		t.Errorf("notification record = %+v, want rest's 3 bytes with correlation id c1", rec)
+	}
+}
+
+func TestAuditSummarizesLongPayloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := newMessageAudit(config.AuditConfig{Path: path})
+	if err != nil {
+		t.Fatalf("newMessageAudit: %v", err)
+	}
+
+	a.record(plugin.Message{Topic: "chat", Payload: strings.Repeat("x", 500)}, time.Now())
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	records := readAudit(t, path)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	if rec := records[0]; rec.Payload != strings.Repeat("x", auditPayloadChars)+"…" || rec.PayloadBytes != 500 {
+		t.Errorf("record payload = %d chars (%d bytes), want the first %d of 500", len([]rune(rec.Payload)), rec.PayloadBytes, auditPayloadChars)
+	}
+}
+
+func TestAuditRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := newMessageAudit(config.AuditConfig{Path: path, MaxBytes: 300, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("newMessageAudit: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		a.record(plugin.Message{Topic: "chat", Source: "test", Payload: "a message of some length"}, time.Now())
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	total := 0
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s is %d bytes, want at most 300", name, info.Size())
+		}
+		total += len(readAudit(t, name))
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("found %s.3 beyond the 2 backups kept", path)
+	}
+	if total >= 20 {
+		t.Errorf("kept %d records, want the oldest rotated away", total)
+	}
+}
@@ -2,11 +2,15 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"bicycle/internal/clock"
 	"bicycle/plugin"
 
 	"golang.org/x/sync/errgroup"
@@ -18,28 +22,165 @@ type Subscription struct {
 	ch      chan plugin.Message
 	topics  []string
 	bufSize int
+
+	// filter skips messages it returns false for (nil = none skipped)
+	filter func(plugin.Message) bool
+
+	// coalesce drops the oldest buffered message when the buffer is full
+	coalesce bool
+
+	// done is closed when the subscription is removed
+	done chan struct{}
+
+	// highWater is the peak channel fill observed after a send
+	highWater atomic.Int64
+}
+
+// congestedFill is the buffer fill (as a fraction of its size) at which a
+// subscription counts as congested
+const congestedFill = 0.8
+
+// congested reports whether the channel is at least congestedFill full
+// Unbuffered channels never count as congested
+func (s *Subscription) congested() bool {
+	return s.bufSize > 0 && float64(len(s.ch)) >= congestedFill*float64(s.bufSize)
+}
+
+// recordFill updates the high-water mark with the channel's current fill
+func (s *Subscription) recordFill() {
+	fill := int64(len(s.ch))
+	for {
+		peak := s.highWater.Load()
+		if fill <= peak || s.highWater.CompareAndSwap(peak, fill) {
+			return
+		}
+	}
 }
 
 // Broker implements a topic-based pub/sub message broker
 type Broker struct {
-	mu            sync.RWMutex
-	subscriptions map[string]*Subscription
-	closed        bool
+	mu             sync.RWMutex
+	subscriptions  map[string]*Subscription
+	closed         bool
 	publishTimeout time.Duration
+	clock          clock.Clock
+
+	// fanoutLimit caps concurrent deliveries per publish (0 = unbounded)
+	fanoutLimit int
+
+	// maxAge drops messages without their own MaxAge that waited longer
+	// than this for delivery (0 = no limit)
+	maxAge time.Duration
+
+	// maxPayloadBytes rejects publishes with larger payloads (0 = no limit)
+	maxPayloadBytes int
+
+	// retained keeps recent messages per topic for replay to new subscribers
+	retained *retainStore
+
+	// async makes Publish hand messages to the dispatcher instead of blocking
+	async bool
+
+	// asyncQueue feeds the dispatcher goroutine, started on first use
+	asyncQueue chan asyncPublish
+	asyncOnce  sync.Once
+
+	// authorize decides which sources may publish to which topics (nil = all)
+	authorize PublishAuthorizer
+
+	// tap sees every accepted message before delivery (nil = none)
+	// It must not block
+	tap PublishTap
+
+	// transforms rewrite messages before they are retained, tapped and
+	// delivered, in registration order
+	transforms []topicTransform
+
+	// noSubscribers decides what a publish reaching no one does
+	noSubscribers NoSubscriberPolicy
+
+	// requiredTopics must have a subscriber; publishing to one without
+	// fails whatever noSubscribers says
+	requiredTopics map[string]bool
+}
+
+// NoSubscriberPolicy is what the broker does with a message no subscription wants
+type NoSubscriberPolicy int
+
+const (
+	// NoSubscribersLog logs the message's topic and accepts it (the default)
+	NoSubscribersLog NoSubscriberPolicy = iota
+	// NoSubscribersSilent accepts the message without logging
+	NoSubscribersSilent
+	// NoSubscribersError rejects the message with plugin.ErrNoSubscribers
+	NoSubscribersError
+)
+
+// topicTransform is a transform registered for a topic ("*" = every topic)
+type topicTransform struct {
+	topic string
+	fn    plugin.MessageTransform
+}
+
+// PublishTap observes every message the broker accepts, with the time it was published
+type PublishTap func(msg plugin.Message, at time.Time)
+
+// PublishAuthorizer reports whether a source may publish to a topic
+type PublishAuthorizer func(source, topic string) bool
+
+// ReservedTopicsAuthorizer allows only the listed sources to publish to each
+// reserved topic; topics not in reserved are open to every source
+func ReservedTopicsAuthorizer(reserved map[string][]string) PublishAuthorizer {
+	return func(source, topic string) bool {
+		owners, ok := reserved[topic]
+		if !ok {
+			return true
+		}
+		for _, owner := range owners {
+			if owner == source {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// asyncQueueSize is how many asynchronous publishes may be pending before
+// PublishAsync starts dropping messages
+const asyncQueueSize = 1024
+
+// asyncPublish is a message waiting for the dispatcher
+type asyncPublish struct {
+	ctx context.Context
+	msg plugin.Message
 }
 
 // NewBroker creates a new message broker
 func NewBroker() *Broker {
+	return NewBrokerWithClock(clock.Real())
+}
+
+// NewBrokerWithClock creates a new message broker that measures time with the given clock
+func NewBrokerWithClock(c clock.Clock) *Broker {
 	return &Broker{
-		subscriptions: make(map[string]*Subscription),
-		closed:        false,
+		subscriptions:  make(map[string]*Subscription),
+		closed:         false,
 		publishTimeout: 5 * time.Second, // Default timeout for slow consumers
+		clock:          c,
+		retained:       newRetainStore(0),
+		asyncQueue:     make(chan asyncPublish, asyncQueueSize),
 	}
 }
 
 // Subscribe creates a new subscription for the given topics
 // Returns a channel that will receive matching messages
 func (b *Broker) Subscribe(id string, bufSize int, topics ...string) <-chan plugin.Message {
+	return b.SubscribeWith(id, plugin.SubscribeOptions{BufSize: bufSize, Topics: topics})
+}
+
+// SubscribeWith creates a new subscription configured by opts
+// Returns a channel that will receive matching messages
+func (b *Broker) SubscribeWith(id string, opts plugin.SubscribeOptions) <-chan plugin.Message {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -53,73 +194,365 @@ func (b *Broker) Subscribe(id string, bufSize int, topics ...string) <-chan plug
 	// If subscription already exists, close old channel and replace
 	if old, exists := b.subscriptions[id]; exists {
 		log.Printf("[Broker] Replacing existing subscription for %s", id)
-		close(old.ch)
+		old.close()
 	}
 
 	sub := &Subscription{
-		id:      id,
-		ch:      make(chan plugin.Message, bufSize),
-		topics:  topics,
-		bufSize: bufSize,
+		id:       id,
+		ch:       make(chan plugin.Message, opts.BufSize),
+		topics:   opts.Topics,
+		bufSize:  opts.BufSize,
+		filter:   opts.Filter,
+		coalesce: opts.Coalesce && opts.BufSize > 0,
+		done:     make(chan struct{}),
 	}
 
 	b.subscriptions[id] = sub
-	log.Printf("[Broker] %s subscribed to topics: %v (buffer: %d)", id, topics, bufSize)
+	log.Printf("[Broker] %s subscribed to topics: %v (buffer: %d)", id, opts.Topics, opts.BufSize)
+
+	// Replay retained messages, the newest opts.Replay of them, as many as
+	// fit in the buffer
+	if opts.Replay >= 0 {
+		var retained []plugin.Message
+		now := b.clock.Now()
+		for _, r := range b.retained.matching(sub, now) {
+			if sub.wants(r.msg) && staleError(r.msg, b.maxAgeFor(r.msg), now) == nil {
+				retained = append(retained, r.msg)
+			}
+		}
+		if opts.Replay > 0 && len(retained) > opts.Replay {
+			retained = retained[len(retained)-opts.Replay:]
+		}
+
+		replayed := 0
+		for _, msg := range retained {
+			select {
+			case sub.ch <- msg:
+				replayed++
+			default:
+			}
+		}
+		if replayed > 0 {
+			sub.recordFill()
+			log.Printf("[Broker] Replayed %d retained message(s) to %s", replayed, id)
+		}
+	}
+
+	if opts.Context != nil {
+		go b.unsubscribeWhenDone(opts.Context, sub)
+	}
 
 	return sub.ch
 }
 
+// unsubscribeWhenDone removes sub once ctx is done, unless it was removed or
+// replaced first
+func (b *Broker) unsubscribeWhenDone(ctx context.Context, sub *Subscription) {
+	select {
+	case <-ctx.Done():
+	case <-sub.done:
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscriptions[sub.id] == sub {
+		sub.close()
+		delete(b.subscriptions, sub.id)
+		log.Printf("[Broker] %s unsubscribed (context done)", sub.id)
+	}
+}
+
+// DeliveryTiming records how long a single subscriber took to accept a message
+type DeliveryTiming struct {
+	// SubscriberID identifies the subscription
+	SubscriberID string
+
+	// Duration is the time between the start of delivery and acceptance (or failure)
+	Duration time.Duration
+
+	// Err is set if delivery to this subscriber failed
+	Err error
+}
+
+// PublishReceipt describes the outcome of a timed publish
+type PublishReceipt struct {
+	// Topic is the topic the message was published to
+	Topic string
+
+	// Deliveries contains one entry per targeted subscriber
+	Deliveries []DeliveryTiming
+}
+
+// Slowest returns the delivery that took the longest, or nil if there were none
+func (r *PublishReceipt) Slowest() *DeliveryTiming {
+	var slowest *DeliveryTiming
+	for i := range r.Deliveries {
+		if slowest == nil || r.Deliveries[i].Duration > slowest.Duration {
+			slowest = &r.Deliveries[i]
+		}
+	}
+	return slowest
+}
+
 // Publish broadcasts a message to all interested subscribers
 // Uses fan-out pattern with concurrent delivery and timeout handling
+// In async mode it behaves like PublishAsync
 func (b *Broker) Publish(ctx context.Context, msg plugin.Message) error {
+	b.mu.RLock()
+	async := b.async
+	b.mu.RUnlock()
+
+	if async {
+		return b.PublishAsync(ctx, msg)
+	}
+
+	_, err := b.PublishTimed(ctx, msg)
+	return err
+}
+
+// PublishAsync queues a message for delivery by a background dispatcher and
+// returns immediately. Messages are delivered in the order they were queued,
+// with the usual slow-consumer timeout. If the queue is full the message is
+// dropped and an error returned
+func (b *Broker) PublishAsync(ctx context.Context, msg plugin.Message) error {
+	b.asyncOnce.Do(func() {
+		go b.dispatch()
+	})
+
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
 	if b.closed {
 		return fmt.Errorf("broker is closed")
 	}
+	if err := b.authorizeLocked(msg); err != nil {
+		return err
+	}
+	if err := b.checkPayloadLocked(msg); err != nil {
+		return err
+	}
+	if err := plugin.CheckLoop(msg); err != nil {
+		return err
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = b.clock.Now()
+	}
+
+	// Delivery outlives the caller, so only keep the context's values
+	select {
+	case b.asyncQueue <- asyncPublish{ctx: context.WithoutCancel(ctx), msg: msg}:
+		return nil
+	default:
+		return fmt.Errorf("async publish queue full, dropped message (topic: %s)", msg.Topic)
+	}
+}
+
+// dispatch delivers queued asynchronous publishes until the broker closes
+func (b *Broker) dispatch() {
+	for item := range b.asyncQueue {
+		if _, err := b.PublishTimed(item.ctx, item.msg); err != nil {
+			log.Printf("[Broker] Async publish failed (topic: %s): %v", item.msg.Topic, err)
+		}
+	}
+}
+
+// PublishTimed broadcasts a message like Publish and additionally reports how long
+// each subscriber took to accept it, measured with the broker's clock
+func (b *Broker) PublishTimed(ctx context.Context, msg plugin.Message) (*PublishReceipt, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	receipt := &PublishReceipt{Topic: msg.Topic}
+
+	if b.closed {
+		return receipt, fmt.Errorf("broker is closed")
+	}
+	if err := b.authorizeLocked(msg); err != nil {
+		return receipt, err
+	}
+	if err := b.checkPayloadLocked(msg); err != nil {
+		return receipt, err
+	}
+	if err := plugin.CheckLoop(msg); err != nil {
+		return receipt, err
+	}
+
+	now := b.clock.Now()
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = now
+	}
+	msg = b.transformLocked(msg)
 
 	// Find matching subscriptions
 	var targets []*Subscription
 	for _, sub := range b.subscriptions {
-		if sub.wantsTopic(msg.Topic) {
+		if sub.wants(msg) {
 			targets = append(targets, sub)
 		}
 	}
 
+	// A rejected message is neither retained nor tapped
+	if len(targets) == 0 && (b.noSubscribers == NoSubscribersError || b.requiredTopics[msg.Topic]) {
+		return receipt, fmt.Errorf("%w: topic %s", plugin.ErrNoSubscribers, msg.Topic)
+	}
+
+	b.retained.add(msg, now)
+	if b.tap != nil {
+		b.tap(msg, now)
+	}
+
 	if len(targets) == 0 {
-		// No subscribers for this topic - not an error
-		log.Printf("[Broker] No subscribers for topic: %s", msg.Topic)
-		return nil
+		if b.noSubscribers == NoSubscribersLog {
+			plugin.Logf(messageContext(ctx, msg), "[Broker] No subscribers for topic: %s", msg.Topic)
+		}
+		return receipt, nil
 	}
 
 	// Fan-out: publish to all subscribers concurrently
-	g, gctx := errgroup.WithContext(ctx)
+	// Each goroutine writes only its own slot, so no extra locking is needed
+	// One subscriber failing must not cancel delivery to the others, so the
+	// group doesn't share a context
+	receipt.Deliveries = make([]DeliveryTiming, len(targets))
+	var g errgroup.Group
+	if b.fanoutLimit > 0 {
+		// g.Go blocks once the limit is reached; a limit of 1 delivers sequentially
+		g.SetLimit(b.fanoutLimit)
+	}
 
-	for _, sub := range targets {
-		sub := sub // Capture loop variable
+	maxAge := b.maxAgeFor(msg)
+	for i, sub := range targets {
+		i, sub := i, sub // Capture loop variables
 		g.Go(func() error {
-			return b.publishToSubscriber(gctx, sub, msg)
+			start := b.clock.Now()
+			err := b.publishToSubscriber(ctx, sub, msg, maxAge)
+			receipt.Deliveries[i] = DeliveryTiming{
+				SubscriberID: sub.id,
+				Duration:     b.clock.Now().Sub(start),
+				Err:          err,
+			}
+
+			// A stale message is dropped for this subscriber, not failed
+			if errors.Is(err, plugin.ErrMessageStale) {
+				plugin.Logf(messageContext(ctx, msg), "[Broker] Dropped message for %s: %v", sub.id, err)
+				return nil
+			}
+			return err
 		})
 	}
 
 	// Wait for all publishes to complete
 	if err := g.Wait(); err != nil {
-		return fmt.Errorf("publish failed: %w", err)
+		return receipt, fmt.Errorf("publish failed: %w", err)
 	}
 
-	log.Printf("[Broker] Published message (topic: %s, source: %s) to %d subscriber(s)", msg.Topic, msg.Source, len(targets))
+	plugin.Logf(messageContext(ctx, msg), "[Broker] Published message (topic: %s, source: %s) to %d subscriber(s)", msg.Topic, msg.Source, len(targets))
+	return receipt, nil
+}
+
+// checkPayloadLocked rejects a message whose payload is over the size limit
+// Caller must hold b.mu
+func (b *Broker) checkPayloadLocked(msg plugin.Message) error {
+	if b.maxPayloadBytes <= 0 {
+		return nil
+	}
+	if size := plugin.PayloadSize(msg.Payload); size > b.maxPayloadBytes {
+		return fmt.Errorf("%w: %d bytes on topic %s (limit %d)", plugin.ErrPayloadTooLarge, size, msg.Topic, b.maxPayloadBytes)
+	}
 	return nil
 }
 
+// maxAgeFor returns how long msg may wait for delivery (0 = no limit)
+// Caller must hold b.mu
+func (b *Broker) maxAgeFor(msg plugin.Message) time.Duration {
+	if msg.MaxAge > 0 {
+		return msg.MaxAge
+	}
+	return b.maxAge
+}
+
+// staleError returns an error wrapping plugin.ErrMessageStale if msg is
+// maxAge old or older at now (0 = no limit), or nil
+func staleError(msg plugin.Message, maxAge time.Duration, now time.Time) error {
+	if maxAge <= 0 || now.Sub(msg.Timestamp) < maxAge {
+		return nil
+	}
+	return fmt.Errorf("%w: topic %s, published %s ago (max age %s)", plugin.ErrMessageStale, msg.Topic, now.Sub(msg.Timestamp), maxAge)
+}
+
+// transformLocked passes a message through the transforms registered for
+// its topic; transforms can't move a message to another topic
+// Caller must hold b.mu
+func (b *Broker) transformLocked(msg plugin.Message) plugin.Message {
+	topic := msg.Topic
+	for _, t := range b.transforms {
+		if t.topic == topic || t.topic == "*" {
+			msg = t.fn(msg)
+			msg.Topic = topic
+		}
+	}
+	return msg
+}
+
+// authorizeLocked checks the message against the publish authorizer
+// Caller must hold b.mu
+func (b *Broker) authorizeLocked(msg plugin.Message) error {
+	if b.authorize == nil || b.authorize(msg.Source, msg.Topic) {
+		return nil
+	}
+	return fmt.Errorf("%w: source %q may not publish to topic %q", plugin.ErrPublishUnauthorized, msg.Source, msg.Topic)
+}
+
+// messageContext tags ctx with the message's correlation id, if it carries one
+func messageContext(ctx context.Context, msg plugin.Message) context.Context {
+	if id, ok := msg.Metadata[plugin.MetadataCorrelationID].(string); ok && id != "" {
+		return plugin.WithCorrelationID(ctx, id)
+	}
+	return ctx
+}
+
 // publishToSubscriber sends a message to a single subscriber with timeout
-func (b *Broker) publishToSubscriber(ctx context.Context, sub *Subscription, msg plugin.Message) error {
+// A message older than maxAge (0 = no limit) when its turn comes, or while it
+// waits for room, is dropped with an error wrapping plugin.ErrMessageStale
+func (b *Broker) publishToSubscriber(ctx context.Context, sub *Subscription, msg plugin.Message, maxAge time.Duration) error {
+	now := b.clock.Now()
+	if err := staleError(msg, maxAge, now); err != nil {
+		return err
+	}
+
+	if sub.coalesce {
+		for {
+			select {
+			case sub.ch <- msg:
+				sub.recordFill()
+				return nil
+			default:
+			}
+
+			// Full: drop the oldest buffered message to make room
+			select {
+			case <-sub.ch:
+			default:
+			}
+		}
+	}
+
+	// Stop waiting once the message goes stale (nil channel = never)
+	var staleAt <-chan time.Time
+	if maxAge > 0 {
+		staleAt = b.clock.After(msg.Timestamp.Add(maxAge).Sub(now))
+	}
+
 	select {
 	case sub.ch <- msg:
+		sub.recordFill()
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-time.After(b.publishTimeout):
+	case at := <-staleAt:
+		return fmt.Errorf("%w: topic %s, published %s ago (max age %s)", plugin.ErrMessageStale, msg.Topic, at.Sub(msg.Timestamp), maxAge)
+	case <-b.clock.After(b.publishTimeout):
 		// Slow consumer - this is a policy decision
 		// We could: 1) drop the message, 2) return error, 3) block forever
 		// Here we return an error to alert that the subscriber is slow
@@ -133,12 +566,37 @@ func (b *Broker) Unsubscribe(id string) {
 	defer b.mu.Unlock()
 
 	if sub, ok := b.subscriptions[id]; ok {
-		close(sub.ch)
+		sub.close()
 		delete(b.subscriptions, id)
 		log.Printf("[Broker] %s unsubscribed", id)
 	}
 }
 
+// UnsubscribeDrain removes a subscription like Unsubscribe, then passes the
+// messages still buffered in its channel to drain before returning
+// drain runs without the broker lock held, so it may publish
+func (b *Broker) UnsubscribeDrain(id string, drain func(plugin.Message)) {
+	b.mu.Lock()
+	sub, ok := b.subscriptions[id]
+	if ok {
+		sub.close()
+		delete(b.subscriptions, id)
+	}
+	b.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// A closed channel still yields its buffered messages
+	drained := 0
+	for msg := range sub.ch {
+		drain(msg)
+		drained++
+	}
+	log.Printf("[Broker] %s unsubscribed (drained %d message(s))", id, drained)
+}
+
 // Close shuts down the broker and closes all subscription channels
 func (b *Broker) Close() {
 	b.mu.Lock()
@@ -150,9 +608,12 @@ func (b *Broker) Close() {
 
 	b.closed = true
 
+	// Stop the dispatcher; pending async publishes fail against the closed broker
+	close(b.asyncQueue)
+
 	// Close all subscription channels
 	for id, sub := range b.subscriptions {
-		close(sub.ch)
+		sub.close()
 		log.Printf("[Broker] Closed subscription: %s", id)
 	}
 
@@ -169,6 +630,64 @@ func (b *Broker) SubscriberCount() int {
 	return len(b.subscriptions)
 }
 
+// TopicSubscriberCounts returns how many subscriptions listen on each topic
+// Subscriptions to all topics (no topics or "*") are counted under "*"
+func (b *Broker) TopicSubscriberCounts() map[string]int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, sub := range b.subscriptions {
+		if len(sub.topics) == 0 {
+			counts["*"]++
+			continue
+		}
+
+		// Count each topic once per subscription, even if listed twice
+		seen := make(map[string]bool)
+		for _, topic := range sub.topics {
+			if !seen[topic] {
+				seen[topic] = true
+				counts[topic]++
+			}
+		}
+	}
+	return counts
+}
+
+// Congested reports whether a subscription to topic has a buffer at least
+// congestedFill full, so producers can throttle themselves
+func (b *Broker) Congested(topic string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subscriptions {
+		if sub.wantsTopic(topic) && sub.congested() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats reports buffer usage for each subscription, sorted by ID
+func (b *Broker) Stats() []plugin.SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := make([]plugin.SubscriberStats, 0, len(b.subscriptions))
+	for _, sub := range b.subscriptions {
+		stats = append(stats, plugin.SubscriberStats{
+			ID:        sub.id,
+			Topics:    sub.topics,
+			BufSize:   sub.bufSize,
+			Pending:   len(sub.ch),
+			HighWater: int(sub.highWater.Load()),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
 // SetPublishTimeout sets the timeout for publishing to slow consumers
 func (b *Broker) SetPublishTimeout(timeout time.Duration) {
 	b.mu.Lock()
@@ -176,6 +695,119 @@ func (b *Broker) SetPublishTimeout(timeout time.Duration) {
 	b.publishTimeout = timeout
 }
 
+// PublishTimeout returns the timeout for publishing to slow consumers
+func (b *Broker) PublishTimeout() time.Duration {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.publishTimeout
+}
+
+// SetFanoutLimit caps how many subscribers a single publish delivers to concurrently
+// Zero or less means unbounded
+func (b *Broker) SetFanoutLimit(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if limit < 0 {
+		limit = 0
+	}
+	b.fanoutLimit = limit
+}
+
+// SetMaxAge sets how long a message without its own MaxAge may wait for
+// delivery before it is dropped (0 = no limit)
+func (b *Broker) SetMaxAge(maxAge time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxAge = maxAge
+}
+
+// SetMaxPayloadBytes sets the largest payload a publish may carry, measured
+// as string or JSON-encoded size (0 = no limit)
+func (b *Broker) SetMaxPayloadBytes(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if limit < 0 {
+		limit = 0
+	}
+	b.maxPayloadBytes = limit
+}
+
+// SetNoSubscriberPolicy sets what a publish that reaches no subscription does
+func (b *Broker) SetNoSubscriberPolicy(policy NoSubscriberPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.noSubscribers = policy
+}
+
+// SetRequiredTopics sets the topics whose messages are rejected with
+// plugin.ErrNoSubscribers when no subscription wants them (nil = none)
+func (b *Broker) SetRequiredTopics(topics []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requiredTopics = make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		b.requiredTopics[topic] = true
+	}
+}
+
+// SetPublishAuthorizer sets the check applied to every publish
+// nil allows every source to publish to every topic
+func (b *Broker) SetPublishAuthorizer(authorize PublishAuthorizer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.authorize = authorize
+}
+
+// SetTap sets the function shown every accepted message (nil = none)
+func (b *Broker) SetTap(tap PublishTap) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tap = tap
+}
+
+// RegisterTransform adds a transform run on messages published to topic
+// ("*" = every topic) before they are retained, audited and delivered.
+// Transforms run in registration order
+func (b *Broker) RegisterTransform(topic string, fn plugin.MessageTransform) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.transforms = append(b.transforms, topicTransform{topic: topic, fn: fn})
+	log.Printf("[Broker] Registered transform for topic: %s", topic)
+}
+
+// SetAsync switches Publish between synchronous delivery (the default) and
+// asynchronous delivery through PublishAsync
+func (b *Broker) SetAsync(async bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.async = async
+}
+
+// SetRetain sets how many recent messages are retained per topic for replay
+// to new subscribers (0 disables retention)
+func (b *Broker) SetRetain(count int) {
+	b.retained.setSize(count)
+}
+
+// SetRetainTTL sets how long retained messages stay eligible for replay
+// (0 = no limit)
+func (b *Broker) SetRetainTTL(ttl time.Duration) {
+	b.retained.setTTL(ttl)
+}
+
+// wants checks if a subscription is interested in a message: its topic and,
+// if the subscription has a filter, the filter
+func (s *Subscription) wants(msg plugin.Message) bool {
+	return s.wantsTopic(msg.Topic) && (s.filter == nil || s.filter(msg))
+}
+
+// close closes the subscription's channel and marks it removed
+func (s *Subscription) close() {
+	close(s.ch)
+	close(s.done)
+}
+
 // wantsTopic checks if a subscription is interested in a topic
 func (s *Subscription) wantsTopic(topic string) bool {
 	// Empty topics list means subscribe to all
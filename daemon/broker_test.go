@@ -0,0 +1,663 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// waitForWaiters waits until n goroutines are blocked on the fake clock
+func waitForWaiters(t *testing.T, fake *clock.Fake, n int) {
+	t.Helper()
+	waitFor(t, "goroutines to wait on the clock", func() bool { return fake.Waiters() >= n })
+}
+
+func TestPublishTimesOutOnFakeClock(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBrokerWithClock(fake)
+	b.SetPublishTimeout(5 * time.Second)
+	b.Subscribe("stuck", 0, "topic")
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- b.Publish(context.Background(), plugin.Message{Topic: "topic"}) }()
+	waitForWaiters(t, fake, 1)
+
+	fake.Advance(4 * time.Second)
+	select {
+	case err := <-errCh:
+		t.Fatalf("Publish returned %v before the timeout", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	fake.Advance(time.Second)
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "slow consumer") {
+			t.Errorf("Publish = %v, want a slow consumer timeout", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Publish did not time out")
+	}
+}
+
+func TestPublishReceiptTimesEachSubscriber(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBrokerWithClock(fake)
+	b.Subscribe("fast", 1, "topic")
+	slow := b.Subscribe("slow", 0, "topic")
+
+	receipts := make(chan *PublishReceipt, 1)
+	go func() {
+		receipt, err := b.PublishTimed(context.Background(), plugin.Message{Topic: "topic"})
+		if err != nil {
+			t.Errorf("PublishTimed: %v", err)
+		}
+		receipts <- receipt
+	}()
+
+	// Both deliveries start a timeout timer; the slow subscriber only takes
+	// the message two seconds later
+	waitForWaiters(t, fake, 2)
+	fake.Advance(2 * time.Second)
+	<-slow
+
+	receipt := <-receipts
+	durations := make(map[string]time.Duration)
+	for _, d := range receipt.Deliveries {
+		durations[d.SubscriberID] = d.Duration
+	}
+	if durations["slow"] != 2*time.Second || durations["fast"] != 0 {
+		t.Errorf("durations = %v, want slow 2s and fast 0", durations)
+	}
+	if slowest := receipt.Slowest(); slowest == nil || slowest.SubscriberID != "slow" {
+		t.Errorf("Slowest = %+v, want the slow subscriber", slowest)
+	}
+}
+
+func TestFanoutLimitBoundsConcurrentDeliveries(t *testing.T) {
+	const subscribers = 10
+
+	for _, limit := range []int{0, 1, 3} {
+		want := limit
+		if limit == 0 {
+			want = subscribers
+		}
+
+		fake := clock.NewFake(time.Unix(0, 0))
+		b := NewBrokerWithClock(fake)
+		b.SetFanoutLimit(limit)
+
+		// Unbuffered subscribers nobody reads hold each delivery open, and
+		// every delivery in flight waits on the clock for its timeout
+		var channels []<-chan plugin.Message
+		for i := 0; i < subscribers; i++ {
+			channels = append(channels, b.Subscribe(fmt.Sprintf("sub-%d", i), 0, "topic"))
+		}
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- b.Publish(context.Background(), plugin.Message{Topic: "topic"}) }()
+
+		waitForWaiters(t, fake, want)
+		time.Sleep(20 * time.Millisecond)
+		if got := fake.Waiters(); got != want {
+			t.Errorf("limit %d: %d deliveries in flight, want %d", limit, got, want)
+		}
+
+		// Reading every subscriber lets the rest of the deliveries run
+		for _, ch := range channels {
+			go func() { <-ch }()
+		}
+		select {
+		case err := <-errCh:
+			if err != nil {
+				t.Errorf("limit %d: Publish: %v", limit, err)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("limit %d: Publish did not finish once subscribers read", limit)
+		}
+	}
+}
+
+func TestExpiredRetainedMessagesNotReplayed(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBrokerWithClock(fake)
+	b.SetRetain(10)
+	b.SetRetainTTL(time.Minute)
+
+	publish := func(payload string, expiresAt time.Time) {
+		t.Helper()
+		if err := b.Publish(context.Background(), plugin.Message{Topic: "task.progress", Payload: payload, ExpiresAt: expiresAt}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	publish("past the ttl", time.Time{})
+	fake.Advance(45 * time.Second)
+	publish("fresh", time.Time{})
+	publish("past its expiry", fake.Now().Add(10*time.Second))
+	fake.Advance(20 * time.Second)
+
+	ch := b.Subscribe("late", 10, "task.progress")
+	if got := len(ch); got != 1 {
+		t.Fatalf("replayed %d messages, want 1", got)
+	}
+	if msg := <-ch; msg.Payload != "fresh" {
+		t.Errorf("replayed %v, want fresh", msg.Payload)
+	}
+
+	// Expired messages were evicted, not just skipped
+	if retained := b.Export().Retained; len(retained) != 1 || retained[0].Message.Payload != "fresh" {
+		t.Errorf("retained = %+v, want only the fresh message", retained)
+	}
+}
+
+func TestPublishAsyncReturnsBeforeDelivery(t *testing.T) {
+	for _, brokerWide := range []bool{false, true} {
+		b := NewBroker()
+		b.SetAsync(brokerWide)
+		ch := b.Subscribe("slow", 0, "notification")
+
+		// Nobody is reading, so a synchronous publish would block
+		returned := make(chan error, 1)
+		go func() {
+			for i := 1; i <= 3; i++ {
+				msg := plugin.Message{Topic: "notification", Payload: i}
+				var err error
+				if brokerWide {
+					err = b.Publish(context.Background(), msg)
+				} else {
+					err = b.PublishAsync(context.Background(), msg)
+				}
+				if err != nil {
+					returned <- err
+					return
+				}
+			}
+			returned <- nil
+		}()
+		select {
+		case err := <-returned:
+			if err != nil {
+				t.Fatalf("async publish (broker-wide %v): %v", brokerWide, err)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("async publish (broker-wide %v) blocked on the subscriber", brokerWide)
+		}
+
+		for want := 1; want <= 3; want++ {
+			select {
+			case msg := <-ch:
+				if msg.Payload != want {
+					t.Errorf("broker-wide %v: got %v, want %d", brokerWide, msg.Payload, want)
+				}
+			case <-time.After(testTimeout):
+				t.Fatalf("broker-wide %v: message %d never delivered", brokerWide, want)
+			}
+		}
+		b.Close()
+	}
+}
+
+func TestTopicSubscriberCounts(t *testing.T) {
+	b := NewBroker()
+	b.Subscribe("tui", 1, "notification", "chat", "response")
+	b.Subscribe("telegram", 1, "notification", "response")
+	b.Subscribe("repeated", 1, "chat", "chat")
+	b.Subscribe("audit", 1)
+
+	want := map[string]int{"notification": 2, "chat": 2, "response": 2, "*": 1}
+	got := b.TopicSubscriberCounts()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+
+	b.Unsubscribe("telegram")
+	if got := b.TopicSubscriberCounts(); got["notification"] != 1 || got["response"] != 1 {
+		t.Errorf("counts after unsubscribing = %v, want notification and response at 1", got)
+	}
+}
+
+func TestStatsRecordHighWaterMark(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("burst", 10, "topic")
+	b.Subscribe("idle", 10, "other")
+
+	publishBurst := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if err := b.Publish(context.Background(), plugin.Message{Topic: "topic"}); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+		}
+	}
+
+	publishBurst(4)
+	for len(ch) > 0 {
+		<-ch
+	}
+	publishBurst(2)
+
+	stats := b.Stats()
+	if len(stats) != 2 || stats[0].ID != "burst" || stats[1].ID != "idle" {
+		t.Fatalf("stats = %+v, want burst then idle", stats)
+	}
+	if burst := stats[0]; burst.HighWater != 4 || burst.Pending != 2 || burst.BufSize != 10 {
+		t.Errorf("burst stats = %+v, want high-water 4 with 2 of 10 pending", burst)
+	}
+	if idle := stats[1]; idle.HighWater != 0 || idle.Pending != 0 {
+		t.Errorf("idle stats = %+v, want nothing recorded", idle)
+	}
+}
+
+func TestUnsubscribeDrainDeliversBufferedMessages(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("transport", 10, "notification")
+	echoes := b.Subscribe("echoes", 10, "echo")
+
+	for i := 0; i < 3; i++ {
+		if err := b.Publish(context.Background(), plugin.Message{Topic: "notification", Payload: i}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	// The drain callback may publish without deadlocking on the broker
+	var drained []interface{}
+	b.UnsubscribeDrain("transport", func(msg plugin.Message) {
+		drained = append(drained, msg.Payload)
+		if err := b.Publish(context.Background(), plugin.Message{Topic: "echo", Payload: msg.Payload}); err != nil {
+			t.Errorf("Publish from drain: %v", err)
+		}
+	})
+
+	if len(drained) != 3 || drained[0] != 0 || drained[1] != 1 || drained[2] != 2 {
+		t.Errorf("drained %v, want [0 1 2]", drained)
+	}
+	if _, open := <-ch; open {
+		t.Error("channel still open after draining")
+	}
+	if len(echoes) != 3 {
+		t.Errorf("drain published %d messages, want 3", len(echoes))
+	}
+
+	// Draining an unknown subscription is a no-op
+	b.UnsubscribeDrain("transport", func(plugin.Message) { t.Error("drained a removed subscription") })
+}
+
+func TestUnsubscribeClosesImmediately(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("transport", 10, "notification")
+	if err := b.Publish(context.Background(), plugin.Message{Topic: "notification"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	b.Unsubscribe("transport")
+
+	if err := b.Publish(context.Background(), plugin.Message{Topic: "notification"}); err != nil {
+		t.Fatalf("Publish after unsubscribe: %v", err)
+	}
+	if n := len(ch); n != 1 {
+		t.Errorf("channel holds %d messages, want only the one published before unsubscribing", n)
+	}
+	<-ch
+	if _, open := <-ch; open {
+		t.Error("channel still open after unsubscribing")
+	}
+}
+
+func TestPublishAuthorizerBlocksReservedTopics(t *testing.T) {
+	b := NewBroker()
+	events := b.Subscribe("events", 10, "daemon.event", "chat")
+	b.SetPublishAuthorizer(ReservedTopicsAuthorizer(map[string][]string{"daemon.event": {"daemon"}}))
+
+	err := b.Publish(context.Background(), plugin.Message{Topic: "daemon.event", Source: "rest"})
+	if !errors.Is(err, plugin.ErrPublishUnauthorized) {
+		t.Errorf("Publish from rest = %v, want ErrPublishUnauthorized", err)
+	}
+	if _, err := b.PublishTimed(context.Background(), plugin.Message{Topic: "daemon.event", Source: "websocket"}); !errors.Is(err, plugin.ErrPublishUnauthorized) {
+		t.Errorf("PublishTimed from websocket = %v, want ErrPublishUnauthorized", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("%d unauthorized messages delivered", len(events))
+	}
+
+	for _, msg := range []plugin.Message{
+		{Topic: "daemon.event", Source: "daemon"},
+		{Topic: "chat", Source: "rest"},
+	} {
+		if err := b.Publish(context.Background(), msg); err != nil {
+			t.Errorf("Publish %s from %s: %v", msg.Topic, msg.Source, err)
+		}
+	}
+	if len(events) != 2 {
+		t.Errorf("%d authorized messages delivered, want 2", len(events))
+	}
+
+	// Without an authorizer every source may publish anywhere
+	b.SetPublishAuthorizer(nil)
+	if err := b.Publish(context.Background(), plugin.Message{Topic: "daemon.event", Source: "rest"}); err != nil {
+		t.Errorf("Publish without an authorizer: %v", err)
+	}
+}
+
+func TestStaleMessagesDropped(t *testing.T) {
+	fake := clock.NewFake(time.Unix(100, 0))
+	b := NewBrokerWithClock(fake)
+	b.SetMaxAge(10 * time.Second)
+	ch := b.Subscribe("sub", 10, "progress")
+
+	for _, msg := range []plugin.Message{
+		{Payload: "fresh"},
+		{Payload: "stale", Timestamp: fake.Now().Add(-10 * time.Second)},
+		{Payload: "own max age", Timestamp: fake.Now().Add(-2 * time.Second), MaxAge: time.Second},
+		{Payload: "old but allowed", Timestamp: fake.Now().Add(-20 * time.Second), MaxAge: time.Minute},
+	} {
+		msg.Topic = "progress"
+		if err := b.Publish(context.Background(), msg); err != nil {
+			t.Errorf("Publish(%v): %v", msg.Payload, err)
+		}
+	}
+
+	var got []interface{}
+	for len(ch) > 0 {
+		got = append(got, (<-ch).Payload)
+	}
+	if want := "[fresh old but allowed]"; fmt.Sprint(got) != want {
+		t.Errorf("delivered %v, want %s", got, want)
+	}
+}
+
+func TestMessageGoesStaleWaitingForRoom(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBrokerWithClock(fake)
+	b.SetPublishTimeout(time.Minute)
+	ch := b.Subscribe("full", 1, "progress")
+	b.Publish(context.Background(), plugin.Message{Topic: "progress", Payload: "first"})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- b.Publish(context.Background(), plugin.Message{Topic: "progress", Payload: "second", MaxAge: time.Second})
+	}()
+	waitForWaiters(t, fake, 2)
+	fake.Advance(time.Second)
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Publish = %v, want the stale message dropped without an error", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Publish still waiting after the message went stale")
+	}
+	if first := <-ch; first.Payload != "first" || len(ch) != 0 {
+		t.Errorf("buffer holds %v and %d more, want only first", first.Payload, len(ch))
+	}
+}
+
+func TestPayloadSizeLimit(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("sub", 10, "topic")
+
+	// Unlimited by default
+	if err := b.Publish(context.Background(), plugin.Message{Topic: "topic", Payload: strings.Repeat("x", 1<<20)}); err != nil {
+		t.Fatalf("Publish without a limit: %v", err)
+	}
+	<-ch
+
+	b.SetMaxPayloadBytes(16)
+	for _, tt := range []struct {
+		payload interface{}
+		wantErr bool
+	}{
+		{strings.Repeat("x", 16), false},
+		{strings.Repeat("x", 17), true},
+		{[]byte(strings.Repeat("x", 17)), true},
+		{map[string]string{"k": "v"}, false},                      // {"k":"v"} is 9 bytes
+		{map[string]string{"key": strings.Repeat("v", 10)}, true}, // 20 bytes encoded
+	} {
+		err := b.Publish(context.Background(), plugin.Message{Topic: "topic", Payload: tt.payload})
+		if tt.wantErr != errors.Is(err, plugin.ErrPayloadTooLarge) {
+			t.Errorf("Publish(%v) = %v, want rejected %v", tt.payload, err, tt.wantErr)
+		}
+	}
+	if len(ch) != 2 {
+		t.Errorf("%d messages delivered, want the 2 under the limit", len(ch))
+	}
+}
+
+func TestCongestedWhenBufferNearlyFull(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("slow", 10, "task.progress")
+	b.Subscribe("unbuffered", 0, "task.progress")
+	b.Subscribe("other", 1, "chat")
+
+	publish := func(n int) {
+		for i := 0; i < n; i++ {
+			b.Publish(context.Background(), plugin.Message{Topic: "task.progress"})
+		}
+	}
+
+	publish(7)
+	if b.Congested("task.progress") {
+		t.Error("congested at 7 of 10")
+	}
+	publish(1)
+	if !b.Congested("task.progress") {
+		t.Error("not congested at 8 of 10")
+	}
+	if b.Congested("chat") {
+		t.Error("chat congested by another topic's subscriber")
+	}
+
+	for len(ch) > 5 {
+		<-ch
+	}
+	if b.Congested("task.progress") {
+		t.Error("still congested after the subscriber caught up")
+	}
+}
+
+func TestSubscribeWithOptions(t *testing.T) {
+	even := func(msg plugin.Message) bool { return msg.Payload.(int)%2 == 0 }
+
+	tests := []struct {
+		name string
+		opts plugin.SubscribeOptions
+		want string // payloads received: replayed ones, then 5..9 published live
+	}{
+		{"topics only", plugin.SubscribeOptions{BufSize: 20, Topics: []string{"n"}}, "[0 1 2 3 4 5 6 7 8 9]"},
+		{"other topic", plugin.SubscribeOptions{BufSize: 20, Topics: []string{"other"}}, "[]"},
+		{"no replay", plugin.SubscribeOptions{BufSize: 20, Topics: []string{"n"}, Replay: -1}, "[5 6 7 8 9]"},
+		{"replay two", plugin.SubscribeOptions{BufSize: 20, Topics: []string{"n"}, Replay: 2}, "[3 4 5 6 7 8 9]"},
+		{"filter", plugin.SubscribeOptions{BufSize: 20, Topics: []string{"n"}, Filter: even}, "[0 2 4 6 8]"},
+		{"filtered replay", plugin.SubscribeOptions{BufSize: 20, Filter: even, Replay: 1}, "[4 6 8]"},
+		{"coalesce", plugin.SubscribeOptions{BufSize: 3, Topics: []string{"n"}, Replay: -1, Coalesce: true}, "[7 8 9]"},
+	}
+	for _, tt := range tests {
+		b := NewBroker()
+		b.SetRetain(10)
+		for i := 0; i < 5; i++ {
+			b.Publish(context.Background(), plugin.Message{Topic: "n", Payload: i})
+		}
+
+		ch := b.SubscribeWith("sub", tt.opts)
+		for i := 5; i < 10; i++ {
+			b.Publish(context.Background(), plugin.Message{Topic: "n", Payload: i})
+		}
+
+		got := []interface{}{}
+		for len(ch) > 0 {
+			got = append(got, (<-ch).Payload)
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%s: received %v, want %s", tt.name, got, tt.want)
+		}
+		b.Close()
+	}
+}
+
+func TestSubscribeWithContextEndsSubscription(t *testing.T) {
+	b := NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := b.SubscribeWith("sub", plugin.SubscribeOptions{BufSize: 1, Topics: []string{"n"}, Context: ctx})
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("received a message, want the channel closed")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("channel still open after the context ended")
+	}
+	if counts := b.TopicSubscriberCounts(); counts["n"] != 0 {
+		t.Errorf("subscriber counts = %v, want the subscription gone", counts)
+	}
+}
+
+func TestTransformsRedactTheirTopicInOrder(t *testing.T) {
+	b := NewBroker()
+	out := b.Subscribe("sub", 10, "chat", "notification")
+
+	b.RegisterTransform("chat", func(msg plugin.Message) plugin.Message {
+		msg.Payload = strings.ReplaceAll(msg.Payload.(string), "hunter2", "[redacted]")
+		return msg
+	})
+	b.RegisterTransform("chat", func(msg plugin.Message) plugin.Message {
+		msg.Payload = msg.Payload.(string) + " (checked)"
+		// A transform can't move the message to another topic
+		msg.Topic = "notification"
+		return msg
+	})
+
+	for _, msg := range []plugin.Message{
+		{Topic: "chat", Payload: "my password is hunter2"},
+		{Topic: "notification", Payload: "hunter2"},
+	} {
+		if err := b.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish %s: %v", msg.Topic, err)
+		}
+	}
+
+	want := []plugin.Message{
+		{Topic: "chat", Payload: "my password is [redacted] (checked)"},
+		{Topic: "notification", Payload: "hunter2"},
+	}
+	for _, w := range want {
+		got := <-out
+		if got.Topic != w.Topic || got.Payload != w.Payload {
+			t.Errorf("delivered %s %q, want %s %q", got.Topic, got.Payload, w.Topic, w.Payload)
+		}
+	}
+}
+
+func TestTwoTransportLoopNotRecirculated(t *testing.T) {
+	b := NewBroker()
+	transports := map[string]<-chan plugin.Message{
+		"telegram":  b.Subscribe("telegram", 10, "chat"),
+		"websocket": b.Subscribe("websocket", 10, "chat"),
+	}
+
+	if err := b.Publish(context.Background(), plugin.Message{Topic: "chat", Source: "telegram:42", Payload: "hi"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// Each transport relays every chat message to the other side, as a
+	// naive two-way bridge would, until nothing is left to relay
+	delivered, relayed := 0, 0
+	for pending := true; pending; {
+		pending = false
+		for name, ch := range transports {
+			select {
+			case msg := <-ch:
+				pending = true
+				delivered++
+				if delivered > 10 {
+					t.Fatal("message still circulating after 10 deliveries")
+				}
+				if sourceTransport := strings.SplitN(msg.Source, ":", 2)[0]; sourceTransport == name {
+					continue
+				}
+				out, err := plugin.Relay(msg, name)
+				if err != nil {
+					if !errors.Is(err, plugin.ErrMessageLoop) {
+						t.Errorf("Relay to %s: %v, want ErrMessageLoop", name, err)
+					}
+					continue
+				}
+				if err := b.Publish(context.Background(), out); err != nil {
+					t.Fatalf("Publish relayed by %s: %v", name, err)
+				}
+				relayed++
+			default:
+			}
+		}
+	}
+	if relayed != 1 {
+		t.Errorf("message relayed %d times, want once (telegram to websocket)", relayed)
+	}
+
+	// A transport that skips Relay and re-publishes as itself is refused
+	echo := plugin.Message{
+		Topic:    "chat",
+		Source:   "telegram:42",
+		Metadata: map[string]interface{}{plugin.MetadataVia: []string{"telegram", "websocket"}},
+	}
+	if err := b.Publish(context.Background(), echo); !errors.Is(err, plugin.ErrMessageLoop) {
+		t.Errorf("Publish echoed message = %v, want ErrMessageLoop", err)
+	}
+	if _, err := b.PublishTimed(context.Background(), echo); !errors.Is(err, plugin.ErrMessageLoop) {
+		t.Errorf("PublishTimed echoed message = %v, want ErrMessageLoop", err)
+	}
+}
+
+func TestNoSubscriberPolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		setting  string
+		logLevel string
+		required []string
+		topic    string
+		wantErr  bool
+		wantLog  bool
+	}{
+		{"log", "log", "info", nil, "idle", false, true},
+		{"default logs", "", "info", nil, "idle", false, true},
+		{"silent", "silent", "info", nil, "idle", false, false},
+		{"debug at info level", "debug", "info", nil, "idle", false, false},
+		{"debug at debug level", "debug", "debug", nil, "idle", false, true},
+		{"error", "error", "info", nil, "idle", true, false},
+		{"required topic", "silent", "info", []string{"audit"}, "audit", true, false},
+		{"other topic than required", "silent", "info", []string{"audit"}, "idle", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroker()
+			b.SetNoSubscriberPolicy(noSubscriberPolicy(config.DaemonConfig{BrokerNoSubscribers: tt.setting, LogLevel: tt.logLevel}))
+			b.SetRequiredTopics(tt.required)
+			logs := captureLog(t)
+
+			err := b.Publish(context.Background(), plugin.Message{Topic: tt.topic})
+			if got := errors.Is(err, plugin.ErrNoSubscribers); got != tt.wantErr {
+				t.Errorf("Publish = %v, want ErrNoSubscribers: %v", err, tt.wantErr)
+			}
+			if got := len(logs.lines("No subscribers")) > 0; got != tt.wantLog {
+				t.Errorf("logged no subscribers: %v, want %v", got, tt.wantLog)
+			}
+		})
+	}
+
+	// A required topic with a subscriber delivers as usual
+	b := NewBroker()
+	b.SetRequiredTopics([]string{"audit"})
+	ch := b.Subscribe("auditor", 1, "audit")
+	if err := b.Publish(context.Background(), plugin.Message{Topic: "audit"}); err != nil || len(ch) != 1 {
+		t.Errorf("Publish to a subscribed required topic = %v (%d delivered), want delivery", err, len(ch))
+	}
+}
@@ -0,0 +1,212 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// chatStateKey is the state manager key recent chat messages are persisted under
+const chatStateKey = "daemon.chats"
+
+// chatSaveDelay is how long a recorded chat message waits to be persisted, so
+// a burst of messages is saved at once
+const chatSaveDelay = time.Second
+
+// chatHistory keeps the most recent chat messages of each conversation
+type chatHistory struct {
+	mu     sync.RWMutex
+	chats  map[string][]plugin.ChatEntry
+	size   int           // Most messages kept per conversation
+	maxAge time.Duration // Older messages are dropped (0 = no limit)
+	dirty  bool          // Messages were added since the last save
+
+	// saveMu serializes saves, so an older snapshot never overwrites a newer one
+	saveMu sync.Mutex
+}
+
+// newChatHistory creates a history keeping at most size messages per conversation
+func newChatHistory(size int, maxAge time.Duration) *chatHistory {
+	return &chatHistory{
+		chats:  make(map[string][]plugin.ChatEntry),
+		size:   size,
+		maxAge: maxAge,
+	}
+}
+
+// add appends a message to a conversation, evicting the oldest beyond the
+// size, and forgets conversations whose messages are all past the age limit
+func (h *chatHistory) add(chat string, entry plugin.ChatEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := append(h.chats[chat], entry)
+	if len(entries) > h.size {
+		entries = entries[len(entries)-h.size:]
+	}
+	h.chats[chat] = entries
+	h.dirty = true
+
+	if h.maxAge > 0 {
+		for key, entries := range h.chats {
+			if entry.Time.Sub(entries[len(entries)-1].Time) > h.maxAge {
+				delete(h.chats, key)
+			}
+		}
+	}
+}
+
+// takeDirty reports whether messages were added since the last call
+func (h *chatHistory) takeDirty() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dirty := h.dirty
+	h.dirty = false
+	return dirty
+}
+
+// recent returns a conversation's messages still within the age limit, oldest first
+func (h *chatHistory) recent(chat string, now time.Time) []plugin.ChatEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.fresh(h.chats[chat], now)
+}
+
+// all returns every conversation's messages still within the age limit
+func (h *chatHistory) all(now time.Time) map[string][]plugin.ChatEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	out := make(map[string][]plugin.ChatEntry, len(h.chats))
+	for chat, entries := range h.chats {
+		if kept := h.fresh(entries, now); len(kept) > 0 {
+			out[chat] = kept
+		}
+	}
+	return out
+}
+
+// restore replaces the history with previously saved conversations
+func (h *chatHistory) restore(saved map[string][]plugin.ChatEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.chats = make(map[string][]plugin.ChatEntry, len(saved))
+	for chat, entries := range saved {
+		if len(entries) > h.size {
+			entries = entries[len(entries)-h.size:]
+		}
+		h.chats[chat] = entries
+	}
+}
+
+// fresh returns a copy of entries without those past the age limit
+// Caller must hold h.mu
+func (h *chatHistory) fresh(entries []plugin.ChatEntry, now time.Time) []plugin.ChatEntry {
+	out := make([]plugin.ChatEntry, 0, len(entries))
+	for _, e := range entries {
+		if h.maxAge > 0 && now.Sub(e.Time) > h.maxAge {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// recordChats adds every chat message from the channel to the history until
+// the channel is closed, persisting it to sm (if any) chatSaveDelay after the
+// first unsaved message. Stop saves what is left
+func (d *Daemon) recordChats(ch <-chan plugin.Message, sm plugin.StateManager) {
+	defer d.wg.Done()
+
+	var save <-chan time.Time // set while added messages wait to be saved
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			d.chats.add(plugin.ChatKey(msg), plugin.ChatEntry{
+				Time:   d.clock.Now(),
+				Source: msg.Source,
+				Text:   plugin.PayloadText(msg.Payload),
+			})
+			if sm != nil && save == nil {
+				save = d.clock.After(chatSaveDelay)
+			}
+		case <-save:
+			save = nil
+			d.saveChats(d.ctx, sm)
+		}
+	}
+}
+
+// RecentChats returns the kept messages of a conversation, oldest first
+func (d *Daemon) RecentChats(chat string) []plugin.ChatEntry {
+	d.mu.RLock()
+	chats := d.chats
+	d.mu.RUnlock()
+
+	if chats == nil {
+		return nil
+	}
+	return chats.recent(chat, d.clock.Now())
+}
+
+// saveChats persists the chat history to the state manager, if messages were
+// added since it was last saved
+func (d *Daemon) saveChats(ctx context.Context, sm plugin.StateManager) {
+	d.chats.saveMu.Lock()
+	defer d.chats.saveMu.Unlock()
+
+	if !d.chats.takeDirty() {
+		return
+	}
+	data, err := json.Marshal(d.chats.all(d.clock.Now()))
+	if err != nil {
+		log.Printf("[Daemon] Error encoding chat history: %v", err)
+		return
+	}
+
+	if err := sm.Set(ctx, chatStateKey, string(data)); err != nil {
+		log.Printf("[Daemon] Error saving chat history: %v", err)
+		return
+	}
+	if err := sm.Save(ctx); err != nil {
+		log.Printf("[Daemon] Error persisting state: %v", err)
+	}
+}
+
+// loadChats restores the chat history from the state manager
+// Caller must hold d.mu
+func (d *Daemon) loadChats(ctx context.Context) {
+	if d.stateManager == nil {
+		return
+	}
+
+	val, err := d.stateManager.Get(ctx, chatStateKey)
+	if err != nil {
+		// Nothing saved yet
+		return
+	}
+
+	data, ok := val.(string)
+	if !ok {
+		log.Printf("[Daemon] Ignoring saved chat history of unexpected type %T", val)
+		return
+	}
+
+	var saved map[string][]plugin.ChatEntry
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		log.Printf("[Daemon] Error decoding chat history: %v", err)
+		return
+	}
+
+	d.chats.restore(saved)
+	log.Printf("[Daemon] Restored chat history for %d conversation(s)", len(saved))
+}
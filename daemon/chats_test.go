@@ -0,0 +1,173 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// statePlugin is a plugin providing a fake state manager
+type statePlugin struct {
+	state *testutil.StateManager
+}
+
+func (p *statePlugin) Name() string                                { return "fakestate" }
+func (p *statePlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *statePlugin) Extensions() []plugin.Extension              { return []plugin.Extension{p.state} }
+func (p *statePlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *statePlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	return nil
+}
+
+// startChatDaemon starts a daemon on a fake clock keeping chat history in a
+// fake state manager
+func startChatDaemon(t *testing.T, maxAge int) (*Daemon, *testutil.StateManager, *clock.Fake) {
+	t.Helper()
+
+	fake := clock.NewFake(time.Unix(1000, 0))
+	state := testutil.NewStateManager()
+	return startChatDaemonOn(t, 100, maxAge, state, fake), state, fake
+}
+
+// startChatDaemonOn starts a daemon keeping size chat messages per
+// conversation in state, as a restart on the same state would
+func startChatDaemonOn(t *testing.T, size, maxAge int, state *testutil.StateManager, fake *clock.Fake) *Daemon {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.ChatHistorySize = size
+	cfg.Daemon.ChatHistoryMaxAge = maxAge
+	cfg.Plugins["fakestate"] = config.PluginConfig{Enabled: true}
+
+	d := NewWithClock(cfg, fake)
+	if err := d.AddPlugin(&statePlugin{state: state}); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { d.Stop() })
+	return d
+}
+
+// publishChat publishes a chat message in conversation chat
+func publishChat(d *Daemon, chat, text string) {
+	d.broker.Publish(context.Background(), plugin.Message{
+		Topic:    "chat",
+		Payload:  text,
+		Source:   chat,
+		Metadata: map[string]interface{}{plugin.MetadataChat: chat},
+	})
+}
+
+// savedChats returns the chat history saved in state
+func savedChats(t *testing.T, state *testutil.StateManager) map[string][]plugin.ChatEntry {
+	t.Helper()
+
+	val, err := state.Get(context.Background(), chatStateKey)
+	if err != nil {
+		return nil
+	}
+	var saved map[string][]plugin.ChatEntry
+	if err := json.Unmarshal([]byte(val.(string)), &saved); err != nil {
+		t.Fatalf("decoding saved chats: %v", err)
+	}
+	return saved
+}
+
+func TestChatHistorySavesBurstOnce(t *testing.T) {
+	d, state, fake := startChatDaemon(t, 0)
+	before := state.Saves()
+
+	for i := 0; i < 50; i++ {
+		publishChat(d, "telegram:1", fmt.Sprintf("message %d", i))
+	}
+	waitFor(t, "the messages to be recorded", func() bool { return len(d.RecentChats("telegram:1")) == 50 })
+	if got := state.Saves() - before; got != 0 {
+		t.Errorf("saved %d times before the delay, want 0", got)
+	}
+
+	waitFor(t, "the save timer", func() bool { return fake.Waiters() > 0 })
+	fake.Advance(chatSaveDelay)
+	waitFor(t, "the burst to be saved", func() bool { return state.Saves()-before == 1 })
+	if got := len(savedChats(t, state)["telegram:1"]); got != 50 {
+		t.Errorf("saved %d messages, want 50", got)
+	}
+}
+
+func TestStopSavesUnsavedChats(t *testing.T) {
+	d, state, _ := startChatDaemon(t, 0)
+
+	publishChat(d, "telegram:1", "hello")
+	waitFor(t, "the message to be recorded", func() bool { return len(d.RecentChats("telegram:1")) == 1 })
+
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := savedChats(t, state)["telegram:1"]; len(got) != 1 || got[0].Text != "hello" {
+		t.Errorf("saved %v, want the hello message", got)
+	}
+}
+
+func TestChatHistoryForgetsStaleConversations(t *testing.T) {
+	h := newChatHistory(10, time.Minute)
+	start := time.Unix(1000, 0)
+
+	h.add("old", plugin.ChatEntry{Time: start, Text: "a"})
+	h.add("recent", plugin.ChatEntry{Time: start.Add(30 * time.Second), Text: "b"})
+	h.add("new", plugin.ChatEntry{Time: start.Add(90 * time.Second), Text: "c"})
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.chats["old"]; ok {
+		t.Error("conversation past the age limit is still kept")
+	}
+	if len(h.chats) != 2 {
+		t.Errorf("kept %d conversations, want recent and new", len(h.chats))
+	}
+}
+
+func TestChatsRecoveredAfterRestart(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+	state := testutil.NewStateManager()
+	d := startChatDaemonOn(t, 3, 600, state, fake)
+
+	publishChat(d, "telegram:2", "stale")
+	waitFor(t, "the first message to be recorded", func() bool { return len(d.RecentChats("telegram:2")) == 1 })
+	fake.Advance(5 * time.Minute)
+	for i := 1; i <= 4; i++ {
+		publishChat(d, "telegram:1", fmt.Sprintf("message %d", i))
+	}
+	publishChat(d, "websocket:a", "hi")
+	waitFor(t, "the messages to be recorded", func() bool { return len(d.RecentChats("websocket:a")) == 1 })
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Restarting on the same state brings the conversations back, capped at
+	// the history size and without messages that aged out meanwhile
+	fake.Advance(6 * time.Minute)
+	restarted := startChatDaemonOn(t, 3, 600, state, fake)
+
+	var texts []string
+	for _, e := range restarted.RecentChats("telegram:1") {
+		texts = append(texts, e.Text)
+	}
+	if got, want := fmt.Sprint(texts), "[message 2 message 3 message 4]"; got != want {
+		t.Errorf("recovered telegram:1 = %s, want %s", got, want)
+	}
+	if got := restarted.RecentChats("websocket:a"); len(got) != 1 || got[0].Text != "hi" || got[0].Source != "websocket:a" {
+		t.Errorf("recovered websocket:a = %+v, want hi", got)
+	}
+	if got := restarted.RecentChats("telegram:2"); len(got) != 0 {
+		t.Errorf("recovered telegram:2 = %+v, want the stale message dropped", got)
+	}
+}
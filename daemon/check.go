@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+
+	"bicycle/plugin"
+)
+
+// CheckPlugin runs a plugin's requirement checks against the daemon's current
+// config and mode without starting it, reporting each requirement's outcome.
+// Any registered plugin can be checked, whether it is enabled or not
+func (d *Daemon) CheckPlugin(name string) (*plugin.RequirementReport, error) {
+	d.mu.RLock()
+	p, ok := d.added[name]
+	ctx := context.WithValue(d.ctx, "config", d.config)
+	d.mu.RUnlock()
+
+	if !ok {
+		if p, ok = plugin.GetRegistry().Get(name); !ok {
+			return nil, fmt.Errorf("unknown plugin: %s", name)
+		}
+	}
+
+	report := &plugin.RequirementReport{Plugin: name}
+	if err := p.CheckRequirements(plugin.WithRequirementReport(ctx, report)); err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Passed = true
+	}
+	return report, nil
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// mixedPlugin declares a passing required, a failing optional and a failing
+// required requirement, and records whether it was started
+type mixedPlugin struct {
+	executorPlugin
+	started bool
+}
+
+func (p *mixedPlugin) CheckRequirements(ctx context.Context) error {
+	rc := plugin.NewRequirementChecker(p.name)
+	rc.AddRequired("config", "Config is present", func(ctx context.Context) error { return nil })
+	rc.AddOptional("cache", "Cache directory is writable", func(ctx context.Context) error { return errors.New("read-only") })
+	rc.AddRequired("token", "API token is set", func(ctx context.Context) error { return errors.New("not set") })
+	return rc.Check(ctx)
+}
+
+func (p *mixedPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	p.started = true
+	return nil
+}
+
+func TestCheckPluginReportsEachRequirement(t *testing.T) {
+	cfg := config.DefaultConfig()
+	d := New(cfg)
+	p := &mixedPlugin{executorPlugin: executorPlugin{name: "mixed"}}
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+
+	report, err := d.CheckPlugin("mixed")
+	if err != nil {
+		t.Fatalf("CheckPlugin: %v", err)
+	}
+	if report.Passed || report.Error == "" {
+		t.Errorf("report = %+v, want failed with the error", report)
+	}
+
+	want := []plugin.RequirementResult{
+		{Name: "config", Description: "Config is present", Required: true, Passed: true},
+		{Name: "cache", Description: "Cache directory is writable", Error: "read-only"},
+		{Name: "token", Description: "API token is set", Required: true, Error: "not set"},
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("results = %+v, want %d", report.Results, len(want))
+	}
+	for i, result := range report.Results {
+		if result != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, result, want[i])
+		}
+	}
+	if p.started {
+		t.Error("checking the plugin started it")
+	}
+
+	if _, err := d.CheckPlugin("nonexistent"); err == nil {
+		t.Error("checking an unknown plugin succeeded")
+	}
+}
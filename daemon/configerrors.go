@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"bicycle/plugin"
+)
+
+// validatePluginConfig checks a plugin's settings against its schema and
+// its own ValidateConfig, if it has them
+func (d *Daemon) validatePluginConfig(ctx context.Context, p plugin.Plugin) error {
+	var errs []error
+
+	if sp, ok := p.(plugin.SettingsSchemaProvider); ok {
+		settings := d.config.Plugins[p.Name()].Settings
+		if err := plugin.CheckSettingKinds(p.Name(), sp.SettingsSchema(), settings); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if v, ok := p.(plugin.ConfigValidator); ok {
+		if err := v.ValidateConfig(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// recordConfigErrors keeps the ConfigErrors in err for the startup summary.
+// With validation set, an error without any is recorded as a ConfigError of
+// its own, since it came from checking the plugin's settings
+// Caller must hold d.mu
+func (d *Daemon) recordConfigErrors(name string, err error, validation bool) {
+	found := plugin.ConfigErrors(err)
+	if len(found) == 0 && validation {
+		found = []*plugin.ConfigError{plugin.NewConfigError(name, "", err.Error())}
+	}
+	d.configErrors = append(d.configErrors, found...)
+}
+
+// logConfigErrors prints every misconfiguration found during Start at once
+// Caller must hold d.mu
+func (d *Daemon) logConfigErrors() {
+	if len(d.configErrors) == 0 {
+		return
+	}
+
+	plugins := make(map[string]bool)
+	for _, e := range d.configErrors {
+		plugins[e.Plugin] = true
+	}
+
+	log.Printf("[Daemon] %d plugin(s) skipped for configuration errors:", len(plugins))
+	for _, e := range d.configErrors {
+		log.Printf("[Daemon]   %v", e)
+	}
+}
+
+// ConfigErrors returns the plugin misconfigurations found during Start
+func (d *Daemon) ConfigErrors() []*plugin.ConfigError {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]*plugin.ConfigError(nil), d.configErrors...)
+}
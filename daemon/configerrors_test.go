@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// misconfiguredPlugin fails validation or its requirement check with the
+// given errors
+type misconfiguredPlugin struct {
+	executorPlugin
+	validateErr error
+	requireErr  error
+}
+
+func (p *misconfiguredPlugin) ValidateConfig(ctx context.Context) error    { return p.validateErr }
+func (p *misconfiguredPlugin) CheckRequirements(ctx context.Context) error { return p.requireErr }
+
+func TestConfigErrorsAggregatedAtStartup(t *testing.T) {
+	logs := captureLog(t)
+	cfg := config.DefaultConfig()
+	d := New(cfg)
+
+	for _, p := range []plugin.Plugin{
+		&misconfiguredPlugin{
+			executorPlugin: executorPlugin{name: "alpha"},
+			validateErr: errors.Join(
+				plugin.NewConfigError("alpha", "url", "is required"),
+				plugin.NewConfigError("alpha", "timeout", "must be positive"),
+			),
+		},
+		&misconfiguredPlugin{
+			executorPlugin: executorPlugin{name: "beta"},
+			requireErr:     fmt.Errorf("checking token: %w", plugin.NewConfigError("beta", "token", "is required")),
+		},
+		&executorPlugin{name: "healthy", executor: testutil.NewExecutor("work")},
+	} {
+		cfg.Plugins[p.Name()] = config.PluginConfig{Enabled: true}
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	var got []string
+	for _, e := range d.ConfigErrors() {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"plugin alpha: setting url: is required",
+		"plugin alpha: setting timeout: must be positive",
+		"plugin beta: setting token: is required",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("config errors = %q, want %q", got, want)
+	}
+
+	// The summary names every misconfigured plugin together
+	if len(logs.lines("2 plugin(s) skipped for configuration errors:")) != 1 {
+		t.Error("log has no configuration error summary")
+	}
+	for _, line := range want {
+		if len(logs.lines("[Daemon]   "+line)) != 1 {
+			t.Errorf("summary missing %q", line)
+		}
+	}
+}
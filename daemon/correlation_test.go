@@ -0,0 +1,110 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// logBuffer collects log output; log writes from any goroutine
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines returns the lines logged so far that contain substr
+func (b *logBuffer) lines(substr string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var out []string
+	for _, line := range strings.Split(b.buf.String(), "\n") {
+		if strings.Contains(line, substr) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// captureLog sends the standard logger to a buffer until the test ends
+func captureLog(t *testing.T) *logBuffer {
+	buf := &logBuffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return buf
+}
+
+func TestTaskLogLinesShareCorrelationID(t *testing.T) {
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		plugin.Logf(ctx, "[Fake] Working on %s", task.ID)
+		return "done", nil
+	}
+	d := startDaemon(t, config.DefaultConfig(), executor)
+	responses := testutil.Collect(d.broker, "test", "response")
+	logs := captureLog(t)
+
+	if err := d.ExecuteTask(context.Background(), &plugin.Task{ID: "t1", Type: "work", CorrelationID: "trace-1"}); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if msgs := responses.WaitFor(1, testTimeout); len(msgs) != 1 {
+		t.Fatalf("got %d responses, want 1", len(msgs))
+	}
+	waitFor(t, "the response publish to be logged", func() bool {
+		return len(logs.lines("[Broker] Published message (topic: response")) > 0
+	})
+
+	tagged := strings.Join(logs.lines("[cid=trace-1]"), "\n")
+	for _, want := range []string{
+		"[Daemon] Executing task: work (ID: t1)",
+		"[Fake] Working on t1",
+		"[Daemon] Task completed successfully",
+		"[Broker] Published message (topic: response",
+	} {
+		if !strings.Contains(tagged, want) {
+			t.Errorf("no %q line tagged with the correlation id in:\n%s", want, tagged)
+		}
+	}
+	for _, line := range logs.lines("(ID: t1") {
+		if !strings.Contains(line, "[cid=trace-1]") {
+			t.Errorf("log line for t1 without its correlation id: %s", line)
+		}
+	}
+}
+
+func TestTaskWithoutCorrelationIDUsesItsID(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig(), testutil.NewExecutor("work"))
+
+	task := &plugin.Task{ID: "t1", Type: "work"}
+	if err := d.ExecuteTask(context.Background(), task); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if task.CorrelationID != "t1" {
+		t.Errorf("correlation id = %q, want the task id", task.CorrelationID)
+	}
+
+	waitFor(t, "t1 to finish", func() bool { return len(d.TaskResults()) == 1 })
+
+	// A transport's correlation id on the context is used when the task has none
+	traced := &plugin.Task{ID: "t2", Type: "work"}
+	if err := d.ExecuteTask(plugin.WithCorrelationID(context.Background(), "req-7"), traced); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if traced.CorrelationID != "req-7" {
+		t.Errorf("correlation id = %q, want the context's req-7", traced.CorrelationID)
+	}
+}
@@ -2,11 +2,17 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
+	"bicycle/internal/clock"
 	"bicycle/internal/config"
 	"bicycle/plugin"
 )
@@ -24,6 +30,9 @@ const (
 
 	// DefaultShutdownTimeout is the default timeout for graceful shutdown
 	DefaultShutdownTimeout = 10 * time.Second
+
+	// TopicHeartbeat is the topic for periodic daemon liveness messages
+	TopicHeartbeat = "daemon.heartbeat"
 )
 
 // Daemon represents the main daemon instance
@@ -33,27 +42,131 @@ type Daemon struct {
 	config  *config.Config
 	broker  *Broker
 	plugins map[string]plugin.Plugin
+	order   []string // plugin names in the order they were added
+	started []string // plugin names in the order they were started
 	ctx     context.Context
 	cancel  context.CancelFunc
 	wg      sync.WaitGroup
+	clock   clock.Clock
+
+	// startedAt records when Start completed, for uptime reporting
+	startedAt time.Time
+
+	// pluginsVersion advances whenever d.config.Plugins is replaced. The
+	// config itself is never replaced: contexts handed out carry the same
+	// pointer, so everyone sees the current settings
+	pluginsVersion uint64
+
+	// startAttempts counts each plugin's start attempts, so a Start that
+	// finishes after timing out can tell whether a later attempt took over
+	startAttempts map[string]uint64
+
+	// deps holds each plugin's declared dependencies as of Start, for /deps
+	deps map[string][]string
+
+	// configErrors lists the plugin misconfigurations found during Start
+	configErrors []*plugin.ConfigError
+
+	// added holds every plugin added, including those skipped at Start, for /check
+	added map[string]plugin.Plugin
+
+	// Registered executors, in registration order
+	executors []plugin.Executor
+
+	// picker chooses among executors handling the same task type
+	picker *executorPicker
+
+	// State manager used for daemon-level persistence (first one registered)
+	stateManager plugin.StateManager
+
+	// audit records every published message when daemon.message_audit is set
+	audit *messageAudit
+
+	// Recent notifications, for /log
+	notifications *notificationRing
+
+	// Recent chat messages per conversation, for backfill (nil = not kept)
+	chats *chatHistory
+
+	// Recent task results
+	results *taskResults
+
+	// Recent tasks that failed every attempt
+	deadLetters *deadLetters
+
+	// Registered task lifecycle observers
+	observers *taskObservers
+
+	// statusTemplate renders /status (nil = StatusSnapshot.String)
+	statusTemplate *template.Template
+
+	// Running tasks, in start order
+	running []*runningTask
+
+	// Tasks waiting to start, in submission order
+	queue []*plugin.Task
 
-	// Current task information
-	currentTask *plugin.Task
-	executor    plugin.Executor
+	// ready is set once Start has finished, and cleared when Stop begins
+	ready atomic.Bool
+
+	// shutdown is closed when a plugin asks the daemon to stop
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+
+	// restart is set when the stop was asked for by RequestRestart
+	restart atomic.Bool
 }
 
 // New creates a new daemon instance
 func New(cfg *config.Config) *Daemon {
+	return NewWithClock(cfg, clock.Real())
+}
+
+// NewWithClock creates a new daemon instance whose daemon and broker use the given clock
+func NewWithClock(cfg *config.Config, c clock.Clock) *Daemon {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Daemon{
+	d := &Daemon{
 		state:   StateIdle,
 		config:  cfg,
-		broker:  NewBroker(),
+		broker:  NewBrokerWithClock(c),
 		plugins: make(map[string]plugin.Plugin),
-		ctx:     ctx,
+		added:   make(map[string]plugin.Plugin),
 		cancel:  cancel,
+		clock:   c,
+
+		startAttempts: make(map[string]uint64),
+
+		shutdown: make(chan struct{}),
+
+		picker:        newExecutorPicker(cfg.Daemon.ExecutorStrategy, cfg.Daemon.ExecutorWeights),
+		notifications: newNotificationRing(cfg.Daemon.NotificationLogSize),
+		results:       newTaskResults(maxTaskResults),
+		deadLetters:   newDeadLetters(maxDeadLetters),
+		observers:     newTaskObservers(),
+
+		statusTemplate: parseStatusTemplate(cfg.Daemon.StatusTemplate),
 	}
+
+	// Enrich the root context once so every derived context (plugin start,
+	// task execution, command routing) sees the same values
+	d.ctx = d.withValues(ctx)
+
+	return d
+}
+
+// withValues attaches the daemon, broker, config and mode values to a context
+func (d *Daemon) withValues(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, "mode", d.config.Mode)
+	ctx = context.WithValue(ctx, "daemon", d)
+	ctx = context.WithValue(ctx, "broker", plugin.MessageBroker(d.broker))
+	ctx = context.WithValue(ctx, "config", d.config)
+	return ctx
+}
+
+// Context returns the daemon's root context carrying mode, daemon and config values
+func (d *Daemon) Context() context.Context {
+	return d.ctx
 }
 
 // AddPlugin adds a plugin to the daemon
@@ -64,7 +177,7 @@ func (d *Daemon) AddPlugin(p plugin.Plugin) error {
 	name := p.Name()
 
 	// Check if plugin is enabled in config
-	if !d.config.IsPluginEnabled(name) {
+	if !d.config.IsPluginEnabled(name, plugin.PluginLabels(p)...) {
 		log.Printf("[Daemon] Plugin %s is disabled in config, skipping", name)
 		return nil
 	}
@@ -74,6 +187,8 @@ func (d *Daemon) AddPlugin(p plugin.Plugin) error {
 	}
 
 	d.plugins[name] = p
+	d.added[name] = p
+	d.order = append(d.order, name)
 	log.Printf("[Daemon] Added plugin: %s", name)
 
 	return nil
@@ -90,30 +205,123 @@ func (d *Daemon) Start() error {
 
 	log.Println("[Daemon] Starting daemon...")
 
-	// Create context with mode
-	ctx := context.WithValue(d.ctx, "mode", d.config.Mode)
-	ctx = context.WithValue(ctx, "daemon", d)
-	ctx = context.WithValue(ctx, "config", d.config)
+	ctx := d.ctx
 
 	// Configure broker
-	d.broker.SetPublishTimeout(time.Duration(d.config.Daemon.PublishTimeout) * time.Second)
+	d.applyBrokerSettings()
+
+	// Audit every message from the first one published
+	if auditCfg := d.config.Daemon.MessageAudit; auditCfg.Path != "" {
+		audit, err := newMessageAudit(auditCfg)
+		if err != nil {
+			return err
+		}
+		d.audit = audit
+		d.broker.SetTap(audit.record)
+		log.Printf("[Daemon] Auditing broker messages to %s", auditCfg.Path)
+	}
+
+	// Restore retained messages from a previous run before anyone subscribes
+	if path := d.config.Daemon.BrokerSnapshotFile; path != "" {
+		if _, err := os.Stat(path); err == nil {
+			if err := d.broker.ReadSnapshotFile(path); err != nil {
+				log.Printf("[Daemon] Error importing broker snapshot: %v", err)
+			}
+		}
+	}
+
+	// Record notifications from startup onwards
+	d.wg.Add(1)
+	go d.recordNotifications(d.broker.Subscribe("daemon.notifications", d.config.Daemon.BrokerBufferSize, "notification"))
+
+	// Record transport delivery failures against task results
+	d.wg.Add(1)
+	go d.recordDeliveryFailures(d.broker.Subscribe("daemon.delivery", d.config.Daemon.BrokerBufferSize, plugin.TopicDeliveryFailed))
+
+	// Answer /ping through the broker
+	d.wg.Add(1)
+	go d.answerPings(d.broker.Subscribe("daemon.loopback", d.config.Daemon.BrokerBufferSize, plugin.TopicPing))
+
+	// Call task observers from one subscription, so they see a task's
+	// events in the order they were published
+	d.wg.Add(1)
+	go d.observeTasks(d.broker.Subscribe("daemon.observers", d.config.Daemon.BrokerBufferSize,
+		plugin.TopicTaskStarted, plugin.TopicTaskProgress, plugin.TopicTaskCompleted, plugin.TopicTaskFailed))
+
+	// Resolve start order so dependencies start before their dependents
+	deps := d.pluginDependencyMap()
+	d.deps = deps
+	d.configErrors = nil
+	order, problems := resolveStartOrder(d.order, deps)
+	for name, err := range problems {
+		log.Printf("[Daemon] Skipping plugin %s: %v", name, err)
+		delete(d.plugins, name)
+	}
+
+	// Check every plugin and create the subscribers' subscriptions before
+	// any plugin starts, so none misses what another publishes while starting
+	early := make(map[string][]string)
+	for _, name := range order {
+		p := d.plugins[name]
+
+		// Skip plugins whose dependencies were skipped
+		if missing := d.missingDependency(p); missing != "" {
+			log.Printf("[Daemon] Skipping plugin %s: dependency %s skipped", name, missing)
+			delete(d.plugins, name)
+			continue
+		}
+
+		// Check settings before anything else touches them
+		if err := d.validatePluginConfig(ctx, p); err != nil {
+			log.Printf("[Daemon] Plugin %s is misconfigured: %v", name, err)
+			log.Printf("[Daemon] Skipping plugin: %s", name)
+			d.recordConfigErrors(name, err, true)
+			delete(d.plugins, name)
+			continue
+		}
 
-	// Start plugins
-	for name, p := range d.plugins {
 		log.Printf("[Daemon] Checking requirements for plugin: %s", name)
 
 		// Check requirements
 		if err := p.CheckRequirements(ctx); err != nil {
 			log.Printf("[Daemon] Plugin %s requirements failed: %v", name, err)
 			log.Printf("[Daemon] Skipping plugin: %s", name)
+			d.recordConfigErrors(name, err, false)
+			delete(d.plugins, name)
+			continue
+		}
+
+		ids, err := d.subscribePlugin(ctx, p)
+		if err != nil {
+			log.Printf("[Daemon] Plugin %s failed to subscribe: %v", name, err)
+			log.Printf("[Daemon] Skipping plugin: %s", name)
+			d.recordConfigErrors(name, err, false)
+			delete(d.plugins, name)
+			continue
+		}
+		early[name] = ids
+	}
+
+	// Start plugins
+	for _, name := range order {
+		p, ok := d.plugins[name]
+		if !ok {
+			continue // Skipped above
+		}
+
+		// Skip plugins whose dependencies failed to start
+		if missing := d.unstartedDependency(p); missing != "" {
+			log.Printf("[Daemon] Skipping plugin %s: dependency %s not running", name, missing)
+			d.unsubscribeAll(early[name])
 			delete(d.plugins, name)
 			continue
 		}
 
 		// Start plugin
-		log.Printf("[Daemon] Starting plugin: %s", name)
-		if err := p.Start(ctx, d.broker); err != nil {
+		if err := d.startPlugin(ctx, p); err != nil {
 			log.Printf("[Daemon] Failed to start plugin %s: %v", name, err)
+			d.recordConfigErrors(name, err, false)
+			d.unsubscribeAll(early[name])
 			delete(d.plugins, name)
 			continue
 		}
@@ -122,30 +330,216 @@ func (d *Daemon) Start() error {
 		for _, ext := range p.Extensions() {
 			if ext.Type() == plugin.ExtensionTypeExecutor {
 				if executor, ok := ext.(plugin.Executor); ok {
-					d.executor = executor
-					log.Printf("[Daemon] Registered executor from plugin: %s", name)
+					d.executors = append(d.executors, executor)
+					log.Printf("[Daemon] Registered executor %s from plugin: %s", executor.Name(), name)
+				}
+			}
+			if ext.Type() == plugin.ExtensionTypeState && d.stateManager == nil {
+				if sm, ok := ext.(plugin.StateManager); ok {
+					d.stateManager = sm
+					log.Printf("[Daemon] Using state manager %s from plugin: %s", sm.Name(), name)
 				}
 			}
 		}
 
+		d.started = append(d.started, name)
 		log.Printf("[Daemon] Started plugin: %s", name)
 	}
 
+	// Registered task handlers come after plugin executors, so a plugin
+	// executor for the same task type takes precedence
+	d.executors = append(d.executors, plugin.NewDispatchExecutor(plugin.GetTaskHandlerRegistry()))
+
+	d.loadNotifications(ctx)
+
+	// Keep recent chat messages, restored from and persisted to the state manager
+	if size := d.config.Daemon.ChatHistorySize; size > 0 {
+		d.chats = newChatHistory(size, time.Duration(d.config.Daemon.ChatHistoryMaxAge)*time.Second)
+		d.loadChats(ctx)
+		d.wg.Add(1)
+		go d.recordChats(d.broker.Subscribe("daemon.chats", d.config.Daemon.BrokerBufferSize, "chat"), d.stateManager)
+	}
+
+	d.startedAt = d.clock.Now()
 	log.Printf("[Daemon] Started with %d active plugin(s)", len(d.plugins))
+	d.logConfigErrors()
 
+	// Start heartbeat
+	if interval := time.Duration(d.config.Daemon.HeartbeatInterval) * time.Second; interval > 0 {
+		d.wg.Add(1)
+		go d.runHeartbeat(interval)
+	}
+
+	// Start supervising plugin health
+	if interval := time.Duration(d.config.Daemon.SupervisorInterval) * time.Second; interval > 0 {
+		d.wg.Add(1)
+		go d.runSupervisor(interval, d.config.Daemon.SupervisorFailureThreshold)
+	}
+
+	d.ready.Store(true)
 	return nil
 }
 
+// Ready reports whether the daemon has finished starting and can accept
+// commands and tasks. Transports start listening before this is true
+func (d *Daemon) Ready() bool {
+	return d.ready.Load()
+}
+
+// startPlugin starts a plugin, retrying failed starts as configured by the
+// plugin's start_retries and start_retry_delay
+// Caller must hold d.mu
+func (d *Daemon) startPlugin(ctx context.Context, p plugin.Plugin) error {
+	name := p.Name()
+	ctx = plugin.WithPluginName(ctx, name)
+	pc, _ := d.config.GetPluginConfig(name)
+	delay := time.Duration(pc.StartRetryDelay) * time.Second
+
+	timeout := time.Duration(d.config.Daemon.PluginStartTimeout) * time.Second
+	if pc.StartTimeout > 0 {
+		timeout = time.Duration(pc.StartTimeout) * time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		log.Printf("[Daemon] Starting plugin: %s", name)
+		err := d.startWithTimeout(ctx, p, timeout)
+		if err == nil {
+			return nil
+		}
+		if attempt >= pc.StartRetries {
+			return err
+		}
+
+		// Start is not safe to call again while an earlier call may still
+		// be running, so a plugin that timed out fails
+		if errors.Is(err, errStartTimedOut) {
+			log.Printf("[Daemon] Plugin %s start timed out and is still running, not retrying", name)
+			return err
+		}
+
+		log.Printf("[Daemon] Plugin %s failed to start (attempt %d of %d): %v, retrying in %s",
+			name, attempt+1, pc.StartRetries+1, err, delay)
+
+		select {
+		case <-d.clock.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// errStartTimedOut is returned by startWithTimeout when Start is still running
+var errStartTimedOut = errors.New("start timed out")
+
+// startWithTimeout runs a plugin's Start, giving up after timeout (0 = no limit)
+// Start keeps the daemon context rather than a deadline context, because plugins
+// hold on to it for their lifetime. A plugin that finishes starting after the
+// timeout is stopped again if the daemon gave up on it and no later attempt
+// has started it since
+// Caller must hold d.mu
+func (d *Daemon) startWithTimeout(ctx context.Context, p plugin.Plugin, timeout time.Duration) error {
+	name := p.Name()
+	d.startAttempts[name]++
+	attempt := d.startAttempts[name]
+
+	if timeout <= 0 {
+		return p.Start(ctx, d.broker)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Start(ctx, d.broker)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-d.clock.After(timeout):
+		go func() {
+			if err := <-errCh; err != nil {
+				return
+			}
+
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if d.startAttempts[name] != attempt || d.isStarted(name) {
+				return // Another attempt owns the plugin now
+			}
+			log.Printf("[Daemon] Plugin %s started after timing out, stopping it", name)
+			if err := p.Stop(context.Background()); err != nil {
+				log.Printf("[Daemon] Error stopping plugin %s: %v", name, err)
+			}
+		}()
+		return fmt.Errorf("%w after %s", errStartTimedOut, timeout)
+	}
+}
+
+// runHeartbeat publishes a status snapshot on the heartbeat topic every interval
+// until the daemon context is cancelled
+func (d *Daemon) runHeartbeat(interval time.Duration) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.clock.After(interval):
+			if err := d.broker.Publish(d.ctx, plugin.Message{
+				Topic:   TopicHeartbeat,
+				Payload: d.Snapshot(d.ctx),
+				Source:  "daemon",
+			}); err != nil {
+				log.Printf("[Daemon] Error publishing heartbeat: %v", err)
+			}
+		}
+	}
+}
+
 // Stop stops the daemon and all plugins
 func (d *Daemon) Stop() error {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
 	if d.state == StateStopped {
+		d.mu.Unlock()
 		return nil
 	}
 
 	log.Println("[Daemon] Stopping daemon...")
+	d.ready.Store(false)
+
+	// Queued tasks won't start now; report them while the broker still runs
+	if queued := d.queue; len(queued) > 0 {
+		d.queue = nil
+		d.mu.Unlock()
+		d.failQueued(queued, "daemon is stopping")
+		d.mu.Lock()
+	}
+
+	// Let the running tasks finish before cancelling everything
+	if d.config.Daemon.Tasks.DrainOnShutdown && len(d.running) > 0 {
+		running := append([]*runningTask(nil), d.running...)
+		d.mu.Unlock()
+		log.Printf("[Daemon] Waiting for %d running task(s) to finish...", len(running))
+		for _, rt := range running {
+			<-rt.done
+		}
+		d.mu.Lock()
+	}
+
+	// Persist while the state manager is still running
+	d.saveNotifications(context.Background())
+	if d.chats != nil && d.stateManager != nil {
+		d.saveChats(context.Background(), d.stateManager)
+	}
+
+	// Dump broker state while subscriptions are still in place
+	if path := d.config.Daemon.BrokerSnapshotFile; path != "" {
+		if err := d.broker.WriteSnapshotFile(path); err != nil {
+			log.Printf("[Daemon] Error writing broker snapshot: %v", err)
+		} else {
+			log.Printf("[Daemon] Wrote broker snapshot to %s", path)
+		}
+	}
 
 	// Cancel context
 	d.cancel()
@@ -154,51 +548,133 @@ func (d *Daemon) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	for name, p := range d.plugins {
+	// Stop in reverse start order so dependents stop before their dependencies
+	for i := len(d.started) - 1; i >= 0; i-- {
+		name := d.started[i]
+		p, ok := d.plugins[name]
+		if !ok {
+			continue
+		}
 		log.Printf("[Daemon] Stopping plugin: %s", name)
 		if err := p.Stop(ctx); err != nil {
 			log.Printf("[Daemon] Error stopping plugin %s: %v", name, err)
 		}
 	}
 
-	// Close broker
+	// Close broker; once it is closed nothing more reaches the audit log
 	d.broker.Close()
+	if d.audit != nil {
+		if err := d.audit.Close(); err != nil {
+			log.Printf("[Daemon] Error closing message audit: %v", err)
+		}
+		d.audit = nil
+	}
+	d.mu.Unlock()
 
-	// Wait for goroutines
+	// Wait for goroutines outside the lock, since they may need it to finish
 	d.wg.Wait()
 
+	d.mu.Lock()
 	d.state = StateStopped
+	d.mu.Unlock()
 	log.Println("[Daemon] Stopped")
 
 	return nil
 }
 
-// Reset resets the daemon to idle state
+// RequestShutdown asks the process running the daemon to stop it
+// Plugins call this when the daemon can no longer do useful work, e.g. when
+// the interactive UI fails
+func (d *Daemon) RequestShutdown(reason string) {
+	d.shutdownOnce.Do(func() {
+		log.Printf("[Daemon] Shutdown requested: %s", reason)
+		close(d.shutdown)
+	})
+}
+
+// RequestRestart asks the process running the daemon to stop it and then
+// start the binary again in place, to apply settings that can't be reloaded
+func (d *Daemon) RequestRestart(reason string) {
+	d.restart.Store(true)
+	d.RequestShutdown(reason + " (restart)")
+}
+
+// RestartRequested reports whether the requested stop should be followed by a restart
+func (d *Daemon) RestartRequested() bool {
+	return d.restart.Load()
+}
+
+// ShutdownRequested returns a channel closed when RequestShutdown is called
+func (d *Daemon) ShutdownRequested() <-chan struct{} {
+	return d.shutdown
+}
+
+// Reset resets the daemon to idle state, cancelling the running tasks and
+// dropping the queued ones
 func (d *Daemon) Reset(ctx context.Context) error {
 	d.mu.Lock()
-	defer d.mu.Unlock()
 
-	if d.state != StateWorking {
+	if len(d.running) == 0 && len(d.queue) == 0 {
+		d.mu.Unlock()
 		return fmt.Errorf("daemon is not working")
 	}
 
 	log.Println("[Daemon] Resetting to idle state...")
 
-	// Cancel current task if there's an executor
-	if d.executor != nil && d.currentTask != nil {
-		if err := d.executor.CancelTask(ctx, d.currentTask.ID); err != nil {
-			log.Printf("[Daemon] Error cancelling task: %v", err)
-		}
+	for len(d.running) > 0 {
+		d.stopTask(ctx, d.running[0])
 	}
+	dropped := d.queue
+	d.queue = nil
+	d.mu.Unlock()
 
-	d.currentTask = nil
-	d.state = StateIdle
+	d.failQueued(dropped, "task dropped by reset")
 
 	log.Println("[Daemon] Reset to idle state")
 
 	return nil
 }
 
+// CancelTask cancels the running or queued task with the given ID
+func (d *Daemon) CancelTask(ctx context.Context, taskID string) error {
+	d.mu.Lock()
+
+	for _, rt := range d.running {
+		if rt.task.ID == taskID {
+			log.Printf("[Daemon] Cancelling task: %s", taskID)
+			d.stopTask(ctx, rt)
+			d.mu.Unlock()
+			return nil
+		}
+	}
+
+	for i, task := range d.queue {
+		if task.ID == taskID {
+			log.Printf("[Daemon] Cancelling queued task: %s", taskID)
+			d.queue = append(d.queue[:i:i], d.queue[i+1:]...)
+			d.mu.Unlock()
+			d.failQueued([]*plugin.Task{task}, "task cancelled")
+			return nil
+		}
+	}
+
+	d.mu.Unlock()
+	return fmt.Errorf("task not found: %s", taskID)
+}
+
+// stopTask cancels a running task and forgets it; its executor stays busy
+// until ExecuteTask returns. Caller must hold d.mu
+func (d *Daemon) stopTask(ctx context.Context, rt *runningTask) {
+	if err := rt.executor.CancelTask(ctx, rt.task.ID); err != nil {
+		log.Printf("[Daemon] Error cancelling task: %v", err)
+	}
+
+	// Cancel the task context so the executor stops working
+	rt.cancel()
+
+	d.removeRunning(rt)
+}
+
 // GetState returns the current daemon state
 func (d *Daemon) GetState() State {
 	d.mu.RLock()
@@ -214,31 +690,37 @@ func (d *Daemon) SetState(state State) {
 	log.Printf("[Daemon] State changed to: %s", state)
 }
 
-// GetStatus returns a status string for the daemon
-func (d *Daemon) GetStatus(ctx context.Context) string {
+// GetCurrentTask returns the longest-running task, or nil when idle
+func (d *Daemon) GetCurrentTask() *plugin.Task {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
-	status := fmt.Sprintf("Daemon Status:\n")
-	status += fmt.Sprintf("  State: %s\n", d.state)
-	status += fmt.Sprintf("  Mode: %s\n", d.config.Mode)
-	status += fmt.Sprintf("  Active Plugins: %d\n", len(d.plugins))
+	if len(d.running) == 0 {
+		return nil
+	}
+	return d.running[0].task
+}
 
-	if d.state == StateWorking && d.currentTask != nil {
-		status += fmt.Sprintf("  Current Task: %s (ID: %s)\n", d.currentTask.Type, d.currentTask.ID)
+// GetRunningTasks returns the running tasks in start order
+func (d *Daemon) GetRunningTasks() []*plugin.Task {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-		// Get executor status if available
-		if d.executor != nil {
-			if execStatus, err := d.executor.GetStatus(ctx); err == nil {
-				status += fmt.Sprintf("  Progress: %d%%\n", execStatus.Progress)
-				if execStatus.Message != "" {
-					status += fmt.Sprintf("  Message: %s\n", execStatus.Message)
-				}
-			}
-		}
+	tasks := make([]*plugin.Task, len(d.running))
+	for i, rt := range d.running {
+		tasks[i] = rt.task
 	}
+	return tasks
+}
+
+// TopicSubscriberCounts returns the broker's subscriber count per topic
+func (d *Daemon) TopicSubscriberCounts() map[string]int {
+	return d.broker.TopicSubscriberCounts()
+}
 
-	return status
+// BrokerStats returns buffer usage for each broker subscription
+func (d *Daemon) BrokerStats() []plugin.SubscriberStats {
+	return d.broker.Stats()
 }
 
 // GetBroker returns the message broker
@@ -246,70 +728,409 @@ func (d *Daemon) GetBroker() *Broker {
 	return d.broker
 }
 
+// GetClock returns the clock used by the daemon
+func (d *Daemon) GetClock() clock.Clock {
+	return d.clock
+}
+
 // GetConfig returns the daemon configuration
 func (d *Daemon) GetConfig() *config.Config {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	return d.config
 }
 
-// GetPlugins returns all active plugins
+// GetPlugins returns all active plugins in start order
+// Before Start, plugins are returned in the order they were added
 func (d *Daemon) GetPlugins() []plugin.Plugin {
 	d.mu.RLock()
 	defer d.mu.RUnlock()
 
+	names := d.order
+	if len(d.started) > 0 {
+		names = d.started
+	}
+
 	plugins := make([]plugin.Plugin, 0, len(d.plugins))
-	for _, p := range d.plugins {
-		plugins = append(plugins, p)
+	for _, name := range names {
+		if p, ok := d.plugins[name]; ok {
+			plugins = append(plugins, p)
+		}
 	}
 	return plugins
 }
 
+// unstartedDependency returns the first dependency of p that is not running, or ""
+// Caller must hold d.mu
+func (d *Daemon) unstartedDependency(p plugin.Plugin) string {
+	for _, dep := range pluginDependencies(p) {
+		running := false
+		for _, name := range d.started {
+			if name == dep {
+				running = true
+				break
+			}
+		}
+		if !running {
+			return dep
+		}
+	}
+	return ""
+}
+
 // ExecuteTask executes a task using the registered executor
+// The task starts at once if it can, and otherwise waits in the queue. It runs
+// in the background under the daemon's context rather than the caller's, so
+// it outlives the request and always sees the daemon values
 func (d *Daemon) ExecuteTask(ctx context.Context, task *plugin.Task) error {
+	// Replace an input_file reference with the file's contents, before
+	// taking d.mu since the read can block
+	if contents, ok, err := readInputFile(task, d.taskConfig()); err != nil {
+		return err
+	} else if ok {
+		task.Input = contents
+		delete(task.Options, plugin.OptionInputFile)
+	}
+
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if d.state != StateIdle {
-		return fmt.Errorf("daemon is not idle (current state: %s)", d.state)
+	if _, err := d.admitTask(task); err != nil {
+		return err
 	}
 
-	if d.executor == nil {
-		return fmt.Errorf("no executor available")
+	// Trace the task under the submitter's correlation id, or its own ID
+	if task.CorrelationID == "" {
+		task.CorrelationID = plugin.CorrelationID(ctx)
+	}
+	if task.CorrelationID == "" {
+		task.CorrelationID = task.ID
 	}
 
-	d.currentTask = task
-	d.state = StateWorking
+	// Address the task's messages to whoever submitted it
+	if task.ReplyTo == "" {
+		if principal, ok := plugin.PrincipalFromContext(ctx); ok {
+			task.ReplyTo = principal.Source
+		}
+	}
+
+	d.observers.track(task)
+
+	if idle := d.startable(task); idle != nil {
+		d.startTask(task, idle)
+		return nil
+	}
+
+	d.queue = append(d.queue, task)
+	plugin.Logf(plugin.WithCorrelationID(d.ctx, task.CorrelationID), "[Daemon] Queued task: %s (ID: %s, %d waiting)",
+		task.Type, task.ID, len(d.queue))
+	return nil
+}
+
+// startTask runs a task in the background on one of the idle executors that
+// can handle it. Caller must hold d.mu
+func (d *Daemon) startTask(task *plugin.Task, idle []plugin.Executor) {
+	executor := d.picker.pick(task.Type, idle)
+	d.picker.acquire(executor)
+	tasks := d.config.Daemon.Tasks
+	retries := taskRetries{
+		any:       d.config.Daemon.TaskMaxRetries,
+		retryable: tasks.MaxRetries,
+		delay:     time.Duration(tasks.RetryDelay) * time.Second,
+	}
+	runCtx := plugin.WithCorrelationID(d.ctx, task.CorrelationID)
+
+	// Pin the executor's settings now, so a reload while the task runs (or
+	// between retries) doesn't change them
+	if preparer, ok := executor.(plugin.TaskPreparer); ok {
+		preparer.PrepareTask(task)
+	}
 
-	log.Printf("[Daemon] Executing task: %s (ID: %s)", task.Type, task.ID)
+	plugin.Logf(runCtx, "[Daemon] Executing task: %s (ID: %s)", task.Type, task.ID)
 
 	// Execute in background
+	taskCtx, cancelTask := context.WithCancel(runCtx)
+	if tasks.DefaultTimeout > 0 {
+		taskCtx, cancelTask = context.WithTimeout(runCtx, time.Duration(tasks.DefaultTimeout)*time.Second)
+	}
+	rt := &runningTask{
+		task:     task,
+		executor: executor,
+		cancel:   cancelTask,
+		done:     make(chan struct{}),
+	}
+	d.running = append(d.running, rt)
+	d.state = StateWorking
+
+	startedAt := d.clock.Now()
 	d.wg.Add(1)
 	go func() {
 		defer d.wg.Done()
+		defer close(rt.done)
+		defer cancelTask()
 
-		if err := d.executor.ExecuteTask(ctx, task); err != nil {
-			log.Printf("[Daemon] Task execution failed: %v", err)
-			// Publish error message
-			d.broker.Publish(ctx, plugin.Message{
-				Topic:   "notification",
-				Payload: fmt.Sprintf("Task failed: %v", err),
-				Source:  "daemon",
-			})
-		} else {
-			log.Printf("[Daemon] Task completed successfully")
-			// Publish completion message
-			d.broker.Publish(ctx, plugin.Message{
-				Topic:   "notification",
-				Payload: "Task completed successfully",
-				Source:  "daemon",
-			})
+		d.publishTaskEvent(runCtx, plugin.TopicTaskStarted, task, fmt.Sprintf("Started task: %s", task.Type))
+
+		output, attempts, err := d.runWithRetries(taskCtx, runCtx, executor, task, retries)
+
+		result := &plugin.TaskResult{
+			ID:       task.ID,
+			Type:     task.Type,
+			Output:   output,
+			Duration: d.clock.Now().Sub(startedAt),
+			Attempts: attempts,
+			Tags:     task.Tags,
 		}
+		if _, binary := output.([]byte); binary {
+			result.OutputEncoding = plugin.EncodingBase64
+		}
+		if err != nil {
+			result.Error = err.Error()
+			result.ErrorDetail, _ = plugin.AsTaskError(err)
+		}
+		d.results.add(result)
+
+		// Keep tasks that failed every attempt for inspection; cancelled
+		// tasks didn't fail
+		if err != nil && !errors.Is(taskCtx.Err(), context.Canceled) {
+			d.deadLetters.add(task, result, d.clock.Now())
+			plugin.Logf(runCtx, "[Daemon] Task %s dead-lettered after %d attempt(s)", task.ID, attempts)
+		}
+
+		d.publishOutcome(runCtx, task, result, err)
 
-		// Reset state
+		// Free the slot and the executor and start whatever was waiting for
+		// them. A reset or cancel already removed the task from d.running
 		d.mu.Lock()
-		d.state = StateIdle
-		d.currentTask = nil
+		d.picker.release(executor)
+		d.removeRunning(rt)
+		d.startQueued()
 		d.mu.Unlock()
 	}()
+}
 
-	return nil
+// publishOutcome reports a finished task: a notification on failure or a
+// response on success, unless the submitter awaits the task, then the
+// lifecycle event. It publishes under the daemon context, since the task
+// context may already be cancelled
+func (d *Daemon) publishOutcome(runCtx context.Context, task *plugin.Task, result *plugin.TaskResult, err error) {
+	// An awaited task's submitter shows the result from the lifecycle event
+	if err != nil {
+		plugin.Logf(runCtx, "[Daemon] Task execution failed: %v", err)
+		// Publish error message
+		metadata := map[string]interface{}{
+			"task_id":                    task.ID,
+			"error":                      err.Error(),
+			plugin.MetadataCorrelationID: task.CorrelationID,
+			plugin.MetadataReplyTo:       task.ReplyTo,
+			plugin.MetadataSeverity:      string(plugin.SeverityError),
+		}
+		if taskErr := result.ErrorDetail; taskErr != nil {
+			metadata["error_code"] = taskErr.Code
+			metadata["retryable"] = taskErr.Retryable
+		}
+		if !task.Awaited {
+			d.broker.Publish(runCtx, plugin.Message{
+				Topic:       "notification",
+				Payload:     fmt.Sprintf("Task failed: %v", err),
+				Source:      "daemon",
+				Metadata:    metadata,
+				OrderingKey: task.OrderingKey,
+			})
+		}
+	} else {
+		plugin.Logf(runCtx, "[Daemon] Task completed successfully")
+		// Publish structured result
+		if !task.Awaited {
+			d.broker.Publish(runCtx, plugin.Message{
+				Topic:   "response",
+				Payload: result,
+				Source:  "daemon",
+				Metadata: map[string]interface{}{
+					"task_id":                    task.ID,
+					plugin.MetadataCorrelationID: task.CorrelationID,
+					plugin.MetadataReplyTo:       task.ReplyTo,
+					plugin.MetadataSeverity:      string(plugin.SeveritySuccess),
+				},
+				OrderingKey: task.OrderingKey,
+			})
+		}
+	}
+
+	if err != nil {
+		d.publishTaskEvent(runCtx, plugin.TopicTaskFailed, task, result)
+	} else {
+		d.publishTaskEvent(runCtx, plugin.TopicTaskCompleted, task, result)
+	}
+}
+
+// taskRetries is how often a failed task is run again
+type taskRetries struct {
+	any       int           // daemon.task_max_retries, for any error
+	retryable int           // tasks.max_retries, for a retryable plugin.TaskError
+	delay     time.Duration // tasks.retry_delay
+}
+
+// runWithRetries runs a task, running it again after a failure up to
+// task_max_retries times, or tasks.max_retries times for a retryable
+// plugin.TaskError if that is more. It returns the last attempt's output, how
+// many attempts were made and the last error
+func (d *Daemon) runWithRetries(taskCtx, runCtx context.Context, executor plugin.Executor, task *plugin.Task, policy taskRetries) (interface{}, int, error) {
+	delay := policy.delay
+	for attempt := 1; ; attempt++ {
+		output, err := executor.ExecuteTask(taskCtx, task)
+		if err == nil || taskCtx.Err() != nil {
+			return output, attempt, err
+		}
+		retries := policy.any
+		if plugin.IsRetryable(err) {
+			retries = max(retries, policy.retryable)
+		}
+		if attempt > retries {
+			return output, attempt, err
+		}
+
+		plugin.Logf(runCtx, "[Daemon] Task failed (attempt %d of %d), retrying in %s: %v",
+			attempt, retries+1, delay, err)
+		d.broker.Publish(runCtx, plugin.Message{
+			Topic:   plugin.TopicTaskRetrying,
+			Payload: fmt.Sprintf("Retrying task %s after error: %v", task.Type, err),
+			Source:  "daemon",
+			Metadata: map[string]interface{}{
+				"task_id":                    task.ID,
+				"type":                       task.Type,
+				"attempt":                    attempt + 1,
+				"error":                      err.Error(),
+				plugin.MetadataCorrelationID: task.CorrelationID,
+				plugin.MetadataReplyTo:       task.ReplyTo,
+				plugin.MetadataSeverity:      string(plugin.SeverityWarn),
+			},
+			OrderingKey: task.OrderingKey,
+		})
+
+		select {
+		case <-d.clock.After(delay):
+		case <-taskCtx.Done():
+			return output, attempt, err
+		}
+	}
+}
+
+// publishTaskEvent publishes a task lifecycle message
+func (d *Daemon) publishTaskEvent(ctx context.Context, topic string, task *plugin.Task, payload interface{}) {
+	severity := plugin.SeverityInfo
+	switch topic {
+	case plugin.TopicTaskCompleted:
+		severity = plugin.SeveritySuccess
+	case plugin.TopicTaskFailed:
+		severity = plugin.SeverityError
+	}
+
+	d.broker.Publish(ctx, plugin.Message{
+		Topic:   topic,
+		Payload: payload,
+		Source:  "daemon",
+		Metadata: map[string]interface{}{
+			"task_id":                    task.ID,
+			"type":                       task.Type,
+			plugin.MetadataCorrelationID: task.CorrelationID,
+			plugin.MetadataReplyTo:       task.ReplyTo,
+			plugin.MetadataSeverity:      string(severity),
+		},
+		OrderingKey: task.OrderingKey,
+	})
+}
+
+// PreviewTask reports whether ExecuteTask would accept the task, without running it
+func (d *Daemon) PreviewTask(task *plugin.Task) error {
+	if _, _, err := readInputFile(task, d.taskConfig()); err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, err := d.admitTask(task)
+	return err
+}
+
+// taskConfig returns a copy of the task settings, which a reload may change
+func (d *Daemon) taskConfig() config.TaskConfig {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.config.Daemon.Tasks
+}
+
+// admitTask checks that a task can run now and returns the executors that
+// can handle it. Caller must hold d.mu
+func (d *Daemon) admitTask(task *plugin.Task) ([]plugin.Executor, error) {
+	// Stop clears ready before it releases d.mu to drain, so nothing starts
+	// once shutdown has begun
+	if !d.ready.Load() {
+		return nil, fmt.Errorf("daemon is not running")
+	}
+	if d.state != StateIdle && d.state != StateWorking {
+		return nil, fmt.Errorf("daemon is not running (current state: %s)", d.state)
+	}
+
+	if limit := d.config.Daemon.Tasks.MaxInputBytes; limit > 0 {
+		if size := inputSize(task.Input); size > limit {
+			return nil, fmt.Errorf("task input is %d bytes, limit is %d", size, limit)
+		}
+	}
+
+	candidates := d.executorsFor(task.Type)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no executor available for task type: %s", task.Type)
+	}
+
+	// A task that can't start now waits in the queue, if there is room
+	if tasks := d.config.Daemon.Tasks; d.startable(task) == nil && len(d.queue) >= tasks.QueueSize {
+		return nil, fmt.Errorf("daemon is busy (%d running, %d of %d queued)", len(d.running), len(d.queue), tasks.QueueSize)
+	}
+
+	return candidates, nil
+}
+
+// inputSize returns the size of a task input in bytes, JSON-encoding
+// anything that isn't already a string or byte slice
+func inputSize(input interface{}) int {
+	switch v := input.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
+// executorsFor returns the registered executors that can handle the task
+// type, in registration order. Caller must hold d.mu
+func (d *Daemon) executorsFor(taskType string) []plugin.Executor {
+	var candidates []plugin.Executor
+	for _, executor := range d.executors {
+		if executor.CanHandle(taskType) {
+			candidates = append(candidates, executor)
+		}
+	}
+	return candidates
+}
+
+// GetExecutor returns the registered executor with the given name
+func (d *Daemon) GetExecutor(name string) (plugin.Executor, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, executor := range d.executors {
+		if executor.Name() == name {
+			return executor, true
+		}
+	}
+	return nil, false
 }
@@ -0,0 +1,373 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// testTimeout bounds every wait in the tests
+const testTimeout = 5 * time.Second
+
+// executorPlugin is a plugin providing a fake executor
+type executorPlugin struct {
+	name     string
+	executor *testutil.Executor
+}
+
+func (p *executorPlugin) Name() string                                { return p.name }
+func (p *executorPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *executorPlugin) Extensions() []plugin.Extension              { return []plugin.Extension{p.executor} }
+func (p *executorPlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *executorPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	return nil
+}
+
+// startDaemon starts a daemon with a plugin for each fake executor and stops
+// it when the test ends
+func startDaemon(t *testing.T, cfg *config.Config, executors ...*testutil.Executor) *Daemon {
+	t.Helper()
+
+	d := New(cfg)
+	for i, executor := range executors {
+		p := &executorPlugin{name: "fake" + string(rune('a'+i)), executor: executor}
+		cfg.Plugins[p.name] = config.PluginConfig{Enabled: true}
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { d.Stop() })
+	return d
+}
+
+// gate holds tasks in a fake executor until the test releases them
+type gate struct {
+	started chan string
+	release chan struct{}
+}
+
+// newGatedExecutor returns a fake executor for taskType whose tasks report
+// their ID on started and then wait for a value on release
+func newGatedExecutor(g *gate, taskType string) *testutil.Executor {
+	executor := testutil.NewExecutor(taskType)
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		g.started <- task.ID
+		select {
+		case <-g.release:
+			return task.ID, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return executor
+}
+
+func newGate() *gate {
+	return &gate{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+// next returns the ID of the next task to start
+func (g *gate) next(t *testing.T) string {
+	t.Helper()
+
+	select {
+	case id := <-g.started:
+		return id
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for a task to start")
+		return ""
+	}
+}
+
+// idle asserts that no task starts for a short while
+func (g *gate) idle(t *testing.T) {
+	t.Helper()
+
+	select {
+	case id := <-g.started:
+		t.Fatalf("task %s started, want none", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// submit runs ExecuteTask for a new task and fails the test on error
+func submit(t *testing.T, d *Daemon, id, taskType string) {
+	t.Helper()
+
+	if err := d.ExecuteTask(context.Background(), &plugin.Task{ID: id, Type: taskType}); err != nil {
+		t.Fatalf("ExecuteTask(%s): %v", id, err)
+	}
+}
+
+func TestStopRefusesTasksWhileDraining(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.DrainOnShutdown = true
+	cfg.Daemon.Tasks.MaxConcurrent = 2
+
+	g := newGate()
+	first, second := newGatedExecutor(g, "work"), newGatedExecutor(g, "work")
+	d := startDaemon(t, cfg, first, second)
+
+	submit(t, d, "first", "work")
+	g.next(t)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- d.Stop() }()
+	waitFor(t, "Stop to begin draining", func() bool { return !d.Ready() })
+
+	// A slot and an executor are free, but the daemon is shutting down
+	if err := d.ExecuteTask(context.Background(), &plugin.Task{ID: "second", Type: "work"}); err == nil {
+		t.Error("ExecuteTask during shutdown succeeded, want an error")
+	}
+	if err := d.PreviewTask(&plugin.Task{ID: "third", Type: "work"}); err == nil {
+		t.Error("PreviewTask during shutdown succeeded, want an error")
+	}
+
+	close(g.release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := len(first.Executed()) + len(second.Executed()); got != 1 {
+		t.Errorf("executed %d tasks, want 1", got)
+	}
+}
+
+func TestTaskContextCarriesDaemonValues(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Mode = plugin.ModeDaemon
+
+	got := make(chan context.Context, 1)
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		got <- ctx
+		return nil, nil
+	}
+	d := startDaemon(t, cfg, executor)
+
+	submit(t, d, "t1", "work")
+	var ctx context.Context
+	select {
+	case ctx = <-got:
+	case <-time.After(testTimeout):
+		t.Fatal("task did not run")
+	}
+
+	if ctx.Value("daemon") != d {
+		t.Errorf("daemon = %v, want the daemon", ctx.Value("daemon"))
+	}
+	if ctx.Value("config") != cfg {
+		t.Errorf("config = %v, want the daemon's config", ctx.Value("config"))
+	}
+	if mode := ctx.Value("mode"); mode != plugin.ModeDaemon {
+		t.Errorf("mode = %v, want %s", mode, plugin.ModeDaemon)
+	}
+	if _, ok := ctx.Value("broker").(plugin.MessageBroker); !ok {
+		t.Errorf("broker = %T, want a plugin.MessageBroker", ctx.Value("broker"))
+	}
+}
+
+// labeledPlugin is a plugin in the given label groups
+type labeledPlugin struct {
+	executorPlugin
+	labels []string
+}
+
+func (p *labeledPlugin) Labels() []string { return p.labels }
+
+func TestEnabledLabelsSelectPlugins(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.EnableLabels = []string{"api"}
+	cfg.Plugins["tui"] = config.PluginConfig{Enabled: true}
+	d := New(cfg)
+
+	for _, p := range []*labeledPlugin{
+		{executorPlugin{name: "rest"}, []string{"api"}},
+		{executorPlugin{name: "websocket"}, []string{"api", "ui"}},
+		{executorPlugin{name: "telegram"}, []string{"chat"}},
+		{executorPlugin{name: "tui"}, []string{"ui"}},
+		{executorPlugin{name: "echo"}, nil},
+	} {
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin(%s): %v", p.name, err)
+		}
+	}
+
+	if got, want := fmt.Sprint(d.order), "[rest websocket tui]"; got != want {
+		t.Errorf("added plugins = %s, want %s", got, want)
+	}
+}
+
+func TestTaskFinishingAfterResetLeavesNextTaskRunning(t *testing.T) {
+	// The first task ignores cancellation, so it finishes after the reset
+	straggler := make(chan struct{})
+	g := newGate()
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		g.started <- task.ID
+		if task.ID == "first" {
+			<-straggler
+			return "late", nil
+		}
+		select {
+		case <-g.release:
+			return task.ID, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.QueueSize = 1
+	d := startDaemon(t, cfg, executor)
+	finish := sync.OnceFunc(func() { close(straggler) })
+	t.Cleanup(finish)
+	done := testutil.Collect(d.broker, "test", plugin.TopicTaskCompleted, plugin.TopicTaskFailed)
+
+	submit(t, d, "first", "work")
+	g.next(t)
+	if err := d.Reset(context.Background()); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if state := d.GetState(); state != StateIdle {
+		t.Fatalf("state after reset = %s, want idle", state)
+	}
+
+	submit(t, d, "second", "work")
+	finish()
+	if id := g.next(t); id != "second" {
+		t.Fatalf("started %s, want second", id)
+	}
+	done.WaitFor(1, testTimeout)
+
+	// The straggler finishing must not mark the daemon idle under the second task
+	if state := d.GetState(); state != StateWorking {
+		t.Errorf("state after the reset task finished = %s, want working", state)
+	}
+	d.mu.RLock()
+	running := len(d.running)
+	d.mu.RUnlock()
+	if running != 1 {
+		t.Errorf("%d tasks running, want the second", running)
+	}
+
+	g.release <- struct{}{}
+	waitFor(t, "the daemon to go idle", func() bool { return d.GetState() == StateIdle })
+}
+
+func TestResetRacingCompletionSettlesIdle(t *testing.T) {
+	const rounds = 50
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.QueueSize = rounds
+	d := startDaemon(t, cfg, testutil.NewExecutor("work"))
+	done := testutil.Collect(d.broker, "test", plugin.TopicTaskCompleted, plugin.TopicTaskFailed)
+
+	for i := 0; i < rounds; i++ {
+		submit(t, d, fmt.Sprintf("task-%d", i), "work")
+		d.Reset(context.Background()) // fails if the task already finished
+	}
+	done.WaitFor(rounds, testTimeout)
+	waitFor(t, "the daemon to go idle", func() bool { return d.GetState() == StateIdle })
+
+	d.mu.RLock()
+	running, queued := len(d.running), len(d.queue)
+	d.mu.RUnlock()
+	if running != 0 || queued != 0 {
+		t.Errorf("%d running and %d queued after settling, want none", running, queued)
+	}
+
+	// Every task finished exactly once
+	seen := make(map[string]int)
+	for _, msg := range done.Messages() {
+		id, _ := msg.Metadata["task_id"].(string)
+		seen[id]++
+	}
+	for i := 0; i < rounds; i++ {
+		if n := seen[fmt.Sprintf("task-%d", i)]; n != 1 {
+			t.Errorf("task-%d finished %d times, want once", i, n)
+		}
+	}
+}
+
+func TestRequestRestartStopsThenFlagsRestart(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig())
+	if d.RestartRequested() {
+		t.Fatal("restart requested before asking")
+	}
+
+	d.RequestRestart("/reexec")
+	select {
+	case <-d.ShutdownRequested():
+	case <-time.After(testTimeout):
+		t.Fatal("restart did not request a shutdown")
+	}
+	if !d.RestartRequested() {
+		t.Error("restart not flagged")
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if state := d.GetState(); state != StateStopped {
+		t.Errorf("state = %s, want stopped before re-executing", state)
+	}
+}
+
+// featurePlugin records whether its gated path ran at Start
+type featurePlugin struct {
+	executorPlugin
+	gated bool
+}
+
+func (p *featurePlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	if plugin.HasFeature(ctx, "streaming") {
+		p.gated = true
+	}
+	return nil
+}
+
+func TestFeatureGatedPathOnlyForListedPlugins(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Plugins["streamer"] = config.PluginConfig{Enabled: true, Features: []string{"tools", "streaming"}}
+	cfg.Plugins["plain"] = config.PluginConfig{Enabled: true, Features: []string{"tools"}}
+	d := New(cfg)
+
+	streamer := &featurePlugin{executorPlugin: executorPlugin{name: "streamer"}}
+	plain := &featurePlugin{executorPlugin: executorPlugin{name: "plain"}}
+	for _, p := range []*featurePlugin{streamer, plain} {
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	if !streamer.gated {
+		t.Error("streaming path skipped for the plugin listing the feature")
+	}
+	if plain.gated {
+		t.Error("streaming path ran for a plugin not listing the feature")
+	}
+}
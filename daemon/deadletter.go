@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// maxDeadLetters is how many dead-lettered tasks are kept
+const maxDeadLetters = 100
+
+// deadLetters keeps the most recent tasks that failed every attempt
+type deadLetters struct {
+	mu      sync.RWMutex
+	entries []*plugin.DeadLetter
+	size    int
+}
+
+// newDeadLetters creates a store holding at most size tasks
+func newDeadLetters(size int) *deadLetters {
+	return &deadLetters{size: size}
+}
+
+// add records a failed task and its last result, evicting the oldest if full
+func (s *deadLetters) add(task *plugin.Task, result *plugin.TaskResult, failedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, &plugin.DeadLetter{
+		ID:            task.ID,
+		Type:          task.Type,
+		Input:         task.Input,
+		Options:       maps.Clone(task.Options),
+		Tags:          maps.Clone(task.Tags),
+		CorrelationID: task.CorrelationID,
+		Error:         result.Error,
+		ErrorDetail:   result.ErrorDetail,
+		Attempts:      result.Attempts,
+		FailedAt:      failedAt,
+	})
+	if len(s.entries) > s.size {
+		s.entries = s.entries[len(s.entries)-s.size:]
+	}
+}
+
+// list returns copies of the stored tasks, newest first
+func (s *deadLetters) list() []*plugin.DeadLetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]*plugin.DeadLetter, 0, len(s.entries))
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		copied := *s.entries[i]
+		entries = append(entries, &copied)
+	}
+	return entries
+}
+
+// DeadLetters returns the recent tasks that failed every attempt, newest first
+func (d *Daemon) DeadLetters() []*plugin.DeadLetter {
+	return d.deadLetters.list()
+}
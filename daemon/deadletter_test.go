@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// newFailingExecutor returns a fake executor whose tasks always fail with err
+func newFailingExecutor(err error) *testutil.Executor {
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		return nil, err
+	}
+	return executor
+}
+
+func TestFailingTaskIsRetriedThenDeadLettered(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.TaskMaxRetries = 2
+
+	executor := newFailingExecutor(errors.New("disk full"))
+	d := startDaemon(t, cfg, executor)
+
+	task := &plugin.Task{ID: "t1", Type: "work", Input: "payload", Tags: map[string]string{"job": "backup"}}
+	if err := d.ExecuteTask(context.Background(), task); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	waitFor(t, "the task to be dead-lettered", func() bool { return len(d.DeadLetters()) > 0 })
+
+	if got := len(executor.Executed()); got != 3 {
+		t.Errorf("executed %d times, want 3", got)
+	}
+	letters := d.DeadLetters()
+	if len(letters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(letters))
+	}
+	letter := letters[0]
+	if letter.ID != "t1" || letter.Error != "disk full" || letter.Attempts != 3 {
+		t.Errorf("dead letter = %+v, want t1 failing with disk full after 3 attempts", letter)
+	}
+	if letter.Input != "payload" || letter.Tags["job"] != "backup" {
+		t.Errorf("dead letter input %v, tags %v, want the submitted ones", letter.Input, letter.Tags)
+	}
+}
+
+func TestRetryableErrorUsesLargerRetryCount(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"plain error", errors.New("boom"), 2},
+		{"retryable error", plugin.NewTaskError("rate_limited", "slow down", true), 4},
+		{"permanent task error", plugin.NewTaskError("bad_input", "no", false), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Daemon.TaskMaxRetries = 1
+			cfg.Daemon.Tasks.MaxRetries = 3
+
+			executor := newFailingExecutor(tt.err)
+			d := startDaemon(t, cfg, executor)
+
+			submit(t, d, "t1", "work")
+			waitFor(t, "the task to be dead-lettered", func() bool { return len(d.DeadLetters()) > 0 })
+			if got := len(executor.Executed()); got != tt.want {
+				t.Errorf("executed %d times, want %d", got, tt.want)
+			}
+			if got := d.DeadLetters()[0].Attempts; got != tt.want {
+				t.Errorf("dead letter attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSucceedingRetryIsNotDeadLettered(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.TaskMaxRetries = 2
+
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		if len(executor.Executed()) == 1 {
+			return nil, errors.New("flaky")
+		}
+		return "done", nil
+	}
+	d := startDaemon(t, cfg, executor)
+
+	submit(t, d, "t1", "work")
+	waitFor(t, "the task to finish", func() bool { return len(d.TaskResults()) > 0 })
+
+	result := d.TaskResults()[0]
+	if result.Error != "" || result.Attempts != 2 {
+		t.Errorf("result = %+v, want success on attempt 2", result)
+	}
+	if got := len(d.DeadLetters()); got != 0 {
+		t.Errorf("%d dead letters, want 0", got)
+	}
+}
@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+
+	"bicycle/plugin"
+)
+
+// pluginDependencies returns the declared dependencies of a plugin, if any
+func pluginDependencies(p plugin.Plugin) []string {
+	if dep, ok := p.(plugin.Dependent); ok {
+		return dep.Dependencies()
+	}
+	return nil
+}
+
+// DependencyGraph returns the plugins' dependencies, their resolved start
+// order and any missing or cyclic dependencies
+// After Start it describes the plugins as they were when the daemon started
+func (d *Daemon) DependencyGraph() plugin.DependencyGraph {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	deps := d.deps
+	if deps == nil {
+		deps = d.pluginDependencyMap()
+	}
+	order, problems := resolveStartOrder(d.order, deps)
+
+	running := make(map[string]bool, len(d.started))
+	for _, name := range d.started {
+		running[name] = true
+	}
+
+	graph := plugin.DependencyGraph{StartOrder: order}
+	for _, name := range d.order {
+		node := plugin.PluginDependencies{
+			Name:      name,
+			DependsOn: deps[name],
+			Running:   running[name],
+		}
+		if err := problems[name]; err != nil {
+			node.Problem = err.Error()
+		}
+		graph.Plugins = append(graph.Plugins, node)
+	}
+	return graph
+}
+
+// pluginDependencyMap returns the declared dependencies of each added plugin
+// Caller must hold d.mu
+func (d *Daemon) pluginDependencyMap() map[string][]string {
+	deps := make(map[string][]string, len(d.plugins))
+	for name, p := range d.plugins {
+		deps[name] = pluginDependencies(p)
+	}
+	return deps
+}
+
+// resolveStartOrder orders plugin names so every plugin comes after its dependencies
+// Plugins keep their relative order in names where dependencies allow
+// Plugins with missing or cyclic dependencies are left out and reported in problems
+func resolveStartOrder(names []string, deps map[string][]string) (order []string, problems map[string]error) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	known := make(map[string]bool, len(names))
+	for _, name := range names {
+		known[name] = true
+	}
+
+	state := make(map[string]int, len(names))
+	problems = make(map[string]error)
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		switch state[name] {
+		case done:
+			return
+		case visiting:
+			// Report the cycle from where it starts on the stack
+			start := 0
+			for i, n := range stack {
+				if n == name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, stack[start:]...), name)
+			problems[name] = fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+			return
+		}
+
+		state[name] = visiting
+		stack = append(stack, name)
+
+		for _, dep := range deps[name] {
+			if !known[dep] {
+				problems[name] = fmt.Errorf("missing dependency: %s", dep)
+				continue
+			}
+			visit(dep)
+			if _, failed := problems[dep]; failed && problems[name] == nil {
+				problems[name] = fmt.Errorf("dependency %s unavailable", dep)
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = done
+
+		if problems[name] == nil {
+			order = append(order, name)
+		}
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+
+	return order, problems
+}
@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// lifecycleLog records plugin starts and stops in order
+type lifecycleLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *lifecycleLog) record(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *lifecycleLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.events)
+}
+
+// dependentPlugin records its lifecycle and depends on deps
+type dependentPlugin struct {
+	name string
+	deps []string
+	log  *lifecycleLog
+}
+
+func (p *dependentPlugin) Name() string                                { return p.name }
+func (p *dependentPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *dependentPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *dependentPlugin) Dependencies() []string                      { return p.deps }
+
+func (p *dependentPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	p.log.record("start " + p.name)
+	return nil
+}
+
+func (p *dependentPlugin) Stop(ctx context.Context) error {
+	p.log.record("stop " + p.name)
+	return nil
+}
+
+func TestPluginsStopInReverseStartOrder(t *testing.T) {
+	events := &lifecycleLog{}
+	cfg := config.DefaultConfig()
+	d := New(cfg)
+
+	// Added dependents first, so only the dependencies decide the order
+	for _, p := range []*dependentPlugin{
+		{name: "app", deps: []string{"cache"}, log: events},
+		{name: "cache", deps: []string{"store"}, log: events},
+		{name: "store", log: events},
+	} {
+		cfg.Plugins[p.name] = config.PluginConfig{Enabled: true}
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	want := "[start store start cache start app stop app stop cache stop store]"
+	if got := events.String(); got != want {
+		t.Errorf("lifecycle = %s, want %s", got, want)
+	}
+}
+
+func TestDependencyGraphOrderAndProblems(t *testing.T) {
+	cfg := config.DefaultConfig()
+	d := New(cfg)
+	for _, p := range []*dependentPlugin{
+		{name: "app", deps: []string{"cache", "store"}},
+		{name: "cache", deps: []string{"store"}},
+		{name: "store"},
+		{name: "ping", deps: []string{"pong"}},
+		{name: "pong", deps: []string{"ping"}},
+		{name: "orphan", deps: []string{"ghost"}},
+	} {
+		p.log = &lifecycleLog{}
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+
+	graph := d.DependencyGraph()
+	if got, want := fmt.Sprint(graph.StartOrder), "[store cache app]"; got != want {
+		t.Errorf("start order = %s, want %s", got, want)
+	}
+
+	problems := make(map[string]string)
+	for _, node := range graph.Plugins {
+		problems[node.Name] = node.Problem
+	}
+	for name, want := range map[string]string{
+		"app":    "",
+		"ping":   "dependency cycle: ping -> pong -> ping",
+		"pong":   "dependency ping unavailable",
+		"orphan": "missing dependency: ghost",
+	} {
+		if problems[name] != want {
+			t.Errorf("%s problem = %q, want %q", name, problems[name], want)
+		}
+	}
+}
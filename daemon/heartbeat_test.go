@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// startHeartbeatDaemon starts a daemon with no plugins on a fake clock,
+// beating every interval seconds, and subscribes to its heartbeats
+func startHeartbeatDaemon(t *testing.T, interval int) (*Daemon, *clock.Fake, <-chan plugin.Message) {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.HeartbeatInterval = interval
+	fake := clock.NewFake(time.Unix(0, 0))
+	d := NewWithClock(cfg, fake)
+	heartbeats := d.broker.Subscribe("test.heartbeat", 10, TopicHeartbeat)
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { d.Stop() })
+	return d, fake, heartbeats
+}
+
+// expectNoHeartbeat fails if a heartbeat arrives shortly
+func expectNoHeartbeat(t *testing.T, heartbeats <-chan plugin.Message, when string) {
+	t.Helper()
+	select {
+	case msg, ok := <-heartbeats:
+		if ok {
+			t.Fatalf("heartbeat %v published %s", msg.Payload, when)
+		}
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestHeartbeatPublishedEveryInterval(t *testing.T) {
+	d, fake, heartbeats := startHeartbeatDaemon(t, 60)
+
+	for beat := 1; beat <= 2; beat++ {
+		// Each heartbeat's publish also waits on the clock for its timeout
+		waitForWaiters(t, fake, beat)
+		fake.Advance(59 * time.Second)
+		expectNoHeartbeat(t, heartbeats, "before the interval")
+
+		fake.Advance(time.Second)
+		select {
+		case msg := <-heartbeats:
+			if _, ok := msg.Payload.(*StatusSnapshot); !ok || msg.Source != "daemon" {
+				t.Errorf("heartbeat %d = %+v, want a status snapshot from the daemon", beat, msg)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("heartbeat %d not published after the interval", beat)
+		}
+	}
+
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	fake.Advance(time.Hour)
+	expectNoHeartbeat(t, heartbeats, "after Stop")
+}
+
+func TestZeroHeartbeatIntervalDisablesHeartbeat(t *testing.T) {
+	_, fake, heartbeats := startHeartbeatDaemon(t, 0)
+
+	if n := fake.Waiters(); n != 0 {
+		t.Errorf("%d goroutine(s) waiting on the clock, want none", n)
+	}
+	fake.Advance(time.Hour)
+	expectNoHeartbeat(t, heartbeats, "with the heartbeat disabled")
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// defaultInputFileMaxBytes caps input files when no limit is configured
+const defaultInputFileMaxBytes = 1 << 20
+
+// readInputFile returns the contents of the task's input_file option, or
+// ok=false when the task has none
+// The path is resolved against the configured input root and must stay
+// inside it after following symlinks
+// Opening a file can block (a FIFO, a hung network mount), so callers must
+// not hold d.mu; tasks is a copy of the task settings taken under it
+func readInputFile(task *plugin.Task, tasks config.TaskConfig) (contents string, ok bool, err error) {
+	value, ok := task.Options[plugin.OptionInputFile]
+	if !ok {
+		return "", false, nil
+	}
+
+	name, isString := value.(string)
+	if !isString || name == "" {
+		return "", true, fmt.Errorf("%s must be a non-empty string", plugin.OptionInputFile)
+	}
+	if task.Input != nil && task.Input != "" {
+		return "", true, fmt.Errorf("task has both input and %s", plugin.OptionInputFile)
+	}
+
+	if tasks.InputRoot == "" {
+		return "", true, fmt.Errorf("%s is disabled (no input root configured)", plugin.OptionInputFile)
+	}
+
+	path, err := resolveInputPath(tasks.InputRoot, name)
+	if err != nil {
+		return "", true, err
+	}
+
+	limit := tasks.MaxInputBytes
+	if limit == 0 {
+		limit = defaultInputFileMaxBytes
+	}
+
+	// Reject FIFOs and devices before opening them, since opening one may
+	// never return
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", true, fmt.Errorf("input file %s is not a regular file", name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	// Check again in case the path was replaced since the Stat
+	info, err = f.Stat()
+	if err != nil {
+		return "", true, fmt.Errorf("failed to stat input file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", true, fmt.Errorf("input file %s is not a regular file", name)
+	}
+
+	// Read one byte past the limit so files that grew since Stat are caught too
+	data, err := io.ReadAll(io.LimitReader(f, int64(limit)+1))
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read input file: %w", err)
+	}
+	if len(data) > limit {
+		return "", true, fmt.Errorf("input file %s exceeds limit of %d bytes", name, limit)
+	}
+
+	return string(data), true, nil
+}
+
+// resolveInputPath joins name onto root and rejects results that escape it
+func resolveInputPath(root, name string) (string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid input root: %w", err)
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid input root: %w", err)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("input file %s not found", name)
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("input file %s is outside the input root", name)
+	}
+
+	return path, nil
+}
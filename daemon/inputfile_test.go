@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// inputFileTask returns a task reading its input from name
+func inputFileTask(name string) *plugin.Task {
+	return &plugin.Task{ID: "t1", Type: "work", Options: map[string]interface{}{plugin.OptionInputFile: name}}
+}
+
+// writeFile writes contents to path, failing the test on error
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadInputFile(t *testing.T) {
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(outside, "secret.txt"), "secret")
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "prompt.txt"), "summarize this")
+	writeFile(t, filepath.Join(root, "big.txt"), strings.Repeat("x", 17))
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	tasks := config.TaskConfig{InputRoot: root, MaxInputBytes: 16}
+
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{"valid file", "prompt.txt", "summarize this", ""},
+		{"too large", "big.txt", "", "exceeds limit of 16 bytes"},
+		{"traversal", "../" + filepath.Base(outside) + "/secret.txt", "", "outside the input root"},
+		{"absolute path outside", filepath.Join(outside, "secret.txt"), "", "outside the input root"},
+		{"symlink out of the root", "link.txt", "", "outside the input root"},
+		{"missing", "nope.txt", "", "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := readInputFile(inputFileTask(tt.file), tasks)
+			if !ok {
+				t.Fatal("input_file option not recognized")
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("readInputFile = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadInputFileDisabledWithoutRoot(t *testing.T) {
+	_, _, err := readInputFile(inputFileTask("prompt.txt"), config.TaskConfig{})
+	if err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("error = %v, want input_file disabled", err)
+	}
+}
+
+func TestTaskInputReadFromFile(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "prompt.txt"), "from the file")
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.InputRoot = root
+	executor := testutil.NewExecutor("work")
+	d := startDaemon(t, cfg, executor)
+
+	if err := d.ExecuteTask(context.Background(), inputFileTask("prompt.txt")); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	waitFor(t, "the task to run", func() bool { return len(executor.Executed()) == 1 })
+	task := executor.Executed()[0]
+	if task.Input != "from the file" {
+		t.Errorf("input = %v, want the file's contents", task.Input)
+	}
+	if _, ok := task.Options[plugin.OptionInputFile]; ok {
+		t.Error("input_file option still set after reading the file")
+	}
+
+	if err := d.ExecuteTask(context.Background(), inputFileTask("../escape.txt")); err == nil {
+		t.Error("ExecuteTask accepted an input file outside the root")
+	}
+}
@@ -0,0 +1,25 @@
+package daemon
+
+import (
+	"log"
+
+	"bicycle/plugin"
+)
+
+// answerPings echoes daemon.ping messages back as daemon.pong so /ping can
+// tell a healthy broker from a dead transport
+func (d *Daemon) answerPings(ch <-chan plugin.Message) {
+	defer d.wg.Done()
+
+	for msg := range ch {
+		id, _ := msg.Metadata[plugin.MetadataCorrelationID].(string)
+		if err := d.broker.Publish(d.ctx, plugin.Message{
+			Topic:    plugin.TopicPong,
+			Payload:  msg.Payload,
+			Source:   "daemon",
+			Metadata: map[string]interface{}{plugin.MetadataCorrelationID: id},
+		}); err != nil {
+			log.Printf("[Daemon] Error answering ping: %v", err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// notificationStateKey is the state manager key the notification log is persisted under
+const notificationStateKey = "daemon.notifications"
+
+// NotificationEntry is a single recorded notification
+type NotificationEntry struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Text   string    `json:"text"`
+}
+
+// String formats the entry as a log line
+func (e NotificationEntry) String() string {
+	return fmt.Sprintf("%s [%s] %s", e.Time.Format("15:04:05"), e.Source, e.Text)
+}
+
+// notificationRing keeps the most recent notifications up to a fixed size
+type notificationRing struct {
+	mu      sync.RWMutex
+	entries []NotificationEntry
+	size    int
+}
+
+// newNotificationRing creates a ring holding at most size entries
+func newNotificationRing(size int) *notificationRing {
+	return &notificationRing{size: size}
+}
+
+// add appends an entry, evicting the oldest if the ring is full
+func (r *notificationRing) add(entry NotificationEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+	if len(r.entries) > r.size {
+		r.entries = r.entries[len(r.entries)-r.size:]
+	}
+}
+
+// last returns up to n of the most recent entries, oldest first
+// n <= 0 returns all entries
+func (r *notificationRing) last(n int) []NotificationEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	start := 0
+	if n > 0 && n < len(r.entries) {
+		start = len(r.entries) - n
+	}
+
+	out := make([]NotificationEntry, len(r.entries)-start)
+	copy(out, r.entries[start:])
+	return out
+}
+
+// restore prepends previously saved entries, keeping the newest within the size bound
+func (r *notificationRing) restore(saved []NotificationEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(append([]NotificationEntry{}, saved...), r.entries...)
+	if len(r.entries) > r.size {
+		r.entries = r.entries[len(r.entries)-r.size:]
+	}
+}
+
+// recordNotifications appends every notification from the channel to the ring
+// until the channel is closed
+func (d *Daemon) recordNotifications(ch <-chan plugin.Message) {
+	defer d.wg.Done()
+
+	for msg := range ch {
+		d.notifications.add(NotificationEntry{
+			Time:   d.clock.Now(),
+			Source: msg.Source,
+			Text:   fmt.Sprintf("%v", msg.Payload),
+		})
+	}
+}
+
+// RecentNotifications returns up to n of the most recent notifications as log lines
+func (d *Daemon) RecentNotifications(n int) []string {
+	entries := d.notifications.last(n)
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.String()
+	}
+	return lines
+}
+
+// saveNotifications persists the notification log to the state manager
+// Caller must hold d.mu
+func (d *Daemon) saveNotifications(ctx context.Context) {
+	if !d.config.Daemon.PersistNotifications || d.stateManager == nil {
+		return
+	}
+
+	data, err := json.Marshal(d.notifications.last(0))
+	if err != nil {
+		log.Printf("[Daemon] Error encoding notification log: %v", err)
+		return
+	}
+
+	if err := d.stateManager.Set(ctx, notificationStateKey, string(data)); err != nil {
+		log.Printf("[Daemon] Error saving notification log: %v", err)
+		return
+	}
+	if err := d.stateManager.Save(ctx); err != nil {
+		log.Printf("[Daemon] Error persisting state: %v", err)
+	}
+}
+
+// loadNotifications restores the notification log from the state manager
+// Caller must hold d.mu
+func (d *Daemon) loadNotifications(ctx context.Context) {
+	if !d.config.Daemon.PersistNotifications || d.stateManager == nil {
+		return
+	}
+
+	val, err := d.stateManager.Get(ctx, notificationStateKey)
+	if err != nil {
+		// Nothing saved yet
+		return
+	}
+
+	data, ok := val.(string)
+	if !ok {
+		log.Printf("[Daemon] Ignoring saved notification log of unexpected type %T", val)
+		return
+	}
+
+	var saved []NotificationEntry
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		log.Printf("[Daemon] Error decoding notification log: %v", err)
+		return
+	}
+
+	d.notifications.restore(saved)
+	log.Printf("[Daemon] Restored %d notification(s)", len(saved))
+}
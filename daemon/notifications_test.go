@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// startNotificationDaemon starts a daemon keeping size notifications,
+// persisted to state when persist is set
+func startNotificationDaemon(t *testing.T, state *testutil.StateManager, size int, persist bool) *Daemon {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Daemon.NotificationLogSize = size
+	cfg.Daemon.PersistNotifications = persist
+	cfg.Plugins["fakestate"] = config.PluginConfig{Enabled: true}
+
+	d := New(cfg)
+	if err := d.AddPlugin(&statePlugin{state: state}); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { d.Stop() })
+	return d
+}
+
+// notify publishes notifications numbered from to through and waits until
+// the daemon has logged the last of them
+func notify(t *testing.T, d *Daemon, from, through int) {
+	t.Helper()
+	for i := from; i <= through; i++ {
+		d.broker.Publish(context.Background(), plugin.Message{Topic: "notification", Source: "test", Payload: fmt.Sprintf("note %d", i)})
+	}
+	last := fmt.Sprintf("note %d", through)
+	waitFor(t, "the notifications to be logged", func() bool {
+		recent := d.RecentNotifications(1)
+		return len(recent) == 1 && strings.HasSuffix(recent[0], last)
+	})
+}
+
+// loggedTexts returns the text of each logged notification, oldest first
+func loggedTexts(d *Daemon) []string {
+	var texts []string
+	for _, entry := range d.notifications.last(0) {
+		texts = append(texts, entry.Text)
+	}
+	return texts
+}
+
+func TestNotificationLogSurvivesRestart(t *testing.T) {
+	state := testutil.NewStateManager()
+
+	first := startNotificationDaemon(t, state, 5, true)
+	notify(t, first, 1, 3)
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	second := startNotificationDaemon(t, state, 5, true)
+	notify(t, second, 4, 4)
+
+	want := "[note 1 note 2 note 3 note 4]"
+	if got := fmt.Sprint(loggedTexts(second)); got != want {
+		t.Errorf("notification log = %s, want %s", got, want)
+	}
+}
+
+func TestRestoredNotificationLogKeepsSizeBound(t *testing.T) {
+	state := testutil.NewStateManager()
+
+	first := startNotificationDaemon(t, state, 10, true)
+	notify(t, first, 1, 6)
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// A smaller log after the restart keeps the newest entries
+	second := startNotificationDaemon(t, state, 4, true)
+	want := "[note 3 note 4 note 5 note 6]"
+	if got := fmt.Sprint(loggedTexts(second)); got != want {
+		t.Errorf("notification log = %s, want %s", got, want)
+	}
+}
+
+func TestNotificationLogNotPersistedByDefault(t *testing.T) {
+	state := testutil.NewStateManager()
+
+	first := startNotificationDaemon(t, state, 5, false)
+	notify(t, first, 1, 2)
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := state.Get(context.Background(), notificationStateKey); err == nil {
+		t.Error("notification log saved with persist_notifications off")
+	}
+
+	second := startNotificationDaemon(t, state, 5, false)
+	if got := loggedTexts(second); len(got) != 0 {
+		t.Errorf("notification log = %v after restart, want it empty", got)
+	}
+}
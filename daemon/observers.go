@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"log"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// taskObservers holds the registered task observers and the tasks they're
+// following
+type taskObservers struct {
+	mu        sync.RWMutex
+	observers []plugin.TaskObserver
+
+	// tasks maps the ID of each task started since an observer was
+	// registered to the task, until it finishes
+	tasks map[string]*plugin.Task
+}
+
+// newTaskObservers creates an empty observer list
+func newTaskObservers() *taskObservers {
+	return &taskObservers{tasks: make(map[string]*plugin.Task)}
+}
+
+// add registers an observer
+func (o *taskObservers) add(observer plugin.TaskObserver) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observers = append(o.observers, observer)
+}
+
+// track remembers a task so its lifecycle events can be handed to the
+// observers; it does nothing when there are none
+func (o *taskObservers) track(task *plugin.Task) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.observers) > 0 {
+		o.tasks[task.ID] = task
+	}
+}
+
+// dispatch calls the observers for a task lifecycle message
+func (o *taskObservers) dispatch(msg plugin.Message) {
+	taskID, _ := msg.Metadata["task_id"].(string)
+	if taskID == "" {
+		return
+	}
+
+	o.mu.Lock()
+	task, ok := o.tasks[taskID]
+	if ok && (msg.Topic == plugin.TopicTaskCompleted || msg.Topic == plugin.TopicTaskFailed) {
+		delete(o.tasks, taskID)
+	}
+	observers := o.observers
+	o.mu.Unlock()
+
+	// Only follow tasks the daemon started, and only its own events for them
+	if !ok || (msg.Topic != plugin.TopicTaskProgress && msg.Source != "daemon") {
+		return
+	}
+
+	for _, observer := range observers {
+		notifyObserver(observer, task, msg)
+	}
+}
+
+// notifyObserver calls the observer callback matching msg; a panicking
+// observer is logged rather than stopping the others
+func notifyObserver(observer plugin.TaskObserver, task *plugin.Task, msg plugin.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[Daemon] Task observer panicked on %s (task: %s): %v", msg.Topic, task.ID, r)
+		}
+	}()
+
+	switch msg.Topic {
+	case plugin.TopicTaskStarted:
+		observer.OnStart(task)
+	case plugin.TopicTaskProgress:
+		observer.OnProgress(task, progressValue(msg.Metadata["progress"]), plugin.PayloadText(msg.Payload))
+	case plugin.TopicTaskCompleted:
+		if result, ok := msg.Payload.(*plugin.TaskResult); ok {
+			observer.OnComplete(task, result)
+		}
+	case plugin.TopicTaskFailed:
+		if result, ok := msg.Payload.(*plugin.TaskResult); ok {
+			observer.OnError(task, result)
+		}
+	}
+}
+
+// progressValue reads a "progress" metadata value, which may have been
+// decoded from JSON
+func progressValue(v interface{}) int {
+	switch p := v.(type) {
+	case int:
+		return p
+	case int64:
+		return int(p)
+	case float64:
+		return int(p)
+	}
+	return 0
+}
+
+// RegisterTaskObserver adds an observer that is called as each task starts,
+// reports progress, completes or fails
+func (d *Daemon) RegisterTaskObserver(o plugin.TaskObserver) {
+	d.observers.add(o)
+}
+
+// observeTasks hands task lifecycle messages to the task observers, in the
+// order they were published, until the channel is closed
+func (d *Daemon) observeTasks(ch <-chan plugin.Message) {
+	defer d.wg.Done()
+
+	for msg := range ch {
+		d.observers.dispatch(msg)
+	}
+}
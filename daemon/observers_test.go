@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// recordingObserver records each callback as "<callback> <task id>"
+type recordingObserver struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (o *recordingObserver) record(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.calls = append(o.calls, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) OnStart(task *plugin.Task) { o.record("start %s", task.ID) }
+
+func (o *recordingObserver) OnProgress(task *plugin.Task, progress int, message string) {
+	o.record("progress %s %d %s", task.ID, progress, message)
+}
+
+func (o *recordingObserver) OnComplete(task *plugin.Task, result *plugin.TaskResult) {
+	o.record("complete %s %v", task.ID, result.Output)
+}
+
+func (o *recordingObserver) OnError(task *plugin.Task, result *plugin.TaskResult) {
+	o.record("error %s %s", task.ID, result.Error)
+}
+
+func (o *recordingObserver) recorded() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.calls...)
+}
+
+func TestTaskObserverSeesLifecycleInOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"success", nil, []string{"start t1", "progress t1 50 halfway", "complete t1 done"}},
+		{"failure", errors.New("boom"), []string{"start t1", "progress t1 50 halfway", "error t1 boom"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := testutil.NewExecutor("work")
+			d := startDaemon(t, config.DefaultConfig(), executor)
+			executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+				d.broker.Publish(ctx, plugin.Message{
+					Topic:    plugin.TopicTaskProgress,
+					Source:   "fakea",
+					Payload:  "halfway",
+					Metadata: map[string]interface{}{"task_id": task.ID, "progress": 50},
+				})
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return "done", nil
+			}
+
+			observer := &recordingObserver{}
+			d.RegisterTaskObserver(observer)
+
+			submit(t, d, "t1", "work")
+			waitFor(t, "the observer to see the task finish", func() bool { return len(observer.recorded()) >= len(tt.want) })
+			if got := observer.recorded(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("observer calls = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
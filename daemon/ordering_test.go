@@ -0,0 +1,120 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// keyedTasks runs tasks that each wait for their own release, and records
+// whether two tasks with the same ordering key ever overlapped
+type keyedTasks struct {
+	started  chan string
+	release  map[string]chan struct{}
+	mu       sync.Mutex
+	running  map[string]int
+	overlaps []string
+}
+
+func newKeyedTasks(ids ...string) *keyedTasks {
+	k := &keyedTasks{
+		started: make(chan string, len(ids)),
+		release: make(map[string]chan struct{}),
+		running: make(map[string]int),
+	}
+	for _, id := range ids {
+		k.release[id] = make(chan struct{})
+	}
+	return k
+}
+
+func (k *keyedTasks) executor() *testutil.Executor {
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		k.mu.Lock()
+		k.running[task.OrderingKey]++
+		if k.running[task.OrderingKey] > 1 {
+			k.overlaps = append(k.overlaps, task.ID)
+		}
+		k.mu.Unlock()
+		defer func() {
+			k.mu.Lock()
+			k.running[task.OrderingKey]--
+			k.mu.Unlock()
+		}()
+
+		k.started <- task.ID
+		select {
+		case <-k.release[task.ID]:
+			return task.ID, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return executor
+}
+
+func TestOrderingKeySerializesTasks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.MaxConcurrent = 2
+	cfg.Daemon.Tasks.QueueSize = 10
+
+	ids := []string{"a1", "b1", "a2", "b2", "a3", "b3"}
+	k := newKeyedTasks(ids...)
+	d := startDaemon(t, cfg, k.executor(), k.executor())
+
+	for _, id := range ids {
+		task := &plugin.Task{ID: id, Type: "work", OrderingKey: id[:1]}
+		if err := d.ExecuteTask(context.Background(), task); err != nil {
+			t.Fatalf("ExecuteTask(%s): %v", id, err)
+		}
+	}
+
+	g := &gate{started: k.started}
+	// The first task of each key runs side by side with the other
+	started := map[string]bool{g.next(t): true, g.next(t): true}
+	if !started["a1"] || !started["b1"] {
+		t.Fatalf("started %v, want a1 and b1", started)
+	}
+	g.idle(t)
+
+	// Finishing a task starts the next one with its key, even when tasks with
+	// the other key were submitted earlier
+	for _, step := range [][2]string{{"b1", "b2"}, {"b2", "b3"}, {"a1", "a2"}, {"a2", "a3"}} {
+		close(k.release[step[0]])
+		if id := g.next(t); id != step[1] {
+			t.Fatalf("finishing %s started %s, want %s", step[0], id, step[1])
+		}
+		g.idle(t)
+	}
+	close(k.release["a3"])
+	close(k.release["b3"])
+	waitFor(t, "the daemon to go idle", func() bool { return d.GetState() == StateIdle })
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.overlaps) > 0 {
+		t.Errorf("tasks %v ran alongside a task with the same ordering key", k.overlaps)
+	}
+}
+
+func TestTasksWithoutOrderingKeyAreNotSerialized(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.MaxConcurrent = 2
+
+	k := newKeyedTasks("first", "second")
+	d := startDaemon(t, cfg, k.executor(), k.executor())
+
+	submit(t, d, "first", "work")
+	submit(t, d, "second", "work")
+	g := &gate{started: k.started}
+	g.next(t)
+	g.next(t)
+
+	close(k.release["first"])
+	close(k.release["second"])
+}
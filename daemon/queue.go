@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+
+	"bicycle/plugin"
+)
+
+// runningTask is a task being executed
+type runningTask struct {
+	task     *plugin.Task
+	executor plugin.Executor
+	cancel   context.CancelFunc
+	done     chan struct{} // closed when the task finishes
+}
+
+// startable returns the idle executors that can run task now, or nil when it
+// has to wait because max_concurrent tasks are running, every executor for it
+// is busy, or a task with its ordering key is running or queued ahead of it.
+// An executor runs one task at a time. d.queue must only hold tasks submitted
+// before task. Caller must hold d.mu
+func (d *Daemon) startable(task *plugin.Task) []plugin.Executor {
+	if len(d.running) >= d.config.Daemon.Tasks.MaxConcurrent {
+		return nil
+	}
+	if key := task.OrderingKey; key != "" {
+		for _, rt := range d.running {
+			if rt.task.OrderingKey == key {
+				return nil
+			}
+		}
+		for _, queued := range d.queue {
+			if queued.OrderingKey == key {
+				return nil
+			}
+		}
+	}
+
+	var idle []plugin.Executor
+	for _, executor := range d.executorsFor(task.Type) {
+		if d.picker.busy[executor] == 0 {
+			idle = append(idle, executor)
+		}
+	}
+	return idle
+}
+
+// startQueued starts the queued tasks that can run now, in submission order
+// Caller must hold d.mu
+func (d *Daemon) startQueued() {
+	// Rebuild the queue as we go, so it holds the tasks ahead of each one
+	pending := d.queue
+	d.queue = nil
+	for _, task := range pending {
+		if idle := d.startable(task); idle != nil {
+			d.startTask(task, idle)
+		} else {
+			d.queue = append(d.queue, task)
+		}
+	}
+}
+
+// removeRunning forgets a running task, if it is still listed, and returns
+// to idle when no task is left. Caller must hold d.mu
+func (d *Daemon) removeRunning(rt *runningTask) {
+	for i, r := range d.running {
+		if r == rt {
+			d.running = append(d.running[:i:i], d.running[i+1:]...)
+			break
+		}
+	}
+	if len(d.running) == 0 && d.state == StateWorking {
+		d.state = StateIdle
+	}
+}
+
+// failQueued reports queued tasks that will never run as failed, so their
+// submitters hear about them
+func (d *Daemon) failQueued(tasks []*plugin.Task, reason string) {
+	for _, task := range tasks {
+		result := &plugin.TaskResult{
+			ID:    task.ID,
+			Type:  task.Type,
+			Error: reason,
+			Tags:  task.Tags,
+		}
+		d.results.add(result)
+		d.publishOutcome(plugin.WithCorrelationID(d.ctx, task.CorrelationID), task, result, errors.New(reason))
+	}
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestBusyDaemonRefusesTasksWithoutQueue(t *testing.T) {
+	g := newGate()
+	d := startDaemon(t, config.DefaultConfig(), newGatedExecutor(g, "work"))
+
+	submit(t, d, "first", "work")
+	g.next(t)
+
+	err := d.ExecuteTask(context.Background(), &plugin.Task{ID: "second", Type: "work"})
+	if err == nil || !strings.Contains(err.Error(), "daemon is busy") {
+		t.Fatalf("ExecuteTask while busy = %v, want daemon is busy", err)
+	}
+	close(g.release)
+}
+
+func TestQueuedTasksStartInOrder(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.QueueSize = 2
+
+	g := newGate()
+	d := startDaemon(t, cfg, newGatedExecutor(g, "work"))
+
+	submit(t, d, "first", "work")
+	if id := g.next(t); id != "first" {
+		t.Fatalf("started %s, want first", id)
+	}
+	submit(t, d, "second", "work")
+	submit(t, d, "third", "work")
+	if err := d.ExecuteTask(context.Background(), &plugin.Task{ID: "fourth", Type: "work"}); err == nil {
+		t.Fatal("ExecuteTask with a full queue succeeded, want an error")
+	}
+	if got := d.Snapshot(context.Background()).QueuedTasks; got != 2 {
+		t.Errorf("QueuedTasks = %d, want 2", got)
+	}
+	g.idle(t)
+
+	for _, want := range []string{"second", "third"} {
+		g.release <- struct{}{}
+		if id := g.next(t); id != want {
+			t.Fatalf("started %s, want %s", id, want)
+		}
+	}
+	g.release <- struct{}{}
+	waitFor(t, "the daemon to go idle", func() bool { return d.GetState() == StateIdle })
+}
+
+func TestMaxConcurrentRunsTasksOnSeparateExecutors(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.MaxConcurrent = 2
+	cfg.Daemon.Tasks.QueueSize = 1
+
+	g := newGate()
+	d := startDaemon(t, cfg, newGatedExecutor(g, "work"), newGatedExecutor(g, "work"))
+
+	submit(t, d, "first", "work")
+	submit(t, d, "second", "work")
+	g.next(t)
+	g.next(t)
+	if got := len(d.GetRunningTasks()); got != 2 {
+		t.Fatalf("%d running tasks, want 2", got)
+	}
+
+	// Both executors are busy, so the third task waits
+	submit(t, d, "third", "work")
+	g.idle(t)
+
+	g.release <- struct{}{}
+	if id := g.next(t); id != "third" {
+		t.Fatalf("started %s, want third", id)
+	}
+	close(g.release)
+}
+
+func TestExecutorRunsOneTaskAtATime(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.MaxConcurrent = 2
+	cfg.Daemon.Tasks.QueueSize = 1
+
+	g := newGate()
+	d := startDaemon(t, cfg, newGatedExecutor(g, "work"))
+
+	submit(t, d, "first", "work")
+	g.next(t)
+	submit(t, d, "second", "work")
+	g.idle(t)
+
+	g.release <- struct{}{}
+	if id := g.next(t); id != "second" {
+		t.Fatalf("started %s, want second", id)
+	}
+	close(g.release)
+}
+
+func TestCancelQueuedTask(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.QueueSize = 1
+
+	g := newGate()
+	executor := newGatedExecutor(g, "work")
+	d := startDaemon(t, cfg, executor)
+	failed := testutil.Collect(d.GetBroker(), "test", plugin.TopicTaskFailed)
+
+	submit(t, d, "first", "work")
+	g.next(t)
+	submit(t, d, "second", "work")
+
+	if err := d.CancelTask(context.Background(), "second"); err != nil {
+		t.Fatalf("CancelTask: %v", err)
+	}
+	msgs := failed.WaitFor(1, testTimeout)
+	if len(msgs) != 1 || msgs[0].Metadata["task_id"] != "second" {
+		t.Fatalf("task.failed messages = %v, want one for the cancelled task", msgs)
+	}
+
+	close(g.release)
+	waitFor(t, "the daemon to go idle", func() bool { return d.GetState() == StateIdle })
+	g.idle(t)
+	if got := len(executor.Executed()); got != 1 {
+		t.Errorf("executed %d tasks, want 1", got)
+	}
+}
+
+func TestStopFailsQueuedTasks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.QueueSize = 1
+
+	g := newGate()
+	d := startDaemon(t, cfg, newGatedExecutor(g, "work"))
+
+	submit(t, d, "first", "work")
+	g.next(t)
+	submit(t, d, "second", "work")
+
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	result, ok := d.GetTaskResult("second")
+	if !ok || result.Error != "daemon is stopping" {
+		t.Errorf("queued task result = %+v, want daemon is stopping", result)
+	}
+}
@@ -0,0 +1,196 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// Reload applies the runtime-adjustable settings of a new configuration
+// The broker settings under daemon and the plugin settings take effect, and
+// started plugins that implement plugin.Reloadable re-read their settings;
+// other changes (enabled plugins, mode, buffer sizes) need a restart
+func (d *Daemon) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	ctx, reloadable := d.reloadBroker(cfg)
+	d.reloadStatusTemplate(cfg)
+
+	// Plugins reload without the daemon lock, so they may call back into it
+	for _, p := range reloadable {
+		if err := p.(plugin.Reloadable).Reload(plugin.WithPluginName(ctx, p.Name())); err != nil {
+			log.Printf("[Daemon] Plugin %s kept its settings: %v", p.Name(), err)
+			continue
+		}
+		log.Printf("[Daemon] Reloaded plugin: %s", p.Name())
+	}
+
+	log.Println("[Daemon] Configuration reloaded")
+	return nil
+}
+
+// reloadBroker applies the broker and plugin settings of cfg and returns the
+// daemon context and the started plugins that implement plugin.Reloadable
+func (d *Daemon) reloadBroker(cfg *config.Config) (context.Context, []plugin.Plugin) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := &d.config.Daemon
+	next := cfg.Daemon
+
+	if current.PublishTimeout != next.PublishTimeout {
+		log.Printf("[Daemon] Reload: publish_timeout %ds -> %ds", current.PublishTimeout, next.PublishTimeout)
+	}
+	if current.BrokerFanoutLimit != next.BrokerFanoutLimit {
+		log.Printf("[Daemon] Reload: broker_fanout_limit %d -> %d", current.BrokerFanoutLimit, next.BrokerFanoutLimit)
+	}
+
+	current.PublishTimeout = next.PublishTimeout
+	current.BrokerFanoutLimit = next.BrokerFanoutLimit
+	current.BrokerMaxAge = next.BrokerMaxAge
+	current.BrokerMaxPayloadBytes = next.BrokerMaxPayloadBytes
+	current.BrokerAsync = next.BrokerAsync
+	current.BrokerRetain = next.BrokerRetain
+	current.BrokerRetainTTL = next.BrokerRetainTTL
+	current.BrokerNoSubscribers = next.BrokerNoSubscribers
+	current.BrokerRequiredTopics = next.BrokerRequiredTopics
+	current.ReservedTopics = next.ReservedTopics
+
+	d.applyBrokerSettings()
+
+	// Replace rather than modify the map, which readers use without d.mu
+	d.config.Plugins = cfg.Plugins
+	d.pluginsVersion++
+
+	var reloadable []plugin.Plugin
+	for _, name := range d.started {
+		if _, ok := d.plugins[name].(plugin.Reloadable); ok {
+			reloadable = append(reloadable, d.plugins[name])
+		}
+	}
+
+	base := d.ctx
+	if base == nil {
+		base = context.Background()
+	}
+	return base, reloadable
+}
+
+// reloadStatusTemplate switches /status to the status template of cfg
+func (d *Daemon) reloadStatusTemplate(cfg *config.Config) {
+	tmpl := parseStatusTemplate(cfg.Daemon.StatusTemplate)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config.Daemon.StatusTemplate = cfg.Daemon.StatusTemplate
+	d.statusTemplate = tmpl
+}
+
+// applyBrokerSettings pushes the daemon's broker settings to the broker
+// Caller must hold d.mu
+func (d *Daemon) applyBrokerSettings() {
+	d.broker.SetPublishTimeout(time.Duration(d.config.Daemon.PublishTimeout) * time.Second)
+	d.broker.SetFanoutLimit(d.config.Daemon.BrokerFanoutLimit)
+	d.broker.SetMaxAge(time.Duration(d.config.Daemon.BrokerMaxAge) * time.Millisecond)
+	d.broker.SetMaxPayloadBytes(d.config.Daemon.BrokerMaxPayloadBytes)
+	d.broker.SetAsync(d.config.Daemon.BrokerAsync)
+	d.broker.SetRetain(d.config.Daemon.BrokerRetain)
+	d.broker.SetRetainTTL(time.Duration(d.config.Daemon.BrokerRetainTTL) * time.Second)
+
+	d.broker.SetNoSubscriberPolicy(noSubscriberPolicy(d.config.Daemon))
+	d.broker.SetRequiredTopics(d.config.Daemon.BrokerRequiredTopics)
+	if reserved := d.config.Daemon.ReservedTopics; len(reserved) > 0 {
+		d.broker.SetPublishAuthorizer(ReservedTopicsAuthorizer(reserved))
+	} else {
+		d.broker.SetPublishAuthorizer(nil)
+	}
+}
+
+// noSubscriberPolicy returns the broker policy for the broker_no_subscribers
+// setting; "debug" logs only when the daemon logs at debug level
+func noSubscriberPolicy(cfg config.DaemonConfig) NoSubscriberPolicy {
+	switch cfg.BrokerNoSubscribers {
+	case "silent":
+		return NoSubscribersSilent
+	case "error":
+		return NoSubscribersError
+	case "debug":
+		if cfg.LogLevel != "debug" {
+			return NoSubscribersSilent
+		}
+	}
+	return NoSubscribersLog
+}
+
+// UpdatePluginSettings merges patch into a running plugin's settings and
+// applies them: a plugin implementing plugin.Reloadable reloads, any other is
+// restarted. Plugins that declare a settings schema have the merged settings
+// checked first. With persist, the settings are also written to the last
+// config file loaded. It returns the plugin's new settings
+func (d *Daemon) UpdatePluginSettings(name string, patch map[string]interface{}, persist bool) (map[string]interface{}, error) {
+	d.mu.Lock()
+	p, ok := d.plugins[name]
+	if !ok || !d.isStarted(name) {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("plugin not running: %s", name)
+	}
+
+	previous := d.config.Plugins
+	next := d.config.WithPluginSettings(name, patch).Plugins
+	settings := next[name].Settings
+	if sp, ok := p.(plugin.SettingsSchemaProvider); ok {
+		if err := plugin.ValidateSettings(sp.SettingsSchema(), settings); err != nil {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("plugin %s: %w", name, err)
+		}
+	}
+
+	var persistTo string
+	if persist {
+		sources := d.config.Sources
+		if len(sources) == 0 {
+			d.mu.Unlock()
+			return nil, fmt.Errorf("no config file to persist settings to")
+		}
+		persistTo = sources[len(sources)-1]
+	}
+
+	// Replace rather than modify the map, which readers use without d.mu
+	d.config.Plugins = next
+	d.pluginsVersion++
+	version := d.pluginsVersion
+	ctx := plugin.WithPluginName(d.ctx, name)
+	d.mu.Unlock()
+
+	// Apply without the daemon lock, so the plugin may call back into it
+	var err error
+	if r, ok := p.(plugin.Reloadable); ok {
+		err = r.Reload(ctx)
+	} else {
+		err = d.restartPlugin(ctx, name)
+	}
+	if err != nil {
+		d.mu.Lock()
+		if d.pluginsVersion == version {
+			d.config.Plugins = previous
+			d.pluginsVersion++
+		}
+		d.mu.Unlock()
+		return nil, fmt.Errorf("plugin %s rejected its new settings: %w", name, err)
+	}
+	log.Printf("[Daemon] Updated settings of plugin: %s", name)
+
+	if persistTo != "" {
+		if err := config.PersistPluginSettings(persistTo, name, settings); err != nil {
+			return settings, fmt.Errorf("settings applied but not saved: %w", err)
+		}
+		log.Printf("[Daemon] Saved settings of plugin %s to %s", name, persistTo)
+	}
+	return settings, nil
+}
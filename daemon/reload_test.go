@@ -0,0 +1,188 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// settingsPlugin reads its "greeting" setting when started and reloaded
+type settingsPlugin struct {
+	mu       sync.Mutex
+	startCtx context.Context
+	greeting string
+}
+
+func (p *settingsPlugin) Name() string                                { return "greeter" }
+func (p *settingsPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *settingsPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *settingsPlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *settingsPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	p.mu.Lock()
+	p.startCtx = ctx
+	p.mu.Unlock()
+	return p.Reload(ctx)
+}
+
+func (p *settingsPlugin) Reload(ctx context.Context) error {
+	greeting, _ := ctx.Value("config").(*config.Config).GetPluginSettingString("greeter", "greeting")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.greeting = greeting
+	return nil
+}
+
+// loaded returns the greeting the plugin read last
+func (p *settingsPlugin) loaded() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.greeting
+}
+
+// current reads the greeting from the config the plugin was started with
+func (p *settingsPlugin) current() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	greeting, _ := p.startCtx.Value("config").(*config.Config).GetPluginSettingString("greeter", "greeting")
+	return greeting
+}
+
+// greeterConfig returns a config enabling the greeter with the given greeting
+func greeterConfig(greeting string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Plugins["greeter"] = config.PluginConfig{
+		Enabled:  true,
+		Settings: map[string]interface{}{"greeting": greeting},
+	}
+	return cfg
+}
+
+func TestUpdatePluginSettingsReachesPlugin(t *testing.T) {
+	p := &settingsPlugin{}
+	d := New(greeterConfig("hello"))
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	settings, err := d.UpdatePluginSettings("greeter", map[string]interface{}{"greeting": "hi"}, false)
+	if err != nil {
+		t.Fatalf("UpdatePluginSettings: %v", err)
+	}
+	if settings["greeting"] != "hi" {
+		t.Errorf("returned settings = %v, want greeting hi", settings)
+	}
+	if got := p.loaded(); got != "hi" {
+		t.Errorf("plugin reloaded greeting %q, want hi", got)
+	}
+
+	// Contexts handed out before the patch see it too
+	if got := p.current(); got != "hi" {
+		t.Errorf("start context greeting = %q, want hi", got)
+	}
+	if got, _ := d.Context().Value("config").(*config.Config).GetPluginSettingString("greeter", "greeting"); got != "hi" {
+		t.Errorf("daemon context greeting = %q, want hi", got)
+	}
+}
+
+func TestReloadUpdatesPluginSettings(t *testing.T) {
+	p := &settingsPlugin{}
+	d := New(greeterConfig("hello"))
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	reloaded := greeterConfig("howdy")
+	reloaded.Plugins["greeter"].Settings["volume"] = 3
+	if err := d.Reload(reloaded); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := p.loaded(); got != "howdy" {
+		t.Errorf("plugin reloaded greeting %q, want howdy", got)
+	}
+
+	// A later patch builds on the reloaded settings instead of reverting them
+	settings, err := d.UpdatePluginSettings("greeter", map[string]interface{}{"volume": 5}, false)
+	if err != nil {
+		t.Fatalf("UpdatePluginSettings: %v", err)
+	}
+	if settings["greeting"] != "howdy" || settings["volume"] != 5 {
+		t.Errorf("settings after patch = %v, want greeting howdy and volume 5", settings)
+	}
+	if got := p.current(); got != "howdy" {
+		t.Errorf("start context greeting = %q, want howdy", got)
+	}
+}
+
+func TestReloadChangesPublishTimeout(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	d := NewWithClock(config.DefaultConfig(), fake)
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	reloaded := config.DefaultConfig()
+	reloaded.Daemon.PublishTimeout = 2
+	if err := d.Reload(reloaded); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	// The new timeout is what a stuck subscriber now costs a publisher
+	d.broker.Subscribe("stuck", 0, "slow")
+	errCh := make(chan error, 1)
+	go func() { errCh <- d.broker.Publish(context.Background(), plugin.Message{Topic: "slow"}) }()
+	waitForWaiters(t, fake, 1)
+
+	fake.Advance(2 * time.Second)
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "slow consumer") {
+			t.Errorf("Publish = %v, want a slow consumer timeout", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Publish did not time out after the reloaded timeout")
+	}
+}
+
+func TestReloadRejectsNonPositivePublishTimeout(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig())
+
+	reloaded := config.DefaultConfig()
+	reloaded.Daemon.PublishTimeout = 0
+	if err := d.Reload(reloaded); err == nil {
+		t.Fatal("Reload accepted a zero publish timeout")
+	}
+	if got := d.broker.PublishTimeout(); got != 5*time.Second {
+		t.Errorf("publish timeout = %s after a rejected reload, want 5s", got)
+	}
+}
+
+func TestReservedTopicsConfigured(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.ReservedTopics = map[string][]string{"daemon.event": {"daemon"}}
+	d := startDaemon(t, cfg)
+
+	if err := d.broker.Publish(context.Background(), plugin.Message{Topic: "daemon.event", Source: "rest"}); !errors.Is(err, plugin.ErrPublishUnauthorized) {
+		t.Errorf("Publish from rest = %v, want ErrPublishUnauthorized", err)
+	}
+	if err := d.broker.Publish(context.Background(), plugin.Message{Topic: "daemon.event", Source: "daemon"}); err != nil {
+		t.Errorf("Publish from daemon: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"log"
+	"maps"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// maxTaskResults is how many recent task results are kept
+const maxTaskResults = 100
+
+// taskResults keeps the most recent task results by ID
+type taskResults struct {
+	mu      sync.RWMutex
+	order   []string
+	results map[string]*plugin.TaskResult
+	size    int
+
+	// byTag maps each "key:value" tag to the IDs of the stored results
+	// carrying it, oldest first
+	byTag map[string][]string
+}
+
+// newTaskResults creates a store holding at most size results
+func newTaskResults(size int) *taskResults {
+	return &taskResults{
+		results: make(map[string]*plugin.TaskResult),
+		size:    size,
+		byTag:   make(map[string][]string),
+	}
+}
+
+// add stores a copy of the result, evicting the oldest if full
+func (s *taskResults) add(result *plugin.TaskResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *result
+	stored.Tags = maps.Clone(result.Tags)
+	if old, exists := s.results[result.ID]; exists {
+		s.unindexLocked(old)
+	} else {
+		s.order = append(s.order, result.ID)
+	}
+	s.results[result.ID] = &stored
+	for key, value := range stored.Tags {
+		tag := key + ":" + value
+		s.byTag[tag] = append(s.byTag[tag], stored.ID)
+	}
+
+	for len(s.order) > s.size {
+		s.unindexLocked(s.results[s.order[0]])
+		delete(s.results, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// unindexLocked removes a stored result from the tag index
+func (s *taskResults) unindexLocked(result *plugin.TaskResult) {
+	for key, value := range result.Tags {
+		tag := key + ":" + value
+		ids := s.byTag[tag]
+		for i, id := range ids {
+			if id == result.ID {
+				ids = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(s.byTag, tag)
+		} else {
+			s.byTag[tag] = ids
+		}
+	}
+}
+
+// list returns copies of the stored results carrying every tag ("key:value"),
+// newest first; with no tags it returns them all
+func (s *taskResults) list(tags ...string) []*plugin.TaskResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Start from the first tag's index and check the rest on each result
+	ids, rest := s.order, tags
+	if len(tags) > 0 {
+		ids, rest = s.byTag[tags[0]], tags[1:]
+	}
+
+	var results []*plugin.TaskResult
+	for i := len(ids) - 1; i >= 0; i-- {
+		result := s.results[ids[i]]
+		if !hasTags(result, rest) {
+			continue
+		}
+		copied := *result
+		copied.DeliveryFailures = append([]plugin.DeliveryFailure(nil), result.DeliveryFailures...)
+		results = append(results, &copied)
+	}
+	return results
+}
+
+// hasTags reports whether a result carries every tag ("key:value")
+func hasTags(result *plugin.TaskResult, tags []string) bool {
+	for _, tag := range tags {
+		key, value, _ := plugin.ParseTag(tag)
+		if v, ok := result.Tags[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// get returns a copy of the result with the given ID
+func (s *taskResults) get(id string) (*plugin.TaskResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result, ok := s.results[id]
+	if !ok {
+		return nil, false
+	}
+	copied := *result
+	copied.DeliveryFailures = append([]plugin.DeliveryFailure(nil), result.DeliveryFailures...)
+	return &copied, true
+}
+
+// addDeliveryFailure records a delivery failure against a stored result
+func (s *taskResults) addDeliveryFailure(id string, failure plugin.DeliveryFailure) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, ok := s.results[id]
+	if !ok {
+		return false
+	}
+	result.DeliveryFailures = append(result.DeliveryFailures, failure)
+	return true
+}
+
+// GetTaskResult returns the result of a recently finished task
+func (d *Daemon) GetTaskResult(id string) (*plugin.TaskResult, bool) {
+	return d.results.get(id)
+}
+
+// TaskResults returns the recent task results carrying every given tag
+// ("key:value"), newest first; with no tags it returns them all
+func (d *Daemon) TaskResults(tags ...string) []*plugin.TaskResult {
+	return d.results.list(tags...)
+}
+
+// recordDeliveryFailures records delivery.failed messages against task results
+// until the channel is closed
+func (d *Daemon) recordDeliveryFailures(ch <-chan plugin.Message) {
+	defer d.wg.Done()
+
+	for msg := range ch {
+		taskID, _ := msg.Metadata["task_id"].(string)
+		errText, _ := msg.Metadata["error"].(string)
+
+		log.Printf("[Daemon] Delivery failed (task: %s, source: %s): %s", taskID, msg.Source, errText)
+
+		if taskID == "" {
+			continue
+		}
+		if !d.results.addDeliveryFailure(taskID, plugin.DeliveryFailure{
+			Source: msg.Source,
+			Error:  errText,
+			Time:   d.clock.Now(),
+		}) {
+			log.Printf("[Daemon] No result recorded for task %s", taskID)
+		}
+	}
+}
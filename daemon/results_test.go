@@ -0,0 +1,182 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestCompletionMessageCarriesResult(t *testing.T) {
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		return "answer", nil
+	}
+	d := startDaemon(t, config.DefaultConfig(), executor)
+	responses := testutil.Collect(d.broker, "test", "response")
+
+	if err := d.ExecuteTask(context.Background(), &plugin.Task{ID: "t1", Type: "work", CorrelationID: "c1"}); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	msgs := responses.WaitFor(1, testTimeout)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d responses, want 1", len(msgs))
+	}
+
+	msg := msgs[0]
+	result, ok := msg.Payload.(*plugin.TaskResult)
+	if !ok {
+		t.Fatalf("payload = %T, want *plugin.TaskResult", msg.Payload)
+	}
+	if result.ID != "t1" || result.Type != "work" || result.Output != "answer" || result.Error != "" {
+		t.Errorf("result = %+v, want t1 with output answer", result)
+	}
+	if msg.Metadata["task_id"] != "t1" || msg.Metadata[plugin.MetadataCorrelationID] != "c1" {
+		t.Errorf("metadata = %v, want task t1 with correlation id c1", msg.Metadata)
+	}
+	if got := d.TaskResults(); len(got) != 1 || got[0].ID != "t1" || got[0].Output != "answer" {
+		t.Errorf("TaskResults = %v, want the published result", got)
+	}
+}
+
+func TestFailureNotificationCarriesTaskID(t *testing.T) {
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		return nil, errors.New("broken")
+	}
+	d := startDaemon(t, config.DefaultConfig(), executor)
+	notifications := testutil.Collect(d.broker, "test", "notification")
+
+	submit(t, d, "t1", "work")
+	msgs := notifications.WaitFor(1, testTimeout)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(msgs))
+	}
+	if msgs[0].Metadata["task_id"] != "t1" || msgs[0].Metadata["error"] != "broken" {
+		t.Errorf("metadata = %v, want task t1 failing with broken", msgs[0].Metadata)
+	}
+}
+
+func TestDeliveryFailureRecordedAgainstResult(t *testing.T) {
+	executor := testutil.NewExecutor("work")
+	d := startDaemon(t, config.DefaultConfig(), executor)
+	responses := testutil.Collect(d.broker, "test", "response")
+
+	submit(t, d, "t1", "work")
+	if msgs := responses.WaitFor(1, testTimeout); len(msgs) != 1 {
+		t.Fatalf("got %d responses, want 1", len(msgs))
+	}
+
+	d.broker.Publish(context.Background(), plugin.Message{
+		Topic:    plugin.TopicDeliveryFailed,
+		Source:   "websocket",
+		Metadata: map[string]interface{}{"task_id": "t1", "error": "client gone"},
+	})
+
+	waitFor(t, "the delivery failure to be recorded", func() bool {
+		results := d.TaskResults()
+		return len(results) == 1 && len(results[0].DeliveryFailures) == 1
+	})
+	failure := d.TaskResults()[0].DeliveryFailures[0]
+	if failure.Source != "websocket" || failure.Error != "client gone" {
+		t.Errorf("delivery failure = %+v, want websocket's client gone", failure)
+	}
+}
+
+func TestTaskResultsFilteredByTag(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig(), testutil.NewExecutor("work"))
+
+	tasks := []*plugin.Task{
+		{ID: "t1", Type: "work", Tags: map[string]string{"project": "x", "user": "ann"}},
+		{ID: "t2", Type: "work", Tags: map[string]string{"project": "y", "user": "ann"}},
+		{ID: "t3", Type: "work", Tags: map[string]string{"project": "x", "user": "bob"}},
+		{ID: "t4", Type: "work"},
+	}
+	for _, task := range tasks {
+		if err := d.ExecuteTask(context.Background(), task); err != nil {
+			t.Fatalf("ExecuteTask(%s): %v", task.ID, err)
+		}
+		waitFor(t, task.ID+" to finish", func() bool { _, ok := d.results.get(task.ID); return ok })
+	}
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{nil, []string{"t4", "t3", "t2", "t1"}},
+		{[]string{"project:x"}, []string{"t3", "t1"}},
+		{[]string{"project:x", "user:ann"}, []string{"t1"}},
+		{[]string{"user:ann"}, []string{"t2", "t1"}},
+		{[]string{"project:z"}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, result := range d.TaskResults(tt.tags...) {
+			got = append(got, result.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("TaskResults(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+	if result, _ := d.results.get("t1"); result.Tags["project"] != "x" || result.Tags["user"] != "ann" {
+		t.Errorf("t1 result tags = %v, want the task's tags", result.Tags)
+	}
+}
+
+func TestEvictedResultsLeaveTagIndex(t *testing.T) {
+	s := newTaskResults(2)
+	for _, id := range []string{"a", "b", "c"} {
+		s.add(&plugin.TaskResult{ID: id, Tags: map[string]string{"project": "x"}})
+	}
+
+	var got []string
+	for _, result := range s.list("project:x") {
+		got = append(got, result.ID)
+	}
+	if !slices.Equal(got, []string{"c", "b"}) {
+		t.Errorf("list(project:x) = %v, want [c b]", got)
+	}
+	if ids := s.byTag["project:x"]; len(ids) != 2 {
+		t.Errorf("tag index holds %v, want only the kept results", ids)
+	}
+}
+
+func TestLifecycleMessagesCarrySeverity(t *testing.T) {
+	executor := testutil.NewExecutor("work", "fail")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		if task.Type == "fail" {
+			return nil, errors.New("broken")
+		}
+		return "answer", nil
+	}
+	cfg := config.DefaultConfig()
+	cfg.Daemon.Tasks.QueueSize = 1
+	d := startDaemon(t, cfg, executor)
+	events := testutil.Collect(d.broker, "test", "response", "notification",
+		plugin.TopicTaskStarted, plugin.TopicTaskCompleted, plugin.TopicTaskFailed)
+
+	submit(t, d, "ok", "work")
+	submit(t, d, "bad", "fail")
+	msgs := events.WaitFor(6, testTimeout)
+
+	want := map[string]plugin.Severity{
+		"ok " + plugin.TopicTaskStarted:   plugin.SeverityInfo,
+		"ok " + plugin.TopicTaskCompleted: plugin.SeveritySuccess,
+		"ok response":                     plugin.SeveritySuccess,
+		"bad " + plugin.TopicTaskStarted:  plugin.SeverityInfo,
+		"bad " + plugin.TopicTaskFailed:   plugin.SeverityError,
+		"bad notification":                plugin.SeverityError,
+	}
+	got := make(map[string]plugin.Severity)
+	for _, msg := range msgs {
+		got[msg.Metadata["task_id"].(string)+" "+msg.Topic] = plugin.SeverityOf(msg)
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("severities = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// retainedMessage is a published message kept for replay to new subscribers
+type retainedMessage struct {
+	seq uint64
+	at  time.Time
+	msg plugin.Message
+}
+
+// retainStore keeps the last N messages per topic
+type retainStore struct {
+	mu     sync.Mutex
+	size   int
+	ttl    time.Duration // max age of a retained message (0 = no limit)
+	seq    uint64
+	topics map[string][]retainedMessage
+}
+
+// newRetainStore creates a store keeping size messages per topic (0 = disabled)
+func newRetainStore(size int) *retainStore {
+	return &retainStore{
+		size:   size,
+		topics: make(map[string][]retainedMessage),
+	}
+}
+
+// setSize changes the per-topic limit, trimming existing topics if needed
+func (r *retainStore) setSize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.size = size
+	for topic, msgs := range r.topics {
+		r.topics[topic] = trimRetained(msgs, size)
+	}
+}
+
+// setTTL sets the max age of retained messages
+func (r *retainStore) setTTL(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = ttl
+}
+
+// expired reports whether a retained message is stale at now
+func (r *retainStore) expired(m retainedMessage, now time.Time) bool {
+	if r.ttl > 0 && now.Sub(m.at) > r.ttl {
+		return true
+	}
+	return !m.msg.ExpiresAt.IsZero() && !now.Before(m.msg.ExpiresAt)
+}
+
+// evictLocked drops expired messages from a topic
+// Caller must hold r.mu
+func (r *retainStore) evictLocked(topic string, now time.Time) {
+	msgs := r.topics[topic]
+	kept := msgs[:0]
+	for _, m := range msgs {
+		if !r.expired(m, now) {
+			kept = append(kept, m)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.topics, topic)
+		return
+	}
+	r.topics[topic] = kept
+}
+
+// add retains a message published at the given time
+func (r *retainStore) add(msg plugin.Message, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size <= 0 {
+		return
+	}
+
+	r.seq++
+	r.topics[msg.Topic] = trimRetained(append(r.topics[msg.Topic], retainedMessage{
+		seq: r.seq,
+		at:  at,
+		msg: msg,
+	}), r.size)
+}
+
+// matching returns unexpired retained messages wanted by the subscription,
+// oldest first, evicting expired ones along the way
+func (r *retainStore) matching(sub *Subscription, now time.Time) []retainedMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []retainedMessage
+	for topic := range r.topics {
+		if sub.wantsTopic(topic) {
+			r.evictLocked(topic, now)
+			out = append(out, r.topics[topic]...)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].seq < out[j].seq })
+	return out
+}
+
+// all returns every unexpired retained message, oldest first
+func (r *retainStore) all(now time.Time) []retainedMessage {
+	return r.matching(&Subscription{}, now)
+}
+
+// trimRetained keeps only the newest size messages
+func trimRetained(msgs []retainedMessage, size int) []retainedMessage {
+	if size <= 0 {
+		return nil
+	}
+	if len(msgs) > size {
+		msgs = msgs[len(msgs)-size:]
+	}
+	return msgs
+}
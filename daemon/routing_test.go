@@ -0,0 +1,106 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestTaskRepliesAddressedToSubmitter(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig(), testutil.NewExecutor("llm_query"))
+	responses := testutil.Collect(d.broker, "test", "response")
+
+	ctx := plugin.WithPrincipal(context.Background(), plugin.Principal{Source: "telegram:42", Role: plugin.RoleUser})
+	if err := d.ExecuteTask(ctx, &plugin.Task{ID: "t1", Type: "llm_query"}); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+
+	msgs := responses.WaitFor(1, testTimeout)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d responses, want 1", len(msgs))
+	}
+	response := msgs[0]
+	if got := plugin.ReplyTo(response); got != "telegram:42" {
+		t.Fatalf("reply_to = %q, want telegram:42", got)
+	}
+	for transport, want := range map[string]bool{"telegram": true, "websocket": false, "tui": false} {
+		if got := plugin.AddressedTo(response, transport); got != want {
+			t.Errorf("AddressedTo(%s) = %v, want %v", transport, got, want)
+		}
+	}
+}
+
+func TestTaskWithoutPrincipalIsBroadcast(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig(), testutil.NewExecutor("work"))
+	responses := testutil.Collect(d.broker, "test", "response")
+
+	submit(t, d, "t1", "work")
+
+	msgs := responses.WaitFor(1, testTimeout)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d responses, want 1", len(msgs))
+	}
+	for _, transport := range []string{"telegram", "websocket", "tui"} {
+		if !plugin.AddressedTo(msgs[0], transport) {
+			t.Errorf("broadcast response not addressed to %s", transport)
+		}
+	}
+}
+
+func TestTaskLifecycleEventsPublished(t *testing.T) {
+	executor := testutil.NewExecutor("work")
+	executor.Handler = func(ctx context.Context, task *plugin.Task) (interface{}, error) {
+		if task.ID == "bad" {
+			return nil, errors.New("boom")
+		}
+		return "done", nil
+	}
+	d := startDaemon(t, config.DefaultConfig(), executor)
+	events := testutil.Collect(d.broker, "test", plugin.TopicTaskStarted, plugin.TopicTaskCompleted, plugin.TopicTaskFailed)
+
+	submit(t, d, "good", "work")
+	waitFor(t, "good to finish", func() bool { return len(d.TaskResults()) == 1 })
+	submit(t, d, "bad", "work")
+
+	msgs := events.WaitFor(4, testTimeout)
+	want := []struct{ topic, taskID string }{
+		{plugin.TopicTaskStarted, "good"},
+		{plugin.TopicTaskCompleted, "good"},
+		{plugin.TopicTaskStarted, "bad"},
+		{plugin.TopicTaskFailed, "bad"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("got %d task events, want %d", len(msgs), len(want))
+	}
+	for i, w := range want {
+		if msgs[i].Topic != w.topic || msgs[i].Metadata["task_id"] != w.taskID {
+			t.Errorf("event %d = %s for %v, want %s for %s", i, msgs[i].Topic, msgs[i].Metadata["task_id"], w.topic, w.taskID)
+		}
+	}
+	if result, ok := msgs[3].Payload.(*plugin.TaskResult); !ok || result.Error == "" {
+		t.Errorf("failed event payload = %#v, want a *TaskResult with the error", msgs[3].Payload)
+	}
+}
+
+func TestDaemonAnswersPings(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig())
+	pongs := testutil.Collect(d.broker, "test", plugin.TopicPong)
+
+	if err := d.broker.Publish(context.Background(), plugin.Message{
+		Topic:    plugin.TopicPing,
+		Payload:  "ping",
+		Metadata: map[string]interface{}{plugin.MetadataCorrelationID: "p1"},
+	}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	pongs.WaitFor(1, testTimeout)
+	pong := pongs.Messages()[0]
+	if pong.Metadata[plugin.MetadataCorrelationID] != "p1" || pong.Source != "daemon" {
+		t.Errorf("pong = %+v, want one from the daemon for p1", pong)
+	}
+}
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// BrokerSnapshot is a serializable view of the broker's state, used to hand
+// retained messages over to a successor process
+type BrokerSnapshot struct {
+	// TakenAt is when the snapshot was exported
+	TakenAt time.Time `json:"taken_at"`
+
+	// Retained contains retained messages, oldest first
+	Retained []RetainedMessage `json:"retained"`
+
+	// Subscriptions describes the subscription topology (not live channels)
+	Subscriptions []SubscriptionInfo `json:"subscriptions"`
+}
+
+// RetainedMessage is a retained message with its publish time
+type RetainedMessage struct {
+	At      time.Time      `json:"at"`
+	Message plugin.Message `json:"message"`
+}
+
+// SubscriptionInfo describes a subscription without its channel
+type SubscriptionInfo struct {
+	ID      string   `json:"id"`
+	Topics  []string `json:"topics"`
+	BufSize int      `json:"buf_size"`
+}
+
+// Export captures retained messages and subscription metadata
+func (b *Broker) Export() BrokerSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	snap := BrokerSnapshot{TakenAt: b.clock.Now()}
+
+	for _, r := range b.retained.all(snap.TakenAt) {
+		snap.Retained = append(snap.Retained, RetainedMessage{At: r.at, Message: r.msg})
+	}
+
+	for _, sub := range b.subscriptions {
+		snap.Subscriptions = append(snap.Subscriptions, SubscriptionInfo{
+			ID:      sub.id,
+			Topics:  sub.topics,
+			BufSize: sub.bufSize,
+		})
+	}
+
+	return snap
+}
+
+// ImportSnapshot restores retained messages from a snapshot so they replay to
+// new subscribers. Subscriptions are not recreated; their owners subscribe again
+// when they start
+func (b *Broker) ImportSnapshot(snap BrokerSnapshot) {
+	for _, r := range snap.Retained {
+		b.retained.add(r.Message, r.At)
+	}
+
+	log.Printf("[Broker] Imported snapshot from %s: %d retained message(s), %d subscription(s) in previous topology",
+		snap.TakenAt.Format(time.RFC3339), len(snap.Retained), len(snap.Subscriptions))
+}
+
+// WriteSnapshotFile exports the broker state to a JSON file
+func (b *Broker) WriteSnapshotFile(path string) error {
+	data, err := json.MarshalIndent(b.Export(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// ReadSnapshotFile imports broker state from a JSON file written by WriteSnapshotFile
+func (b *Broker) ReadSnapshotFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap BrokerSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	b.ImportSnapshot(snap)
+	return nil
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+func TestSnapshotReplaysRetainedMessagesInNewBroker(t *testing.T) {
+	old := NewBroker()
+	old.SetRetain(2)
+	old.Subscribe("watcher", 10, "status")
+	for i := 1; i <= 3; i++ {
+		if err := old.Publish(context.Background(), plugin.Message{Topic: "status", Payload: fmt.Sprintf("status %d", i)}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	snap := old.Export()
+	if len(snap.Subscriptions) != 1 || snap.Subscriptions[0].ID != "watcher" || snap.Subscriptions[0].BufSize != 10 {
+		t.Errorf("subscriptions = %+v, want the watcher", snap.Subscriptions)
+	}
+
+	path := filepath.Join(t.TempDir(), "broker.json")
+	if err := old.WriteSnapshotFile(path); err != nil {
+		t.Fatalf("WriteSnapshotFile: %v", err)
+	}
+	old.Close()
+
+	successor := NewBroker()
+	successor.SetRetain(2)
+	if err := successor.ReadSnapshotFile(path); err != nil {
+		t.Fatalf("ReadSnapshotFile: %v", err)
+	}
+
+	ch := successor.Subscribe("watcher", 10, "status")
+	for _, want := range []string{"status 2", "status 3"} {
+		select {
+		case msg := <-ch:
+			if msg.Payload != want {
+				t.Errorf("replayed %v, want %s", msg.Payload, want)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("%s not replayed", want)
+		}
+	}
+	select {
+	case msg := <-ch:
+		t.Errorf("replayed extra message %v", msg.Payload)
+	default:
+	}
+}
+
+func TestReadSnapshotFileMissing(t *testing.T) {
+	if err := NewBroker().ReadSnapshotFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("ReadSnapshotFile succeeded for a missing file")
+	}
+}
+
+func TestDaemonHandsOffRetainedMessagesThroughSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.json")
+	newConfig := func() *config.Config {
+		cfg := config.DefaultConfig()
+		cfg.Daemon.BrokerRetain = 5
+		cfg.Daemon.BrokerSnapshotFile = path
+		return cfg
+	}
+
+	first := startDaemon(t, newConfig())
+	first.broker.Publish(context.Background(), plugin.Message{Topic: "status", Payload: "before restart"})
+	if err := first.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	second := startDaemon(t, newConfig())
+	select {
+	case msg := <-second.broker.Subscribe("watcher", 10, "status"):
+		if msg.Payload != "before restart" {
+			t.Errorf("replayed %v, want the message from before the restart", msg.Payload)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("retained message not replayed after the restart")
+	}
+}
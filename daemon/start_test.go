@@ -0,0 +1,243 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// flakyPlugin fails its first failures starts, and its requirements check
+// when requirementErr is set
+type flakyPlugin struct {
+	failures       int32
+	requirementErr error
+	starts         atomic.Int32
+}
+
+func (p *flakyPlugin) Name() string                   { return "flaky" }
+func (p *flakyPlugin) Extensions() []plugin.Extension { return nil }
+func (p *flakyPlugin) Stop(ctx context.Context) error { return nil }
+
+func (p *flakyPlugin) CheckRequirements(ctx context.Context) error {
+	return p.requirementErr
+}
+
+func (p *flakyPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	if p.starts.Add(1) <= p.failures {
+		return errors.New("port in use")
+	}
+	return nil
+}
+
+// flakyConfig returns a config enabling the flaky plugin with retries
+func flakyConfig(retries, delay int) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.PluginStartTimeout = 0
+	cfg.Plugins["flaky"] = config.PluginConfig{Enabled: true, StartRetries: retries, StartRetryDelay: delay}
+	return cfg
+}
+
+// pluginRunning reports whether the daemon started the named plugin
+func pluginRunning(d *Daemon, name string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.isStarted(name)
+}
+
+func TestPluginStartRetried(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		retries     int
+		wantRunning bool
+	}{
+		{"succeeds on the last retry", 2, 2, true},
+		{"fails every attempt", 3, 2, false},
+		{"not retried by default", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &flakyPlugin{failures: tt.failures}
+			d := New(flakyConfig(tt.retries, 0))
+			if err := d.AddPlugin(p); err != nil {
+				t.Fatalf("AddPlugin: %v", err)
+			}
+			if err := d.Start(); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer d.Stop()
+
+			if got := pluginRunning(d, "flaky"); got != tt.wantRunning {
+				t.Errorf("running = %v, want %v", got, tt.wantRunning)
+			}
+			if got, want := p.starts.Load(), int32(tt.retries+1); got != want {
+				t.Errorf("Start called %d times, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestPluginStartRetryWaitsForDelay(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	p := &flakyPlugin{failures: 1}
+	d := NewWithClock(flakyConfig(1, 10), fake)
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- d.Start() }()
+	defer d.Stop()
+
+	waitForWaiters(t, fake, 1)
+	fake.Advance(9 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if got := p.starts.Load(); got != 1 {
+		t.Fatalf("Start called %d times before the retry delay, want 1", got)
+	}
+
+	fake.Advance(time.Second)
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("daemon did not finish starting after the retry delay")
+	}
+	if !pluginRunning(d, "flaky") || p.starts.Load() != 2 {
+		t.Errorf("running = %v after %d starts, want running after 2", pluginRunning(d, "flaky"), p.starts.Load())
+	}
+}
+
+func TestPluginRequirementFailureNotRetried(t *testing.T) {
+	p := &flakyPlugin{requirementErr: errors.New("no terminal")}
+	d := New(flakyConfig(3, 0))
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	if got := p.starts.Load(); got != 0 {
+		t.Errorf("Start called %d times for a plugin failing its requirements, want 0", got)
+	}
+	if pluginRunning(d, "flaky") {
+		t.Error("plugin failing its requirements is running")
+	}
+}
+
+// blockingPlugin's Start blocks until release is closed
+type blockingPlugin struct {
+	name    string
+	release chan struct{}
+	stopped atomic.Bool
+}
+
+func (p *blockingPlugin) Name() string                                { return p.name }
+func (p *blockingPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *blockingPlugin) Extensions() []plugin.Extension              { return nil }
+
+func (p *blockingPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	<-p.release
+	return nil
+}
+
+func (p *blockingPlugin) Stop(ctx context.Context) error {
+	p.stopped.Store(true)
+	return nil
+}
+
+func TestBlockingPluginStartTimesOut(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Plugins["stuck"] = config.PluginConfig{Enabled: true, StartTimeout: 10}
+	cfg.Plugins["flaky"] = config.PluginConfig{Enabled: true}
+
+	fake := clock.NewFake(time.Unix(0, 0))
+	d := NewWithClock(cfg, fake)
+	stuck := &blockingPlugin{name: "stuck", release: make(chan struct{})}
+	fine := &flakyPlugin{}
+	for _, p := range []plugin.Plugin{stuck, fine} {
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- d.Start() }()
+	defer d.Stop()
+
+	waitForWaiters(t, fake, 1)
+	fake.Advance(10 * time.Second)
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("daemon startup hung on the blocking plugin")
+	}
+
+	if pluginRunning(d, "stuck") {
+		t.Error("plugin that timed out is running")
+	}
+	if !pluginRunning(d, "flaky") {
+		t.Error("plugin after the blocking one did not start")
+	}
+
+	// A start that finishes after the timeout is undone
+	close(stuck.release)
+	waitFor(t, "the late plugin to be stopped", stuck.stopped.Load)
+}
+
+// readinessPlugin records whether the daemon was ready while it started
+type readinessPlugin struct {
+	readyAtStart atomic.Bool
+}
+
+func (p *readinessPlugin) Name() string                                { return "watcher" }
+func (p *readinessPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *readinessPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *readinessPlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *readinessPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	p.readyAtStart.Store(plugin.IsReady(ctx))
+	return nil
+}
+
+func TestDaemonReadyOnlyAfterStart(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Plugins["watcher"] = config.PluginConfig{Enabled: true}
+	d := New(cfg)
+	p := &readinessPlugin{}
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+
+	if d.Ready() {
+		t.Error("ready before Start")
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if p.readyAtStart.Load() {
+		t.Error("ready while plugins were still starting")
+	}
+	if !d.Ready() {
+		t.Error("not ready after Start")
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if d.Ready() {
+		t.Error("still ready after Stop")
+	}
+}
@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// StatusSnapshot is a point-in-time view of the daemon's state
+type StatusSnapshot struct {
+	// Timestamp is when the snapshot was taken
+	Timestamp time.Time `json:"timestamp"`
+
+	// State is the daemon state
+	State State `json:"state"`
+
+	// Mode is the execution mode
+	Mode plugin.Mode `json:"mode"`
+
+	// ActivePlugins is the number of running plugins
+	ActivePlugins int `json:"active_plugins"`
+
+	// Uptime is the time since the daemon started (zero if not started)
+	Uptime time.Duration `json:"uptime"`
+
+	// CurrentTask is the longest-running task, if any
+	CurrentTask *plugin.Task `json:"current_task,omitempty"`
+
+	// RunningTasks is how many tasks are running
+	RunningTasks int `json:"running_tasks,omitempty"`
+
+	// QueuedTasks is how many tasks are waiting to start
+	QueuedTasks int `json:"queued_tasks,omitempty"`
+
+	// Progress is the executor's progress on the current task (0-100)
+	Progress int `json:"progress,omitempty"`
+
+	// Message is the executor's status message for the current task
+	Message string `json:"message,omitempty"`
+
+	// Sections are contributed by plugins implementing plugin.StatusReporter
+	Sections []StatusSection `json:"sections,omitempty"`
+}
+
+// StatusSection is a plugin-contributed part of the status output
+type StatusSection struct {
+	Plugin string   `json:"plugin"`
+	Title  string   `json:"title"`
+	Lines  []string `json:"lines"`
+}
+
+// Snapshot captures the current daemon status
+func (d *Daemon) Snapshot(ctx context.Context) *StatusSnapshot {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	now := d.clock.Now()
+	snap := &StatusSnapshot{
+		Timestamp:     now,
+		State:         d.state,
+		Mode:          d.config.Mode,
+		ActivePlugins: len(d.plugins),
+	}
+	if !d.startedAt.IsZero() {
+		snap.Uptime = now.Sub(d.startedAt)
+	}
+
+	if len(d.running) > 0 {
+		current := d.running[0]
+		snap.CurrentTask = current.task
+		snap.RunningTasks = len(d.running)
+
+		// Get executor status if available
+		if execStatus, err := current.executor.GetStatus(ctx); err == nil {
+			snap.Progress = execStatus.Progress
+			snap.Message = execStatus.Message
+		}
+	}
+	snap.QueuedTasks = len(d.queue)
+
+	// Collect plugin sections in name order for stable output
+	names := make([]string, 0, len(d.plugins))
+	for name := range d.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if reporter, ok := d.plugins[name].(plugin.StatusReporter); ok {
+			title, lines := reporter.StatusSection()
+			snap.Sections = append(snap.Sections, StatusSection{
+				Plugin: name,
+				Title:  title,
+				Lines:  lines,
+			})
+		}
+	}
+
+	return snap
+}
+
+// String renders the snapshot in the multi-line status format
+func (s *StatusSnapshot) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("Daemon Status:\n")
+	sb.WriteString(fmt.Sprintf("  State: %s\n", s.State))
+	sb.WriteString(fmt.Sprintf("  Mode: %s\n", s.Mode))
+	sb.WriteString(fmt.Sprintf("  Active Plugins: %d\n", s.ActivePlugins))
+	if s.Uptime > 0 {
+		sb.WriteString(fmt.Sprintf("  Uptime: %s\n", s.Uptime.Round(time.Second)))
+	}
+
+	if s.CurrentTask != nil {
+		sb.WriteString(fmt.Sprintf("  Current Task: %s (ID: %s)\n", s.CurrentTask.Type, s.CurrentTask.ID))
+		sb.WriteString(fmt.Sprintf("  Progress: %d%%\n", s.Progress))
+		if s.Message != "" {
+			sb.WriteString(fmt.Sprintf("  Message: %s\n", s.Message))
+		}
+	}
+	if s.RunningTasks > 1 {
+		sb.WriteString(fmt.Sprintf("  Running Tasks: %d\n", s.RunningTasks))
+	}
+	if s.QueuedTasks > 0 {
+		sb.WriteString(fmt.Sprintf("  Queued Tasks: %d\n", s.QueuedTasks))
+	}
+
+	for _, section := range s.Sections {
+		sb.WriteString(fmt.Sprintf("\n%s:\n", section.Title))
+		for _, line := range section.Lines {
+			sb.WriteString(fmt.Sprintf("  %s\n", line))
+		}
+	}
+
+	return sb.String()
+}
+
+// statusTemplateFuncs are available to status templates
+var statusTemplateFuncs = template.FuncMap{
+	// round drops the fractional seconds of a duration, e.g. {{round .Uptime}}
+	"round": func(d time.Duration) time.Duration {
+		return d.Round(time.Second)
+	},
+}
+
+// parseStatusTemplate parses the daemon.status_template setting
+// An empty or invalid template gives nil, so the default format is used
+func parseStatusTemplate(text string) *template.Template {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	tmpl, err := template.New("status").Funcs(statusTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		log.Printf("[Daemon] Warning: invalid status_template, using the default status format: %v", err)
+		return nil
+	}
+	return tmpl
+}
+
+// Render renders the snapshot with tmpl, falling back to String when tmpl is
+// nil or fails to execute
+func (s *StatusSnapshot) Render(tmpl *template.Template) string {
+	if tmpl == nil {
+		return s.String()
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, s); err != nil {
+		log.Printf("[Daemon] Warning: status_template failed, using the default status format: %v", err)
+		return s.String()
+	}
+	return sb.String()
+}
+
+// GetStatus returns a status string for the daemon, rendered with
+// daemon.status_template when one is set
+func (d *Daemon) GetStatus(ctx context.Context) string {
+	snap := d.Snapshot(ctx)
+
+	d.mu.RLock()
+	tmpl := d.statusTemplate
+	d.mu.RUnlock()
+
+	return snap.Render(tmpl)
+}
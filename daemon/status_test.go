@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// reportingPlugin contributes a status section
+type reportingPlugin struct {
+	name  string
+	title string
+	lines []string
+}
+
+func (p *reportingPlugin) Name() string                                { return p.name }
+func (p *reportingPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *reportingPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *reportingPlugin) Stop(ctx context.Context) error              { return nil }
+func (p *reportingPlugin) StatusSection() (string, []string)           { return p.title, p.lines }
+
+func (p *reportingPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	return nil
+}
+
+func TestStatusIncludesPluginSections(t *testing.T) {
+	cfg := config.DefaultConfig()
+	d := New(cfg)
+	for _, p := range []*reportingPlugin{
+		{name: "zeta", title: "Zeta", lines: []string{"Connected clients: 2"}},
+		{name: "alpha", title: "Alpha", lines: []string{"Active chat: 42", "Tokens: 100"}},
+	} {
+		cfg.Plugins[p.name] = config.PluginConfig{Enabled: true}
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+
+	status := d.GetStatus(context.Background())
+	want := "\nAlpha:\n  Active chat: 42\n  Tokens: 100\n\nZeta:\n  Connected clients: 2\n"
+	if !strings.HasSuffix(status, want) {
+		t.Errorf("status = %q, want it to end with the plugin sections %q", status, want)
+	}
+
+	sections := d.Snapshot(context.Background()).Sections
+	if len(sections) != 2 || sections[0].Plugin != "alpha" || sections[1].Plugin != "zeta" {
+		t.Errorf("snapshot sections = %+v, want alpha then zeta", sections)
+	}
+}
+
+func TestStatusWithoutReportingPlugins(t *testing.T) {
+	d := startDaemon(t, config.DefaultConfig())
+
+	if sections := d.Snapshot(context.Background()).Sections; len(sections) != 0 {
+		t.Errorf("snapshot sections = %+v, want none", sections)
+	}
+	if status := d.GetStatus(context.Background()); strings.Contains(status, "\n\n") {
+		t.Errorf("status = %q, want no section blocks", status)
+	}
+}
+
+func TestStatusTemplateRendersSnapshot(t *testing.T) {
+	snap := &StatusSnapshot{
+		State:         StateWorking,
+		Mode:          plugin.ModeDaemon,
+		ActivePlugins: 3,
+		Uptime:        90*time.Minute + 1500*time.Millisecond,
+		CurrentTask:   &plugin.Task{ID: "t1", Type: "llm_query"},
+		Progress:      40,
+		QueuedTasks:   2,
+	}
+
+	tmpl := parseStatusTemplate(`{{.State}} | {{.ActivePlugins}} plugins | up {{round .Uptime}}{{with .CurrentTask}} | {{.Type}} {{$.Progress}}%{{end}} | {{.QueuedTasks}} queued`)
+	if tmpl == nil {
+		t.Fatal("template did not parse")
+	}
+	want := "working | 3 plugins | up 1h30m2s | llm_query 40% | 2 queued"
+	if got := snap.Render(tmpl); got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+}
+
+func TestBadStatusTemplateFallsBackToDefault(t *testing.T) {
+	logs := captureLog(t)
+	snap := &StatusSnapshot{State: StateIdle, Mode: plugin.ModeDaemon}
+
+	if tmpl := parseStatusTemplate("{{.State"); tmpl != nil {
+		t.Error("invalid template parsed")
+	}
+	if len(logs.lines("invalid status_template")) != 1 {
+		t.Error("no warning for the invalid template")
+	}
+
+	// A template that fails on this snapshot falls back too
+	failing := parseStatusTemplate("{{.CurrentTask.ID}}")
+	if got := snap.Render(failing); got != snap.String() {
+		t.Errorf("status = %q, want the default format", got)
+	}
+}
+
+func TestDaemonStatusUsesConfiguredTemplate(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.StatusTemplate = "{{.State}} ({{.Mode}})"
+	d := startDaemon(t, cfg)
+
+	if got := d.GetStatus(context.Background()); got != "idle (daemon)" {
+		t.Errorf("status = %q, want the templated one-liner", got)
+	}
+}
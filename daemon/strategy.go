@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// executorPicker chooses among the executors that can handle a task type
+// It is guarded by the daemon's mutex
+type executorPicker struct {
+	strategy string
+	weights  map[string]int
+
+	// next is the round-robin position per task type
+	next map[string]int
+
+	// current is the smooth weighted round-robin score per task type and executor
+	current map[string]map[plugin.Executor]int
+
+	// busy counts the tasks each executor is running
+	busy map[plugin.Executor]int
+}
+
+// newExecutorPicker creates a picker using the given strategy and weights
+func newExecutorPicker(strategy string, weights map[string]int) *executorPicker {
+	return &executorPicker{
+		strategy: strategy,
+		weights:  weights,
+		next:     make(map[string]int),
+		current:  make(map[string]map[plugin.Executor]int),
+		busy:     make(map[plugin.Executor]int),
+	}
+}
+
+// pick returns the executor to run a task of taskType, advancing the
+// strategy's state. candidates must not be empty
+func (p *executorPicker) pick(taskType string, candidates []plugin.Executor) plugin.Executor {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	switch p.strategy {
+	case config.ExecutorStrategyRoundRobin:
+		i := p.next[taskType] % len(candidates)
+		p.next[taskType] = i + 1
+		return candidates[i]
+
+	case config.ExecutorStrategyWeighted:
+		return p.pickWeighted(taskType, candidates)
+
+	case config.ExecutorStrategyLeastBusy:
+		// Ties go to the earliest registered executor
+		best := candidates[0]
+		for _, executor := range candidates[1:] {
+			if p.busy[executor] < p.busy[best] {
+				best = executor
+			}
+		}
+		return best
+	}
+
+	return candidates[0]
+}
+
+// pickWeighted applies smooth weighted round-robin: each executor gains its
+// weight every pick, and the leader is chosen and set back by the total,
+// spreading picks evenly in proportion to the weights
+func (p *executorPicker) pickWeighted(taskType string, candidates []plugin.Executor) plugin.Executor {
+	current := p.current[taskType]
+	if current == nil {
+		current = make(map[plugin.Executor]int)
+		p.current[taskType] = current
+	}
+
+	var best plugin.Executor
+	total := 0
+	for _, executor := range candidates {
+		weight := p.weight(executor)
+		total += weight
+		current[executor] += weight
+		if best == nil || current[executor] > current[best] {
+			best = executor
+		}
+	}
+	current[best] -= total
+	return best
+}
+
+// weight returns an executor's configured weight, defaulting to 1
+func (p *executorPicker) weight(executor plugin.Executor) int {
+	if w, ok := p.weights[executor.Name()]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// acquire records that executor started a task
+func (p *executorPicker) acquire(executor plugin.Executor) {
+	p.busy[executor]++
+}
+
+// release records that executor finished a task
+func (p *executorPicker) release(executor plugin.Executor) {
+	if p.busy[executor] <= 1 {
+		delete(p.busy, executor)
+		return
+	}
+	p.busy[executor]--
+}
@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"fmt"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// namedExecutor is a fake executor with its own name, for weights
+type namedExecutor struct {
+	*testutil.Executor
+	name string
+}
+
+func (e *namedExecutor) Name() string { return e.name }
+
+// threeExecutors returns executors a, b and c handling "work"
+func threeExecutors() []plugin.Executor {
+	var executors []plugin.Executor
+	for _, name := range []string{"a", "b", "c"} {
+		executors = append(executors, &namedExecutor{Executor: testutil.NewExecutor("work"), name: name})
+	}
+	return executors
+}
+
+// picks returns how many of n picks went to each executor, by name
+func picks(p *executorPicker, candidates []plugin.Executor, n int) map[string]int {
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[p.pick("work", candidates).Name()]++
+	}
+	return counts
+}
+
+func TestRoundRobinSpreadsEvenly(t *testing.T) {
+	candidates := threeExecutors()
+	p := newExecutorPicker(config.ExecutorStrategyRoundRobin, nil)
+
+	var order []string
+	for i := 0; i < 6; i++ {
+		order = append(order, p.pick("work", candidates).Name())
+	}
+	if got := fmt.Sprint(order); got != "[a b c a b c]" {
+		t.Errorf("picked %s, want [a b c a b c]", got)
+	}
+}
+
+func TestWeightedFollowsWeights(t *testing.T) {
+	candidates := threeExecutors()
+	p := newExecutorPicker(config.ExecutorStrategyWeighted, map[string]int{"a": 3, "b": 2})
+
+	counts := picks(p, candidates, 60)
+	if counts["a"] != 30 || counts["b"] != 20 || counts["c"] != 10 {
+		t.Errorf("picks = %v, want a:30 b:20 c:10", counts)
+	}
+}
+
+func TestLeastBusyPicksFewestRunning(t *testing.T) {
+	candidates := threeExecutors()
+	p := newExecutorPicker(config.ExecutorStrategyLeastBusy, nil)
+
+	// Each pick starts a task, so three picks use every executor once
+	for i, want := range []string{"a", "b", "c", "a"} {
+		executor := p.pick("work", candidates)
+		if executor.Name() != want {
+			t.Errorf("pick %d = %s, want %s", i, executor.Name(), want)
+		}
+		p.acquire(executor)
+	}
+
+	// b finishing makes it the least busy
+	p.release(candidates[1])
+	if got := p.pick("work", candidates).Name(); got != "b" {
+		t.Errorf("pick after b finished = %s, want b", got)
+	}
+}
+
+func TestFirstStrategyAlwaysPicksFirst(t *testing.T) {
+	candidates := threeExecutors()
+	p := newExecutorPicker(config.ExecutorStrategyFirst, nil)
+
+	if counts := picks(p, candidates, 5); counts["a"] != 5 {
+		t.Errorf("picks = %v, want all a", counts)
+	}
+}
+
+func TestDaemonRoundRobinsTasks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Daemon.ExecutorStrategy = config.ExecutorStrategyRoundRobin
+	executors := []*testutil.Executor{testutil.NewExecutor("work"), testutil.NewExecutor("work"), testutil.NewExecutor("work")}
+	d := startDaemon(t, cfg, executors...)
+
+	for i := 0; i < 6; i++ {
+		submit(t, d, fmt.Sprintf("t%d", i), "work")
+		waitFor(t, "the task to finish", func() bool { return len(d.TaskResults()) == i+1 })
+	}
+	for i, executor := range executors {
+		if got := len(executor.Executed()); got != 2 {
+			t.Errorf("executor %d ran %d tasks, want 2", i, got)
+		}
+	}
+}
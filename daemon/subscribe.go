@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"context"
+
+	"bicycle/plugin"
+)
+
+// subscribePlugin lets a plugin implementing plugin.Subscriber subscribe
+// ahead of its start and returns the ids of the subscriptions it created, so
+// they can be dropped if the plugin doesn't start after all
+// Caller must hold d.mu
+func (d *Daemon) subscribePlugin(ctx context.Context, p plugin.Plugin) ([]string, error) {
+	subscriber, ok := p.(plugin.Subscriber)
+	if !ok {
+		return nil, nil
+	}
+
+	ids, err := subscriber.Subscribe(plugin.WithPluginName(ctx, p.Name()), d.broker)
+	if err != nil {
+		d.unsubscribeAll(ids)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// unsubscribeAll removes the subscriptions with the given ids
+func (d *Daemon) unsubscribeAll(ids []string) {
+	for _, id := range ids {
+		d.broker.Unsubscribe(id)
+	}
+}
+
+// missingDependency returns the first dependency of p that is no longer
+// among the daemon's plugins, or ""
+// Caller must hold d.mu
+func (d *Daemon) missingDependency(p plugin.Plugin) string {
+	for _, dep := range pluginDependencies(p) {
+		if _, ok := d.plugins[dep]; !ok {
+			return dep
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// announcingPlugin publishes an announcement as it starts
+type announcingPlugin struct{}
+
+func (p *announcingPlugin) Name() string                                { return "announcer" }
+func (p *announcingPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *announcingPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *announcingPlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *announcingPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	return broker.Publish(ctx, plugin.Message{Topic: "announce", Payload: "hello", Source: "announcer"})
+}
+
+// listeningPlugin subscribes to announcements before it starts, after the
+// announcer, and fails its start when startErr is set
+type listeningPlugin struct {
+	startErr error
+	ch       <-chan plugin.Message
+}
+
+func (p *listeningPlugin) Name() string                                { return "listener" }
+func (p *listeningPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *listeningPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *listeningPlugin) Dependencies() []string                      { return []string{"announcer"} }
+func (p *listeningPlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *listeningPlugin) Subscribe(ctx context.Context, broker plugin.MessageBroker) ([]string, error) {
+	p.ch = broker.Subscribe("listener", 10, "announce")
+	return []string{"listener"}, nil
+}
+
+func (p *listeningPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	return p.startErr
+}
+
+// startAnnouncing starts a daemon with the announcer and listener
+func startAnnouncing(t *testing.T, listener *listeningPlugin) *Daemon {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	d := New(cfg)
+	for _, p := range []plugin.Plugin{listener, &announcingPlugin{}} {
+		cfg.Plugins[p.Name()] = config.PluginConfig{Enabled: true}
+		if err := d.AddPlugin(p); err != nil {
+			t.Fatalf("AddPlugin: %v", err)
+		}
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { d.Stop() })
+	return d
+}
+
+func TestSubscribersReceiveStartupMessages(t *testing.T) {
+	listener := &listeningPlugin{}
+	startAnnouncing(t, listener)
+
+	select {
+	case msg := <-listener.ch:
+		if msg.Payload != "hello" {
+			t.Errorf("received %v, want the announcement", msg.Payload)
+		}
+	default:
+		t.Fatal("the announcement published before the listener started was lost")
+	}
+}
+
+func TestFailedStartDropsEarlySubscriptions(t *testing.T) {
+	d := startAnnouncing(t, &listeningPlugin{startErr: errors.New("port in use")})
+
+	if pluginRunning(d, "listener") {
+		t.Fatal("listener running after a failed start")
+	}
+	if counts := d.broker.TopicSubscriberCounts(); counts["announce"] != 0 {
+		t.Errorf("subscriber counts = %v, want the listener's subscription dropped", counts)
+	}
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// RestartPlugin stops a running plugin and starts it again with the daemon's
+// context, keeping its place in the start order
+func (d *Daemon) RestartPlugin(name string) error {
+	return d.restartPlugin(nil, name)
+}
+
+// restartPlugin restarts a plugin, starting it with ctx (nil = the daemon's context)
+func (d *Daemon) restartPlugin(ctx context.Context, name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if ctx == nil {
+		ctx = d.ctx
+	}
+
+	if !d.ready.Load() {
+		return fmt.Errorf("daemon is not running")
+	}
+	p, ok := d.plugins[name]
+	if !ok || !d.isStarted(name) {
+		return fmt.Errorf("plugin not running: %s", name)
+	}
+
+	log.Printf("[Daemon] Restarting plugin: %s", name)
+	if err := p.Stop(ctx); err != nil {
+		log.Printf("[Daemon] Error stopping plugin %s: %v", name, err)
+	}
+	ids, err := d.subscribePlugin(ctx, p)
+	if err != nil {
+		return fmt.Errorf("failed to restart plugin %s: %w", name, err)
+	}
+	if err := d.startPlugin(ctx, p); err != nil {
+		d.unsubscribeAll(ids)
+		return fmt.Errorf("failed to restart plugin %s: %w", name, err)
+	}
+
+	log.Printf("[Daemon] Restarted plugin: %s", name)
+	return nil
+}
+
+// isStarted reports whether the named plugin was started
+// Caller must hold d.mu
+func (d *Daemon) isStarted(name string) bool {
+	for _, started := range d.started {
+		if started == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runSupervisor health-checks plugins every interval and restarts those that
+// fail threshold checks in a row
+func (d *Daemon) runSupervisor(interval time.Duration, threshold int) {
+	defer d.wg.Done()
+
+	if threshold < 1 {
+		threshold = 1
+	}
+	failures := make(map[string]int)
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-d.clock.After(interval):
+			d.superviseOnce(interval, threshold, failures)
+		}
+	}
+}
+
+// superviseOnce runs one round of health checks, updating failures
+func (d *Daemon) superviseOnce(timeout time.Duration, threshold int, failures map[string]int) {
+	d.mu.RLock()
+	checkers := make(map[string]plugin.HealthChecker)
+	for _, name := range d.started {
+		if hc, ok := d.plugins[name].(plugin.HealthChecker); ok {
+			checkers[name] = hc
+		}
+	}
+	d.mu.RUnlock()
+
+	for name, hc := range checkers {
+		ctx, cancel := context.WithTimeout(d.ctx, timeout)
+		err := hc.HealthCheck(ctx)
+		cancel()
+
+		if err == nil {
+			delete(failures, name)
+			continue
+		}
+		if d.ctx.Err() != nil {
+			return
+		}
+
+		failures[name]++
+		log.Printf("[Daemon] Plugin %s failed health check (%d/%d): %v", name, failures[name], threshold, err)
+		if failures[name] < threshold {
+			continue
+		}
+
+		delete(failures, name)
+		payload := fmt.Sprintf("Plugin %s restarted after %d failed health checks", name, threshold)
+		severity := plugin.SeverityWarn
+		if err := d.RestartPlugin(name); err != nil {
+			log.Printf("[Daemon] %v", err)
+			payload = fmt.Sprintf("Plugin %s is unhealthy and could not be restarted: %v", name, err)
+			severity = plugin.SeverityError
+		}
+		d.broker.Publish(d.ctx, plugin.Message{
+			Topic:    "notification",
+			Payload:  payload,
+			Source:   "daemon",
+			Metadata: map[string]interface{}{plugin.MetadataSeverity: string(severity)},
+		})
+	}
+}
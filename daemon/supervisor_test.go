@@ -0,0 +1,81 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// sickPlugin fails its health checks while sick is set and counts its starts
+type sickPlugin struct {
+	sick   atomic.Bool
+	starts atomic.Int32
+}
+
+func (p *sickPlugin) Name() string                                { return "sick" }
+func (p *sickPlugin) CheckRequirements(ctx context.Context) error { return nil }
+func (p *sickPlugin) Extensions() []plugin.Extension              { return nil }
+func (p *sickPlugin) Stop(ctx context.Context) error              { return nil }
+
+func (p *sickPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	p.starts.Add(1)
+	return nil
+}
+
+func (p *sickPlugin) HealthCheck(ctx context.Context) error {
+	if p.sick.Load() {
+		return errors.New("receive loop exited")
+	}
+	return nil
+}
+
+func TestSupervisorRestartsUnhealthyPlugin(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Plugins["sick"] = config.PluginConfig{Enabled: true}
+	d := New(cfg)
+	p := &sickPlugin{}
+	if err := d.AddPlugin(p); err != nil {
+		t.Fatalf("AddPlugin: %v", err)
+	}
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer d.Stop()
+	notifications := testutil.Collect(d.broker, "test", "notification")
+
+	failures := make(map[string]int)
+	check := func() { d.superviseOnce(time.Second, 2, failures) }
+
+	// A healthy check in between resets the count
+	p.sick.Store(true)
+	check()
+	p.sick.Store(false)
+	check()
+	p.sick.Store(true)
+	check()
+	if got := p.starts.Load(); got != 1 {
+		t.Fatalf("started %d times before reaching the threshold, want 1", got)
+	}
+
+	check()
+	if got := p.starts.Load(); got != 2 {
+		t.Fatalf("started %d times after 2 failed checks in a row, want a restart", got)
+	}
+	msgs := notifications.WaitFor(1, testTimeout)
+	if len(msgs) != 1 || !strings.Contains(plugin.PayloadText(msgs[0].Payload), "Plugin sick restarted after 2 failed health checks") {
+		t.Errorf("notifications = %v, want the restart reported", msgs)
+	}
+
+	// The count starts over after a restart
+	check()
+	if got := p.starts.Load(); got != 2 {
+		t.Errorf("started %d times after one more failure, want no second restart yet", got)
+	}
+}
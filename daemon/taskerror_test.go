@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestTaskErrorRecordedAndPublished(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *plugin.TaskError
+		attempts int
+	}{
+		{"retryable", plugin.NewTaskError("rate_limited", "slow down", true), 2},
+		{"fatal", plugin.NewTaskError("bad_input", "unparseable", false), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Daemon.Tasks.MaxRetries = 1
+
+			executor := newFailingExecutor(tt.err)
+			d := startDaemon(t, cfg, executor)
+			notifications := testutil.Collect(d.broker, "test", "notification")
+			retries := testutil.Collect(d.broker, "retries", plugin.TopicTaskRetrying)
+
+			submit(t, d, "t1", "work")
+			waitFor(t, "the task to finish", func() bool { return len(d.TaskResults()) > 0 })
+
+			if got := len(executor.Executed()); got != tt.attempts {
+				t.Errorf("executed %d times, want %d", got, tt.attempts)
+			}
+			if got := len(retries.WaitFor(tt.attempts-1, testTimeout)); got != tt.attempts-1 {
+				t.Errorf("published %d retries, want %d", got, tt.attempts-1)
+			}
+
+			result := d.TaskResults()[0]
+			if result.ErrorDetail == nil || *result.ErrorDetail != *tt.err {
+				t.Errorf("result error detail = %+v, want %+v", result.ErrorDetail, tt.err)
+			}
+
+			msgs := notifications.WaitFor(1, testTimeout)
+			if len(msgs) != 1 {
+				t.Fatalf("got %d notifications, want the failure", len(msgs))
+			}
+			if got := msgs[0].Metadata; got["error_code"] != tt.err.Code || got["retryable"] != tt.err.Retryable {
+				t.Errorf("failure metadata = %v, want code %s, retryable %v", got, tt.err.Code, tt.err.Retryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock abstracts the passage of time so time-dependent behavior
+// (timeouts, timestamps, TTLs) can be driven deterministically in tests
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock implements Clock using the time package
+type realClock struct{}
+
+// Real returns a Clock backed by the system time
+func Real() Clock {
+	return realClock{}
+}
+
+// Now returns the current system time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// After delegates to time.After
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Fake is a manually advanced Clock for tests
+// Time only moves when Advance or Set is called
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// waiter is a pending After call on a fake clock
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewFake creates a fake clock starting at the given time
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake clock's current time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After returns a channel that fires once the fake clock has been advanced by d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- f.now
+		return ch
+	}
+
+	f.waiters = append(f.waiters, &waiter{deadline: f.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the fake clock forward and fires any expired waiters
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+	f.fire()
+}
+
+// Set moves the fake clock to the given time and fires any expired waiters
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	f.now = now
+	f.mu.Unlock()
+	f.fire()
+}
+
+// Waiters returns the number of pending After calls
+// Tests use it to wait until a goroutine is blocked on the clock
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// fire delivers the current time to every waiter whose deadline has passed
+func (f *Fake) fire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Fire in deadline order so earlier timers are observed first
+	sort.Slice(f.waiters, func(i, j int) bool {
+		return f.waiters[i].deadline.Before(f.waiters[j].deadline)
+	})
+
+	pending := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.deadline.After(f.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- f.now
+	}
+	f.waiters = pending
+}
@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeAfterFiresWhenAdvanced(t *testing.T) {
+	start := time.Unix(100, 0)
+	f := NewFake(start)
+
+	late := f.After(2 * time.Second)
+	early := f.After(time.Second)
+	if f.Waiters() != 2 {
+		t.Fatalf("Waiters = %d, want 2", f.Waiters())
+	}
+
+	f.Advance(time.Second)
+	select {
+	case at := <-early:
+		if !at.Equal(start.Add(time.Second)) {
+			t.Errorf("fired at %v, want %v", at, start.Add(time.Second))
+		}
+	default:
+		t.Fatal("one second timer did not fire")
+	}
+	select {
+	case <-late:
+		t.Fatal("two second timer fired early")
+	default:
+	}
+
+	f.Set(start.Add(time.Minute))
+	select {
+	case <-late:
+	default:
+		t.Fatal("two second timer did not fire")
+	}
+	if f.Waiters() != 0 {
+		t.Errorf("Waiters = %d, want 0", f.Waiters())
+	}
+}
+
+func TestFakeAfterZeroFiresNow(t *testing.T) {
+	f := NewFake(time.Unix(100, 0))
+	select {
+	case <-f.After(0):
+	default:
+		t.Error("After(0) did not fire immediately")
+	}
+	if !f.Now().Equal(time.Unix(100, 0)) {
+		t.Errorf("Now = %v, want the start time", f.Now())
+	}
+}
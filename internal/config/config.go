@@ -1,11 +1,16 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"strings"
 
 	"bicycle/plugin"
 
+	"github.com/mattn/go-isatty"
 	"gopkg.in/yaml.v3"
 )
 
@@ -19,6 +24,25 @@ type Config struct {
 
 	// Mode specifies the execution mode
 	Mode plugin.Mode `yaml:"mode"`
+
+	// ModeDetected is set when Mode was not configured and was picked by
+	// DetectMode
+	ModeDetected bool `yaml:"-"`
+
+	// Profiles maps a profile name to the set of plugins it enables
+	Profiles map[string][]string `yaml:"profiles,omitempty"`
+
+	// ActiveProfile selects one of Profiles (empty means no profile)
+	ActiveProfile string `yaml:"active_profile,omitempty"`
+
+	// EnableLabels enables plugins carrying any of these labels
+	EnableLabels []string `yaml:"enable_labels,omitempty"`
+
+	// DisableLabels disables plugins carrying any of these labels
+	DisableLabels []string `yaml:"disable_labels,omitempty"`
+
+	// Sources lists the files the configuration was loaded from, in merge order
+	Sources []string `yaml:"-"`
 }
 
 // DaemonConfig contains daemon-specific configuration
@@ -31,6 +55,177 @@ type DaemonConfig struct {
 
 	// PublishTimeout is the timeout for publishing messages (in seconds)
 	PublishTimeout int `yaml:"publish_timeout"`
+
+	// BrokerAsync makes Publish return immediately and deliver in the background
+	BrokerAsync bool `yaml:"broker_async"`
+
+	// BrokerRetain is how many recent messages are retained per topic and
+	// replayed to new subscribers (0 = disabled)
+	BrokerRetain int `yaml:"broker_retain"`
+
+	// BrokerRetainTTL is how long retained messages stay eligible for replay
+	// (in seconds, 0 = no limit)
+	BrokerRetainTTL int `yaml:"broker_retain_ttl"`
+
+	// BrokerSnapshotFile is where retained messages are dumped on shutdown and
+	// restored from on start (empty = disabled)
+	BrokerSnapshotFile string `yaml:"broker_snapshot_file"`
+
+	// BrokerFanoutLimit caps concurrent deliveries per publish (0 = unbounded)
+	BrokerFanoutLimit int `yaml:"broker_fanout_limit"`
+
+	// BrokerMaxAge drops messages that waited longer than this for delivery
+	// (in milliseconds, 0 = no limit); Message.MaxAge overrides it
+	BrokerMaxAge int `yaml:"broker_max_age_ms"`
+
+	// BrokerMaxPayloadBytes rejects publishes whose payload is larger, as
+	// string or JSON-encoded size (0 = no limit)
+	BrokerMaxPayloadBytes int `yaml:"broker_max_payload_bytes"`
+
+	// BrokerNoSubscribers is what a publish reaching no subscriber does:
+	// "log" (the default), "silent", "debug" (log only at log_level debug)
+	// or "error" (reject it)
+	BrokerNoSubscribers string `yaml:"broker_no_subscribers"`
+
+	// BrokerRequiredTopics must have a subscriber; publishes to them that
+	// reach no one are rejected whatever BrokerNoSubscribers says
+	BrokerRequiredTopics []string `yaml:"broker_required_topics"`
+
+	// ReservedTopics maps a topic to the only sources allowed to publish to it
+	// Topics not listed are open to every source
+	ReservedTopics map[string][]string `yaml:"reserved_topics"`
+
+	// MessageAudit writes every published message to an append-only log
+	MessageAudit AuditConfig `yaml:"message_audit"`
+
+	// CommandRateLimits caps how many commands each principal may run, by role
+	// Roles not listed are unlimited
+	CommandRateLimits map[plugin.Role]RateLimit `yaml:"command_rate_limits"`
+
+	// PluginStartTimeout bounds each plugin Start call (in seconds)
+	PluginStartTimeout int `yaml:"plugin_start_timeout"`
+
+	// CommandTimeout bounds command handling in transports (in seconds); a
+	// transport's own command_timeout setting overrides it
+	CommandTimeout int `yaml:"command_timeout"`
+
+	// HeartbeatInterval is the interval between daemon.heartbeat messages (in seconds)
+	// Zero disables the heartbeat
+	HeartbeatInterval int `yaml:"heartbeat_interval"`
+
+	// SupervisorInterval is the interval between plugin health checks (in
+	// seconds). Zero disables the supervisor
+	SupervisorInterval int `yaml:"supervisor_interval"`
+
+	// SupervisorFailureThreshold is how many health checks in a row a plugin
+	// may fail before it is restarted
+	SupervisorFailureThreshold int `yaml:"supervisor_failure_threshold"`
+
+	// NotificationLogSize is how many recent notifications are kept for /log
+	NotificationLogSize int `yaml:"notification_log_size"`
+
+	// PersistNotifications saves the notification log to the state manager on
+	// shutdown and restores it on start
+	PersistNotifications bool `yaml:"persist_notifications"`
+
+	// ChatHistorySize is how many recent chat messages are kept per
+	// conversation and persisted to the state manager, so executors can
+	// backfill a conversation after a restart (0 = disabled)
+	ChatHistorySize int `yaml:"chat_history_size"`
+
+	// ChatHistoryMaxAge drops kept chat messages older than this
+	// (in seconds, 0 = no limit)
+	ChatHistoryMaxAge int `yaml:"chat_history_max_age"`
+
+	// StatusTemplate is a Go template rendering the status snapshot for
+	// /status and the REST status endpoint (empty = the multi-line format)
+	StatusTemplate string `yaml:"status_template"`
+
+	// TaskMaxRetries is how many more times a failed task is run whatever
+	// the error, before it is dead-lettered (0 = only tasks.max_retries applies)
+	TaskMaxRetries int `yaml:"task_max_retries"`
+
+	// Tasks configures the daemon task system
+	Tasks TaskConfig `yaml:"tasks"`
+
+	// ExecutorStrategy picks among several executors that handle a task type:
+	// first, round_robin, weighted or least_busy (default first)
+	ExecutorStrategy string `yaml:"executor_strategy"`
+
+	// ExecutorWeights gives each executor's share under the weighted strategy,
+	// by executor name (unlisted executors weigh 1)
+	ExecutorWeights map[string]int `yaml:"executor_weights"`
+
+	// PluginDir is a directory of Go plugins (.so files) loaded at startup,
+	// each exporting a Register function (empty = none)
+	PluginDir string `yaml:"plugin_dir"`
+}
+
+// Executor selection strategies
+const (
+	ExecutorStrategyFirst      = "first"
+	ExecutorStrategyRoundRobin = "round_robin"
+	ExecutorStrategyWeighted   = "weighted"
+	ExecutorStrategyLeastBusy  = "least_busy"
+)
+
+// RateLimit allows Commands invocations in any window of Per seconds
+type RateLimit struct {
+	Commands int `yaml:"commands"`
+	Per      int `yaml:"per"`
+}
+
+// AuditConfig contains settings for the broker message audit log
+type AuditConfig struct {
+	// Path is the JSONL file messages are appended to (empty = disabled)
+	Path string `yaml:"path"`
+
+	// MaxBytes is the size at which the file is rotated (0 = never rotate)
+	MaxBytes int64 `yaml:"max_bytes"`
+
+	// MaxBackups is how many rotated files are kept (path.1 is the newest)
+	MaxBackups int `yaml:"max_backups"`
+}
+
+// Validate checks if the audit configuration is valid
+func (a *AuditConfig) Validate() error {
+	if a.MaxBytes < 0 {
+		return fmt.Errorf("message audit max bytes must not be negative")
+	}
+	if a.MaxBackups < 0 {
+		return fmt.Errorf("message audit max backups must not be negative")
+	}
+	return nil
+}
+
+// TaskConfig contains settings for task execution
+type TaskConfig struct {
+	// QueueSize is how many tasks may wait while the daemon is busy (0 = reject when busy)
+	QueueSize int `yaml:"queue_size"`
+
+	// MaxConcurrent is how many tasks may run at once
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	// DefaultTimeout bounds task execution (in seconds, 0 = no limit)
+	DefaultTimeout int `yaml:"default_timeout"`
+
+	// DrainOnShutdown lets a running task finish before the daemon stops
+	// instead of cancelling it
+	DrainOnShutdown bool `yaml:"drain_on_shutdown"`
+
+	// MaxInputBytes caps the encoded size of a task's input (0 = no limit)
+	MaxInputBytes int `yaml:"max_input_bytes"`
+
+	// InputRoot is the directory tasks may read input_file from (empty = disabled)
+	InputRoot string `yaml:"input_root"`
+
+	// MaxRetries is how many more times a task failing with a retryable
+	// plugin.TaskError is run, if more than Daemon.TaskMaxRetries
+	// (0 = never retry)
+	MaxRetries int `yaml:"max_retries"`
+
+	// RetryDelay is the wait between attempts (in seconds)
+	RetryDelay int `yaml:"retry_delay"`
 }
 
 // PluginConfig contains configuration for a specific plugin
@@ -38,23 +233,66 @@ type PluginConfig struct {
 	// Enabled indicates if the plugin should be loaded
 	Enabled bool `yaml:"enabled"`
 
+	// StartRetries is how many more times a failed Start is attempted
+	StartRetries int `yaml:"start_retries,omitempty"`
+
+	// StartRetryDelay is the wait between start attempts (in seconds)
+	StartRetryDelay int `yaml:"start_retry_delay,omitempty"`
+
+	// StartTimeout overrides daemon.plugin_start_timeout for this plugin (in seconds)
+	StartTimeout int `yaml:"start_timeout,omitempty"`
+
+	// Features turns on experimental behaviors the plugin gates with
+	// plugin.HasFeature
+	Features []string `yaml:"features,omitempty"`
+
 	// Settings contains plugin-specific settings
 	Settings map[string]interface{} `yaml:"settings"`
 }
 
 // Load loads configuration from a YAML file
 func Load(path string) (*Config, error) {
-	// Read file
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	return LoadFiles([]string{path})
+}
+
+// LoadFiles loads and merges configuration from YAML files in order
+// Later files override earlier ones: mappings merge key by key (so plugin
+// settings combine), while scalars and lists are replaced
+func LoadFiles(paths []string) (*Config, error) {
+	merged := make(map[string]interface{})
+
+	for _, path := range paths {
+		// Read file
+		data, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parse YAML
+		var layer map[string]interface{}
+		if err := yaml.Unmarshal(data, &layer); err != nil {
+			return nil, parseError(path, err)
+		}
+
+		// Decode the file on its own too, so type errors point at its lines
+		// rather than at the merged tree's
+		var typed Config
+		if err := yaml.Unmarshal(data, &typed); err != nil {
+			return nil, parseError(path, err)
+		}
+		mergeMaps(merged, layer)
 	}
 
-	// Parse YAML
+	// Decode the merged tree into the typed config
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge config: %w", err)
+	}
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	cfg.Sources = paths
 
 	// Apply defaults
 	cfg.applyDefaults()
@@ -67,21 +305,132 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
-// LoadOrDefault loads configuration from a file or returns default config
+// maxConfigBytes is the largest config file Load accepts
+const maxConfigBytes = 1 << 20
+
+// readConfigFile reads a config file, rejecting files over maxConfigBytes
+func readConfigFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if info.Size() > maxConfigBytes {
+		return nil, fmt.Errorf("config file %s is too large (%d bytes, limit %d); is it the right file?", path, info.Size(), maxConfigBytes)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxConfigBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if len(data) > maxConfigBytes {
+		return nil, fmt.Errorf("config file %s is too large (limit %d bytes); is it the right file?", path, maxConfigBytes)
+	}
+	return data, nil
+}
+
+// yamlLine matches the location yaml.v3 puts in its error messages
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// parseError describes a YAML error in path, leading each problem with
+// "path:line:" so editors and terminals can jump to it
+func parseError(path string, err error) error {
+	var problems []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		problems = typeErr.Errors
+	} else {
+		problems = []string{err.Error()}
+	}
+
+	for i, problem := range problems {
+		if m := yamlLine.FindStringSubmatch(problem); m != nil {
+			problems[i] = fmt.Sprintf("%s:%s: %s", path, m[1], m[2])
+		} else {
+			problems[i] = fmt.Sprintf("%s: %s", path, strings.TrimPrefix(problem, "yaml: "))
+		}
+	}
+	return fmt.Errorf("failed to parse config:\n  %s", strings.Join(problems, "\n  "))
+}
+
+// DefaultPath is the config file read when none is given
+const DefaultPath = "config.yaml"
+
+// LoadOrDefault loads configuration from a file or returns default config if
+// the file doesn't exist
 func LoadOrDefault(path string) (*Config, error) {
-	if path == "" || !fileExists(path) {
-		return DefaultConfig(), nil
+	if !fileExists(path) {
+		cfg := DefaultConfig()
+		cfg.Mode = DetectMode()
+		cfg.ModeDetected = true
+		return cfg, nil
+	}
+	return LoadFiles([]string{path})
+}
+
+// LoadFilesOrDefault merges the given files, each of which must exist. With
+// no files it loads DefaultPath, or returns the default config if that
+// doesn't exist
+func LoadFilesOrDefault(paths []string) (*Config, error) {
+	if len(paths) == 0 {
+		return LoadOrDefault(DefaultPath)
+	}
+	for _, path := range paths {
+		if !fileExists(path) {
+			return nil, fmt.Errorf("config file %s not found", path)
+		}
+	}
+	return LoadFiles(paths)
+}
+
+// stdinIsTerminal reports whether stdin is a terminal (replaceable for testing)
+var stdinIsTerminal = func() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// DetectMode picks the mode for a config that doesn't set one: interactive
+// when stdin is a terminal, daemon otherwise
+func DetectMode() plugin.Mode {
+	if stdinIsTerminal() {
+		return plugin.ModeInteractive
+	}
+	return plugin.ModeDaemon
+}
+
+// mergeMaps merges src into dst, recursing into nested mappings
+func mergeMaps(dst, src map[string]interface{}) {
+	for key, srcVal := range src {
+		srcMap, srcIsMap := srcVal.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = srcVal
 	}
-	return Load(path)
 }
 
 // DefaultConfig returns a default configuration
 func DefaultConfig() *Config {
 	cfg := &Config{
 		Daemon: DaemonConfig{
-			LogLevel:         "info",
-			BrokerBufferSize: 100,
-			PublishTimeout:   5,
+			LogLevel:            "info",
+			BrokerBufferSize:    100,
+			PublishTimeout:      5,
+			CommandTimeout:      30,
+			PluginStartTimeout:  30,
+			NotificationLogSize: 50,
+			Tasks: TaskConfig{
+				MaxConcurrent: 1,
+			},
+			ExecutorStrategy:           ExecutorStrategyFirst,
+			SupervisorFailureThreshold: 3,
 		},
 		Plugins: make(map[string]PluginConfig),
 		Mode:    plugin.ModeDaemon,
@@ -101,10 +450,31 @@ func (c *Config) applyDefaults() {
 	if c.Daemon.PublishTimeout == 0 {
 		c.Daemon.PublishTimeout = 5
 	}
+	if c.Daemon.CommandTimeout == 0 {
+		c.Daemon.CommandTimeout = 30
+	}
+	if c.Daemon.PluginStartTimeout == 0 {
+		c.Daemon.PluginStartTimeout = 30
+	}
+	if c.Daemon.NotificationLogSize == 0 {
+		c.Daemon.NotificationLogSize = 50
+	}
+	if c.Daemon.SupervisorFailureThreshold == 0 {
+		c.Daemon.SupervisorFailureThreshold = 3
+	}
+
+	// Task defaults
+	if c.Daemon.Tasks.MaxConcurrent == 0 {
+		c.Daemon.Tasks.MaxConcurrent = 1
+	}
+	if c.Daemon.ExecutorStrategy == "" {
+		c.Daemon.ExecutorStrategy = ExecutorStrategyFirst
+	}
 
-	// Mode defaults
+	// Mode defaults to what the terminal suggests
 	if c.Mode == "" {
-		c.Mode = plugin.ModeDaemon
+		c.Mode = DetectMode()
+		c.ModeDetected = true
 	}
 
 	// Ensure plugins map exists
@@ -141,6 +511,146 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("publish timeout must be at least 1 second")
 	}
 
+	// Validate notification log size
+	if c.Daemon.NotificationLogSize < 1 {
+		return fmt.Errorf("notification log size must be at least 1")
+	}
+
+	// Validate chat history
+	if c.Daemon.ChatHistorySize < 0 {
+		return fmt.Errorf("chat history size must not be negative")
+	}
+	if c.Daemon.ChatHistoryMaxAge < 0 {
+		return fmt.Errorf("chat history max age must not be negative")
+	}
+
+	// Validate task retries
+	if c.Daemon.TaskMaxRetries < 0 {
+		return fmt.Errorf("task max retries must not be negative")
+	}
+
+	// Validate command timeout
+	if c.Daemon.CommandTimeout < 1 {
+		return fmt.Errorf("command timeout must be at least 1 second")
+	}
+
+	// Validate plugin start timeout
+	if c.Daemon.PluginStartTimeout < 1 {
+		return fmt.Errorf("plugin start timeout must be at least 1 second")
+	}
+
+	// Validate retention
+	if c.Daemon.BrokerRetain < 0 {
+		return fmt.Errorf("broker retain count must not be negative")
+	}
+	if c.Daemon.BrokerRetainTTL < 0 {
+		return fmt.Errorf("broker retain TTL must not be negative")
+	}
+
+	// Validate fan-out limit
+	if c.Daemon.BrokerFanoutLimit < 0 {
+		return fmt.Errorf("broker fan-out limit must not be negative")
+	}
+	if c.Daemon.BrokerMaxAge < 0 {
+		return fmt.Errorf("broker max age must not be negative")
+	}
+	if c.Daemon.BrokerMaxPayloadBytes < 0 {
+		return fmt.Errorf("broker max payload bytes must not be negative")
+	}
+	switch c.Daemon.BrokerNoSubscribers {
+	case "", "log", "silent", "debug", "error":
+	default:
+		return fmt.Errorf("invalid broker_no_subscribers: %s (want log, silent, debug or error)", c.Daemon.BrokerNoSubscribers)
+	}
+
+	// Validate heartbeat interval
+	if c.Daemon.HeartbeatInterval < 0 {
+		return fmt.Errorf("heartbeat interval must not be negative")
+	}
+
+	// Validate supervisor settings
+	if c.Daemon.SupervisorInterval < 0 {
+		return fmt.Errorf("supervisor interval must not be negative")
+	}
+	if c.Daemon.SupervisorFailureThreshold < 0 {
+		return fmt.Errorf("supervisor failure threshold must not be negative")
+	}
+
+	// Validate task settings
+	if err := c.Daemon.Tasks.Validate(); err != nil {
+		return err
+	}
+
+	// Validate command rate limits
+	for role, limit := range c.Daemon.CommandRateLimits {
+		if role != plugin.RoleUser && role != plugin.RoleAdmin {
+			return fmt.Errorf("command rate limit for unknown role: %s", role)
+		}
+		if limit.Commands < 1 || limit.Per < 1 {
+			return fmt.Errorf("command rate limit for %s: commands and per must be at least 1", role)
+		}
+	}
+
+	// Validate message audit settings
+	if err := c.Daemon.MessageAudit.Validate(); err != nil {
+		return err
+	}
+
+	// Validate executor selection
+	switch c.Daemon.ExecutorStrategy {
+	case "", ExecutorStrategyFirst, ExecutorStrategyRoundRobin, ExecutorStrategyWeighted, ExecutorStrategyLeastBusy:
+	default:
+		return fmt.Errorf("invalid executor strategy: %s (must be first, round_robin, weighted or least_busy)", c.Daemon.ExecutorStrategy)
+	}
+	for name, weight := range c.Daemon.ExecutorWeights {
+		if weight < 1 {
+			return fmt.Errorf("executor %s: weight must be at least 1", name)
+		}
+	}
+
+	// Validate plugin settings
+	for name, pc := range c.Plugins {
+		if pc.StartRetries < 0 {
+			return fmt.Errorf("plugin %s: start retries must not be negative", name)
+		}
+		if pc.StartRetryDelay < 0 {
+			return fmt.Errorf("plugin %s: start retry delay must not be negative", name)
+		}
+		if pc.StartTimeout < 0 {
+			return fmt.Errorf("plugin %s: start timeout must not be negative", name)
+		}
+	}
+
+	// Validate active profile
+	if c.ActiveProfile != "" {
+		if _, exists := c.Profiles[c.ActiveProfile]; !exists {
+			return fmt.Errorf("unknown profile: %s", c.ActiveProfile)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks if the task configuration is valid
+func (t *TaskConfig) Validate() error {
+	if t.QueueSize < 0 {
+		return fmt.Errorf("task queue size must not be negative")
+	}
+	if t.MaxConcurrent < 1 {
+		return fmt.Errorf("task max concurrent must be at least 1")
+	}
+	if t.DefaultTimeout < 0 {
+		return fmt.Errorf("task default timeout must not be negative")
+	}
+	if t.MaxInputBytes < 0 {
+		return fmt.Errorf("task max input bytes must not be negative")
+	}
+	if t.MaxRetries < 0 {
+		return fmt.Errorf("task max retries must not be negative")
+	}
+	if t.RetryDelay < 0 {
+		return fmt.Errorf("task retry delay must not be negative")
+	}
 	return nil
 }
 
@@ -150,14 +660,50 @@ func (c *Config) GetPluginConfig(name string) (PluginConfig, bool) {
 	return cfg, exists
 }
 
-// IsPluginEnabled checks if a plugin is enabled in the configuration
-func (c *Config) IsPluginEnabled(name string) bool {
+// PluginFeatures returns the features turned on for a plugin
+func (c *Config) PluginFeatures(name string) []string {
+	return c.Plugins[name].Features
+}
+
+// IsPluginEnabled checks if a plugin with the given labels is enabled in the
+// configuration
+// When a profile is active or enable_labels is set, plugins named by the
+// profile or carrying an enabled label are enabled and all others are
+// disabled. Plugins carrying a label in disable_labels are disabled. Either
+// way, a plugin config that explicitly sets enabled: true wins
+func (c *Config) IsPluginEnabled(name string, labels ...string) bool {
 	cfg, exists := c.Plugins[name]
-	if !exists {
-		// If not specified in config, assume enabled
+	if exists && cfg.Enabled {
 		return true
 	}
-	return cfg.Enabled
+
+	if hasAny(labels, c.DisableLabels) {
+		return false
+	}
+
+	if c.ActiveProfile != "" || len(c.EnableLabels) > 0 {
+		for _, p := range c.Profiles[c.ActiveProfile] {
+			if p == name {
+				return true
+			}
+		}
+		return hasAny(labels, c.EnableLabels)
+	}
+
+	// If not specified in config, assume enabled
+	return !exists
+}
+
+// hasAny reports whether labels and set have a label in common
+func hasAny(labels, set []string) bool {
+	for _, label := range labels {
+		for _, s := range set {
+			if label == s {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // GetPluginSetting retrieves a specific setting for a plugin
@@ -208,6 +754,16 @@ func (c *Config) GetPluginSettingBool(pluginName, settingName string) (bool, boo
 	return b, ok
 }
 
+// CommandTimeoutFor returns the command timeout for a transport plugin (in
+// seconds, 0 = no limit): its command_timeout setting if set and not
+// negative, otherwise daemon.command_timeout
+func (c *Config) CommandTimeoutFor(pluginName string) int {
+	if timeout, ok := c.GetPluginSettingInt(pluginName, "command_timeout"); ok && timeout >= 0 {
+		return timeout
+	}
+	return c.Daemon.CommandTimeout
+}
+
 // Save writes the configuration to a YAML file
 func (c *Config) Save(path string) error {
 	data, err := yaml.Marshal(c)
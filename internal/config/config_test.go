@@ -0,0 +1,418 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"bicycle/plugin"
+)
+
+// writeConfig writes a config file into a temporary directory and returns its path
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	return path
+}
+
+func TestTaskDefaults(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "daemon:\n  log_level: debug\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tasks := cfg.Daemon.Tasks
+	if tasks.MaxConcurrent != 1 {
+		t.Errorf("MaxConcurrent = %d, want 1", tasks.MaxConcurrent)
+	}
+	if tasks.QueueSize != 0 {
+		t.Errorf("QueueSize = %d, want 0", tasks.QueueSize)
+	}
+	if got := DefaultConfig().Daemon.Tasks.MaxConcurrent; got != 1 {
+		t.Errorf("DefaultConfig MaxConcurrent = %d, want 1", got)
+	}
+}
+
+func TestTaskSettingsLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "daemon:\n  tasks:\n    queue_size: 8\n    max_concurrent: 3\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := cfg.Daemon.Tasks.QueueSize; got != 8 {
+		t.Errorf("QueueSize = %d, want 8", got)
+	}
+	if got := cfg.Daemon.Tasks.MaxConcurrent; got != 3 {
+		t.Errorf("MaxConcurrent = %d, want 3", got)
+	}
+}
+
+func TestTaskValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		tasks string
+		want  string
+	}{
+		{"negative queue size", "queue_size: -1", "queue size"},
+		{"negative max concurrent", "max_concurrent: -2", "max concurrent"},
+		{"negative default timeout", "default_timeout: -1", "default timeout"},
+		{"negative max input bytes", "max_input_bytes: -1", "max input bytes"},
+		{"negative max retries", "max_retries: -1", "max retries"},
+		{"negative retry delay", "retry_delay: -1", "retry delay"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, "daemon:\n  tasks:\n    "+tt.tasks+"\n"))
+			if err == nil {
+				t.Fatal("Load succeeded, want a validation error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskValidateZeroMaxConcurrent(t *testing.T) {
+	// Load defaults 0 to 1, but a TaskConfig built in code is checked as is
+	tasks := TaskConfig{MaxConcurrent: 0}
+	if err := tasks.Validate(); err == nil {
+		t.Error("Validate accepted max_concurrent 0")
+	}
+
+	tasks = TaskConfig{MaxConcurrent: 1}
+	if err := tasks.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestTaskMaxRetries(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "daemon:\n  task_max_retries: 2\n  tasks:\n    max_retries: 4\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Daemon.TaskMaxRetries != 2 || cfg.Daemon.Tasks.MaxRetries != 4 {
+		t.Errorf("retries = %d and %d, want 2 and 4", cfg.Daemon.TaskMaxRetries, cfg.Daemon.Tasks.MaxRetries)
+	}
+
+	if _, err := Load(writeConfig(t, "daemon:\n  task_max_retries: -1\n")); err == nil || !strings.Contains(err.Error(), "task max retries") {
+		t.Errorf("Load with task_max_retries -1 = %v, want a validation error", err)
+	}
+}
+
+func TestLoadFilesOrDefaultRequiresListedFiles(t *testing.T) {
+	path := writeConfig(t, "daemon:\n  log_level: debug\n")
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+
+	if _, err := LoadFilesOrDefault([]string{path, missing}); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("LoadFilesOrDefault with a missing file = %v, want an error naming it", err)
+	}
+	if _, err := LoadFilesOrDefault([]string{missing}); err == nil {
+		t.Error("LoadFilesOrDefault with only a missing file succeeded, want an error")
+	}
+
+	cfg, err := LoadFilesOrDefault([]string{path})
+	if err != nil {
+		t.Fatalf("LoadFilesOrDefault: %v", err)
+	}
+	if cfg.Daemon.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want debug", cfg.Daemon.LogLevel)
+	}
+}
+
+func TestLoadFilesOrDefaultWithoutFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// The default path is optional
+	cfg, err := LoadFilesOrDefault(nil)
+	if err != nil {
+		t.Fatalf("LoadFilesOrDefault without %s: %v", DefaultPath, err)
+	}
+	if !cfg.ModeDetected || len(cfg.Sources) != 0 {
+		t.Errorf("got mode detected %v, sources %v, want the built-in defaults", cfg.ModeDetected, cfg.Sources)
+	}
+
+	if err := os.WriteFile(DefaultPath, []byte("daemon:\n  log_level: warn\n"), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+	if cfg, err = LoadFilesOrDefault(nil); err != nil {
+		t.Fatalf("LoadFilesOrDefault: %v", err)
+	}
+	if cfg.Daemon.LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want warn from %s", cfg.Daemon.LogLevel, DefaultPath)
+	}
+}
+
+func TestProfileEnablesItsPlugins(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+profiles:
+  api: [rest, websocket]
+  chat: [telegram]
+active_profile: api
+plugins:
+  tui:
+    enabled: true
+  telegram:
+    enabled: false
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"rest", true},
+		{"websocket", true},
+		{"telegram", false}, // in another profile
+		{"tui", true},       // explicitly enabled
+		{"echo", false},     // not in the profile
+	}
+	for _, tt := range tests {
+		if got := cfg.IsPluginEnabled(tt.name); got != tt.want {
+			t.Errorf("IsPluginEnabled(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	cfg.ActiveProfile = "chat"
+	if !cfg.IsPluginEnabled("telegram") || cfg.IsPluginEnabled("rest") {
+		t.Error("switching to the chat profile did not switch the enabled plugins")
+	}
+}
+
+func TestLabelsSelectPlugins(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+enable_labels: [api]
+disable_labels: [experimental]
+plugins:
+  tui:
+    enabled: true
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   bool
+	}{
+		{"rest", []string{"api"}, true},
+		{"websocket", []string{"api", "ui"}, true},
+		{"telegram", []string{"chat"}, false},               // not in an enabled group
+		{"echo", nil, false},                                // unlabeled
+		{"graphql", []string{"api", "experimental"}, false}, // disabled group wins
+		{"tui", []string{"ui"}, true},                       // explicitly enabled
+	}
+	for _, tt := range tests {
+		if got := cfg.IsPluginEnabled(tt.name, tt.labels...); got != tt.want {
+			t.Errorf("IsPluginEnabled(%s, %v) = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestPluginFeaturesParsed(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "plugins:\n  llm:\n    features: [streaming, tools]\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.PluginFeatures("llm"); len(got) != 2 || got[0] != "streaming" || got[1] != "tools" {
+		t.Errorf("llm features = %v, want [streaming tools]", got)
+	}
+	if got := cfg.PluginFeatures("rest"); len(got) != 0 {
+		t.Errorf("rest features = %v, want none", got)
+	}
+}
+
+func TestUnknownProfileRejected(t *testing.T) {
+	_, err := Load(writeConfig(t, "profiles:\n  api: [rest]\nactive_profile: missing\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown profile") {
+		t.Errorf("Load = %v, want an unknown profile error", err)
+	}
+}
+
+func TestCommandTimeoutFor(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `daemon:
+  command_timeout: 20
+plugins:
+  rest:
+    enabled: true
+    settings:
+      command_timeout: 5
+  telegram:
+    enabled: true
+    settings:
+      command_timeout: 0
+  websocket:
+    enabled: true
+`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for name, want := range map[string]int{"rest": 5, "telegram": 0, "websocket": 20} {
+		if got := cfg.CommandTimeoutFor(name); got != want {
+			t.Errorf("CommandTimeoutFor(%s) = %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestLoadFilesMergesInOrder(t *testing.T) {
+	base := writeConfig(t, `daemon:
+  log_level: info
+  command_timeout: 20
+plugins:
+  rest:
+    enabled: true
+    settings:
+      port: 8081
+      host: 127.0.0.1
+  websocket:
+    enabled: true
+`)
+	override := writeConfig(t, `daemon:
+  log_level: debug
+plugins:
+  rest:
+    settings:
+      port: 9000
+      auth_token: secret
+  websocket:
+    enabled: false
+`)
+
+	cfg, err := LoadFiles([]string{base, override})
+	if err != nil {
+		t.Fatalf("LoadFiles: %v", err)
+	}
+
+	if cfg.Daemon.LogLevel != "debug" {
+		t.Errorf("log_level = %q, want the override's debug", cfg.Daemon.LogLevel)
+	}
+	if cfg.Daemon.CommandTimeout != 20 {
+		t.Errorf("command_timeout = %d, want the base's 20", cfg.Daemon.CommandTimeout)
+	}
+	if port, _ := cfg.GetPluginSettingInt("rest", "port"); port != 9000 {
+		t.Errorf("rest port = %d, want the override's 9000", port)
+	}
+	if host, _ := cfg.GetPluginSettingString("rest", "host"); host != "127.0.0.1" {
+		t.Errorf("rest host = %q, want the base's 127.0.0.1", host)
+	}
+	if token, _ := cfg.GetPluginSettingString("rest", "auth_token"); token != "secret" {
+		t.Errorf("rest auth_token = %q, want the override's secret", token)
+	}
+	if !cfg.IsPluginEnabled("rest") || cfg.IsPluginEnabled("websocket") {
+		t.Error("plugin enablement not merged: want rest kept enabled and websocket disabled")
+	}
+	if len(cfg.Sources) != 2 || cfg.Sources[0] != base || cfg.Sources[1] != override {
+		t.Errorf("sources = %v, want both files in order", cfg.Sources)
+	}
+}
+
+// fakeTerminal makes stdin look like a terminal, or not, until the test ends
+func fakeTerminal(t *testing.T, terminal bool) {
+	saved := stdinIsTerminal
+	stdinIsTerminal = func() bool { return terminal }
+	t.Cleanup(func() { stdinIsTerminal = saved })
+}
+
+func TestModeDetectedFromTerminal(t *testing.T) {
+	for _, tt := range []struct {
+		terminal bool
+		want     plugin.Mode
+	}{
+		{true, plugin.ModeInteractive},
+		{false, plugin.ModeDaemon},
+	} {
+		fakeTerminal(t, tt.terminal)
+
+		cfg, err := Load(writeConfig(t, "daemon:\n  log_level: info\n"))
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if cfg.Mode != tt.want || !cfg.ModeDetected {
+			t.Errorf("terminal %v: mode = %s (detected %v), want detected %s", tt.terminal, cfg.Mode, cfg.ModeDetected, tt.want)
+		}
+
+		t.Chdir(t.TempDir())
+		if cfg, err = LoadOrDefault(DefaultPath); err != nil {
+			t.Fatalf("LoadOrDefault: %v", err)
+		}
+		if cfg.Mode != tt.want || !cfg.ModeDetected {
+			t.Errorf("terminal %v without a file: mode = %s (detected %v), want detected %s", tt.terminal, cfg.Mode, cfg.ModeDetected, tt.want)
+		}
+	}
+}
+
+func TestConfiguredModeNotDetected(t *testing.T) {
+	fakeTerminal(t, true)
+
+	cfg, err := Load(writeConfig(t, "mode: daemon\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Mode != plugin.ModeDaemon || cfg.ModeDetected {
+		t.Errorf("mode = %s (detected %v), want daemon from the file", cfg.Mode, cfg.ModeDetected)
+	}
+}
+
+func TestParseErrorPointsAtLine(t *testing.T) {
+	path := writeConfig(t, "daemon:\n  log_level: info\n  tasks: [unclosed\n")
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load accepted broken YAML")
+	}
+	if !strings.Contains(err.Error(), path+":") {
+		t.Errorf("error %q does not name %s", err, path)
+	}
+	if !regexp.MustCompile(regexp.QuoteMeta(path) + `:\d+: `).MatchString(err.Error()) {
+		t.Errorf("error %q has no line number", err)
+	}
+}
+
+func TestTypeErrorsListEveryLine(t *testing.T) {
+	path := writeConfig(t, "daemon:\n  tasks:\n    max_concurrent: many\n    queue_size: lots\n")
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load accepted mistyped settings")
+	}
+	for _, want := range []string{path + ":3: ", path + ":4: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestOversizedConfigRejected(t *testing.T) {
+	path := writeConfig(t, "# "+strings.Repeat("x", maxConfigBytes)+"\n")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "too large") || !strings.Contains(err.Error(), path) {
+		t.Errorf("Load error = %v, want a too-large error naming the file", err)
+	}
+}
+
+func TestBrokerNoSubscribersSettings(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "daemon:\n  broker_no_subscribers: error\n  broker_required_topics: [audit, billing]\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Daemon.BrokerNoSubscribers != "error" || strings.Join(cfg.Daemon.BrokerRequiredTopics, ",") != "audit,billing" {
+		t.Errorf("broker settings = %q, %v, want error and [audit billing]", cfg.Daemon.BrokerNoSubscribers, cfg.Daemon.BrokerRequiredTopics)
+	}
+
+	_, err = Load(writeConfig(t, "daemon:\n  broker_no_subscribers: shout\n"))
+	if err == nil || !strings.Contains(err.Error(), "broker_no_subscribers") {
+		t.Errorf("Load with an unknown policy = %v, want a broker_no_subscribers error", err)
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WithPluginSettings returns a copy of the config with patch merged into the
+// named plugin's settings. A nil value removes the setting. Numbers decoded
+// from JSON become ints when they are whole, as YAML would load them
+// The receiver is not modified
+func (c *Config) WithPluginSettings(name string, patch map[string]interface{}) *Config {
+	next := *c
+	next.Plugins = make(map[string]PluginConfig, len(c.Plugins))
+	for n, pc := range c.Plugins {
+		next.Plugins[n] = pc
+	}
+
+	pc := next.Plugins[name]
+	settings := make(map[string]interface{}, len(pc.Settings)+len(patch))
+	for key, value := range pc.Settings {
+		settings[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(settings, key)
+			continue
+		}
+		settings[key] = normalizeNumber(value)
+	}
+	pc.Settings = settings
+	next.Plugins[name] = pc
+
+	return &next
+}
+
+// normalizeNumber converts JSON numbers to int when whole and float64 otherwise
+func normalizeNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int(v)
+		}
+	}
+	return value
+}
+
+// PersistPluginSettings writes a plugin's settings into the config file at
+// path, replacing plugins.<name>.settings and keeping the rest of the file,
+// including comments elsewhere, as it was
+func PersistPluginSettings(path, name string, settings map[string]interface{}) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	data, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return parseError(path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a YAML mapping", path)
+	}
+
+	var value yaml.Node
+	if err := value.Encode(settings); err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	plugins := mappingValue(doc.Content[0], "plugins")
+	entry := mappingValue(plugins, name)
+	setMappingValue(entry, "settings", &value)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// mappingValue returns the mapping stored under key in a mapping node,
+// creating it if it is missing or not a mapping
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key && node.Content[i+1].Kind == yaml.MappingNode {
+			return node.Content[i+1]
+		}
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(node, key, child)
+	return child
+}
+
+// setMappingValue sets key to value in a mapping node
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
@@ -0,0 +1,119 @@
+package listener
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultSocketMode is the file mode of a unix socket when none is configured:
+// only the daemon's user may connect
+const DefaultSocketMode os.FileMode = 0600
+
+// Config describes where a server listens
+type Config struct {
+	// Host and Port are the TCP address; IPv6 hosts may be written with or
+	// without brackets ("::1" or "[::1]"), and an empty host listens on
+	// every interface
+	Host string
+	Port int
+
+	// UnixSocket is a socket file path; when set it replaces Host and Port
+	UnixSocket string
+
+	// SocketMode is the unix socket's file mode (0 = DefaultSocketMode)
+	SocketMode os.FileMode
+}
+
+// Listen opens the listener described by cfg
+// A unix socket left behind by an earlier run is replaced; the socket file
+// is removed again when the listener is closed
+func Listen(cfg Config) (net.Listener, error) {
+	if cfg.UnixSocket == "" {
+		return net.Listen("tcp", cfg.Address())
+	}
+
+	// A socket file outlives a crashed daemon; anything else at the path is
+	// not ours to remove
+	if info, err := os.Lstat(cfg.UnixSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", cfg.UnixSocket)
+		}
+		if err := os.Remove(cfg.UnixSocket); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", cfg.UnixSocket)
+	if err != nil {
+		return nil, err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	mode := cfg.SocketMode
+	if mode == 0 {
+		mode = DefaultSocketMode
+	}
+	if err := os.Chmod(cfg.UnixSocket, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	return &unixListener{Listener: ln}, nil
+}
+
+// Address returns the TCP address to listen on, e.g. "0.0.0.0:8081" or
+// "[::1]:8081"
+func (cfg Config) Address() string {
+	host := strings.TrimSuffix(strings.TrimPrefix(cfg.Host, "["), "]")
+	return net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+}
+
+// String describes the listen address for logs
+func (cfg Config) String() string {
+	if cfg.UnixSocket != "" {
+		return "unix socket " + cfg.UnixSocket
+	}
+	return cfg.Address()
+}
+
+// ParseSocketMode reads an octal file mode such as "0660"
+func ParseSocketMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("must be an octal file mode such as 0660")
+	}
+	return os.FileMode(mode), nil
+}
+
+// unixListener numbers the connections it accepts
+// Unix socket peers have no address of their own, so each connection reports
+// "unix:<n>" as its remote address: the host part is shared by every local
+// client, like an IP, and the rest tells connections apart, like a port
+type unixListener struct {
+	net.Listener
+	accepted atomic.Int64
+}
+
+// Accept waits for the next connection and gives it its remote address
+func (l *unixListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	n := l.accepted.Add(1)
+	return &unixConn{Conn: conn, remote: &net.UnixAddr{Name: fmt.Sprintf("unix:%d", n), Net: "unix"}}, nil
+}
+
+// unixConn is an accepted unix socket connection with a numbered remote address
+type unixConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+// RemoteAddr returns the connection's numbered address
+func (c *unixConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,109 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"", ":8081"},
+		{"127.0.0.1", "127.0.0.1:8081"},
+		{"::1", "[::1]:8081"},
+		{"[::1]", "[::1]:8081"},
+	}
+	for _, tt := range tests {
+		if got := (Config{Host: tt.host, Port: 8081}).Address(); got != tt.want {
+			t.Errorf("Address() for host %q = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestListenIPv6(t *testing.T) {
+	ln, err := Listen(Config{Host: "[::1]", Port: 0})
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial %s: %v", ln.Addr(), err)
+	}
+	conn.Close()
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "bicycle.sock")
+
+	// A socket left behind by an earlier run is replaced
+	stale, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := Listen(Config{UnixSocket: socket, SocketMode: 0660})
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("socket mode = %v, want 0660", info.Mode().Perm())
+	}
+
+	// Each accepted connection gets its own address
+	for _, want := range []string{"unix:1", "unix:2"} {
+		client, err := net.Dial("unix", socket)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatalf("Accept: %v", err)
+		}
+		if got := conn.RemoteAddr().String(); got != want {
+			t.Errorf("remote address = %s, want %s", got, want)
+		}
+		conn.Close()
+		client.Close()
+	}
+
+	ln.Close()
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket file still there after Close: %v", err)
+	}
+}
+
+func TestListenRefusesNonSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("keep me"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := Listen(Config{UnixSocket: path}); err == nil {
+		t.Fatal("Listen replaced a regular file")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "keep me" {
+		t.Errorf("file after a refused Listen = %q, %v, want it untouched", data, err)
+	}
+}
+
+func TestParseSocketMode(t *testing.T) {
+	if mode, err := ParseSocketMode("0660"); err != nil || mode != 0660 {
+		t.Errorf("ParseSocketMode(0660) = %v, %v", mode, err)
+	}
+	for _, bad := range []string{"rw", "0999", "1777"} {
+		if _, err := ParseSocketMode(bad); err == nil {
+			t.Errorf("ParseSocketMode(%s) succeeded", bad)
+		}
+	}
+}
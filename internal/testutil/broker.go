@@ -0,0 +1,155 @@
+// Package testutil provides fakes and helpers for testing plugins without a
+// running daemon
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// Broker is an in-memory plugin.MessageBroker
+// Publish delivers synchronously to matching subscribers without blocking,
+// dropping messages for subscribers whose buffer is full, and records every
+// published message
+type Broker struct {
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	published     []plugin.Message
+}
+
+// subscription is a subscriber's channel and topics
+type subscription struct {
+	ch     chan plugin.Message
+	topics []string
+}
+
+// NewBroker creates an empty in-memory broker
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+// Subscribe creates a subscription for the given topics (none = all topics)
+func (b *Broker) Subscribe(id string, bufSize int, topics ...string) <-chan plugin.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if old, exists := b.subscriptions[id]; exists {
+		close(old.ch)
+	}
+
+	sub := &subscription{
+		ch:     make(chan plugin.Message, bufSize),
+		topics: topics,
+	}
+	b.subscriptions[id] = sub
+	return sub.ch
+}
+
+// Publish records the message and delivers it to matching subscribers
+func (b *Broker) Publish(ctx context.Context, msg plugin.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = append(b.published, msg)
+	for _, sub := range b.subscriptions {
+		if !sub.wants(msg.Topic) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+		}
+	}
+	return nil
+}
+
+// Unsubscribe removes a subscription and closes its channel
+func (b *Broker) Unsubscribe(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sub, ok := b.subscriptions[id]; ok {
+		close(sub.ch)
+		delete(b.subscriptions, id)
+	}
+}
+
+// UnsubscribeDrain removes a subscription and passes its buffered messages
+// to drain
+func (b *Broker) UnsubscribeDrain(id string, drain func(plugin.Message)) {
+	b.mu.Lock()
+	sub, ok := b.subscriptions[id]
+	if ok {
+		close(sub.ch)
+		delete(b.subscriptions, id)
+	}
+	b.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	for msg := range sub.ch {
+		drain(msg)
+	}
+}
+
+// Congested reports whether a subscriber of topic has a buffer at least 80% full
+func (b *Broker) Congested(topic string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, sub := range b.subscriptions {
+		if c := cap(sub.ch); c > 0 && sub.wants(topic) && len(sub.ch)*5 >= c*4 {
+			return true
+		}
+	}
+	return false
+}
+
+// Published returns every message published so far
+func (b *Broker) Published() []plugin.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]plugin.Message(nil), b.published...)
+}
+
+// PublishedOn returns the messages published on a topic
+func (b *Broker) PublishedOn(topic string) []plugin.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var msgs []plugin.Message
+	for _, msg := range b.published {
+		if msg.Topic == topic {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// Subscribed reports whether a subscription with the given id exists
+func (b *Broker) Subscribed(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.subscriptions[id]
+	return ok
+}
+
+// wants checks if the subscription listens on a topic
+func (s *subscription) wants(topic string) bool {
+	if len(s.topics) == 0 {
+		return true
+	}
+	for _, t := range s.topics {
+		if t == topic || t == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bicycle/plugin"
+)
+
+func TestBrokerDeliversToMatchingSubscribers(t *testing.T) {
+	b := NewBroker()
+	tasks := b.Subscribe("tasks", 10, "task.progress")
+	all := b.Subscribe("all", 10)
+
+	b.Publish(context.Background(), plugin.Message{Topic: "task.progress", Payload: "half"})
+	b.Publish(context.Background(), plugin.Message{Topic: "notification", Payload: "hello"})
+
+	if got := len(tasks); got != 1 {
+		t.Fatalf("task subscriber has %d messages, want 1", got)
+	}
+	if msg := <-tasks; msg.Payload != "half" {
+		t.Errorf("task subscriber got %v, want half", msg.Payload)
+	}
+	if got := len(all); got != 2 {
+		t.Errorf("catch-all subscriber has %d messages, want 2", got)
+	}
+	if got := len(b.Published()); got != 2 {
+		t.Errorf("recorded %d messages, want 2", got)
+	}
+	if got := b.PublishedOn("notification"); len(got) != 1 || got[0].Payload != "hello" {
+		t.Errorf("PublishedOn(notification) = %v, want the hello message", got)
+	}
+}
+
+func TestBrokerDropsForFullSubscribers(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("slow", 1, "chat")
+
+	for i := 0; i < 3; i++ {
+		if err := b.Publish(context.Background(), plugin.Message{Topic: "chat", Payload: i}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if got := len(ch); got != 1 {
+		t.Errorf("buffered %d messages, want 1", got)
+	}
+	if !b.Congested("chat") {
+		t.Error("Congested(chat) = false with a full buffer")
+	}
+}
+
+func TestBrokerUnsubscribeClosesChannel(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe("sub", 10)
+	b.Publish(context.Background(), plugin.Message{Topic: "a"})
+
+	var drained []plugin.Message
+	b.UnsubscribeDrain("sub", func(msg plugin.Message) { drained = append(drained, msg) })
+	if len(drained) != 1 {
+		t.Errorf("drained %d messages, want 1", len(drained))
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel still open after unsubscribing")
+	}
+	if b.Subscribed("sub") {
+		t.Error("Subscribed(sub) = true after unsubscribing")
+	}
+}
+
+func TestCollectorGathersMessages(t *testing.T) {
+	b := NewBroker()
+	c := Collect(b, "collector", "response")
+
+	b.Publish(context.Background(), plugin.Message{Topic: "response", Payload: 1})
+	b.Publish(context.Background(), plugin.Message{Topic: "notification", Payload: 2})
+	b.Publish(context.Background(), plugin.Message{Topic: "response", Payload: 3})
+
+	msgs := c.WaitFor(2, time.Second)
+	if len(msgs) != 2 || msgs[0].Payload != 1 || msgs[1].Payload != 3 {
+		t.Errorf("collected %v, want the two responses in order", msgs)
+	}
+
+	b.Unsubscribe("collector")
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Error("collector not done after unsubscribing")
+	}
+}
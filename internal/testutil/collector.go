@@ -0,0 +1,73 @@
+package testutil
+
+import (
+	"sync"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// Collector drains a subscription channel in the background and keeps the
+// messages it receives
+type Collector struct {
+	mu       sync.Mutex
+	messages []plugin.Message
+	arrived  chan struct{}
+	done     chan struct{}
+}
+
+// Collect subscribes to the broker under id and collects matching messages
+// until the subscription is closed
+func Collect(broker plugin.MessageBroker, id string, topics ...string) *Collector {
+	c := &Collector{
+		arrived: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	ch := broker.Subscribe(id, 100, topics...)
+
+	go func() {
+		defer close(c.done)
+		for msg := range ch {
+			c.mu.Lock()
+			c.messages = append(c.messages, msg)
+			c.mu.Unlock()
+
+			select {
+			case c.arrived <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return c
+}
+
+// Messages returns the messages collected so far
+func (c *Collector) Messages() []plugin.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]plugin.Message(nil), c.messages...)
+}
+
+// WaitFor waits until at least n messages have been collected or the timeout
+// passes, and returns the messages collected by then
+func (c *Collector) WaitFor(n int, timeout time.Duration) []plugin.Message {
+	deadline := time.After(timeout)
+	for {
+		if msgs := c.Messages(); len(msgs) >= n {
+			return msgs
+		}
+		select {
+		case <-c.arrived:
+		case <-c.done:
+			return c.Messages()
+		case <-deadline:
+			return c.Messages()
+		}
+	}
+}
+
+// Done is closed once the subscription channel has been closed
+func (c *Collector) Done() <-chan struct{} {
+	return c.done
+}
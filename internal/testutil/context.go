@@ -0,0 +1,112 @@
+package testutil
+
+import (
+	"context"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// ContextOption customizes a context built by NewContext
+type ContextOption func(ctx context.Context) context.Context
+
+// NewContext returns a context carrying the values the daemon gives plugins:
+// "mode" (daemon mode), "config" (the default config), "daemon" (a new
+// Daemon) and "broker" (a new Broker), each replaceable with an option
+func NewContext(opts ...ContextOption) context.Context {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, "mode", plugin.ModeDaemon)
+	ctx = context.WithValue(ctx, "config", config.DefaultConfig())
+	ctx = context.WithValue(ctx, "daemon", NewDaemon())
+	ctx = context.WithValue(ctx, "broker", plugin.MessageBroker(NewBroker()))
+
+	for _, opt := range opts {
+		ctx = opt(ctx)
+	}
+	return ctx
+}
+
+// WithMode sets the execution mode
+func WithMode(mode plugin.Mode) ContextOption {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, "mode", mode)
+	}
+}
+
+// WithConfig sets the configuration
+func WithConfig(cfg *config.Config) ContextOption {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, "config", cfg)
+	}
+}
+
+// WithDaemon sets the daemon value; it can be any type implementing the
+// interfaces the plugin under test looks up
+func WithDaemon(daemon interface{}) ContextOption {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, "daemon", daemon)
+	}
+}
+
+// WithBroker sets the broker value
+func WithBroker(broker plugin.MessageBroker) ContextOption {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, "broker", broker)
+	}
+}
+
+// WithPrincipal attaches a principal, as transports do for commands
+func WithPrincipal(p plugin.Principal) ContextOption {
+	return func(ctx context.Context) context.Context {
+		return plugin.WithPrincipal(ctx, p)
+	}
+}
+
+// WithPluginSetting sets a plugin setting on the context's config, copying
+// the config so other contexts are unaffected
+func WithPluginSetting(pluginName, key string, value interface{}) ContextOption {
+	return func(ctx context.Context) context.Context {
+		cfg := copyConfig(ctx)
+
+		pc := cfg.Plugins[pluginName]
+		settings := make(map[string]interface{}, len(pc.Settings)+1)
+		for k, v := range pc.Settings {
+			settings[k] = v
+		}
+		settings[key] = value
+		pc.Settings = settings
+		cfg.Plugins[pluginName] = pc
+
+		return context.WithValue(ctx, "config", cfg)
+	}
+}
+
+// WithPluginFeatures turns on features for a plugin in the context's config
+// and hands the context to that plugin, so plugin.HasFeature sees them
+func WithPluginFeatures(pluginName string, features ...string) ContextOption {
+	return func(ctx context.Context) context.Context {
+		cfg := copyConfig(ctx)
+
+		pc := cfg.Plugins[pluginName]
+		pc.Features = append(append([]string(nil), pc.Features...), features...)
+		cfg.Plugins[pluginName] = pc
+
+		ctx = context.WithValue(ctx, "config", cfg)
+		return plugin.WithPluginName(ctx, pluginName)
+	}
+}
+
+// copyConfig returns a copy of the context's config (or the default config)
+// whose plugin configs can be changed without affecting other contexts
+func copyConfig(ctx context.Context) *config.Config {
+	cfg := config.DefaultConfig()
+	if existing, ok := ctx.Value("config").(*config.Config); ok {
+		copied := *existing
+		copied.Plugins = make(map[string]config.PluginConfig, len(existing.Plugins))
+		for name, pc := range existing.Plugins {
+			copied.Plugins[name] = pc
+		}
+		cfg = &copied
+	}
+	return cfg
+}
@@ -0,0 +1,96 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+func TestNewContextDefaults(t *testing.T) {
+	ctx := NewContext()
+
+	if mode, _ := ctx.Value("mode").(plugin.Mode); mode != plugin.ModeDaemon {
+		t.Errorf("mode = %v, want %s", ctx.Value("mode"), plugin.ModeDaemon)
+	}
+	if _, ok := ctx.Value("config").(*config.Config); !ok {
+		t.Errorf("config = %T, want *config.Config", ctx.Value("config"))
+	}
+	if _, ok := ctx.Value("daemon").(*Daemon); !ok {
+		t.Errorf("daemon = %T, want *Daemon", ctx.Value("daemon"))
+	}
+	if _, ok := ctx.Value("broker").(plugin.MessageBroker); !ok {
+		t.Errorf("broker = %T, want a plugin.MessageBroker", ctx.Value("broker"))
+	}
+}
+
+func TestContextOptions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	d := NewDaemon()
+	b := NewBroker()
+	principal := plugin.Principal{Source: "test", Role: plugin.RoleAdmin}
+
+	ctx := NewContext(
+		WithMode(plugin.ModeInteractive),
+		WithConfig(cfg),
+		WithDaemon(d),
+		WithBroker(b),
+		WithPrincipal(principal),
+	)
+
+	if mode := ctx.Value("mode"); mode != plugin.ModeInteractive {
+		t.Errorf("mode = %v, want %s", mode, plugin.ModeInteractive)
+	}
+	if got := ctx.Value("config"); got != cfg {
+		t.Error("config is not the one passed to WithConfig")
+	}
+	if got := ctx.Value("daemon"); got != d {
+		t.Error("daemon is not the one passed to WithDaemon")
+	}
+	if got := ctx.Value("broker"); got != plugin.MessageBroker(b) {
+		t.Error("broker is not the one passed to WithBroker")
+	}
+	if got, ok := plugin.PrincipalFromContext(ctx); !ok || got != principal {
+		t.Errorf("principal = %+v, want %+v", got, principal)
+	}
+}
+
+func TestWithPluginSettingCopiesConfig(t *testing.T) {
+	base := config.DefaultConfig()
+	ctx := NewContext(WithConfig(base), WithPluginSetting("echo", "delay_ms", 5))
+
+	cfg := ctx.Value("config").(*config.Config)
+	if got, ok := cfg.GetPluginSettingInt("echo", "delay_ms"); !ok || got != 5 {
+		t.Errorf("delay_ms = %d, %v, want 5", got, ok)
+	}
+	if _, ok := base.GetPluginSetting("echo", "delay_ms"); ok {
+		t.Error("WithPluginSetting changed the config passed to WithConfig")
+	}
+}
+
+func TestWithPluginFeatures(t *testing.T) {
+	ctx := NewContext(WithPluginFeatures("echo", "fast"))
+
+	if !plugin.HasFeature(ctx, "fast") {
+		t.Error("HasFeature(fast) = false")
+	}
+	if plugin.HasFeature(ctx, "slow") {
+		t.Error("HasFeature(slow) = true")
+	}
+}
+
+func TestDaemonRecordsTasks(t *testing.T) {
+	d := NewDaemon()
+	ctx := NewContext(WithDaemon(d))
+
+	submitter := ctx.Value("daemon").(interface {
+		ExecuteTask(ctx context.Context, task *plugin.Task) error
+	})
+	if err := submitter.ExecuteTask(ctx, &plugin.Task{ID: "t1"}); err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if tasks := d.Tasks(); len(tasks) != 1 || tasks[0].ID != "t1" {
+		t.Errorf("Tasks() = %v, want t1", tasks)
+	}
+}
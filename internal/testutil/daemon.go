@@ -0,0 +1,112 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// Daemon is a fake daemon for the context's "daemon" value
+// It records submitted tasks instead of running them
+type Daemon struct {
+	mu        sync.Mutex
+	tasks     []*plugin.Task
+	cancelled []string
+	resets    int
+	shutdown  []string
+
+	// ExecuteErr is returned by ExecuteTask and PreviewTask when set
+	ExecuteErr error
+
+	// NotReady makes Ready report false
+	NotReady bool
+
+	// Status is returned by GetStatus
+	Status string
+}
+
+// NewDaemon creates a fake daemon that accepts every task
+func NewDaemon() *Daemon {
+	return &Daemon{Status: "Status: idle"}
+}
+
+// ExecuteTask records the task, or returns ExecuteErr
+func (d *Daemon) ExecuteTask(ctx context.Context, task *plugin.Task) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.ExecuteErr != nil {
+		return d.ExecuteErr
+	}
+	d.tasks = append(d.tasks, task)
+	return nil
+}
+
+// PreviewTask returns ExecuteErr without recording the task
+func (d *Daemon) PreviewTask(task *plugin.Task) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.ExecuteErr
+}
+
+// Tasks returns the tasks submitted so far
+func (d *Daemon) Tasks() []*plugin.Task {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*plugin.Task(nil), d.tasks...)
+}
+
+// CancelTask records the task ID
+func (d *Daemon) CancelTask(ctx context.Context, taskID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cancelled = append(d.cancelled, taskID)
+	return nil
+}
+
+// Cancelled returns the task IDs passed to CancelTask
+func (d *Daemon) Cancelled() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.cancelled...)
+}
+
+// Ready reports whether the fake daemon accepts traffic
+func (d *Daemon) Ready() bool {
+	return !d.NotReady
+}
+
+// GetStatus returns Status
+func (d *Daemon) GetStatus(ctx context.Context) string {
+	return d.Status
+}
+
+// Reset counts the call
+func (d *Daemon) Reset(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resets++
+	return nil
+}
+
+// Resets returns how many times Reset was called
+func (d *Daemon) Resets() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.resets
+}
+
+// RequestShutdown records the reason
+func (d *Daemon) RequestShutdown(reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.shutdown = append(d.shutdown, reason)
+}
+
+// ShutdownReasons returns the reasons passed to RequestShutdown
+func (d *Daemon) ShutdownReasons() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.shutdown...)
+}
@@ -0,0 +1,111 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// Executor is a fake plugin.Executor for a fixed set of task types
+// ExecuteTask calls Handler if set and otherwise returns the task input
+type Executor struct {
+	mu          sync.Mutex
+	types       []string
+	currentTask *plugin.Task
+	executed    []*plugin.Task
+	cancelled   []string
+
+	// Handler produces the task output (nil = echo the input)
+	Handler plugin.TaskHandler
+}
+
+// NewExecutor creates a fake executor handling the given task types
+func NewExecutor(taskTypes ...string) *Executor {
+	return &Executor{types: taskTypes}
+}
+
+// Type returns the extension type
+func (e *Executor) Type() plugin.ExtensionType {
+	return plugin.ExtensionTypeExecutor
+}
+
+// Name returns the extension name
+func (e *Executor) Name() string {
+	return "fake"
+}
+
+// SupportsMode reports that the fake works in all modes
+func (e *Executor) SupportsMode(mode plugin.Mode) bool {
+	return true
+}
+
+// CanHandle reports whether the task type is one of the executor's types
+func (e *Executor) CanHandle(taskType string) bool {
+	for _, t := range e.types {
+		if t == taskType {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecuteTask records the task and runs Handler
+func (e *Executor) ExecuteTask(ctx context.Context, task *plugin.Task) (interface{}, error) {
+	e.mu.Lock()
+	e.currentTask = task
+	e.executed = append(e.executed, task)
+	handler := e.Handler
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.currentTask = nil
+		e.mu.Unlock()
+	}()
+
+	if handler == nil {
+		return task.Input, nil
+	}
+	return handler(ctx, task)
+}
+
+// CancelTask records the cancellation of the running task
+func (e *Executor) CancelTask(ctx context.Context, taskID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.currentTask == nil || e.currentTask.ID != taskID {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+	e.cancelled = append(e.cancelled, taskID)
+	return nil
+}
+
+// GetStatus reports whether a task is running
+func (e *Executor) GetStatus(ctx context.Context) (*plugin.ExecutorStatus, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := &plugin.ExecutorStatus{State: plugin.ExecutorStateIdle}
+	if e.currentTask != nil {
+		status.State = plugin.ExecutorStateWorking
+		status.CurrentTask = e.currentTask
+	}
+	return status, nil
+}
+
+// Executed returns the tasks run so far
+func (e *Executor) Executed() []*plugin.Task {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*plugin.Task(nil), e.executed...)
+}
+
+// Cancelled returns the IDs of cancelled tasks
+func (e *Executor) Cancelled() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.cancelled...)
+}
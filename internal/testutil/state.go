@@ -0,0 +1,89 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bicycle/plugin"
+)
+
+// StateManager is an in-memory plugin.StateManager
+// Save and Load only count calls
+type StateManager struct {
+	mu    sync.Mutex
+	data  map[string]interface{}
+	saves int
+	loads int
+}
+
+// NewStateManager creates an empty in-memory state manager
+func NewStateManager() *StateManager {
+	return &StateManager{data: make(map[string]interface{})}
+}
+
+// Type returns the extension type
+func (s *StateManager) Type() plugin.ExtensionType {
+	return plugin.ExtensionTypeState
+}
+
+// Name returns the extension name
+func (s *StateManager) Name() string {
+	return "fake"
+}
+
+// SupportsMode reports that the fake works in all modes
+func (s *StateManager) SupportsMode(mode plugin.Mode) bool {
+	return true
+}
+
+// Get retrieves a value by key
+func (s *StateManager) Get(ctx context.Context, key string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.data[key]
+	if !ok {
+		return nil, fmt.Errorf("key not found: %s", key)
+	}
+	return value, nil
+}
+
+// Set stores a value by key
+func (s *StateManager) Set(ctx context.Context, key string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+// Delete removes a value by key
+func (s *StateManager) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+// Save counts the call
+func (s *StateManager) Save(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saves++
+	return nil
+}
+
+// Load counts the call
+func (s *StateManager) Load(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	return nil
+}
+
+// Saves returns how many times Save was called
+func (s *StateManager) Saves() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"bicycle/daemon"
@@ -14,11 +15,14 @@ import (
 
 	// Import all plugins (triggers init registration)
 	_ "bicycle/cmd"
+	_ "bicycle/plugins/bridge"
+	_ "bicycle/plugins/executor/echo"
 	_ "bicycle/plugins/executor/llm"
 	_ "bicycle/plugins/rest"
 	_ "bicycle/plugins/state/memory"
 	_ "bicycle/plugins/telegram"
 	_ "bicycle/plugins/tui"
+	_ "bicycle/plugins/webhook"
 	_ "bicycle/plugins/websocket"
 )
 
@@ -28,8 +32,10 @@ var (
 
 func main() {
 	// Parse command-line flags
-	configPath := flag.String("config", "config.yaml", "Path to configuration file")
-	mode := flag.String("mode", "", "Execution mode (daemon or interactive)")
+	var configPaths configPathList
+	flag.Var(&configPaths, "config", "Path to configuration file; repeat or comma-separate to merge several, later files overriding earlier (default \"config.yaml\")")
+	mode := flag.String("mode", "", "Execution mode (daemon or interactive; detected from the terminal if unset)")
+	profile := flag.String("profile", "", "Plugin profile to activate (overrides active_profile)")
 	showVersion := flag.Bool("version", false, "Show version information")
 	listPlugins := flag.Bool("list-plugins", false, "List registered plugins")
 
@@ -62,23 +68,21 @@ func main() {
 		return
 	}
 
-	// Load configuration
-	cfg, err := config.LoadOrDefault(*configPath)
+	// Load configuration, with the CLI's mode and profile winning
+	overrides := flagOverrides{mode: *mode, profile: *profile}
+	cfg, err := loadConfig(configPaths, overrides)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Override mode if specified via CLI
-	if *mode != "" {
-		cfg.Mode = plugin.Mode(*mode)
-		if err := cfg.Validate(); err != nil {
-			log.Fatalf("Invalid mode: %v", err)
-		}
-	}
-
 	// Print startup banner
 	printBanner(cfg)
 
+	// Load Go plugins, which register alongside the built-in ones
+	if cfg.Daemon.PluginDir != "" {
+		plugin.LoadDir(cfg.Daemon.PluginDir)
+	}
+
 	// Create daemon
 	d := daemon.New(cfg)
 
@@ -100,15 +104,27 @@ func main() {
 		log.Fatalf("Failed to start daemon: %v", err)
 	}
 
-	// Setup signal handling for graceful shutdown
+	// Setup signal handling for graceful shutdown and config reload
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal, reloading config on SIGHUP
 	log.Println("Daemon running. Press Ctrl+C to stop.")
-	<-sigCh
-
-	log.Println("Shutdown signal received, stopping...")
+wait:
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				reloadConfig(d, configPaths, overrides)
+				continue
+			}
+			log.Println("Shutdown signal received, stopping...")
+			break wait
+		case <-d.ShutdownRequested():
+			log.Println("Shutdown requested, stopping...")
+			break wait
+		}
+	}
 
 	// Stop daemon
 	if err := d.Stop(); err != nil {
@@ -116,6 +132,75 @@ func main() {
 	}
 
 	log.Println("Daemon stopped")
+
+	if d.RestartRequested() {
+		signal.Stop(sigCh)
+		if err := reexec(); err != nil {
+			log.Fatalf("Failed to restart: %v", err)
+		}
+	}
+}
+
+// execFunc replaces the running process; a variable so the restart path can
+// be exercised without replacing the process
+var execFunc = syscall.Exec
+
+// reexec replaces the process with a fresh run of the same binary, arguments
+// and environment. It only returns on failure
+func reexec() error {
+	path, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating executable: %w", err)
+	}
+
+	log.Printf("Restarting: %s %s", path, strings.Join(os.Args[1:], " "))
+	return execFunc(path, os.Args, os.Environ())
+}
+
+// flagOverrides are the settings given on the command line, which win over
+// the config files at startup and on every reload
+type flagOverrides struct {
+	mode    string
+	profile string
+}
+
+// loadConfig reads the config files and applies the command-line overrides
+func loadConfig(paths []string, overrides flagOverrides) (*config.Config, error) {
+	cfg, err := config.LoadFilesOrDefault(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	if overrides.mode != "" {
+		cfg.Mode = plugin.Mode(overrides.mode)
+		cfg.ModeDetected = false
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid mode: %w", err)
+		}
+	}
+	if overrides.profile != "" {
+		cfg.ActiveProfile = overrides.profile
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid profile: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// reloadConfig re-reads the config files and applies runtime-adjustable
+// settings, keeping the command-line overrides
+func reloadConfig(d *daemon.Daemon, paths []string, overrides flagOverrides) {
+	log.Println("Reloading configuration...")
+
+	cfg, err := loadConfig(paths, overrides)
+	if err != nil {
+		log.Printf("Failed to reload config: %v", err)
+		return
+	}
+
+	if err := d.Reload(cfg); err != nil {
+		log.Printf("Failed to apply config: %v", err)
+	}
 }
 
 // printBanner prints the startup banner
@@ -124,7 +209,43 @@ func printBanner(cfg *config.Config) {
 	fmt.Println("║            Bicycle Daemon v" + version + "           ║")
 	fmt.Println("╚════════════════════════════════════════════╝")
 	fmt.Println()
-	fmt.Printf("Mode: %s\n", cfg.Mode)
-	fmt.Printf("Config: loaded\n")
+	if cfg.ModeDetected {
+		fmt.Printf("Mode: %s (detected from terminal; set -mode or mode to override)\n", cfg.Mode)
+	} else {
+		fmt.Printf("Mode: %s\n", cfg.Mode)
+	}
+	if cfg.ActiveProfile != "" {
+		fmt.Printf("Profile: %s\n", cfg.ActiveProfile)
+	}
+	if len(cfg.EnableLabels) > 0 {
+		fmt.Printf("Enabled labels: %s\n", strings.Join(cfg.EnableLabels, ", "))
+	}
+	if len(cfg.DisableLabels) > 0 {
+		fmt.Printf("Disabled labels: %s\n", strings.Join(cfg.DisableLabels, ", "))
+	}
+	if len(cfg.Sources) > 0 {
+		fmt.Printf("Config: %s\n", strings.Join(cfg.Sources, ", "))
+	} else {
+		fmt.Println("Config: defaults")
+	}
 	fmt.Println()
 }
+
+// configPathList collects -config values, accepting repeated flags and
+// comma-separated lists
+type configPathList []string
+
+// String returns the paths as a comma-separated list
+func (l *configPathList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds one or more comma-separated paths
+func (l *configPathList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*l = append(*l, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"testing"
+
+	"bicycle/plugin"
+)
+
+func TestLoadConfigAppliesOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := "mode: interactive\nprofiles:\n  api: [rest]\n  chat: [telegram]\nactive_profile: chat\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	// Loading again, as a SIGHUP reload does, keeps the overrides
+	for i := 0; i < 2; i++ {
+		cfg, err := loadConfig([]string{path}, flagOverrides{mode: "daemon", profile: "api"})
+		if err != nil {
+			t.Fatalf("loadConfig: %v", err)
+		}
+		if cfg.Mode != plugin.ModeDaemon || cfg.ModeDetected {
+			t.Errorf("mode = %s (detected %v), want daemon from the flag", cfg.Mode, cfg.ModeDetected)
+		}
+		if cfg.ActiveProfile != "api" {
+			t.Errorf("profile = %q, want api from the flag", cfg.ActiveProfile)
+		}
+	}
+
+	cfg, err := loadConfig([]string{path}, flagOverrides{})
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if cfg.Mode != plugin.ModeInteractive || cfg.ActiveProfile != "chat" {
+		t.Errorf("without flags got mode %s, profile %q, want the file's", cfg.Mode, cfg.ActiveProfile)
+	}
+}
+
+func TestLoadConfigRejectsInvalidOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("mode: daemon\n"), 0644); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+
+	if _, err := loadConfig([]string{path}, flagOverrides{mode: "sideways"}); err == nil {
+		t.Error("loadConfig accepted an unknown mode")
+	}
+	if _, err := loadConfig([]string{path}, flagOverrides{profile: "missing"}); err == nil {
+		t.Error("loadConfig accepted an unknown profile")
+	}
+}
+
+func TestReexecRunsSameBinaryWithSameArgs(t *testing.T) {
+	defer func(exec func(string, []string, []string) error, args []string) {
+		execFunc, os.Args = exec, args
+	}(execFunc, os.Args)
+
+	var gotPath string
+	var gotArgs, gotEnv []string
+	execFunc = func(path string, args, env []string) error {
+		gotPath, gotArgs, gotEnv = path, args, env
+		return errors.New("exec refused")
+	}
+	os.Args = []string{"bicycle", "--mode", "daemon", "--config", "a.yaml"}
+	t.Setenv("BICYCLE_TEST_REEXEC", "1")
+
+	if err := reexec(); err == nil || err.Error() != "exec refused" {
+		t.Errorf("reexec = %v, want the exec error", err)
+	}
+
+	want, _ := os.Executable()
+	if gotPath != want {
+		t.Errorf("exec path = %q, want %q", gotPath, want)
+	}
+	if strings.Join(gotArgs, " ") != "bicycle --mode daemon --config a.yaml" {
+		t.Errorf("exec args = %q, want the original arguments", gotArgs)
+	}
+	if !slices.Contains(gotEnv, "BICYCLE_TEST_REEXEC=1") {
+		t.Error("exec environment lost the process environment")
+	}
+}
+
+func TestLoadDirRegistersGoPlugins(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Go plugins are only tested on Linux")
+	}
+	if testing.Short() {
+		t.Skip("building a Go plugin is slow")
+	}
+
+	// The plugin must be built with the same flags as the test binary
+	dir := t.TempDir()
+	args := []string{"build", "-buildmode=plugin", "-o", filepath.Join(dir, "hello.so")}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "-race" && setting.Value == "true" {
+				args = append(args, "-race")
+			}
+		}
+	}
+	build := exec.Command("go", append(args, "./testdata/goplugin")...)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("building the test plugin: %v\n%s", err, out)
+	}
+
+	// A file that isn't a Go plugin is skipped rather than failing the rest
+	if err := os.WriteFile(filepath.Join(dir, "broken.so"), []byte("not a plugin"), 0644); err != nil {
+		t.Fatalf("writing broken.so: %v", err)
+	}
+
+	loaded := plugin.LoadDir(dir)
+	if len(loaded) != 1 || filepath.Base(loaded[0]) != "hello.so" {
+		t.Errorf("loaded %v, want only hello.so", loaded)
+	}
+	if _, ok := plugin.GetRegistry().Get("hello-so"); !ok {
+		t.Error("hello-so not in the registry after loading")
+	}
+
+	if loaded := plugin.LoadDir(filepath.Join(dir, "missing")); loaded != nil {
+		t.Errorf("loading a missing dir = %v, want nothing", loaded)
+	}
+}
@@ -0,0 +1,32 @@
+package plugin
+
+import "time"
+
+// MetadataChat is the message metadata key naming the conversation a chat
+// message belongs to, e.g. "telegram:12345"; it matches the principal source
+// of commands sent from the same conversation
+const MetadataChat = "chat"
+
+// ChatKey returns the conversation a chat message belongs to, falling back
+// to its source for transports with a single conversation (e.g. "tui")
+func ChatKey(msg Message) string {
+	if chat, ok := msg.Metadata[MetadataChat].(string); ok && chat != "" {
+		return chat
+	}
+	return msg.Source
+}
+
+// ChatEntry is a chat message kept so a conversation can be backfilled
+type ChatEntry struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Text   string    `json:"text"`
+}
+
+// ChatHistory is implemented by daemons that keep recent chat messages per
+// conversation across restarts, so an executor resuming a conversation can
+// backfill it. It is available from the "daemon" context value
+type ChatHistory interface {
+	// RecentChats returns the kept messages of a conversation, oldest first
+	RecentChats(chat string) []ChatEntry
+}
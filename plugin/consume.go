@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// MessageHandler processes one message received from the broker
+type MessageHandler func(ctx context.Context, msg Message)
+
+// HandleWithTimeout runs handle for a message, waiting at most timeout
+// (0 = no limit) so one slow downstream call can't back up a subscription
+// On overrun it logs, cancels the handler's context and returns false; the
+// handler keeps running in the background until it notices or finishes
+func HandleWithTimeout(ctx context.Context, name string, timeout time.Duration, msg Message, handle MessageHandler) bool {
+	if timeout <= 0 {
+		handle(ctx, msg)
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handle(ctx, msg)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		log.Printf("[%s] Handling message (topic: %s) took longer than %s, moving on", name, msg.Topic, timeout)
+		return false
+	}
+}
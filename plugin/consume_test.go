@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHandleWithTimeoutMovesOnFromSlowHandler(t *testing.T) {
+	msgs := make(chan Message, 3)
+	msgs <- Message{Topic: "slow"}
+	msgs <- Message{Topic: "fast"}
+	msgs <- Message{Topic: "fast"}
+	close(msgs)
+
+	release := make(chan struct{})
+	defer close(release)
+	slowCancelled := make(chan struct{})
+
+	var handled []string
+	var completed []bool
+	start := time.Now()
+	for msg := range msgs {
+		ok := HandleWithTimeout(context.Background(), "Test", 20*time.Millisecond, msg, func(ctx context.Context, msg Message) {
+			if msg.Topic == "slow" {
+				<-ctx.Done()
+				close(slowCancelled)
+				<-release
+				return
+			}
+			handled = append(handled, msg.Topic)
+		})
+		completed = append(completed, ok)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("handling took %s, want the slow message abandoned", elapsed)
+	}
+	if len(completed) != 3 || completed[0] || !completed[1] || !completed[2] {
+		t.Errorf("completed = %v, want [false true true]", completed)
+	}
+	if len(handled) != 2 {
+		t.Errorf("handled %v after the slow message, want both fast ones", handled)
+	}
+	select {
+	case <-slowCancelled:
+	case <-time.After(5 * time.Second):
+		t.Error("the slow handler's context was not cancelled")
+	}
+}
+
+func TestHandleWithoutTimeoutWaits(t *testing.T) {
+	done := false
+	ok := HandleWithTimeout(context.Background(), "Test", 0, Message{Topic: "slow"}, func(ctx context.Context, msg Message) {
+		time.Sleep(30 * time.Millisecond)
+		if ctx.Err() == nil {
+			done = true
+		}
+	})
+	if !ok || !done {
+		t.Errorf("returned %v with the handler done %v, want it run to completion", ok, done)
+	}
+}
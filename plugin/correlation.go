@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// MetadataCorrelationID is the message metadata key carrying a correlation id
+const MetadataCorrelationID = "correlation_id"
+
+// NewCorrelationID returns a random id for tracing a request across plugins
+func NewCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithCorrelationID attaches a correlation id to a context
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, "correlation_id", id)
+}
+
+// CorrelationID returns the correlation id attached to a context, if any
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value("correlation_id").(string)
+	return id
+}
+
+// Logf logs like log.Printf, tagging the line with the context's correlation id
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if id := CorrelationID(ctx); id != "" {
+		msg += " [cid=" + id + "]"
+	}
+	log.Print(msg)
+}
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	goplugin "plugin"
+)
+
+// RegisterSymbol is the function a Go plugin (.so) exports to add its plugins
+// and commands, typically by calling Register and cmd.Register
+const RegisterSymbol = "Register"
+
+// LoadDir opens the Go plugins (.so files) in dir, in name order, and calls
+// each one's Register function
+// A file that fails to load or register is logged and skipped; LoadDir
+// returns the paths that loaded
+func LoadDir(dir string) []string {
+	if _, err := os.Stat(dir); err != nil {
+		log.Printf("[Registry] Skipping plugin dir: %v", err)
+		return nil
+	}
+	// Glob returns the matches sorted
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		log.Printf("[Registry] Error reading plugin dir %s: %v", dir, err)
+		return nil
+	}
+
+	var loaded []string
+	for _, path := range paths {
+		if err := loadFile(path); err != nil {
+			log.Printf("[Registry] Skipping %s: %v", path, err)
+			continue
+		}
+		log.Printf("[Registry] Loaded Go plugin: %s", path)
+		loaded = append(loaded, path)
+	}
+	return loaded
+}
+
+// loadFile opens a Go plugin and calls its Register function
+// Register panics on a duplicate name, which fails the file rather than the
+// daemon
+func loadFile(path string) (err error) {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup(RegisterSymbol)
+	if err != nil {
+		return err
+	}
+	register, ok := sym.(func())
+	if !ok {
+		return fmt.Errorf("%s is a %T, want func()", RegisterSymbol, sym)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", RegisterSymbol, r)
+		}
+	}()
+	register()
+	return nil
+}
@@ -1,6 +1,11 @@
 package plugin
 
-import "context"
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
 
 // ExtensionType represents the type of extension
 type ExtensionType string
@@ -47,6 +52,48 @@ type Command struct {
 
 	// Hidden indicates if the command should be hidden from help
 	Hidden bool
+
+	// Deprecated marks a command kept for compatibility and tells users what
+	// to use instead, e.g. "use /jobs instead". Deprecated commands are left
+	// out of help listings, and each source is warned the first time it runs one
+	Deprecated string
+
+	// AliasFor names the command this one runs in its place (Handler is not
+	// used); typically an old name kept working after a rename
+	AliasFor string
+
+	// Roles lists the roles allowed to run the command (empty = everyone)
+	// Admins may run every command
+	Roles []Role
+
+	// Cooldown is the minimum time between invocations by the same source
+	// Zero disables the cooldown
+	Cooldown time.Duration
+
+	// SupportsDryRun indicates the handler checks IsDryRun and only describes
+	// its effect when it is set
+	SupportsDryRun bool
+
+	// Cacheable lets the registry answer repeated calls with the same
+	// arguments from the last successful result for CacheTTL. Only for
+	// read-only commands: a cached call doesn't run the handler
+	Cacheable bool
+
+	// CacheTTL is how long a cached result is reused (zero disables caching)
+	CacheTTL time.Duration
+}
+
+// AllowsRole reports whether a principal with the given role may run the command
+func (c *Command) AllowsRole(role Role) bool {
+	if len(c.Roles) == 0 || role == RoleAdmin {
+		return true
+	}
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
 // CommandHandler processes a command and returns a result
@@ -62,6 +109,39 @@ type CommandResult struct {
 
 	// Broadcast indicates if this result should be sent to all channels
 	Broadcast bool
+
+	// DryRun indicates the output describes what the command would do
+	DryRun bool
+
+	// Untruncated asks transports to show Output in full, ignoring max_render_chars
+	Untruncated bool
+
+	// Artifact is a file produced by the command (exports, logs)
+	// Transports that can offer downloads serve it as one; the rest show Output
+	Artifact *Artifact
+}
+
+// Artifact is a file carried by a command result
+type Artifact struct {
+	// Filename is the suggested name for the downloaded file
+	Filename string
+
+	// ContentType is the MIME type of Data (defaults to application/octet-stream)
+	ContentType string
+
+	// Data is the file contents
+	Data []byte
+}
+
+// WithDryRun marks a context so commands preview their effect instead of acting
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, "dry_run", true)
+}
+
+// IsDryRun reports whether a context was marked with WithDryRun
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value("dry_run").(bool)
+	return dryRun
 }
 
 // CommandExtension wraps a command as an extension
@@ -106,14 +186,51 @@ func (c *CommandExtension) Command() *Command {
 type Executor interface {
 	Extension
 
-	// ExecuteTask starts executing a task
-	ExecuteTask(ctx context.Context, task *Task) error
+	// ExecuteTask executes a task and returns its output
+	// Returning a *TaskError lets the daemon retry retryable failures
+	ExecuteTask(ctx context.Context, task *Task) (interface{}, error)
 
 	// CancelTask cancels a running task
 	CancelTask(ctx context.Context, taskID string) error
 
 	// GetStatus returns the current execution status
 	GetStatus(ctx context.Context) (*ExecutorStatus, error)
+
+	// CanHandle reports whether the executor handles tasks of the given type
+	CanHandle(taskType string) bool
+}
+
+// TaskPreparer is implemented by executors that capture their settings into
+// a task when it is submitted, so the whole task, retries included, runs with
+// the settings in effect then even if the plugin reloads meanwhile
+type TaskPreparer interface {
+	// PrepareTask stores the executor's current settings in task.ExecutorConfig
+	PrepareTask(task *Task)
+}
+
+// TaskObserver is notified of every task's lifecycle as it happens, without
+// subscribing to the task topics. Register one with the daemon's
+// RegisterTaskObserver. Callbacks for all tasks run one at a time, in the
+// order of the matching broker events, so they should return quickly
+type TaskObserver interface {
+	// OnStart is called when a task begins
+	OnStart(task *Task)
+
+	// OnProgress is called for each task.progress update (progress is 0-100)
+	OnProgress(task *Task, progress int, message string)
+
+	// OnComplete is called when a task succeeds
+	OnComplete(task *Task, result *TaskResult)
+
+	// OnError is called when a task fails or is cancelled; result.Error
+	// holds the reason
+	OnError(task *Task, result *TaskResult)
+}
+
+// TaskObserverRegistry is implemented by daemons that accept task observers
+type TaskObserverRegistry interface {
+	// RegisterTaskObserver adds an observer for every task run from now on
+	RegisterTaskObserver(o TaskObserver)
 }
 
 // Task represents a task to be executed
@@ -129,8 +246,175 @@ type Task struct {
 
 	// Options contains task-specific options
 	Options map[string]interface{}
+
+	// Tags label the task for later lookup, e.g. {"project": "x"}; they are
+	// copied into its result
+	Tags map[string]string
+
+	// CorrelationID ties the task's log lines and messages to the request
+	// that created it (defaults to the submitting context's id, then the task ID)
+	CorrelationID string
+
+	// ReplyTo is the source the task's messages are addressed to, e.g.
+	// "telegram:12345" (defaults to the submitting principal's source;
+	// empty broadcasts to every transport)
+	ReplyTo string
+
+	// OrderingKey makes tasks sharing it run one at a time in submission
+	// order, e.g. one conversation's tasks, while tasks with other keys run
+	// side by side (empty = no ordering)
+	OrderingKey string
+
+	// Awaited is set when the submitter waits for the task's task.completed
+	// or task.failed event and shows the result itself; the daemon then
+	// doesn't also publish the result as a response or failure notification
+	Awaited bool
+
+	// ExecutorConfig holds the settings the executor captured at submission
+	// (see TaskPreparer); only the executor that set it reads it. It may hold
+	// secrets, so it is never encoded
+	ExecutorConfig interface{} `json:"-"`
 }
 
+// ParseTag splits a "key:value" tag filter; ok is false when it has no
+// colon or an empty key
+func ParseTag(tag string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(tag, ":")
+	return key, value, ok && key != ""
+}
+
+// OptionInputFile is the task option naming a file, under the daemon's input
+// root, whose contents become the task input
+const OptionInputFile = "input_file"
+
+// TaskResult describes a completed task
+// It is published as the payload of the task's "response" message
+type TaskResult struct {
+	// ID is the task identifier
+	ID string `json:"id"`
+
+	// Type is the task type
+	Type string `json:"type"`
+
+	// Output is the value returned by the executor
+	Output interface{} `json:"output,omitempty"`
+
+	// OutputEncoding is "base64" when Output is binary data
+	OutputEncoding string `json:"output_encoding,omitempty"`
+
+	// Duration is how long the task took to execute
+	Duration time.Duration `json:"duration"`
+
+	// Error is set if the task failed
+	Error string `json:"error,omitempty"`
+
+	// ErrorDetail is set if the executor failed with a *TaskError
+	ErrorDetail *TaskError `json:"error_detail,omitempty"`
+
+	// Attempts is how many times the task ran, including retries
+	Attempts int `json:"attempts,omitempty"`
+
+	// Tags are the task's tags
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// DeliveryFailures records transports that failed to deliver the result
+	DeliveryFailures []DeliveryFailure `json:"delivery_failures,omitempty"`
+}
+
+// DeadLetter is a task that failed on every attempt, kept for inspection
+type DeadLetter struct {
+	// ID is the task identifier
+	ID string `json:"id"`
+
+	// Type is the task type
+	Type string `json:"type"`
+
+	// Input and Options are what the task was submitted with
+	Input   interface{}            `json:"input,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+
+	// Tags are the task's tags
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// CorrelationID traces the task back to the request that created it
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// Error is the last attempt's error
+	Error string `json:"error"`
+
+	// ErrorDetail is set if the last attempt failed with a *TaskError
+	ErrorDetail *TaskError `json:"error_detail,omitempty"`
+
+	// Attempts is how many times the task ran
+	Attempts int `json:"attempts"`
+
+	// FailedAt is when the last attempt failed
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// TaskError is an executor error that tells the daemon what went wrong and
+// whether running the task again may succeed
+type TaskError struct {
+	// Code is a short machine-readable reason, e.g. "rate_limited"
+	Code string `json:"code"`
+
+	// Message describes the failure
+	Message string `json:"message"`
+
+	// Retryable marks failures worth retrying, e.g. timeouts or rate limits
+	Retryable bool `json:"retryable"`
+}
+
+// NewTaskError creates a task error
+func NewTaskError(code, message string, retryable bool) *TaskError {
+	return &TaskError{Code: code, Message: message, Retryable: retryable}
+}
+
+// Error returns the code and message
+func (e *TaskError) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	return e.Code + ": " + e.Message
+}
+
+// AsTaskError returns the *TaskError in err's chain, if any
+func AsTaskError(err error) (*TaskError, bool) {
+	var taskErr *TaskError
+	if errors.As(err, &taskErr) {
+		return taskErr, true
+	}
+	return nil, false
+}
+
+// IsRetryable reports whether err is a *TaskError marked retryable
+func IsRetryable(err error) bool {
+	taskErr, ok := AsTaskError(err)
+	return ok && taskErr.Retryable
+}
+
+// DeliveryFailure records a transport's failure to deliver a task message
+type DeliveryFailure struct {
+	Source string    `json:"source"`
+	Error  string    `json:"error"`
+	Time   time.Time `json:"time"`
+}
+
+// String returns a human-readable form for transports that only render text
+func (r *TaskResult) String() string {
+	if r.Error != "" {
+		return "Task failed: " + r.Error
+	}
+	if r.Output == nil || r.Output == "" {
+		return "Task completed successfully"
+	}
+	return PayloadText(r.Output)
+}
+
+// MetadataCancelled is the message metadata key set to true on a response
+// carrying the partial output of a cancelled task
+const MetadataCancelled = "cancelled"
+
 // ExecutorStatus represents the current state of an executor
 type ExecutorStatus struct {
 	// State is the current executor state
@@ -142,6 +426,10 @@ type ExecutorStatus struct {
 	// Progress indicates task completion percentage (0-100)
 	Progress int
 
+	// Partial is the output streamed so far by an executor that streams it;
+	// after a cancellation it keeps what was produced until the next task
+	Partial string
+
 	// Message contains a status message
 	Message string
 }
@@ -0,0 +1,46 @@
+package plugin
+
+import "context"
+
+// FeatureSource is implemented by the daemon config, which lists the
+// experimental features turned on for each plugin
+type FeatureSource interface {
+	PluginFeatures(pluginName string) []string
+}
+
+// WithPluginName records which plugin a context is handed to, for HasFeature
+// The daemon sets it on the contexts it passes to Start and Reload
+func WithPluginName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, "plugin", name)
+}
+
+// PluginNameFromContext returns the plugin recorded by WithPluginName
+func PluginNameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value("plugin").(string)
+	return name, ok && name != ""
+}
+
+// HasFeature reports whether the features list of the plugin the context was
+// handed to includes feature, e.g. HasFeature(ctx, "streaming")
+func HasFeature(ctx context.Context, feature string) bool {
+	name, ok := PluginNameFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return HasPluginFeature(ctx, name, feature)
+}
+
+// HasPluginFeature reports whether the named plugin's features list in the
+// context's config includes feature
+func HasPluginFeature(ctx context.Context, pluginName, feature string) bool {
+	source, ok := ctx.Value("config").(FeatureSource)
+	if !ok {
+		return false
+	}
+	for _, f := range source.PluginFeatures(pluginName) {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
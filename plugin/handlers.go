@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// TaskHandler executes a single task type and returns its output
+type TaskHandler func(ctx context.Context, task *Task) (interface{}, error)
+
+var (
+	// globalTaskHandlers is the global task handler registry
+	globalTaskHandlers = NewTaskHandlerRegistry()
+)
+
+// TaskHandlerRegistry maps task types to handler functions
+// It lets one plugin serve several task types without writing an executor
+type TaskHandlerRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]TaskHandler
+}
+
+// NewTaskHandlerRegistry creates an empty task handler registry
+func NewTaskHandlerRegistry() *TaskHandlerRegistry {
+	return &TaskHandlerRegistry{
+		handlers: make(map[string]TaskHandler),
+	}
+}
+
+// RegisterTaskHandler adds a handler for a task type to the global registry
+// This is typically called from plugin init() or Start functions
+func RegisterTaskHandler(taskType string, fn TaskHandler) {
+	globalTaskHandlers.Register(taskType, fn)
+}
+
+// GetTaskHandlerRegistry returns the global task handler registry
+func GetTaskHandlerRegistry() *TaskHandlerRegistry {
+	return globalTaskHandlers
+}
+
+// Register adds a handler for a task type
+func (r *TaskHandlerRegistry) Register(taskType string, fn TaskHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[taskType]; exists {
+		panic(fmt.Sprintf("task handler %s already registered", taskType))
+	}
+
+	r.handlers[taskType] = fn
+	log.Printf("[Registry] Registered task handler: %s", taskType)
+}
+
+// Get retrieves the handler for a task type
+func (r *TaskHandlerRegistry) Get(taskType string) (TaskHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, exists := r.handlers[taskType]
+	return fn, exists
+}
+
+// Types returns the registered task types, sorted
+func (r *TaskHandlerRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	types := make([]string, 0, len(r.handlers))
+	for taskType := range r.handlers {
+		types = append(types, taskType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// DispatchExecutor is an executor that routes tasks to registered handlers
+// by task type
+type DispatchExecutor struct {
+	registry *TaskHandlerRegistry
+
+	mu          sync.RWMutex
+	currentTask *Task
+	cancel      context.CancelFunc
+}
+
+// NewDispatchExecutor creates an executor for the handlers in registry
+func NewDispatchExecutor(registry *TaskHandlerRegistry) *DispatchExecutor {
+	return &DispatchExecutor{registry: registry}
+}
+
+// Type returns the extension type
+func (e *DispatchExecutor) Type() ExtensionType {
+	return ExtensionTypeExecutor
+}
+
+// Name returns the extension name
+func (e *DispatchExecutor) Name() string {
+	return "dispatch"
+}
+
+// SupportsMode reports that dispatch works in all modes
+func (e *DispatchExecutor) SupportsMode(mode Mode) bool {
+	return true
+}
+
+// CanHandle reports whether a handler is registered for the task type
+func (e *DispatchExecutor) CanHandle(taskType string) bool {
+	_, ok := e.registry.Get(taskType)
+	return ok
+}
+
+// ExecuteTask runs the handler registered for the task's type
+func (e *DispatchExecutor) ExecuteTask(ctx context.Context, task *Task) (interface{}, error) {
+	fn, ok := e.registry.Get(task.Type)
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for task type: %s", task.Type)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.mu.Lock()
+	e.currentTask = task
+	e.cancel = cancel
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.currentTask = nil
+		e.cancel = nil
+		e.mu.Unlock()
+	}()
+
+	return fn(ctx, task)
+}
+
+// CancelTask cancels the running task's context
+func (e *DispatchExecutor) CancelTask(ctx context.Context, taskID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.currentTask == nil || e.currentTask.ID != taskID {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+
+	e.cancel()
+	return nil
+}
+
+// GetStatus returns the current executor status
+func (e *DispatchExecutor) GetStatus(ctx context.Context) (*ExecutorStatus, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	status := &ExecutorStatus{State: ExecutorStateIdle}
+	if e.currentTask != nil {
+		status.State = ExecutorStateWorking
+		status.CurrentTask = e.currentTask
+	}
+	return status, nil
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDispatchExecutorRoutesByTaskType(t *testing.T) {
+	registry := NewTaskHandlerRegistry()
+	registry.Register("upper", func(ctx context.Context, task *Task) (interface{}, error) {
+		return strings.ToUpper(task.Input.(string)), nil
+	})
+	registry.Register("length", func(ctx context.Context, task *Task) (interface{}, error) {
+		return len(task.Input.(string)), nil
+	})
+
+	if got := registry.Types(); len(got) != 2 || got[0] != "length" || got[1] != "upper" {
+		t.Errorf("Types() = %v, want [length upper]", got)
+	}
+
+	e := NewDispatchExecutor(registry)
+	for _, tt := range []struct {
+		taskType string
+		want     interface{}
+	}{
+		{"upper", "HELLO"},
+		{"length", 5},
+	} {
+		if !e.CanHandle(tt.taskType) {
+			t.Errorf("CanHandle(%s) = false", tt.taskType)
+		}
+		output, err := e.ExecuteTask(context.Background(), &Task{ID: tt.taskType, Type: tt.taskType, Input: "hello"})
+		if err != nil {
+			t.Fatalf("ExecuteTask(%s): %v", tt.taskType, err)
+		}
+		if output != tt.want {
+			t.Errorf("%s output = %v, want %v", tt.taskType, output, tt.want)
+		}
+	}
+
+	if e.CanHandle("missing") {
+		t.Error("CanHandle(missing) = true")
+	}
+	if _, err := e.ExecuteTask(context.Background(), &Task{ID: "t3", Type: "missing"}); err == nil {
+		t.Error("ExecuteTask with no handler succeeded")
+	}
+}
+
+func TestDispatchExecutorCancelsRunningHandler(t *testing.T) {
+	registry := NewTaskHandlerRegistry()
+	started := make(chan struct{})
+	registry.Register("wait", func(ctx context.Context, task *Task) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	e := NewDispatchExecutor(registry)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.ExecuteTask(context.Background(), &Task{ID: "t1", Type: "wait"})
+		done <- err
+	}()
+	<-started
+
+	if status, _ := e.GetStatus(context.Background()); status.State != ExecutorStateWorking || status.CurrentTask.ID != "t1" {
+		t.Errorf("status = %+v, want working on t1", status)
+	}
+	if err := e.CancelTask(context.Background(), "other"); err == nil {
+		t.Error("CancelTask(other) succeeded")
+	}
+	if err := e.CancelTask(context.Background(), "t1"); err != nil {
+		t.Fatalf("CancelTask: %v", err)
+	}
+	if err := <-done; err != context.Canceled {
+		t.Errorf("ExecuteTask error = %v, want context.Canceled", err)
+	}
+	if status, _ := e.GetStatus(context.Background()); status.State != ExecutorStateIdle {
+		t.Errorf("status after cancel = %+v, want idle", status)
+	}
+}
+
+func TestRegisterDuplicateHandlerPanics(t *testing.T) {
+	registry := NewTaskHandlerRegistry()
+	handler := func(ctx context.Context, task *Task) (interface{}, error) { return nil, nil }
+	registry.Register("dup", handler)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering dup twice did not panic")
+		}
+	}()
+	registry.Register("dup", handler)
+}
@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MetadataVia is the message metadata key listing the transports a message
+// passed through before its current source, oldest first. A transport that
+// re-publishes a message it received uses Relay, which records the hop
+const MetadataVia = "via"
+
+// MaxHops is how many transports a message may pass through before brokers
+// refuse it
+const MaxHops = 8
+
+// ErrMessageLoop is returned (wrapped) by Relay and by brokers for a message
+// that came back to a transport it already passed through
+var ErrMessageLoop = errors.New("message loop")
+
+// Via returns every transport a message passed through, oldest first, ending
+// with the transport of its current source ("telegram" for "telegram:12345")
+func Via(msg Message) []string {
+	var via []string
+	switch hops := msg.Metadata[MetadataVia].(type) {
+	case []string:
+		via = append(via, hops...)
+	case []interface{}:
+		for _, hop := range hops {
+			if s, ok := hop.(string); ok {
+				via = append(via, s)
+			}
+		}
+	}
+	if transport := sourceTransport(msg.Source); transport != "" {
+		via = append(via, transport)
+	}
+	return via
+}
+
+// PassedThrough reports whether a message came from or through a transport
+func PassedThrough(msg Message, transport string) bool {
+	for _, hop := range Via(msg) {
+		if hop == transport {
+			return true
+		}
+	}
+	return false
+}
+
+// Relay returns a copy of a received message for transport to re-publish as
+// its source, recording the hop, or an error wrapping ErrMessageLoop if the
+// message already passed through transport or has too many hops
+func Relay(msg Message, transport string) (Message, error) {
+	via := Via(msg)
+	if PassedThrough(msg, transport) {
+		return msg, fmt.Errorf("%w: already passed through %s (via %s)", ErrMessageLoop, transport, strings.Join(via, ", "))
+	}
+	if len(via) >= MaxHops {
+		return msg, fmt.Errorf("%w: more than %d hops", ErrMessageLoop, MaxHops)
+	}
+
+	metadata := make(map[string]interface{}, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataVia] = via
+	msg.Metadata = metadata
+	msg.Source = transport
+	return msg, nil
+}
+
+// CheckLoop returns an error wrapping ErrMessageLoop if a message's source
+// is among the transports it already passed through, or it has too many
+// hops. Brokers run it on every publish
+func CheckLoop(msg Message) error {
+	via := Via(msg)
+	if len(via) > MaxHops {
+		return fmt.Errorf("%w: more than %d hops", ErrMessageLoop, MaxHops)
+	}
+
+	current := sourceTransport(msg.Source)
+	if current == "" {
+		return nil
+	}
+	for _, hop := range via[:len(via)-1] {
+		if hop == current {
+			return fmt.Errorf("%w: back at %s (via %s)", ErrMessageLoop, current, strings.Join(via, ", "))
+		}
+	}
+	return nil
+}
+
+// sourceTransport returns the transport part of a source, e.g. "telegram"
+// for "telegram:12345"
+func sourceTransport(source string) string {
+	transport, _, _ := strings.Cut(source, ":")
+	return transport
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Binary payload convention: on the broker a binary payload is a []byte,
+// passed through untouched and marked with Metadata["encoding"] = "base64".
+// JSON transports carry it as base64 text with an "encoding" field
+
+const (
+	// MetadataEncoding is the metadata key noting a payload's encoding
+	MetadataEncoding = "encoding"
+
+	// EncodingBase64 marks binary data that is base64-encoded on the wire
+	EncodingBase64 = "base64"
+)
+
+// EncodePayload converts a payload to wire text, base64-encoding binary data
+// The returned encoding is empty for text
+func EncodePayload(payload interface{}) (string, string) {
+	switch v := payload.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), EncodingBase64
+	case string:
+		return v, ""
+	default:
+		return fmt.Sprintf("%v", v), ""
+	}
+}
+
+// DecodePayload converts wire text back to a payload, returning []byte for
+// base64-encoded data and the text itself otherwise
+func DecodePayload(text, encoding string) (interface{}, error) {
+	switch encoding {
+	case "":
+		return text, nil
+	case EncodingBase64:
+		data, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 payload: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported payload encoding: %s", encoding)
+	}
+}
+
+// PayloadText renders a payload for text-only transports, summarizing binary
+// data instead of printing raw bytes
+func PayloadText(payload interface{}) string {
+	switch v := payload.(type) {
+	case string:
+		return v
+	case []byte:
+		return fmt.Sprintf("[binary data, %d bytes]", len(v))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// PayloadSize returns a payload's size in bytes: the length of a string or
+// []byte, otherwise the length of its JSON encoding
+func PayloadSize(payload interface{}) int {
+	switch v := payload.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return len(fmt.Sprintf("%v", payload))
+	}
+	return len(data)
+}
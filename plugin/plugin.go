@@ -2,6 +2,8 @@ package plugin
 
 import (
 	"context"
+	"errors"
+	"time"
 )
 
 // Mode represents the execution mode of the daemon
@@ -33,6 +35,75 @@ type Plugin interface {
 	Stop(ctx context.Context) error
 }
 
+// Dependent is optionally implemented by plugins that must start after
+// other plugins. Dependencies are started first and stopped last
+type Dependent interface {
+	// Dependencies returns the names of plugins this plugin depends on
+	Dependencies() []string
+}
+
+// Labeled is optionally implemented by plugins that belong to label groups,
+// e.g. "chat", "api" or "ui", so config can enable or disable them together
+// with enable_labels and disable_labels
+type Labeled interface {
+	// Labels returns the plugin's labels
+	Labels() []string
+}
+
+// PluginLabels returns the labels of a plugin, if any
+func PluginLabels(p Plugin) []string {
+	if l, ok := p.(Labeled); ok {
+		return l.Labels()
+	}
+	return nil
+}
+
+// DependencyGraph describes the plugins' declared dependencies and the order
+// the daemon starts them in
+type DependencyGraph struct {
+	// Plugins lists every added plugin in the order it was added
+	Plugins []PluginDependencies `json:"plugins"`
+
+	// StartOrder lists the plugins that can start, dependencies first
+	StartOrder []string `json:"start_order"`
+}
+
+// PluginDependencies describes one plugin in a DependencyGraph
+type PluginDependencies struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"depends_on,omitempty"`
+	Running   bool     `json:"running"`
+
+	// Problem explains why the plugin can't start, e.g. a missing or cyclic dependency
+	Problem string `json:"problem,omitempty"`
+}
+
+// StatusReporter is optionally implemented by plugins that contribute a
+// section to the daemon status output
+type StatusReporter interface {
+	// StatusSection returns the section title and its lines
+	StatusSection() (title string, lines []string)
+}
+
+// Reloadable is optionally implemented by plugins that can apply new
+// settings without restarting. The context's "config" value is the newly
+// loaded configuration; returning an error keeps the old settings
+type Reloadable interface {
+	// Reload re-reads the plugin's settings
+	Reload(ctx context.Context) error
+}
+
+// Subscriber is optionally implemented by plugins that subscribe to the
+// broker before any plugin starts. The daemon calls Subscribe on every such
+// plugin first, then Start in dependency order, so messages published by
+// plugins while they start are already delivered to the subscription
+type Subscriber interface {
+	// Subscribe creates the plugin's subscriptions and keeps their channels;
+	// reading them begins in Start. It returns the subscription ids, which
+	// the daemon unsubscribes if the plugin doesn't start after all
+	Subscribe(ctx context.Context, broker MessageBroker) ([]string, error)
+}
+
 // MessageBroker defines the interface for pub/sub communication
 // This is defined here to avoid circular dependencies
 type MessageBroker interface {
@@ -47,6 +118,187 @@ type MessageBroker interface {
 	Unsubscribe(id string)
 }
 
+// SubscribeOptions configures a subscription made with SubscribeWith
+type SubscribeOptions struct {
+	// Topics lists the topics to receive (empty = all)
+	Topics []string
+
+	// BufSize is the subscription channel's capacity
+	BufSize int
+
+	// Filter, if set, skips messages it returns false for, replayed ones included
+	Filter func(Message) bool
+
+	// Replay caps how many retained messages are replayed on subscribing
+	// (0 = as many as fit in the buffer, negative = none)
+	Replay int
+
+	// Coalesce makes a full buffer drop its oldest message to take a new one
+	// instead of making the publisher wait, for streams where only recent
+	// values matter. It needs a BufSize of at least 1
+	Coalesce bool
+
+	// Context, if set, ends the subscription and closes its channel when done
+	Context context.Context
+}
+
+// OptionsSubscriber is implemented by brokers that take SubscribeOptions;
+// Subscribe(id, bufSize, topics...) is SubscribeWith with only those set
+type OptionsSubscriber interface {
+	SubscribeWith(id string, opts SubscribeOptions) <-chan Message
+}
+
+// ReadinessChecker is implemented by the daemon so transports can refuse
+// traffic until it has finished starting
+type ReadinessChecker interface {
+	Ready() bool
+}
+
+// IsReady reports whether the daemon in ctx is ready; contexts without a
+// daemon count as ready
+func IsReady(ctx context.Context) bool {
+	if d, ok := ctx.Value("daemon").(ReadinessChecker); ok {
+		return d.Ready()
+	}
+	return true
+}
+
+// HealthChecker is implemented by plugins that can tell whether their
+// background work is still running. The daemon's supervisor restarts plugins
+// that fail repeatedly
+type HealthChecker interface {
+	// HealthCheck returns an error describing what stopped working
+	HealthCheck(ctx context.Context) error
+}
+
+// DrainingUnsubscriber is implemented by brokers that can hand a
+// subscription's buffered messages to the subscriber as it unsubscribes
+type DrainingUnsubscriber interface {
+	// UnsubscribeDrain removes the subscription and passes each message still
+	// buffered in its channel to drain, in order, before returning
+	UnsubscribeDrain(id string, drain func(Message))
+}
+
+// ErrPublishUnauthorized is returned (wrapped) by brokers that refuse a
+// message because its source may not publish to its topic
+var ErrPublishUnauthorized = errors.New("publish not authorized")
+
+// ErrMessageStale is reported (wrapped) by brokers for a delivery they
+// dropped because the message waited for it as long as its MaxAge; the
+// publish itself still succeeds
+var ErrMessageStale = errors.New("message too old to deliver")
+
+// ErrPayloadTooLarge is returned (wrapped) by brokers that refuse a message
+// whose payload is over their size limit
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// ErrNoSubscribers is returned (wrapped) by brokers for a message on a topic
+// that must have a subscriber but has none
+var ErrNoSubscribers = errors.New("no subscribers")
+
+// AsyncPublisher is implemented by brokers that can publish without waiting
+// for delivery, for fire-and-forget messages
+type AsyncPublisher interface {
+	// PublishAsync queues a message for background delivery and returns immediately
+	PublishAsync(ctx context.Context, msg Message) error
+}
+
+// MessageTransform rewrites a message before the broker delivers it, e.g. to
+// redact a payload. It gets a copy of the message but shares its Metadata map,
+// so it must copy the map before changing it
+type MessageTransform func(Message) Message
+
+// TransformRegistrar is implemented by brokers that run transforms on
+// messages before delivery
+type TransformRegistrar interface {
+	// RegisterTransform adds fn to the transforms for topic ("*" = every
+	// topic); a message passes through its transforms in registration order
+	RegisterTransform(topic string, fn MessageTransform)
+}
+
+// CongestionReporter is implemented by brokers that can tell producers a
+// topic's subscribers are falling behind, so they can slow down
+type CongestionReporter interface {
+	// Congested reports whether a subscriber of topic has a nearly full buffer
+	Congested(topic string) bool
+}
+
+// IsCongested reports whether topic is congested on broker; brokers that
+// don't implement CongestionReporter are never congested
+func IsCongested(broker MessageBroker, topic string) bool {
+	if cr, ok := broker.(CongestionReporter); ok {
+		return cr.Congested(topic)
+	}
+	return false
+}
+
+// WaitUncongested blocks while topic is congested on broker, polling every
+// interval, for at most maxWait. It returns ctx's error if ctx ends first
+func WaitUncongested(ctx context.Context, broker MessageBroker, topic string, interval, maxWait time.Duration) error {
+	deadline := time.Now().Add(maxWait)
+	for IsCongested(broker, topic) && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+	return nil
+}
+
+// SubscriberStats describes a broker subscription's buffer usage
+type SubscriberStats struct {
+	// ID identifies the subscription
+	ID string `json:"id"`
+
+	// Topics the subscription listens on (empty = all)
+	Topics []string `json:"topics"`
+
+	// BufSize is the subscription channel's capacity
+	BufSize int `json:"buf_size"`
+
+	// Pending is how many messages are waiting in the channel now
+	Pending int `json:"pending"`
+
+	// HighWater is the most messages ever seen waiting in the channel
+	HighWater int `json:"high_water"`
+}
+
+// TopicDeliveryFailed is published by transports that fail to deliver a
+// task-related message to the end user. Metadata carries "task_id", the
+// original "topic" and the "error"
+const TopicDeliveryFailed = "delivery.failed"
+
+// Broker loopback topics used by /ping. The daemon answers every daemon.ping
+// message with a daemon.pong carrying the same "correlation_id"
+const (
+	TopicPing = "daemon.ping"
+	TopicPong = "daemon.pong"
+)
+
+// Task lifecycle topics published by the daemon for every task it runs
+// Metadata carries "task_id", "type", "correlation_id" and "reply_to".
+// task.progress is published by executors and adds "progress" (0-100)
+const (
+	// TopicTaskStarted is published when a task begins; the payload is a text summary
+	TopicTaskStarted = "task.started"
+
+	// TopicTaskProgress carries executor progress updates
+	TopicTaskProgress = "task.progress"
+
+	// TopicTaskCompleted is published when a task succeeds; the payload is its *TaskResult
+	TopicTaskCompleted = "task.completed"
+
+	// TopicTaskFailed is published when a task fails or is cancelled; the
+	// payload is its *TaskResult with Error set
+	TopicTaskFailed = "task.failed"
+
+	// TopicTaskRetrying is published when a task failed with a retryable
+	// error and is about to run again; the payload is a text summary and
+	// metadata adds "attempt" and "error"
+	TopicTaskRetrying = "task.retrying"
+)
+
 // Message represents a message in the pub/sub system
 type Message struct {
 	// Topic is the message category/channel
@@ -60,4 +312,19 @@ type Message struct {
 
 	// Metadata contains additional message information
 	Metadata map[string]interface{}
+
+	// ExpiresAt stops a retained copy of the message from being replayed
+	// after this time (zero = no expiry)
+	ExpiresAt time.Time
+
+	// Timestamp is when the message was published; the broker sets it if zero
+	Timestamp time.Time
+
+	// MaxAge drops the message if it is still waiting for delivery this long
+	// after Timestamp (zero = the broker's default)
+	MaxAge time.Duration
+
+	// OrderingKey is the ordering key of the task the message reports on, so
+	// subscribers can tell one conversation's messages from another's
+	OrderingKey string
 }
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// congestedBroker reports congestion until its polls run out
+type congestedBroker struct {
+	MessageBroker
+	polls atomic.Int32
+}
+
+func (b *congestedBroker) Congested(topic string) bool {
+	return b.polls.Add(-1) >= 0
+}
+
+func TestWaitUncongestedWaitsForSubscribers(t *testing.T) {
+	b := &congestedBroker{}
+	b.polls.Store(3)
+
+	if err := WaitUncongested(context.Background(), b, "task.progress", time.Millisecond, time.Minute); err != nil {
+		t.Fatalf("WaitUncongested: %v", err)
+	}
+	if left := b.polls.Load(); left != -1 {
+		t.Errorf("returned with %d congested polls left, want it to wait them out", left+1)
+	}
+}
+
+func TestWaitUncongestedGivesUp(t *testing.T) {
+	b := &congestedBroker{}
+	b.polls.Store(1 << 30)
+
+	start := time.Now()
+	if err := WaitUncongested(context.Background(), b, "task.progress", time.Millisecond, 20*time.Millisecond); err != nil {
+		t.Errorf("WaitUncongested after maxWait = %v, want nil", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %s, want about maxWait", waited)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WaitUncongested(ctx, b, "task.progress", time.Millisecond, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitUncongested with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	// Brokers that can't report congestion never are
+	if IsCongested(nil, "task.progress") {
+		t.Error("a broker without congestion reporting is congested")
+	}
+}
+
+func TestSeverityOf(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  Severity
+	}{
+		{nil, SeverityInfo},
+		{"error", SeverityError},
+		{SeverityWarn, SeverityWarn},
+		{"success", SeveritySuccess},
+		{"fatal", SeverityInfo},
+		{42, SeverityInfo},
+	}
+	for _, tt := range tests {
+		msg := Message{Metadata: map[string]interface{}{MetadataSeverity: tt.value}}
+		if got := SeverityOf(msg); got != tt.want {
+			t.Errorf("SeverityOf(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
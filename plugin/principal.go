@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"context"
+)
+
+// Role is the permission level of a principal
+type Role string
+
+const (
+	// RoleUser is an ordinary chat or API user
+	RoleUser Role = "user"
+
+	// RoleAdmin is a trusted operator (local terminal or authenticated API client)
+	RoleAdmin Role = "admin"
+)
+
+// Principal identifies who issued a command
+type Principal struct {
+	// Source is the transport-qualified origin, e.g. "telegram:12345"
+	Source string `json:"source"`
+
+	// UserID identifies the user within the transport
+	UserID string `json:"user_id"`
+
+	// Role is the principal's permission level
+	Role Role `json:"role"`
+
+	// LimitKey is what command rate limits count against, for transports
+	// whose Source names a single connection rather than the client
+	// (empty = Source)
+	LimitKey string `json:"limit_key,omitempty"`
+}
+
+// RateLimitKey returns what the principal's commands are rate limited by
+func (p Principal) RateLimitKey() string {
+	if p.LimitKey != "" {
+		return p.LimitKey
+	}
+	return p.Source
+}
+
+// WithPrincipal attaches a principal to a context
+// The principal's source is also set as the "source" value used for cooldowns
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	ctx = context.WithValue(ctx, "source", p.Source)
+	return context.WithValue(ctx, "principal", p)
+}
+
+// PrincipalFromContext returns the principal attached to a context
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value("principal").(Principal)
+	return p, ok
+}
+
+// RoleFromContext returns the role of the context's principal, or RoleUser
+// if it has none
+func RoleFromContext(ctx context.Context) Role {
+	if p, ok := PrincipalFromContext(ctx); ok && p.Role != "" {
+		return p.Role
+	}
+	return RoleUser
+}
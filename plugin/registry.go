@@ -3,6 +3,7 @@ package plugin
 import (
 	"fmt"
 	"log"
+	"sort"
 	"sync"
 )
 
@@ -57,6 +58,12 @@ func (r *Registry) All() []Plugin {
 	for _, p := range r.plugins {
 		plugins = append(plugins, p)
 	}
+
+	// Sort by name for consistent output and load order
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name() < plugins[j].Name()
+	})
+
 	return plugins
 }
 
@@ -62,6 +62,69 @@ func (rc *RequirementChecker) AddOptional(name, description string, checkFunc fu
 	})
 }
 
+// RequirementResult is the outcome of one requirement check
+type RequirementResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Passed      bool   `json:"passed"`
+	Error       string `json:"error,omitempty"`
+}
+
+// RequirementReport is the outcome of a plugin's requirement checks
+type RequirementReport struct {
+	Plugin string `json:"plugin"`
+
+	// Passed is set when CheckRequirements returned no error
+	Passed bool `json:"passed"`
+
+	// Error is the error CheckRequirements returned, if any
+	Error string `json:"error,omitempty"`
+
+	// Results has one entry per requirement checked with a RequirementChecker
+	Results []RequirementResult `json:"results"`
+}
+
+// WithRequirementReport makes every RequirementChecker run with ctx add its
+// results to report, so a plugin's CheckRequirements can be inspected
+func WithRequirementReport(ctx context.Context, report *RequirementReport) context.Context {
+	return context.WithValue(ctx, "requirement_report", report)
+}
+
+// CheckDetailed runs all requirement checks and returns each outcome,
+// without logging
+func (rc *RequirementChecker) CheckDetailed(ctx context.Context) []RequirementResult {
+	results, _ := rc.run(ctx)
+	return results
+}
+
+// run runs all requirement checks, returning each outcome and the errors of
+// the checks that failed
+func (rc *RequirementChecker) run(ctx context.Context) ([]RequirementResult, []error) {
+	results := make([]RequirementResult, 0, len(rc.requirements))
+	errs := make([]error, 0, len(rc.requirements))
+	for _, req := range rc.requirements {
+		result := RequirementResult{
+			Name:        req.Name,
+			Description: req.Description,
+			Required:    req.Required,
+			Passed:      true,
+		}
+		err := req.CheckFunc(ctx)
+		if err != nil {
+			result.Passed = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+		errs = append(errs, err)
+	}
+
+	if report, ok := ctx.Value("requirement_report").(*RequirementReport); ok {
+		report.Results = append(report.Results, results...)
+	}
+	return results, errs
+}
+
 // Check runs all requirement checks
 // Returns an error if any required check fails
 func (rc *RequirementChecker) Check(ctx context.Context) error {
@@ -72,14 +135,17 @@ func (rc *RequirementChecker) Check(ctx context.Context) error {
 	log.Printf("[%s] Checking %d requirement(s)...", rc.pluginName, len(rc.requirements))
 
 	var errors []string
+	var causes []error
 	var warnings []string
 
-	for _, req := range rc.requirements {
-		if err := req.CheckFunc(ctx); err != nil {
+	_, errs := rc.run(ctx)
+	for i, req := range rc.requirements {
+		if err := errs[i]; err != nil {
 			msg := fmt.Sprintf("%s: %v", req.Name, err)
 
 			if req.Required {
 				errors = append(errors, msg)
+				causes = append(causes, err)
 				log.Printf("[%s] ✗ Required check failed: %s", rc.pluginName, msg)
 			} else {
 				warnings = append(warnings, msg)
@@ -97,13 +163,33 @@ func (rc *RequirementChecker) Check(ctx context.Context) error {
 
 	// Return error if any required checks failed
 	if len(errors) > 0 {
-		return fmt.Errorf("requirement check(s) failed: %s", strings.Join(errors, "; "))
+		return &requirementsError{
+			msg:    fmt.Sprintf("requirement check(s) failed: %s", strings.Join(errors, "; ")),
+			causes: causes,
+		}
 	}
 
 	log.Printf("[%s] All required checks passed", rc.pluginName)
 	return nil
 }
 
+// requirementsError reports failed required checks, keeping each check's
+// error so callers can find a *ConfigError among them
+type requirementsError struct {
+	msg    string
+	causes []error
+}
+
+// Error implements the error interface
+func (e *requirementsError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the failed checks' errors
+func (e *requirementsError) Unwrap() []error {
+	return e.causes
+}
+
 // Common requirement check functions
 
 // RequireMode creates a requirement that checks for a specific mode
@@ -0,0 +1,21 @@
+package plugin
+
+import "strings"
+
+// MetadataReplyTo is the message metadata key naming the source a message is
+// addressed to, e.g. "telegram:12345". Messages without it are broadcast
+const MetadataReplyTo = "reply_to"
+
+// ReplyTo returns the source a message is addressed to, or "" for a broadcast
+func ReplyTo(msg Message) string {
+	replyTo, _ := msg.Metadata[MetadataReplyTo].(string)
+	return replyTo
+}
+
+// AddressedTo reports whether a transport should surface a message
+// Broadcasts are addressed to everyone; addressed messages only reach the
+// transport they name, so "telegram" matches "telegram" and "telegram:12345"
+func AddressedTo(msg Message, transport string) bool {
+	replyTo := ReplyTo(msg)
+	return replyTo == "" || replyTo == transport || strings.HasPrefix(replyTo, transport+":")
+}
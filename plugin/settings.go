@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SettingKind is the type of a plugin setting value
+type SettingKind string
+
+const (
+	// SettingString is a string setting
+	SettingString SettingKind = "string"
+	// SettingInt is an integer setting
+	SettingInt SettingKind = "int"
+	// SettingBool is a boolean setting
+	SettingBool SettingKind = "bool"
+)
+
+// ErrInvalidSettings is returned for settings that don't match a plugin's schema
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// SettingsSchemaProvider is implemented by plugins that declare the settings
+// they read, so changes to them can be checked before they are applied
+type SettingsSchemaProvider interface {
+	// SettingsSchema maps each setting name to its kind
+	SettingsSchema() map[string]SettingKind
+}
+
+// ValidateSettings checks settings against a schema, rejecting unknown
+// settings and values of the wrong kind
+func ValidateSettings(schema map[string]SettingKind, settings map[string]interface{}) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		kind, ok := schema[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown setting %s", ErrInvalidSettings, name)
+		}
+
+		var valid bool
+		switch settings[name].(type) {
+		case string:
+			valid = kind == SettingString
+		case int:
+			valid = kind == SettingInt
+		case bool:
+			valid = kind == SettingBool
+		}
+		if !valid {
+			return fmt.Errorf("%w: %s must be %s, got %T", ErrInvalidSettings, name, kind, settings[name])
+		}
+	}
+	return nil
+}
+
+// ConfigError describes a missing or malformed plugin setting
+type ConfigError struct {
+	// Plugin is the plugin the setting belongs to
+	Plugin string
+
+	// Key is the setting name
+	Key string
+
+	// Reason says what is wrong with the setting
+	Reason string
+}
+
+// NewConfigError creates a ConfigError
+func NewConfigError(pluginName, key, reason string) *ConfigError {
+	return &ConfigError{Plugin: pluginName, Key: key, Reason: reason}
+}
+
+// Error implements the error interface
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("plugin %s: %s", e.Plugin, e.Reason)
+	}
+	return fmt.Sprintf("plugin %s: setting %s: %s", e.Plugin, e.Key, e.Reason)
+}
+
+// ConfigValidator is implemented by plugins that can check their settings
+// before requirements are checked, so a misconfiguration is reported early.
+// Return *ConfigError values, joined with errors.Join when there are several
+type ConfigValidator interface {
+	ValidateConfig(ctx context.Context) error
+}
+
+// ConfigErrors returns every *ConfigError in err's tree
+func ConfigErrors(err error) []*ConfigError {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *ConfigError:
+		return []*ConfigError{e}
+	case interface{ Unwrap() []error }:
+		var out []*ConfigError
+		for _, inner := range e.Unwrap() {
+			out = append(out, ConfigErrors(inner)...)
+		}
+		return out
+	}
+	return ConfigErrors(errors.Unwrap(err))
+}
+
+// CheckSettingKinds reports each setting declared in schema whose value is
+// of the wrong kind. Settings the schema doesn't declare are ignored
+func CheckSettingKinds(pluginName string, schema map[string]SettingKind, settings map[string]interface{}) error {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		if _, ok := schema[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := ValidateSettings(schema, map[string]interface{}{name: settings[name]}); err != nil {
+			errs = append(errs, NewConfigError(pluginName, name, fmt.Sprintf("must be %s, got %T", schema[name], settings[name])))
+		}
+	}
+	return errors.Join(errs...)
+}
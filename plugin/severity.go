@@ -0,0 +1,37 @@
+package plugin
+
+// MetadataSeverity is the message metadata key carrying a notification's
+// Severity, so transports can style errors differently from information
+const MetadataSeverity = "severity"
+
+// Severity tells how a notification should be presented
+type Severity string
+
+const (
+	// SeverityInfo is for routine information (the default)
+	SeverityInfo Severity = "info"
+	// SeveritySuccess is for something that finished well, e.g. a completed task
+	SeveritySuccess Severity = "success"
+	// SeverityWarn is for something that needs attention but didn't fail
+	SeverityWarn Severity = "warn"
+	// SeverityError is for failures, e.g. a failed task
+	SeverityError Severity = "error"
+)
+
+// SeverityOf returns a message's severity; messages without a known one are
+// SeverityInfo
+func SeverityOf(msg Message) Severity {
+	var severity Severity
+	switch s := msg.Metadata[MetadataSeverity].(type) {
+	case Severity:
+		severity = s
+	case string:
+		severity = Severity(s)
+	}
+
+	switch severity {
+	case SeveritySuccess, SeverityWarn, SeverityError:
+		return severity
+	}
+	return SeverityInfo
+}
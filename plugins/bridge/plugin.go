@@ -0,0 +1,421 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// init registers the bridge plugin
+func init() {
+	plugin.Register(NewBridgePlugin())
+}
+
+// transport is the source transport of messages the bridge brings in; a
+// message that already passed through it is never sent back out
+const transport = "bridge"
+
+// defaultRedisAddr is used when redis_addr isn't set
+const defaultRedisAddr = "localhost:6379"
+
+// publishTimeout bounds forwarding one message to the external bus
+const publishTimeout = 5 * time.Second
+
+// bus is an external pub/sub system the bridge federates with
+type bus interface {
+	// Publish sends data to an external channel
+	Publish(ctx context.Context, channel string, data []byte) error
+
+	// Subscribe delivers messages on the external channels until the bus is
+	// closed or the connection fails, then closes the returned channel
+	Subscribe(ctx context.Context, channels ...string) (<-chan busMessage, error)
+
+	// Close disconnects from the bus
+	Close() error
+}
+
+// busMessage is a message received from the external bus
+type busMessage struct {
+	Channel string
+	Data    []byte
+}
+
+// envelope is a broker message as sent over the external bus
+type envelope struct {
+	// Instance names the daemon that sent the message, so it can skip its
+	// own messages coming back
+	Instance string                 `json:"instance"`
+	Topic    string                 `json:"topic"`
+	Payload  interface{}            `json:"payload"`
+	Source   string                 `json:"source"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// mapping pairs a local topic with an external channel
+type mapping struct {
+	topic   string
+	channel string
+	out     bool // forward local messages to the channel
+	in      bool // publish channel messages locally
+}
+
+// BridgePlugin federates the broker with an external pub/sub bus (Redis), so
+// several daemons can share topics
+type BridgePlugin struct {
+	broker   plugin.MessageBroker
+	ctx      context.Context
+	msgCh    <-chan plugin.Message
+	stopCh   chan struct{}
+	bus      bus
+	instance string
+	mappings []mapping
+
+	// dial connects to the external bus; tests replace it with a fake
+	dial func(addr string) (bus, error)
+
+	// forwarded and received count messages sent to and taken from the bus
+	forwarded atomic.Int64
+	received  atomic.Int64
+
+	// linkDown is set when the external subscription ends before Stop
+	linkDown atomic.Bool
+
+	// running is claimed when Start begins (and released if it fails) and
+	// cleared by Stop, so Stop closes stopCh only once
+	running atomic.Bool
+}
+
+// NewBridgePlugin creates a new bridge plugin
+func NewBridgePlugin() *BridgePlugin {
+	return &BridgePlugin{dial: dialRedis}
+}
+
+// Name returns the plugin name
+func (p *BridgePlugin) Name() string {
+	return "bridge"
+}
+
+// Labels returns the plugin's label groups
+func (p *BridgePlugin) Labels() []string {
+	return []string{"integration"}
+}
+
+// ValidateConfig checks the mappings setting before the plugin starts
+func (p *BridgePlugin) ValidateConfig(ctx context.Context) error {
+	var raw interface{}
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		raw, _ = cfg.GetPluginSetting("bridge", "mappings")
+	}
+	_, err := parseMappings(raw)
+	return err
+}
+
+// CheckRequirements validates plugin requirements
+// The mappings are checked by ValidateConfig, the bus connection by Start
+func (p *BridgePlugin) CheckRequirements(ctx context.Context) error {
+	return nil
+}
+
+// Extensions returns the plugin's extensions
+func (p *BridgePlugin) Extensions() []plugin.Extension {
+	return []plugin.Extension{}
+}
+
+// Subscribe parses the mappings and subscribes to the local topics sent out,
+// before any plugin starts
+func (p *BridgePlugin) Subscribe(ctx context.Context, broker plugin.MessageBroker) ([]string, error) {
+	var raw interface{}
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		raw, _ = cfg.GetPluginSetting("bridge", "mappings")
+	}
+
+	mappings, err := parseMappings(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.mappings = mappings
+
+	var topics []string
+	for _, m := range mappings {
+		if m.out && !contains(topics, m.topic) {
+			topics = append(topics, m.topic)
+		}
+	}
+	p.msgCh = nil
+	if len(topics) == 0 {
+		return nil, nil
+	}
+	p.msgCh = broker.Subscribe("bridge", 100, topics...)
+	return []string{"bridge"}, nil
+}
+
+// Start connects to the external bus and begins forwarding in both directions
+func (p *BridgePlugin) Start(ctx context.Context, broker plugin.MessageBroker) (err error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	defer func() {
+		if err != nil {
+			p.running.Store(false)
+		}
+	}()
+
+	p.broker = broker
+	p.ctx = ctx
+	p.stopCh = make(chan struct{})
+	p.linkDown.Store(false)
+
+	addr := defaultRedisAddr
+	p.instance = defaultInstance()
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		if a, ok := cfg.GetPluginSettingString("bridge", "redis_addr"); ok && a != "" {
+			addr = a
+		}
+		if name, ok := cfg.GetPluginSettingString("bridge", "instance"); ok && name != "" {
+			p.instance = name
+		}
+	}
+	mappings := p.mappings // Parsed by Subscribe
+
+	b, err := p.dial(addr)
+	if err != nil {
+		return err
+	}
+	p.bus = b
+
+	// Bring in the external channels
+	var channels []string
+	for _, m := range mappings {
+		if m.in && !contains(channels, m.channel) {
+			channels = append(channels, m.channel)
+		}
+	}
+	if len(channels) > 0 {
+		busCh, err := b.Subscribe(ctx, channels...)
+		if err != nil {
+			b.Close()
+			return err
+		}
+		go p.handleBusMessages(busCh)
+	}
+
+	// Send out the local topics
+	if p.msgCh != nil {
+		go p.handleBrokerMessages(p.msgCh)
+	}
+
+	log.Printf("[Bridge] Started (redis: %s, instance: %s, %d mapping(s))", addr, p.instance, len(mappings))
+	return nil
+}
+
+// Stop stops forwarding and disconnects from the external bus
+func (p *BridgePlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
+	close(p.stopCh)
+	if p.broker != nil {
+		p.broker.Unsubscribe("bridge")
+	}
+	if p.bus != nil {
+		p.bus.Close()
+	}
+
+	log.Printf("[Bridge] Stopped")
+	return nil
+}
+
+// HealthCheck reports whether the external subscription is still running
+func (p *BridgePlugin) HealthCheck(ctx context.Context) error {
+	if p.linkDown.Load() {
+		return fmt.Errorf("external bus subscription ended")
+	}
+	return nil
+}
+
+// StatusSection reports forwarding counts for the daemon status
+func (p *BridgePlugin) StatusSection() (string, []string) {
+	return "Bridge", []string{
+		fmt.Sprintf("Instance: %s", p.instance),
+		fmt.Sprintf("Forwarded: %d, received: %d", p.forwarded.Load(), p.received.Load()),
+	}
+}
+
+// handleBrokerMessages forwards local messages to the external bus
+func (p *BridgePlugin) handleBrokerMessages(msgCh <-chan plugin.Message) {
+	for {
+		select {
+		case msg, ok := <-msgCh:
+			if !ok {
+				return
+			}
+			p.forward(msg)
+
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// forward sends a local message to the channels its topic maps to. Messages
+// that came in over the bridge are not sent back out, so two daemons
+// bridging the same topic both ways don't echo it between them
+func (p *BridgePlugin) forward(msg plugin.Message) {
+	if plugin.PassedThrough(msg, transport) {
+		return
+	}
+
+	metadata := make(map[string]interface{}, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata[plugin.MetadataVia] = plugin.Via(msg)
+
+	data, err := json.Marshal(envelope{
+		Instance: p.instance,
+		Topic:    msg.Topic,
+		Payload:  msg.Payload,
+		Source:   msg.Source,
+		Metadata: metadata,
+	})
+	if err != nil {
+		log.Printf("[Bridge] Error encoding %s message: %v", msg.Topic, err)
+		return
+	}
+
+	for _, m := range p.mappings {
+		if !m.out || m.topic != msg.Topic {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(p.ctx, publishTimeout)
+		err := p.bus.Publish(ctx, m.channel, data)
+		cancel()
+		if err != nil {
+			log.Printf("[Bridge] Error forwarding %s to %s: %v", msg.Topic, m.channel, err)
+			continue
+		}
+		p.forwarded.Add(1)
+	}
+}
+
+// handleBusMessages publishes external messages locally until the bus
+// subscription ends
+func (p *BridgePlugin) handleBusMessages(busCh <-chan busMessage) {
+	for {
+		select {
+		case bm, ok := <-busCh:
+			if !ok {
+				if p.running.Load() {
+					log.Printf("[Bridge] External bus subscription ended")
+					p.linkDown.Store(true)
+				}
+				return
+			}
+			p.receive(bm)
+
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// receive publishes an external message on the local topics its channel maps
+// to, as coming from "bridge:<sending instance>". Our own messages coming
+// back are skipped, and the broker refuses ones that looped through the
+// bridge before
+func (p *BridgePlugin) receive(bm busMessage) {
+	var env envelope
+	if err := json.Unmarshal(bm.Data, &env); err != nil {
+		log.Printf("[Bridge] Ignoring malformed message on %s: %v", bm.Channel, err)
+		return
+	}
+	if env.Instance == p.instance {
+		return
+	}
+
+	for _, m := range p.mappings {
+		if !m.in || m.channel != bm.Channel {
+			continue
+		}
+		msg := plugin.Message{
+			Topic:    m.topic,
+			Payload:  env.Payload,
+			Source:   transport + ":" + env.Instance,
+			Metadata: env.Metadata,
+		}
+		if err := p.broker.Publish(p.ctx, msg); err != nil {
+			log.Printf("[Bridge] Error publishing %s from %s: %v", m.topic, bm.Channel, err)
+			continue
+		}
+		p.received.Add(1)
+	}
+}
+
+// parseMappings reads the mappings setting, a list of maps with topic,
+// channel (default: the topic) and direction ("out", "in" or "both", the
+// default)
+func parseMappings(raw interface{}) ([]mapping, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, plugin.NewConfigError("bridge", "mappings", "must be a non-empty list")
+	}
+
+	var mappings []mapping
+	for i, item := range list {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, plugin.NewConfigError("bridge", "mappings", fmt.Sprintf("mapping %d must be a map", i+1))
+		}
+
+		m := mapping{}
+		m.topic, _ = settings["topic"].(string)
+		if m.topic == "" {
+			return nil, plugin.NewConfigError("bridge", "mappings", fmt.Sprintf("mapping %d: topic is required", i+1))
+		}
+		m.channel, _ = settings["channel"].(string)
+		if m.channel == "" {
+			m.channel = m.topic
+		}
+
+		direction, _ := settings["direction"].(string)
+		switch direction {
+		case "", "both":
+			m.out, m.in = true, true
+		case "out":
+			m.out = true
+		case "in":
+			m.in = true
+		default:
+			return nil, plugin.NewConfigError("bridge", "mappings", fmt.Sprintf("mapping %d: direction must be out, in or both", i+1))
+		}
+
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// defaultInstance names this daemon on the bus when instance isn't set
+func defaultInstance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package bridge
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// fakeBus is a bus that only counts Close calls
+type fakeBus struct {
+	closes atomic.Int32
+}
+
+func (b *fakeBus) Publish(ctx context.Context, channel string, data []byte) error {
+	return nil
+}
+
+func (b *fakeBus) Subscribe(ctx context.Context, channels ...string) (<-chan busMessage, error) {
+	return make(chan busMessage), nil
+}
+
+func (b *fakeBus) Close() error {
+	b.closes.Add(1)
+	return nil
+}
+
+func TestStopTwice(t *testing.T) {
+	broker := testutil.NewBroker()
+	fake := &fakeBus{}
+	p := NewBridgePlugin()
+	p.dial = func(addr string) (bus, error) { return fake, nil }
+
+	if err := p.Start(testutil.NewContext(testutil.WithBroker(broker)), broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+	if got := fake.closes.Load(); got != 1 {
+		t.Errorf("closed the bus %d times, want 1", got)
+	}
+}
+
+// memBus is an in-memory external bus shared by several bridges; every
+// client sees every message, including its own
+type memBus struct {
+	mu        sync.Mutex
+	subs      []*memSub
+	published []busMessage
+}
+
+// memSub is a client's subscription to some channels
+type memSub struct {
+	channels []string
+	ch       chan busMessage
+}
+
+// memClient is one bridge's connection to a memBus
+type memClient struct {
+	bus *memBus
+}
+
+func (c *memClient) Publish(ctx context.Context, channel string, data []byte) error {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	bm := busMessage{Channel: channel, Data: data}
+	c.bus.published = append(c.bus.published, bm)
+	for _, sub := range c.bus.subs {
+		if contains(sub.channels, channel) {
+			sub.ch <- bm
+		}
+	}
+	return nil
+}
+
+func (c *memClient) Subscribe(ctx context.Context, channels ...string) (<-chan busMessage, error) {
+	c.bus.mu.Lock()
+	defer c.bus.mu.Unlock()
+	sub := &memSub{channels: channels, ch: make(chan busMessage, 100)}
+	c.bus.subs = append(c.bus.subs, sub)
+	return sub.ch, nil
+}
+
+func (c *memClient) Close() error { return nil }
+
+// publishedCount returns how many messages went over the bus
+func (b *memBus) publishedCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.published)
+}
+
+// startBridge starts a bridge named instance on its own broker, bridging
+// chat both ways over shared
+func startBridge(t *testing.T, shared *memBus, instance string) (*BridgePlugin, *testutil.Broker) {
+	t.Helper()
+
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("bridge", "instance", instance),
+		testutil.WithPluginSetting("bridge", "mappings", []interface{}{
+			map[string]interface{}{"topic": "chat"},
+		}),
+	)
+
+	p := NewBridgePlugin()
+	p.dial = func(addr string) (bus, error) { return &memClient{bus: shared}, nil }
+	if _, err := p.Subscribe(ctx, broker); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { p.Stop(context.Background()) })
+	return p, broker
+}
+
+// waitForChat waits until n chat messages have been published on broker
+// and returns their payloads
+func waitForChat(t *testing.T, broker *testutil.Broker, n int) []interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		msgs := broker.PublishedOn("chat")
+		if len(msgs) >= n {
+			var payloads []interface{}
+			for _, msg := range msgs {
+				payloads = append(payloads, msg.Payload)
+			}
+			return payloads
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d chat messages, want %d", len(msgs), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBridgesForwardBothWaysWithoutEchoes(t *testing.T) {
+	bus := &memBus{}
+	_, brokerA := startBridge(t, bus, "a")
+	_, brokerB := startBridge(t, bus, "b")
+
+	// Each step waits for the message to cross, so any echo of the
+	// previous one has been forwarded (or suppressed) by then
+	brokerA.Publish(context.Background(), plugin.Message{Topic: "chat", Payload: "hello", Source: "telegram:1"})
+	waitForChat(t, brokerB, 1)
+	brokerB.Publish(context.Background(), plugin.Message{Topic: "chat", Payload: "reply", Source: "websocket:2"})
+	waitForChat(t, brokerA, 2)
+	brokerA.Publish(context.Background(), plugin.Message{Topic: "chat", Payload: "bye", Source: "telegram:1"})
+
+	if got, want := waitForChat(t, brokerB, 3), []interface{}{"hello", "reply", "bye"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chat on b = %v, want %v", got, want)
+	}
+	if got, want := waitForChat(t, brokerA, 3), []interface{}{"hello", "reply", "bye"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chat on a = %v, want %v", got, want)
+	}
+	if got := bus.publishedCount(); got != 3 {
+		t.Errorf("%d messages sent over the bus, want 3 (no echoes)", got)
+	}
+
+	// A bridged message is marked as coming through the bridge, after the
+	// transport it started on
+	got := brokerB.PublishedOn("chat")[0]
+	if got.Source != "bridge:a" || !reflect.DeepEqual(plugin.Via(got), []string{"telegram", "bridge"}) {
+		t.Errorf("bridged message from %s via %v, want bridge:a via [telegram bridge]", got.Source, plugin.Via(got))
+	}
+}
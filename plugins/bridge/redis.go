@@ -0,0 +1,215 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// dialTimeout bounds connecting to Redis
+const dialTimeout = 5 * time.Second
+
+// redisBus is a minimal Redis pub/sub client speaking RESP over plain TCP
+// It keeps one connection for PUBLISH and one per Subscribe
+type redisBus struct {
+	addr string
+
+	mu   sync.Mutex
+	conn net.Conn // publish connection (nil = not connected)
+	rd   *bufio.Reader
+	subs []net.Conn
+}
+
+// dialRedis connects to the Redis server at addr
+func dialRedis(addr string) (bus, error) {
+	b := &redisBus{addr: addr}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.connectLocked(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// connectLocked opens the publish connection
+func (b *redisBus) connectLocked() error {
+	conn, err := net.DialTimeout("tcp", b.addr, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("connecting to redis at %s: %w", b.addr, err)
+	}
+	b.conn = conn
+	b.rd = bufio.NewReader(conn)
+	return nil
+}
+
+// Publish sends data to a channel, reconnecting once if the connection broke
+func (b *redisBus) Publish(ctx context.Context, channel string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		if err := b.connectLocked(); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		b.conn.SetDeadline(deadline)
+	} else {
+		b.conn.SetDeadline(time.Time{})
+	}
+
+	err := writeCommand(b.conn, "PUBLISH", channel, string(data))
+	if err == nil {
+		_, err = readValue(b.rd)
+	}
+	if err != nil {
+		b.conn.Close()
+		b.conn = nil
+		return fmt.Errorf("publishing to redis channel %s: %w", channel, err)
+	}
+	return nil
+}
+
+// Subscribe opens a connection subscribed to channels and delivers their
+// messages until the connection fails or the bus is closed, then closes the
+// returned channel
+func (b *redisBus) Subscribe(ctx context.Context, channels ...string) (<-chan busMessage, error) {
+	conn, err := net.DialTimeout("tcp", b.addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to redis at %s: %w", b.addr, err)
+	}
+
+	args := append([]string{"SUBSCRIBE"}, channels...)
+	if err := writeCommand(conn, args...); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("subscribing to redis channels: %w", err)
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, conn)
+	b.mu.Unlock()
+
+	ch := make(chan busMessage, 100)
+	go func() {
+		defer close(ch)
+		defer conn.Close()
+
+		rd := bufio.NewReader(conn)
+		for {
+			value, err := readValue(rd)
+			if err != nil {
+				return
+			}
+
+			// Deliveries are ["message", channel, data]; subscribe
+			// confirmations are skipped
+			parts, ok := value.([]interface{})
+			if !ok || len(parts) != 3 {
+				continue
+			}
+			kind, _ := parts[0].([]byte)
+			channel, _ := parts[1].([]byte)
+			data, _ := parts[2].([]byte)
+			if string(kind) != "message" {
+				continue
+			}
+
+			select {
+			case ch <- busMessage{Channel: string(channel), Data: data}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Close closes the publish connection and every subscription
+func (b *redisBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+	for _, conn := range b.subs {
+		conn.Close()
+	}
+	b.subs = nil
+	return nil
+}
+
+// writeCommand sends a command as a RESP array of bulk strings
+func writeCommand(w io.Writer, args ...string) error {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readValue reads one RESP value: a string for simple strings, int64 for
+// integers, []byte (nil for null) for bulk strings and []interface{} for
+// arrays. Error replies are returned as errors
+func readValue(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed redis reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed redis bulk length %q", body)
+		}
+		if n < 0 {
+			return []byte(nil), nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed redis array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			value, err := readValue(rd)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown redis reply type %q", kind)
+}
@@ -0,0 +1,250 @@
+package echo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// TaskTypeEcho is the task type handled by the echo executor
+const TaskTypeEcho = "echo"
+
+// init registers the echo executor plugin
+func init() {
+	plugin.Register(NewEchoPlugin())
+}
+
+// EchoPlugin provides a deterministic executor that returns its input
+// It is intended as a predictable target for client and integration testing
+type EchoPlugin struct {
+	broker plugin.MessageBroker
+	mu     sync.RWMutex
+
+	// Executor state
+	state       plugin.ExecutorState
+	currentTask *plugin.Task
+	progress    int
+	message     string
+
+	// Configuration
+	delay         time.Duration // Delay per progress step (or total if no steps)
+	progressSteps int           // Number of task.progress messages to emit
+
+	// running is claimed by Start and cleared by Stop, so repeated or
+	// concurrent calls are no-ops
+	running atomic.Bool
+}
+
+// NewEchoPlugin creates a new echo executor plugin
+func NewEchoPlugin() *EchoPlugin {
+	return &EchoPlugin{
+		state: plugin.ExecutorStateIdle,
+	}
+}
+
+// Name returns the plugin name
+func (p *EchoPlugin) Name() string {
+	return "echo"
+}
+
+// Labels returns the plugin's label groups
+func (p *EchoPlugin) Labels() []string {
+	return []string{"executor"}
+}
+
+// SettingsSchema lists the settings the plugin reads
+func (p *EchoPlugin) SettingsSchema() map[string]plugin.SettingKind {
+	return map[string]plugin.SettingKind{
+		"delay_ms":       plugin.SettingInt,
+		"progress_steps": plugin.SettingInt,
+	}
+}
+
+// CheckRequirements validates plugin requirements
+func (p *EchoPlugin) CheckRequirements(ctx context.Context) error {
+	// Echo has no external requirements
+	return nil
+}
+
+// Extensions returns the plugin's extensions
+func (p *EchoPlugin) Extensions() []plugin.Extension {
+	return []plugin.Extension{
+		NewEchoExecutorExtension(p),
+	}
+}
+
+// Start initializes the echo executor
+func (p *EchoPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	p.broker = broker
+
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		if ms, ok := cfg.GetPluginSettingInt("echo", "delay_ms"); ok {
+			p.delay = time.Duration(ms) * time.Millisecond
+		}
+		if steps, ok := cfg.GetPluginSettingInt("echo", "progress_steps"); ok {
+			p.progressSteps = steps
+		}
+	}
+
+	log.Printf("[Echo] Started (delay: %s, progress steps: %d)", p.delay, p.progressSteps)
+	return nil
+}
+
+// Stop shuts down the echo executor
+func (p *EchoPlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
+	log.Printf("[Echo] Stopped")
+	return nil
+}
+
+// ExecuteTask returns the task input, emitting the configured progress steps first
+func (p *EchoPlugin) ExecuteTask(ctx context.Context, task *plugin.Task) (interface{}, error) {
+	p.mu.Lock()
+	if p.state != plugin.ExecutorStateIdle {
+		p.mu.Unlock()
+		return nil, fmt.Errorf("executor is busy")
+	}
+	p.state = plugin.ExecutorStateWorking
+	p.currentTask = task
+	p.progress = 0
+	p.message = "Echoing..."
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.state = plugin.ExecutorStateIdle
+		p.currentTask = nil
+		p.mu.Unlock()
+	}()
+
+	if p.progressSteps == 0 && p.delay > 0 {
+		if err := p.wait(ctx, p.delay); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 1; i <= p.progressSteps; i++ {
+		if err := p.wait(ctx, p.delay); err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		p.progress = i * 100 / p.progressSteps
+		p.message = fmt.Sprintf("Echoing... %d%%", p.progress)
+		progress, message := p.progress, p.message
+		p.mu.Unlock()
+
+		p.broker.Publish(ctx, plugin.Message{
+			Topic:   "task.progress",
+			Payload: message,
+			Source:  "echo",
+			Metadata: map[string]interface{}{
+				"task_id":                    task.ID,
+				"progress":                   progress,
+				plugin.MetadataCorrelationID: task.CorrelationID,
+				plugin.MetadataReplyTo:       task.ReplyTo,
+			},
+		})
+	}
+
+	p.mu.Lock()
+	p.progress = 100
+	p.message = "Task completed"
+	p.mu.Unlock()
+
+	return task.Input, nil
+}
+
+// wait blocks for d or until the context is cancelled
+func (p *EchoPlugin) wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// CancelTask cancels a running task
+// The daemon cancels the task context, which stops ExecuteTask
+func (p *EchoPlugin) CancelTask(ctx context.Context, taskID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.currentTask == nil || p.currentTask.ID != taskID {
+		return fmt.Errorf("task not found: %s", taskID)
+	}
+
+	p.message = "Task cancelled"
+	return nil
+}
+
+// GetStatus returns the current executor status
+func (p *EchoPlugin) GetStatus(ctx context.Context) (*plugin.ExecutorStatus, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return &plugin.ExecutorStatus{
+		State:       p.state,
+		CurrentTask: p.currentTask,
+		Progress:    p.progress,
+		Message:     p.message,
+	}, nil
+}
+
+// EchoExecutorExtension wraps the echo plugin as an executor extension
+type EchoExecutorExtension struct {
+	plugin *EchoPlugin
+}
+
+// NewEchoExecutorExtension creates a new echo executor extension
+func NewEchoExecutorExtension(plugin *EchoPlugin) *EchoExecutorExtension {
+	return &EchoExecutorExtension{plugin: plugin}
+}
+
+// Type returns the extension type
+func (e *EchoExecutorExtension) Type() plugin.ExtensionType {
+	return plugin.ExtensionTypeExecutor
+}
+
+// Name returns the extension name
+func (e *EchoExecutorExtension) Name() string {
+	return "echo"
+}
+
+// SupportsMode checks if the extension supports the given mode
+func (e *EchoExecutorExtension) SupportsMode(mode plugin.Mode) bool {
+	// Echo executor works in all modes
+	return true
+}
+
+// Implement Executor interface
+func (e *EchoExecutorExtension) ExecuteTask(ctx context.Context, task *plugin.Task) (interface{}, error) {
+	return e.plugin.ExecuteTask(ctx, task)
+}
+
+func (e *EchoExecutorExtension) CancelTask(ctx context.Context, taskID string) error {
+	return e.plugin.CancelTask(ctx, taskID)
+}
+
+func (e *EchoExecutorExtension) GetStatus(ctx context.Context) (*plugin.ExecutorStatus, error) {
+	return e.plugin.GetStatus(ctx)
+}
+
+func (e *EchoExecutorExtension) CanHandle(taskType string) bool {
+	return taskType == TaskTypeEcho
+}
@@ -0,0 +1,82 @@
+package echo
+
+import (
+	"context"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestExecuteTaskEchoesWithProgress(t *testing.T) {
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("echo", "progress_steps", 2),
+	)
+
+	p := NewEchoPlugin()
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer p.Stop(ctx)
+
+	output, err := p.ExecuteTask(ctx, &plugin.Task{ID: "t1", Type: TaskTypeEcho, Input: "hello", CorrelationID: "c1"})
+	if err != nil {
+		t.Fatalf("ExecuteTask: %v", err)
+	}
+	if output != "hello" {
+		t.Errorf("output = %v, want hello", output)
+	}
+
+	progress := broker.PublishedOn("task.progress")
+	if len(progress) != 2 {
+		t.Fatalf("published %d progress messages, want 2", len(progress))
+	}
+	for i, want := range []int{50, 100} {
+		if got := progress[i].Metadata["progress"]; got != want {
+			t.Errorf("progress message %d = %v, want %d", i, got, want)
+		}
+		if got := progress[i].Metadata[plugin.MetadataCorrelationID]; got != "c1" {
+			t.Errorf("progress message %d correlation id = %v, want c1", i, got)
+		}
+	}
+
+	status, _ := p.GetStatus(ctx)
+	if status.State != plugin.ExecutorStateIdle || status.Progress != 100 {
+		t.Errorf("status = %+v, want idle at 100%%", status)
+	}
+}
+
+func TestExecuteTaskStopsWhenCancelled(t *testing.T) {
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("echo", "delay_ms", 60000),
+	)
+
+	p := NewEchoPlugin()
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer p.Stop(ctx)
+
+	taskCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := p.ExecuteTask(taskCtx, &plugin.Task{ID: "t1", Type: TaskTypeEcho, Input: "hello"}); err != context.Canceled {
+		t.Errorf("ExecuteTask error = %v, want context.Canceled", err)
+	}
+}
+
+func TestStopTwice(t *testing.T) {
+	broker := testutil.NewBroker()
+	p := NewEchoPlugin()
+	if err := p.Start(testutil.NewContext(testutil.WithBroker(broker)), broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitCooldown is how long a key is skipped after a rate limit
+// response without a usable Retry-After
+const defaultRateLimitCooldown = time.Minute
+
+// RateLimitError is returned by a provider call that was rate limited (HTTP 429)
+type RateLimitError struct {
+	// RetryAfter is how long the provider asked to wait (0 = not given)
+	RetryAfter time.Duration
+}
+
+// Error describes the rate limit
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+	}
+	return "rate limited"
+}
+
+// rateLimitFromResponse returns a *RateLimitError for a 429 response, nil otherwise
+func rateLimitFromResponse(resp *http.Response, now time.Time) error {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	return &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), now)}
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// keyPool hands out API keys round-robin, skipping keys that are cooling
+// down after a rate limit
+type keyPool struct {
+	mu   sync.Mutex
+	keys []string
+	next int
+
+	// coolUntil holds when each rate-limited key may be used again
+	coolUntil map[string]time.Time
+	now       func() time.Time
+}
+
+// newKeyPool creates a pool over keys
+func newKeyPool(keys []string) *keyPool {
+	return &keyPool{
+		keys:      keys,
+		coolUntil: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// errAllKeysLimited is returned (wrapped) when every key is cooling down
+var errAllKeysLimited = errors.New("all API keys are rate limited")
+
+// pick returns the next key that isn't cooling down
+func (kp *keyPool) pick() (string, error) {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	if len(kp.keys) == 0 {
+		return "", fmt.Errorf("no API key configured")
+	}
+
+	now := kp.now()
+	var soonest time.Time
+	for range kp.keys {
+		key := kp.keys[kp.next]
+		kp.next = (kp.next + 1) % len(kp.keys)
+
+		until, cooling := kp.coolUntil[key]
+		if !cooling || !now.Before(until) {
+			delete(kp.coolUntil, key)
+			return key, nil
+		}
+		if soonest.IsZero() || until.Before(soonest) {
+			soonest = until
+		}
+	}
+	return "", fmt.Errorf("%w, next one free in %s", errAllKeysLimited, soonest.Sub(now).Round(time.Second))
+}
+
+// rateLimited skips key for retryAfter (defaultRateLimitCooldown if zero)
+func (kp *keyPool) rateLimited(key string, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = defaultRateLimitCooldown
+	}
+
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	kp.coolUntil[key] = kp.now().Add(retryAfter)
+}
+
+// limited returns how many keys are cooling down now
+func (kp *keyPool) limited() int {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := kp.now()
+	n := 0
+	for _, until := range kp.coolUntil {
+		if now.Before(until) {
+			n++
+		}
+	}
+	return n
+}
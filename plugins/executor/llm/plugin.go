@@ -2,10 +2,13 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"bicycle/cmd"
@@ -24,9 +27,27 @@ func init() {
 		Usage:       "<question>",
 		Handler:     handleAsk,
 		Modes:       []plugin.Mode{plugin.ModeDaemon, plugin.ModeInteractive},
+
+		SupportsDryRun: true,
 	})
 }
 
+// TaskTypeQuery is the task type for LLM questions
+const TaskTypeQuery = "llm_query"
+
+// taskSubmitter is the part of the daemon /ask uses to run its task
+type taskSubmitter interface {
+	ExecuteTask(ctx context.Context, task *plugin.Task) error
+}
+
+// askWaitTimeout bounds how long an interactive /ask waits to answer inline;
+// a later answer is shown when it arrives
+var askWaitTimeout = 30 * time.Second
+
+// maxCongestionPause bounds how long a task holds back progress updates while
+// their subscribers catch up
+const maxCongestionPause = 5 * time.Second
+
 // LLMPlugin provides LLM-based task execution
 type LLMPlugin struct {
 	broker plugin.MessageBroker
@@ -39,16 +60,47 @@ type LLMPlugin struct {
 	progress    int
 	message     string
 
-	// Configuration
+	// partial is the answer text streamed so far for the current task, or
+	// for the last one if it was cancelled
+	partial string
+
+	// creds are the provider settings new tasks are pinned to; a task keeps
+	// the settings it was submitted with if they change mid-flight
+	creds credentials
+
+	// warmup checks new credentials before they are adopted (replaceable for testing)
+	warmup func(ctx context.Context, creds credentials) error
+
+	// send makes one provider request with an API key (replaceable for
+	// testing), passing answer text to emit as it streams in; a rate-limited
+	// request returns a *RateLimitError
+	send func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(chunk string)) (string, error)
+
+	// step is the simulated work per progress step (replaceable for testing)
+	step time.Duration
+
+	// running is claimed by Start and cleared by Stop, so repeated or
+	// concurrent calls are no-ops
+	running atomic.Bool
+}
+
+// credentials are the provider settings a request runs with
+type credentials struct {
 	provider string
-	apiKey   string
 	model    string
+
+	// apiKeys are used in turn, skipping rate-limited ones
+	apiKeys []string
+	keys    *keyPool
 }
 
 // NewLLMPlugin creates a new LLM executor plugin
 func NewLLMPlugin() *LLMPlugin {
 	return &LLMPlugin{
-		state: plugin.ExecutorStateIdle,
+		state:  plugin.ExecutorStateIdle,
+		warmup: checkCredentials,
+		send:   stubSend,
+		step:   time.Second,
 	}
 }
 
@@ -57,20 +109,41 @@ func (p *LLMPlugin) Name() string {
 	return "llm"
 }
 
+// Labels returns the plugin's label groups
+func (p *LLMPlugin) Labels() []string {
+	return []string{"executor"}
+}
+
+// SettingsSchema lists the settings the plugin reads
+func (p *LLMPlugin) SettingsSchema() map[string]plugin.SettingKind {
+	return map[string]plugin.SettingKind{
+		"provider":     plugin.SettingString,
+		"model":        plugin.SettingString,
+		"api_key":      plugin.SettingString,
+		"api_key_file": plugin.SettingString,
+	}
+}
+
 // CheckRequirements validates plugin requirements
 func (p *LLMPlugin) CheckRequirements(ctx context.Context) error {
 	checker := plugin.NewRequirementChecker("llm")
 
 	// Get configuration
-	p.provider, p.apiKey, p.model = p.getConfig(ctx)
+	creds, err := p.getConfig(ctx)
+	p.mu.Lock()
+	p.creds = creds
+	p.mu.Unlock()
 
 	// Require API key
 	checker.AddRequired(
 		"api_key",
 		"LLM API key required",
 		func(ctx context.Context) error {
-			if p.apiKey == "" {
-				return fmt.Errorf("API key not set (check config or environment)")
+			if err != nil {
+				return err
+			}
+			if len(creds.apiKeys) == 0 {
+				return plugin.NewConfigError("llm", "api_key", "not set (check config or environment)")
 			}
 			return nil
 		},
@@ -80,35 +153,113 @@ func (p *LLMPlugin) CheckRequirements(ctx context.Context) error {
 }
 
 // getConfig retrieves LLM configuration
-func (p *LLMPlugin) getConfig(ctx context.Context) (provider, apiKey, model string) {
+// The API keys come from api_keys, then api_key, then the file named by
+// api_key_file, then the provider's environment variable
+func (p *LLMPlugin) getConfig(ctx context.Context) (credentials, error) {
 	// Defaults
-	provider = "openai"
-	model = "gpt-4"
+	creds := credentials{
+		provider: "openai",
+		model:    "gpt-4",
+	}
 
 	// Try config
 	if cfg, ok := ctx.Value("config").(*config.Config); ok {
 		if prov, ok := cfg.GetPluginSettingString("llm", "provider"); ok {
-			provider = prov
+			creds.provider = prov
 		}
 		if mdl, ok := cfg.GetPluginSettingString("llm", "model"); ok {
-			model = mdl
+			creds.model = mdl
 		}
-		if key, ok := cfg.GetPluginSettingString("llm", "api_key"); ok && key != "" {
-			apiKey = key
+		if raw, ok := cfg.GetPluginSetting("llm", "api_keys"); ok {
+			keys, err := parseAPIKeys(raw)
+			if err != nil {
+				return creds, err
+			}
+			creds.apiKeys = keys
+		} else if key, ok := cfg.GetPluginSettingString("llm", "api_key"); ok && key != "" {
+			creds.apiKeys = []string{key}
+		} else if path, ok := cfg.GetPluginSettingString("llm", "api_key_file"); ok && path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return creds, fmt.Errorf("failed to read API key file: %w", err)
+			}
+			if key := strings.TrimSpace(string(data)); key != "" {
+				creds.apiKeys = []string{key}
+			}
 		}
 	}
 
 	// Fallback to environment variables
-	if apiKey == "" {
-		switch provider {
+	if len(creds.apiKeys) == 0 {
+		var key string
+		switch creds.provider {
 		case "openai":
-			apiKey = os.Getenv("OPENAI_API_KEY")
+			key = os.Getenv("OPENAI_API_KEY")
 		case "anthropic":
-			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+			key = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if key != "" {
+			creds.apiKeys = []string{key}
+		}
+	}
+
+	creds.keys = newKeyPool(creds.apiKeys)
+	return creds, nil
+}
+
+// parseAPIKeys reads the api_keys setting, a list of keys
+func parseAPIKeys(raw interface{}) ([]string, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, plugin.NewConfigError("llm", "api_keys", "must be a list of keys")
+	}
+
+	var keys []string
+	for i, item := range list {
+		key, ok := item.(string)
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, plugin.NewConfigError("llm", "api_keys", fmt.Sprintf("key %d must be a non-empty string", i+1))
 		}
+		keys = append(keys, strings.TrimSpace(key))
+	}
+	return keys, nil
+}
+
+// Reload re-reads the provider settings and API key, adopting them for new
+// tasks once they pass warmup. Tasks already submitted keep the settings
+// pinned by PrepareTask
+func (p *LLMPlugin) Reload(ctx context.Context) error {
+	creds, err := p.getConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if err := p.warmup(ctx, creds); err != nil {
+		return fmt.Errorf("new credentials failed warmup: %w", err)
+	}
+
+	p.mu.Lock()
+	rotated := strings.Join(creds.apiKeys, "\n") != strings.Join(p.creds.apiKeys, "\n")
+	p.creds = creds
+	p.mu.Unlock()
+
+	if rotated {
+		log.Printf("[LLM] API keys rotated (provider: %s, model: %s, keys: %d)", creds.provider, creds.model, len(creds.apiKeys))
 	}
+	return nil
+}
 
-	return provider, apiKey, model
+// checkCredentials is the default warmup: it rejects missing or malformed keys
+// TODO: Make a cheap authenticated request to the provider once API calls exist
+func checkCredentials(ctx context.Context, creds credentials) error {
+	if len(creds.apiKeys) == 0 {
+		return fmt.Errorf("API key not set")
+	}
+	for i, key := range creds.apiKeys {
+		if strings.ContainsAny(key, " \t\r\n") {
+			return fmt.Errorf("API key %d contains whitespace", i+1)
+		}
+	}
+	return nil
 }
 
 // Extensions returns the plugin's extensions
@@ -120,44 +271,83 @@ func (p *LLMPlugin) Extensions() []plugin.Extension {
 
 // Start initializes the LLM executor
 func (p *LLMPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
 	p.broker = broker
 	p.ctx = ctx
 
-	log.Printf("[LLM] Started (provider: %s, model: %s)", p.provider, p.model)
+	p.mu.RLock()
+	creds := p.creds
+	p.mu.RUnlock()
+
+	log.Printf("[LLM] Started (provider: %s, model: %s)", creds.provider, creds.model)
 	return nil
 }
 
 // Stop shuts down the LLM executor
 func (p *LLMPlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
+
 	// Cancel any running task
-	if p.currentTask != nil {
-		p.CancelTask(ctx, p.currentTask.ID)
+	p.mu.RLock()
+	task := p.currentTask
+	p.mu.RUnlock()
+	if task != nil {
+		p.CancelTask(ctx, task.ID)
 	}
 
 	log.Printf("[LLM] Stopped")
 	return nil
 }
 
-// ExecuteTask executes a task using the LLM
-func (p *LLMPlugin) ExecuteTask(ctx context.Context, task *plugin.Task) error {
+// StatusSection reports the provider configuration for the daemon status
+func (p *LLMPlugin) StatusSection() (string, []string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	lines := []string{
+		fmt.Sprintf("Provider: %s", p.creds.provider),
+		fmt.Sprintf("Model: %s", p.creds.model),
+		fmt.Sprintf("State: %s", p.state),
+	}
+	if n := len(p.creds.apiKeys); n > 1 {
+		lines = append(lines, fmt.Sprintf("API keys: %d (%d rate limited)", n, p.creds.keys.limited()))
+	}
+	return "LLM", lines
+}
+
+// ExecuteTask executes a task using the LLM and returns its answer
+func (p *LLMPlugin) ExecuteTask(ctx context.Context, task *plugin.Task) (interface{}, error) {
 	p.mu.Lock()
 	if p.state != plugin.ExecutorStateIdle {
 		p.mu.Unlock()
-		return fmt.Errorf("executor is busy")
+		return nil, fmt.Errorf("executor is busy")
 	}
 	p.state = plugin.ExecutorStateWorking
 	p.currentTask = task
 	p.progress = 0
 	p.message = "Starting task..."
+	p.partial = ""
+	creds, ok := task.ExecutorConfig.(credentials)
+	if !ok {
+		creds = p.creds // Not submitted through the daemon
+	}
 	p.mu.Unlock()
 
-	log.Printf("[LLM] Executing task: %s (ID: %s)", task.Type, task.ID)
+	plugin.Logf(ctx, "[LLM] Executing task: %s (ID: %s)", task.Type, task.ID)
 
 	// Publish start notification
 	p.broker.Publish(ctx, plugin.Message{
 		Topic:   "notification",
 		Payload: fmt.Sprintf("Started task: %s", task.Type),
 		Source:  "llm",
+		Metadata: map[string]interface{}{
+			plugin.MetadataCorrelationID: task.CorrelationID,
+			plugin.MetadataReplyTo:       task.ReplyTo,
+		},
 	})
 
 	// TODO: Implement actual LLM API calls
@@ -165,45 +355,167 @@ func (p *LLMPlugin) ExecuteTask(ctx context.Context, task *plugin.Task) error {
 	for i := 0; i < 10; i++ {
 		select {
 		case <-ctx.Done():
-			p.mu.Lock()
-			p.state = plugin.ExecutorStateIdle
-			p.currentTask = nil
-			p.mu.Unlock()
-			return ctx.Err()
+			return p.cancelled(ctx, task)
 
-		case <-time.After(1 * time.Second):
+		case <-time.After(p.step):
 			p.mu.Lock()
 			p.progress = (i + 1) * 10
 			p.message = fmt.Sprintf("Processing... %d%%", p.progress)
+			progress, message := p.progress, p.message
 			p.mu.Unlock()
 
+			// Let slow subscribers catch up before sending more
+			plugin.WaitUncongested(ctx, p.broker, "task.progress", 50*time.Millisecond, maxCongestionPause)
+
 			// Publish progress update
 			p.broker.Publish(ctx, plugin.Message{
 				Topic:   "notification",
-				Payload: p.message,
+				Payload: message,
+				Source:  "llm",
+				Metadata: map[string]interface{}{
+					plugin.MetadataCorrelationID: task.CorrelationID,
+					plugin.MetadataReplyTo:       task.ReplyTo,
+				},
+			})
+			p.broker.Publish(ctx, plugin.Message{
+				Topic:   "task.progress",
+				Payload: message,
 				Source:  "llm",
+				Metadata: map[string]interface{}{
+					"task_id":                    task.ID,
+					"progress":                   progress,
+					plugin.MetadataCorrelationID: task.CorrelationID,
+					plugin.MetadataReplyTo:       task.ReplyTo,
+				},
 			})
 		}
 	}
 
+	// The daemon publishes the result, so only the output is returned here
+	output, err := p.query(ctx, creds, task)
+	if err != nil && ctx.Err() != nil {
+		return p.cancelled(ctx, task)
+	}
+
 	// Complete task
 	p.mu.Lock()
 	p.state = plugin.ExecutorStateIdle
 	p.currentTask = nil
-	p.progress = 100
-	p.message = "Task completed"
+	if err != nil {
+		p.message = "Task failed"
+	} else {
+		p.progress = 100
+		p.message = "Task completed"
+	}
 	p.mu.Unlock()
 
-	log.Printf("[LLM] Task completed: %s", task.ID)
+	if err != nil {
+		plugin.Logf(ctx, "[LLM] Task failed: %s: %v", task.ID, err)
+		return output, err
+	}
+	plugin.Logf(ctx, "[LLM] Task completed: %s", task.ID)
+	return output, nil
+}
 
-	// Publish completion
-	p.broker.Publish(ctx, plugin.Message{
-		Topic:   "notification",
-		Payload: "Task completed successfully",
-		Source:  "llm",
-	})
+// cancelled ends a task whose context is done. The text streamed so far stays
+// in the executor status and goes out as a final response flagged with
+// MetadataCancelled, so the user sees what was generated before the stop
+func (p *LLMPlugin) cancelled(ctx context.Context, task *plugin.Task) (interface{}, error) {
+	p.mu.Lock()
+	p.state = plugin.ExecutorStateIdle
+	p.currentTask = nil
+	p.message = "Task cancelled"
+	partial := p.partial
+	p.mu.Unlock()
 
-	return nil
+	if partial != "" {
+		plugin.Logf(ctx, "[LLM] Task %s cancelled, sending %d byte(s) of partial output", task.ID, len(partial))
+
+		// The task context is done, but its values still trace the message
+		p.broker.Publish(context.WithoutCancel(ctx), plugin.Message{
+			Topic:   "response",
+			Payload: &plugin.TaskResult{ID: task.ID, Type: task.Type, Output: partial, Tags: task.Tags},
+			Source:  "llm",
+			Metadata: map[string]interface{}{
+				"task_id":                    task.ID,
+				plugin.MetadataCancelled:     true,
+				plugin.MetadataCorrelationID: task.CorrelationID,
+				plugin.MetadataReplyTo:       task.ReplyTo,
+				plugin.MetadataSeverity:      string(plugin.SeverityWarn),
+			},
+		})
+	}
+	return nil, ctx.Err()
+}
+
+// appendPartial records streamed answer text for the current task
+func (p *LLMPlugin) appendPartial(chunk string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partial += chunk
+}
+
+// PrepareTask pins the current provider settings to a task when it is
+// submitted; a reload afterwards only affects later tasks
+func (p *LLMPlugin) PrepareTask(task *plugin.Task) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	task.ExecutorConfig = p.creds
+}
+
+// query produces the answer for a task with the given credentials, rotating
+// through the API keys and moving on to the next key when one is rate limited
+func (p *LLMPlugin) query(ctx context.Context, creds credentials, task *plugin.Task) (string, error) {
+	if len(creds.apiKeys) == 0 {
+		return "", fmt.Errorf("no API key configured")
+	}
+	if creds.keys == nil {
+		creds.keys = newKeyPool(creds.apiKeys)
+	}
+
+	var lastErr error
+	for range creds.apiKeys {
+		key, err := creds.keys.pick()
+		if err != nil {
+			if lastErr != nil {
+				return "", fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
+			return "", err
+		}
+
+		// A retry with another key starts the answer over
+		p.mu.Lock()
+		p.partial = ""
+		p.mu.Unlock()
+
+		output, err := p.send(ctx, creds, key, task, p.appendPartial)
+		var limited *RateLimitError
+		if !errors.As(err, &limited) {
+			return output, err
+		}
+
+		creds.keys.rateLimited(key, limited.RetryAfter)
+		plugin.Logf(ctx, "[LLM] API key %s %v, trying the next key", maskKey(key), limited)
+		lastErr = err
+	}
+	return "", fmt.Errorf("%w (last error: %v)", errAllKeysLimited, lastErr)
+}
+
+// stubSend stands in for the provider request
+// TODO: Call the provider's API with apiKey, returning rateLimitFromResponse
+// for 429 responses and passing streamed text to emit
+func stubSend(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(chunk string)) (string, error) {
+	answer := fmt.Sprintf("[%s/%s stub] %v", creds.provider, creds.model, task.Input)
+	emit(answer)
+	return answer, nil
+}
+
+// maskKey shortens an API key for logs
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return key[:4] + "…" + key[len(key)-4:]
 }
 
 // CancelTask cancels a running task
@@ -235,6 +547,7 @@ func (p *LLMPlugin) GetStatus(ctx context.Context) (*plugin.ExecutorStatus, erro
 		CurrentTask: p.currentTask,
 		Progress:    p.progress,
 		Message:     p.message,
+		Partial:     p.partial,
 	}, nil
 }
 
@@ -265,7 +578,7 @@ func (e *LLMExecutorExtension) SupportsMode(mode plugin.Mode) bool {
 }
 
 // Implement Executor interface
-func (e *LLMExecutorExtension) ExecuteTask(ctx context.Context, task *plugin.Task) error {
+func (e *LLMExecutorExtension) ExecuteTask(ctx context.Context, task *plugin.Task) (interface{}, error) {
 	return e.plugin.ExecuteTask(ctx, task)
 }
 
@@ -277,27 +590,55 @@ func (e *LLMExecutorExtension) GetStatus(ctx context.Context) (*plugin.ExecutorS
 	return e.plugin.GetStatus(ctx)
 }
 
+func (e *LLMExecutorExtension) CanHandle(taskType string) bool {
+	return taskType == TaskTypeQuery
+}
+
+func (e *LLMExecutorExtension) PrepareTask(task *plugin.Task) {
+	e.plugin.PrepareTask(task)
+}
+
 // handleAsk is the command handler for /ask
 func handleAsk(ctx context.Context, args []string) (*plugin.CommandResult, error) {
-	if len(args) == 0 {
-		return nil, fmt.Errorf("usage: /ask <question>")
+	question, options := parseAskArgs(args)
+	if question == "" && options[plugin.OptionInputFile] == nil {
+		return nil, fmt.Errorf("usage: /ask [--option value]... <question>")
 	}
 
-	question := fmt.Sprintf("%v", args)
-
 	// Get daemon from context to execute task
-	daemon, ok := ctx.Value("daemon").(interface {
-		ExecuteTask(context.Context, *plugin.Task) error
-	})
+	daemon, ok := ctx.Value("daemon").(taskSubmitter)
 	if !ok {
 		return nil, fmt.Errorf("daemon not available in context")
 	}
 
 	// Create task
 	task := &plugin.Task{
-		ID:    fmt.Sprintf("ask-%d", time.Now().Unix()),
-		Type:  "llm_query",
-		Input: question,
+		ID:      fmt.Sprintf("ask-%d", time.Now().Unix()),
+		Type:    TaskTypeQuery,
+		Input:   question,
+		Options: options,
+	}
+
+	// Keep one caller's questions in order, since each may follow up the last
+	if principal, ok := plugin.PrincipalFromContext(ctx); ok && principal.Source != "" {
+		task.OrderingKey = "ask:" + principal.Source
+	}
+
+	// Preview: report whether the task would be accepted
+	if plugin.IsDryRun(ctx) {
+		if previewer, ok := daemon.(cmd.TaskPreviewer); ok {
+			if err := previewer.PreviewTask(task); err != nil {
+				return &plugin.CommandResult{Output: fmt.Sprintf("Would fail to submit %s task: %v", task.Type, err)}, nil
+			}
+		}
+		return &plugin.CommandResult{Output: fmt.Sprintf("Would submit %s task: %s", task.Type, question)}, nil
+	}
+
+	// In interactive mode the answer is the command's reply
+	broker, _ := ctx.Value("broker").(plugin.MessageBroker)
+	sink, _ := ctx.Value("message_sink").(cmd.MessageSink)
+	if mode, _ := ctx.Value("mode").(plugin.Mode); mode == plugin.ModeInteractive && broker != nil && sink != nil {
+		return askInline(ctx, daemon, broker, sink, task, question)
 	}
 
 	// Execute task
@@ -309,3 +650,104 @@ func handleAsk(ctx context.Context, args []string) (*plugin.CommandResult, error
 		Output: fmt.Sprintf("Processing question: %s", question),
 	}, nil
 }
+
+// askInline runs the task and waits for its result to return as the reply
+// If it takes longer than askWaitTimeout, the answer goes to sink instead
+func askInline(ctx context.Context, daemon taskSubmitter, broker plugin.MessageBroker, sink cmd.MessageSink, task *plugin.Task, question string) (*plugin.CommandResult, error) {
+	// Subscribe before submitting so the result can't be missed
+	subID := "ask:" + plugin.NewCorrelationID()
+	events := broker.Subscribe(subID, 16, plugin.TopicTaskCompleted, plugin.TopicTaskFailed)
+
+	task.Awaited = true
+	if err := daemon.ExecuteTask(ctx, task); err != nil {
+		broker.Unsubscribe(subID)
+		return nil, err
+	}
+
+	timer := time.NewTimer(askWaitTimeout)
+	defer timer.Stop()
+
+	done := taskEvent(events, task.ID)
+	select {
+	case msg, ok := <-done:
+		broker.Unsubscribe(subID)
+		if !ok {
+			return nil, fmt.Errorf("stopped waiting for the answer (broker closed)")
+		}
+		result, _ := msg.Payload.(*plugin.TaskResult)
+		if result == nil {
+			return nil, fmt.Errorf("unexpected result for task %s", task.ID)
+		}
+		if result.Error != "" {
+			return nil, fmt.Errorf("task failed: %s", result.Error)
+		}
+		return &plugin.CommandResult{Output: result.String(), Data: result}, nil
+
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// Hand the answer to the transport when it arrives
+	go func() {
+		defer broker.Unsubscribe(subID)
+		if msg, ok := <-done; ok {
+			sink(plugin.Message{
+				Topic:    "response",
+				Payload:  msg.Payload,
+				Source:   msg.Source,
+				Metadata: msg.Metadata,
+			})
+		}
+	}()
+
+	return &plugin.CommandResult{
+		Output: fmt.Sprintf("Still working on: %s (the answer will follow)", question),
+	}, nil
+}
+
+// taskEvent returns a channel receiving the first event for taskID from events
+// It is closed without a value if events closes first
+func taskEvent(events <-chan plugin.Message, taskID string) <-chan plugin.Message {
+	found := make(chan plugin.Message, 1)
+	go func() {
+		defer close(found)
+		for msg := range events {
+			if id, _ := msg.Metadata["task_id"].(string); id == taskID {
+				found <- msg
+				return
+			}
+		}
+	}()
+	return found
+}
+
+// parseAskArgs splits /ask arguments into the question and task options
+// "--name value" sets option name; a flag without a value (last, or followed
+// by another flag) is set to true. "--" ends option parsing, so the rest is
+// taken literally. The remaining words are joined with single spaces
+func parseAskArgs(args []string) (string, map[string]interface{}) {
+	options := make(map[string]interface{})
+	var words []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			words = append(words, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			words = append(words, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg, "--")
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			options[name] = args[i+1]
+			i++
+		} else {
+			options[name] = true
+		}
+	}
+
+	return strings.Join(words, " "), options
+}
@@ -0,0 +1,449 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bicycle/cmd"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// startPlugin starts an LLM plugin with one API key, no simulated work and
+// the given send, on a fake broker
+func startPlugin(t *testing.T, send func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(chunk string)) (string, error)) (*LLMPlugin, *testutil.Broker) {
+	t.Helper()
+
+	p := NewLLMPlugin()
+	p.step = 0
+	p.send = send
+	p.creds = credentials{provider: "test", model: "test", apiKeys: []string{"key-1"}}
+
+	broker := testutil.NewBroker()
+	if err := p.Start(testutil.NewContext(testutil.WithBroker(broker)), broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { p.Stop(context.Background()) })
+	return p, broker
+}
+
+func TestExecuteTaskCompletesAfterQuery(t *testing.T) {
+	streaming := make(chan struct{})
+	finish := make(chan struct{})
+	p, _ := startPlugin(t, func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(string)) (string, error) {
+		emit("Go is ")
+		close(streaming)
+		<-finish
+		emit("a language")
+		return "Go is a language", nil
+	})
+
+	task := &plugin.Task{ID: "t1", Type: TaskTypeQuery, Input: "What is Go?"}
+	p.PrepareTask(task)
+
+	type outcome struct {
+		output interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		output, err := p.ExecuteTask(context.Background(), task)
+		done <- outcome{output, err}
+	}()
+
+	<-streaming
+	status, _ := p.GetStatus(context.Background())
+	if status.State != plugin.ExecutorStateWorking || status.CurrentTask != task {
+		t.Errorf("status while streaming = %s (task %v), want working on t1", status.State, status.CurrentTask)
+	}
+
+	close(finish)
+	got := <-done
+	if got.err != nil || got.output != "Go is a language" {
+		t.Fatalf("ExecuteTask = %v, %v, want the answer", got.output, got.err)
+	}
+	status, _ = p.GetStatus(context.Background())
+	if status.State != plugin.ExecutorStateIdle || status.Progress != 100 || status.Message != "Task completed" {
+		t.Errorf("status after the answer = %+v, want idle, 100%%, Task completed", status)
+	}
+}
+
+func TestCancelMidStreamSendsPartialResponse(t *testing.T) {
+	streaming := make(chan struct{})
+	p, broker := startPlugin(t, func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(string)) (string, error) {
+		emit("Go is ")
+		close(streaming)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	responses := testutil.Collect(broker, "test", "response")
+
+	task := &plugin.Task{ID: "t1", Type: TaskTypeQuery, Input: "What is Go?", CorrelationID: "c1"}
+	p.PrepareTask(task)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.ExecuteTask(ctx, task)
+		done <- err
+	}()
+
+	<-streaming
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExecuteTask error = %v, want context.Canceled", err)
+	}
+
+	msgs := responses.WaitFor(1, 5*time.Second)
+	if len(msgs) != 1 {
+		t.Fatalf("got %d responses, want 1", len(msgs))
+	}
+	msg := msgs[0]
+	if msg.Metadata[plugin.MetadataCancelled] != true || msg.Metadata[plugin.MetadataCorrelationID] != "c1" {
+		t.Errorf("response metadata = %v, want cancelled with correlation id c1", msg.Metadata)
+	}
+	result, ok := msg.Payload.(*plugin.TaskResult)
+	if !ok || result.Output != "Go is " {
+		t.Errorf("response payload = %#v, want the partial answer", msg.Payload)
+	}
+
+	status, _ := p.GetStatus(context.Background())
+	if status.State != plugin.ExecutorStateIdle || status.Partial != "Go is " || status.Message != "Task cancelled" {
+		t.Errorf("status after cancel = %+v, want idle with the partial answer", status)
+	}
+}
+
+func TestStopWhileRunningIsSafeTwice(t *testing.T) {
+	streaming := make(chan struct{})
+	finish := make(chan struct{})
+	p, _ := startPlugin(t, func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(string)) (string, error) {
+		close(streaming)
+		<-finish
+		return "done", nil
+	})
+
+	task := &plugin.Task{ID: "t1", Type: TaskTypeQuery, Input: "What is Go?"}
+	p.PrepareTask(task)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.ExecuteTask(context.Background(), task)
+	}()
+	<-streaming
+
+	// Stop reads the running task while ExecuteTask finishes and clears it
+	stopped := make(chan error, 2)
+	go func() { stopped <- p.Stop(context.Background()) }()
+	go func() { stopped <- p.Stop(context.Background()) }()
+	close(finish)
+	for i := 0; i < 2; i++ {
+		if err := <-stopped; err != nil {
+			t.Errorf("Stop: %v", err)
+		}
+	}
+	<-done
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Errorf("Stop after stopping: %v", err)
+	}
+}
+
+func TestParseAskArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		question string
+		options  map[string]interface{}
+	}{
+		{"plain words", []string{"what", "is", "Go?"}, "what is Go?", map[string]interface{}{}},
+		{"quoted arg", []string{"explain", "a  b", "please"}, "explain a  b please", map[string]interface{}{}},
+		{"literal brackets", []string{"is", "[1 2]", "a", "slice?"}, "is [1 2] a slice?", map[string]interface{}{}},
+		{"options", []string{"--model", "fast", "hi", "--verbose"}, "hi", map[string]interface{}{"model": "fast", "verbose": true}},
+		{"flag before flag", []string{"--verbose", "--model", "fast", "hi"}, "hi", map[string]interface{}{"model": "fast", "verbose": true}},
+		{"end of options", []string{"--model", "fast", "--", "--not-a-flag", "x"}, "--not-a-flag x", map[string]interface{}{"model": "fast"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question, options := parseAskArgs(tt.args)
+			if question != tt.question {
+				t.Errorf("question = %q, want %q", question, tt.question)
+			}
+			if len(options) != len(tt.options) {
+				t.Errorf("options = %v, want %v", options, tt.options)
+			}
+			for name, want := range tt.options {
+				if got := options[name]; got != want {
+					t.Errorf("option %s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAskSubmitsJoinedPromptAndOptions(t *testing.T) {
+	d := testutil.NewDaemon()
+	ctx := testutil.NewContext(testutil.WithDaemon(d))
+
+	if _, err := cmd.NewRouter().Route(ctx, `/ask --model fast "what is" [x]?`); err != nil {
+		t.Fatalf("/ask: %v", err)
+	}
+
+	tasks := d.Tasks()
+	if len(tasks) != 1 {
+		t.Fatalf("submitted %d tasks, want 1", len(tasks))
+	}
+	if tasks[0].Input != "what is [x]?" {
+		t.Errorf("prompt = %q, want %q", tasks[0].Input, "what is [x]?")
+	}
+	if got := tasks[0].Options["model"]; got != "fast" {
+		t.Errorf("model option = %v, want fast", got)
+	}
+}
+
+func TestReloadRotatesKeyWithoutInterruptingInFlight(t *testing.T) {
+	keys := make(chan string, 2)
+	finish := make(chan struct{})
+	p, _ := startPlugin(t, func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(string)) (string, error) {
+		keys <- apiKey
+		if task.ID == "t1" {
+			<-finish
+		}
+		return "answer", nil
+	})
+
+	inFlight := &plugin.Task{ID: "t1", Type: TaskTypeQuery, Input: "first"}
+	p.PrepareTask(inFlight)
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.ExecuteTask(context.Background(), inFlight)
+		done <- err
+	}()
+	if key := <-keys; key != "key-1" {
+		t.Fatalf("in-flight request used %q, want key-1", key)
+	}
+
+	rotated := testutil.NewContext(testutil.WithPluginSetting("llm", "api_key", "key-2"))
+	if err := p.Reload(rotated); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	next := &plugin.Task{ID: "t2", Type: TaskTypeQuery, Input: "second"}
+	p.PrepareTask(next)
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight ExecuteTask: %v", err)
+	}
+	if _, err := p.ExecuteTask(context.Background(), next); err != nil {
+		t.Fatalf("ExecuteTask after rotating: %v", err)
+	}
+	if key := <-keys; key != "key-2" {
+		t.Errorf("request after rotating used %q, want key-2", key)
+	}
+}
+
+func TestReloadKeepsKeyFailingWarmup(t *testing.T) {
+	p, _ := startPlugin(t, stubSend)
+
+	bad := testutil.NewContext(testutil.WithPluginSetting("llm", "api_key", "bad key"))
+	if err := p.Reload(bad); err == nil {
+		t.Fatal("Reload adopted a key that fails warmup")
+	}
+
+	task := &plugin.Task{ID: "t1", Type: TaskTypeQuery}
+	p.PrepareTask(task)
+	if creds := task.ExecutorConfig.(credentials); len(creds.apiKeys) != 1 || creds.apiKeys[0] != "key-1" {
+		t.Errorf("keys after a failed reload = %v, want [key-1]", creds.apiKeys)
+	}
+}
+
+func TestReloadedModelOnlyUsedByLaterTasks(t *testing.T) {
+	used := make(chan string, 3)
+	finish := make(chan struct{})
+	p, _ := startPlugin(t, func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(string)) (string, error) {
+		used <- task.ID + " " + creds.provider + "/" + creds.model
+		if task.ID == "running" {
+			<-finish
+		}
+		return "answer", nil
+	})
+
+	running := &plugin.Task{ID: "running", Type: TaskTypeQuery}
+	queued := &plugin.Task{ID: "queued", Type: TaskTypeQuery}
+	p.PrepareTask(running)
+	p.PrepareTask(queued)
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.ExecuteTask(context.Background(), running)
+		done <- err
+	}()
+	if got := <-used; got != "running test/test" {
+		t.Fatalf("request = %q, want the original config", got)
+	}
+
+	if err := p.Reload(testutil.NewContext(
+		testutil.WithPluginSetting("llm", "provider", "anthropic"),
+		testutil.WithPluginSetting("llm", "model", "claude"),
+		testutil.WithPluginSetting("llm", "api_key", "key-2"),
+	)); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	next := &plugin.Task{ID: "next", Type: TaskTypeQuery}
+	p.PrepareTask(next)
+
+	close(finish)
+	if err := <-done; err != nil {
+		t.Fatalf("running task: %v", err)
+	}
+	for _, task := range []*plugin.Task{queued, next} {
+		if _, err := p.ExecuteTask(context.Background(), task); err != nil {
+			t.Fatalf("%s task: %v", task.ID, err)
+		}
+	}
+
+	// Tasks submitted before the reload keep the config they were submitted with
+	for _, want := range []string{"queued test/test", "next anthropic/claude"} {
+		if got := <-used; got != want {
+			t.Errorf("request = %q, want %q", got, want)
+		}
+	}
+}
+
+// answeringDaemon completes each submitted task on broker with answer,
+// after delay
+type answeringDaemon struct {
+	broker *testutil.Broker
+	answer string
+	delay  time.Duration
+}
+
+func (d *answeringDaemon) ExecuteTask(ctx context.Context, task *plugin.Task) error {
+	go func() {
+		time.Sleep(d.delay)
+		d.broker.Publish(context.Background(), plugin.Message{
+			Topic:    plugin.TopicTaskCompleted,
+			Payload:  &plugin.TaskResult{ID: task.ID, Output: d.answer},
+			Metadata: map[string]interface{}{"task_id": task.ID},
+		})
+	}()
+	return nil
+}
+
+func TestAskAnswersInlineInInteractiveMode(t *testing.T) {
+	broker := testutil.NewBroker()
+	sunk := make(chan plugin.Message, 1)
+	newCtx := func(mode plugin.Mode) context.Context {
+		ctx := testutil.NewContext(
+			testutil.WithMode(mode),
+			testutil.WithBroker(broker),
+			testutil.WithDaemon(&answeringDaemon{broker: broker, answer: "42"}),
+		)
+		return cmd.WithMessageSink(ctx, func(msg plugin.Message) { sunk <- msg })
+	}
+
+	result, err := cmd.NewRouter().Route(newCtx(plugin.ModeInteractive), "/ask meaning of life")
+	if err != nil {
+		t.Fatalf("interactive /ask: %v", err)
+	}
+	if result.Output != "42" {
+		t.Errorf("interactive /ask = %q, want the answer inline", result.Output)
+	}
+
+	result, err = cmd.NewRouter().Route(newCtx(plugin.ModeDaemon), "/ask meaning of life")
+	if err != nil {
+		t.Fatalf("daemon /ask: %v", err)
+	}
+	if result.Output != "Processing question: meaning of life" {
+		t.Errorf("daemon /ask = %q, want it processing asynchronously", result.Output)
+	}
+	if len(sunk) != 0 {
+		t.Errorf("%d answers sent to the sink, want none", len(sunk))
+	}
+}
+
+func TestSlowAskAnswerFollowsToSink(t *testing.T) {
+	defer func(timeout time.Duration) { askWaitTimeout = timeout }(askWaitTimeout)
+	askWaitTimeout = 10 * time.Millisecond
+
+	broker := testutil.NewBroker()
+	sunk := make(chan plugin.Message, 1)
+	ctx := cmd.WithMessageSink(testutil.NewContext(
+		testutil.WithMode(plugin.ModeInteractive),
+		testutil.WithBroker(broker),
+		testutil.WithDaemon(&answeringDaemon{broker: broker, answer: "42", delay: 100 * time.Millisecond}),
+	), func(msg plugin.Message) { sunk <- msg })
+
+	result, err := cmd.NewRouter().Route(ctx, "/ask meaning of life")
+	if err != nil {
+		t.Fatalf("/ask: %v", err)
+	}
+	if result.Output != "Still working on: meaning of life (the answer will follow)" {
+		t.Errorf("/ask = %q, want it still working", result.Output)
+	}
+
+	select {
+	case msg := <-sunk:
+		if res, _ := msg.Payload.(*plugin.TaskResult); msg.Topic != "response" || res == nil || res.Output != "42" {
+			t.Errorf("sink got %+v, want the answer as a response", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("late answer never reached the sink")
+	}
+}
+
+func TestKeysRotateAndRateLimitedKeySkipped(t *testing.T) {
+	var limitedOnce bool
+	p, _ := startPlugin(t, func(ctx context.Context, creds credentials, apiKey string, task *plugin.Task, emit func(string)) (string, error) {
+		if apiKey == "key-2" && !limitedOnce {
+			limitedOnce = true
+			return "", &RateLimitError{RetryAfter: time.Minute}
+		}
+		return apiKey, nil
+	})
+
+	now := time.Unix(1_000_000, 0)
+	keys := newKeyPool([]string{"key-1", "key-2", "key-3"})
+	keys.now = func() time.Time { return now }
+	p.creds = credentials{provider: "test", model: "test", apiKeys: keys.keys, keys: keys}
+
+	query := func() string {
+		t.Helper()
+		task := &plugin.Task{ID: "t", Type: TaskTypeQuery, Input: "hi"}
+		p.PrepareTask(task)
+		output, err := p.ExecuteTask(context.Background(), task)
+		if err != nil {
+			t.Fatalf("ExecuteTask: %v", err)
+		}
+		return output.(string)
+	}
+
+	// key-2 is rate limited on its first turn, so that task moves on to
+	// key-3 and key-2 sits out the next round
+	for i, want := range []string{"key-1", "key-3", "key-1", "key-3"} {
+		if got := query(); got != want {
+			t.Errorf("task %d answered with %s, want %s", i+1, got, want)
+		}
+	}
+	if n := keys.limited(); n != 1 {
+		t.Errorf("%d keys rate limited, want 1", n)
+	}
+
+	// Once the cooldown passes key-2 is back in the rotation
+	now = now.Add(time.Minute)
+	for i, want := range []string{"key-1", "key-2", "key-3"} {
+		if got := query(); got != want {
+			t.Errorf("task %d after the cooldown answered with %s, want %s", i+1, got, want)
+		}
+	}
+
+	// With every key cooling down the task fails instead of waiting
+	for _, key := range keys.keys {
+		keys.rateLimited(key, 0)
+	}
+	task := &plugin.Task{ID: "t", Type: TaskTypeQuery, Input: "hi"}
+	p.PrepareTask(task)
+	if _, err := p.ExecuteTask(context.Background(), task); !errors.Is(err, errAllKeysLimited) {
+		t.Errorf("ExecuteTask with every key limited = %v, want errAllKeysLimited", err)
+	}
+}
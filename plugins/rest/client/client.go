@@ -0,0 +1,272 @@
+// Package client is a typed client for the REST plugin's API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bicycle/plugin"
+	"bicycle/plugins/rest"
+)
+
+// RetryPolicy controls how calls are retried after transient failures: a
+// network error, a response cut short, a 429, or a 502, 503 or 504
+type RetryPolicy struct {
+	// MaxAttempts is the most times a call is tried (1 = no retries)
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry, doubled for each one
+	// after it up to MaxBackoff. A longer Retry-After from the server wins
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy tries each call at most three times
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     200 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+}
+
+// Error is a non-2xx response from the API
+type Error struct {
+	StatusCode int
+	Message    string
+
+	// RetryAfter is how long the server asked to wait (0 = not given)
+	RetryAfter time.Duration
+}
+
+// Error describes the response
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// retryable reports whether the request may succeed if sent again
+func (e *Error) retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Client calls the daemon's REST API
+//
+// Status, Health and GetTask are retried per the retry policy. Command and
+// SubmitTask are only retried when given an idempotency key, since without
+// one the daemon may run the command or task once per attempt
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	retry   RetryPolicy
+
+	// sleep waits between attempts (replaceable for testing)
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client
+type Option func(c *Client)
+
+// WithToken sends token as the bearer token (the plugin's auth_token)
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New creates a client for the API at baseURL, e.g. http://localhost:8081
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    http.DefaultClient,
+		retry:   DefaultRetryPolicy,
+		sleep:   sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Health checks that the daemon is answering
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/api/health", nil, "", nil)
+}
+
+// Status returns the daemon's status text
+func (c *Client) Status(ctx context.Context) (*rest.StatusResponse, error) {
+	var resp rest.StatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/status", nil, "", &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetTask returns the result of a recent task
+func (c *Client) GetTask(ctx context.Context, id string) (*plugin.TaskResult, error) {
+	var resp rest.TaskListResponse
+	if err := c.do(ctx, http.MethodGet, "/api/tasks", nil, "", &resp); err != nil {
+		return nil, err
+	}
+	for _, result := range resp.Tasks {
+		if result.ID == id {
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("task %s not found", id)
+}
+
+// Command runs a command. With an idempotencyKey it is retried, and the
+// daemon replays its first response to the retries
+func (c *Client) Command(ctx context.Context, req rest.CommandRequest, idempotencyKey string) (*rest.CommandResponse, error) {
+	var resp rest.CommandResponse
+	if err := c.do(ctx, http.MethodPost, "/api/command", req, idempotencyKey, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmitTask submits a task. With an idempotencyKey it is retried, and the
+// daemon runs the task once
+func (c *Client) SubmitTask(ctx context.Context, req rest.TaskRequest, idempotencyKey string) (*rest.TaskResponse, error) {
+	var resp rest.TaskResponse
+	if err := c.do(ctx, http.MethodPost, "/api/tasks", req, idempotencyKey, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do sends a request, retrying transient failures when it is safe to, and
+// decodes the JSON response into out (if not nil)
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, idempotencyKey string, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+	}
+
+	attempts := c.retry.MaxAttempts
+	if attempts < 1 || (method != http.MethodGet && idempotencyKey == "") {
+		attempts = 1
+	}
+
+	backoff := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		data, err := c.send(ctx, method, path, payload, idempotencyKey)
+		if err == nil {
+			if out == nil {
+				return nil
+			}
+			if err := json.Unmarshal(data, out); err != nil {
+				return fmt.Errorf("decoding response: %w", err)
+			}
+			return nil
+		}
+
+		var apiErr *Error
+		transient := !errors.As(err, &apiErr) || apiErr.retryable()
+		if !transient || attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+
+		wait := backoff
+		if apiErr != nil && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return err
+		}
+		if backoff *= 2; c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// send makes one attempt and returns the response body of a 2xx response.
+// A body cut short is returned as an error, like a failed connection
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, idempotencyKey string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			apiErr.Message = body.Error
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
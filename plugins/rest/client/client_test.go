@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"bicycle/plugins/rest"
+)
+
+// flakyServer fails the first failures requests to each path, alternately by
+// dropping the connection and by cutting the response short, then answers
+// with body
+type flakyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures int
+	calls    map[string]int
+}
+
+func newFlakyServer(t *testing.T, failures int, body string) *flakyServer {
+	s := &flakyServer{failures: failures, calls: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.calls[r.URL.Path]++
+		call := s.calls[r.URL.Path]
+		s.mu.Unlock()
+
+		switch {
+		case call > s.failures:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		case call%2 == 1:
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		default:
+			// Promise more than is sent
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte(`{"status":`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// callsTo returns how many requests reached path
+func (s *flakyServer) callsTo(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[path]
+}
+
+// newTestClient returns a client for url that records its waits instead of
+// sleeping
+func newTestClient(url string, opts ...Option) (*Client, *[]time.Duration) {
+	var waits []time.Duration
+	c := New(url, opts...)
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return c, &waits
+}
+
+func TestGetRetriesTransientFailures(t *testing.T) {
+	server := newFlakyServer(t, 2, `{"status":"ok","message":"Running"}`)
+	c, waits := newTestClient(server.URL)
+
+	status, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Message != "Running" {
+		t.Errorf("message = %q, want Running", status.Message)
+	}
+	if got := server.callsTo("/api/status"); got != 3 {
+		t.Errorf("server saw %d requests, want 3", got)
+	}
+	if want := []time.Duration{200 * time.Millisecond, 400 * time.Millisecond}; len(*waits) != 2 || (*waits)[0] != want[0] || (*waits)[1] != want[1] {
+		t.Errorf("waited %v, want %v", *waits, want)
+	}
+}
+
+func TestGetGivesUpAfterMaxAttempts(t *testing.T) {
+	server := newFlakyServer(t, 10, `{"status":"healthy"}`)
+	c, _ := newTestClient(server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 4}))
+
+	if err := c.Health(context.Background()); err == nil {
+		t.Fatal("Health succeeded, want an error")
+	}
+	if got := server.callsTo("/api/health"); got != 4 {
+		t.Errorf("server saw %d requests, want 4", got)
+	}
+}
+
+func TestPostWithoutKeyIsNotRetried(t *testing.T) {
+	server := newFlakyServer(t, 1, `{"success":true,"task_id":"t1"}`)
+	c, _ := newTestClient(server.URL)
+
+	if _, err := c.SubmitTask(context.Background(), rest.TaskRequest{Type: "echo", Input: "hi"}, ""); err == nil {
+		t.Fatal("SubmitTask succeeded, want the connection error")
+	}
+	if _, err := c.Command(context.Background(), rest.CommandRequest{Command: "status"}, ""); err == nil {
+		t.Fatal("Command succeeded, want the connection error")
+	}
+	if got := server.callsTo("/api/tasks"); got != 1 {
+		t.Errorf("server saw %d task submissions, want 1", got)
+	}
+	if got := server.callsTo("/api/command"); got != 1 {
+		t.Errorf("server saw %d commands, want 1", got)
+	}
+}
+
+func TestPostWithKeyIsRetried(t *testing.T) {
+	server := newFlakyServer(t, 2, `{"success":true,"task_id":"t1"}`)
+	c, _ := newTestClient(server.URL)
+
+	resp, err := c.SubmitTask(context.Background(), rest.TaskRequest{Type: "echo", Input: "hi"}, "key-1")
+	if err != nil {
+		t.Fatalf("SubmitTask: %v", err)
+	}
+	if resp.TaskID != "t1" {
+		t.Errorf("task id = %q, want t1", resp.TaskID)
+	}
+	if got := server.callsTo("/api/tasks"); got != 3 {
+		t.Errorf("server saw %d requests, want 3", got)
+	}
+}
+
+func TestRateLimitHonorsRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":"slow down"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	defer server.Close()
+	c, waits := newTestClient(server.URL)
+
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if len(*waits) != 1 || (*waits)[0] != 3*time.Second {
+		t.Errorf("waited %v, want [3s]", *waits)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"Unauthorized"}`))
+	}))
+	defer server.Close()
+	c, _ := newTestClient(server.URL)
+
+	_, err := c.Status(context.Background())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Unauthorized" {
+		t.Fatalf("Status error = %v, want HTTP 401: Unauthorized", err)
+	}
+	if calls != 1 {
+		t.Errorf("server saw %d requests, want 1", calls)
+	}
+}
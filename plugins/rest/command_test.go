@@ -0,0 +1,222 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/cmd"
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func init() {
+	cmd.Register(&plugin.Command{
+		Name:        "test-block",
+		Description: "Blocks until cancelled",
+		Hidden:      true,
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	cmd.Register(&plugin.Command{
+		Name:        "test-deadline",
+		Description: "Reports how long it was given to run",
+		Hidden:      true,
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return &plugin.CommandResult{Output: "none"}, nil
+			}
+			return &plugin.CommandResult{Output: time.Until(deadline).Round(time.Second).String()}, nil
+		},
+	})
+	cmd.Register(&plugin.Command{
+		Name:        "test-export",
+		Description: "Returns its argument as a CSV artifact",
+		Hidden:      true,
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			return &plugin.CommandResult{
+				Output:   "Exported",
+				Artifact: &plugin.Artifact{Filename: "export.csv", ContentType: "text/csv", Data: []byte(strings.Join(args, ","))},
+			}, nil
+		},
+	})
+}
+
+func TestBlockedCommandTimesOut(t *testing.T) {
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext()
+	p.router = cmd.NewRouter()
+	p.router.SetTimeout(50 * time.Millisecond)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(`{"command":"/test-block"}`))
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.handleCommand(w, r)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still blocked after the command timeout")
+	}
+
+	var resp CommandResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Success || resp.Error != "command /test-block timed out" {
+		t.Errorf("response = %+v, want a timeout error", resp)
+	}
+}
+
+func TestDryRunCommandField(t *testing.T) {
+	d := testutil.NewDaemon()
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(d))
+	p.router = cmd.NewRouter()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(`{"command":"/reset","dry_run":true}`))
+	w := httptest.NewRecorder()
+	p.handleCommand(w, r)
+
+	var resp CommandResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if !resp.Success || !resp.DryRun || !strings.HasPrefix(resp.Output, "Would reset") {
+		t.Errorf("response = %+v, want a dry-run description", resp)
+	}
+	if got := d.Resets(); got != 0 {
+		t.Errorf("dry run reset the daemon %d time(s)", got)
+	}
+}
+
+func TestCommandsRefusedUntilDaemonReady(t *testing.T) {
+	d := testutil.NewDaemon()
+	d.NotReady = true
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(d))
+	p.router = cmd.NewRouter()
+	command := p.readyMiddleware(p.handleCommand)
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		command(w, httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(`{"command":"/whoami"}`)))
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("command before ready = %d, want 503", w.Code)
+	}
+	health := httptest.NewRecorder()
+	p.handleHealth(health, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if health.Code != http.StatusOK {
+		t.Errorf("health before ready = %d, want 200", health.Code)
+	}
+
+	d.NotReady = false
+	w := send()
+	var resp CommandResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if w.Code != http.StatusOK || !resp.Success {
+		t.Errorf("command after ready = %d %+v, want success", w.Code, resp)
+	}
+}
+
+func TestLargeArtifactIsDownloaded(t *testing.T) {
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext()
+	p.router = cmd.NewRouter()
+	p.inlineArtifactBytes = 8
+
+	r := httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(`{"command":"/test-export id name email"}`))
+	w := httptest.NewRecorder()
+	p.handleCommand(w, r)
+
+	if got := w.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=export.csv` {
+		t.Errorf("Content-Disposition = %q, want an attachment named export.csv", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != "13" {
+		t.Errorf("Content-Length = %q, want 13", got)
+	}
+	if got := w.Body.String(); got != "id,name,email" {
+		t.Errorf("body = %q, want the artifact", got)
+	}
+}
+
+func TestSmallArtifactIsInline(t *testing.T) {
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext()
+	p.router = cmd.NewRouter()
+	p.inlineArtifactBytes = 8
+
+	r := httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(`{"command":"/test-export a b"}`))
+	w := httptest.NewRecorder()
+	p.handleCommand(w, r)
+
+	if got := w.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("Content-Disposition = %q, want none", got)
+	}
+	var resp CommandResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	want := ArtifactResponse{Filename: "export.csv", ContentType: "text/csv", Data: "a,b"}
+	if !resp.Success || resp.Output != "Exported" || resp.Artifact == nil || *resp.Artifact != want {
+		t.Errorf("response = %+v (artifact %+v), want the artifact inline", resp, resp.Artifact)
+	}
+}
+
+func TestCommandTimeoutFromConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings []testutil.ContextOption
+		want     string
+	}{
+		{"global default", nil, "30s"},
+		{"rest override", []testutil.ContextOption{testutil.WithPluginSetting("rest", "command_timeout", 7)}, "7s"},
+		{"other transport's override", []testutil.ContextOption{testutil.WithPluginSetting("telegram", "command_timeout", 7)}, "30s"},
+		{"no limit", []testutil.ContextOption{testutil.WithPluginSetting("rest", "command_timeout", 0)}, "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := testutil.NewBroker()
+			opts := append([]testutil.ContextOption{
+				testutil.WithBroker(broker),
+				testutil.WithPluginSetting("rest", "unix_socket", filepath.Join(t.TempDir(), "rest.sock")),
+			}, tt.settings...)
+			p := NewRESTPlugin()
+			if err := p.Start(testutil.NewContext(opts...), broker); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer p.Stop(context.Background())
+
+			r := httptest.NewRequest(http.MethodPost, "/api/command", strings.NewReader(`{"command":"/test-deadline"}`))
+			w := httptest.NewRecorder()
+			p.handleCommand(w, r)
+
+			var resp CommandResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Output != tt.want {
+				t.Errorf("command deadline = %q, want %s", resp.Output, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// RuntimeStats is the response of GET /api/debug/runtime
+type RuntimeStats struct {
+	Goroutines int    `json:"goroutines"`
+	NumCPU     int    `json:"num_cpu"`
+	GoVersion  string `json:"go_version"`
+
+	HeapAlloc   uint64 `json:"heap_alloc_bytes"`
+	HeapSys     uint64 `json:"heap_sys_bytes"`
+	HeapObjects uint64 `json:"heap_objects"`
+	TotalAlloc  uint64 `json:"total_alloc_bytes"`
+	Sys         uint64 `json:"sys_bytes"`
+
+	NumGC        uint32        `json:"num_gc"`
+	LastGC       *time.Time    `json:"last_gc,omitempty"`
+	PauseTotal   time.Duration `json:"pause_total_ns"`
+	NextGC       uint64        `json:"next_gc_bytes"`
+	GCCPUPercent float64       `json:"gc_cpu_percent"`
+}
+
+// readRuntimeStats samples the Go runtime
+func readRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := RuntimeStats{
+		Goroutines:   runtime.NumGoroutine(),
+		NumCPU:       runtime.NumCPU(),
+		GoVersion:    runtime.Version(),
+		HeapAlloc:    m.HeapAlloc,
+		HeapSys:      m.HeapSys,
+		HeapObjects:  m.HeapObjects,
+		TotalAlloc:   m.TotalAlloc,
+		Sys:          m.Sys,
+		NumGC:        m.NumGC,
+		PauseTotal:   time.Duration(m.PauseTotalNs),
+		NextGC:       m.NextGC,
+		GCCPUPercent: m.GCCPUFraction * 100,
+	}
+	if m.LastGC != 0 {
+		last := time.Unix(0, int64(m.LastGC))
+		stats.LastGC = &last
+	}
+	return stats
+}
+
+// handleRuntime returns goroutine, heap and GC stats. Admin only
+func (p *RESTPlugin) handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if p.principal(r).Role != plugin.RoleAdmin {
+		p.sendError(w, http.StatusForbidden, "Runtime stats require the admin role")
+		return
+	}
+
+	p.sendJSON(w, readRuntimeStats())
+}
+
+// mountPprof serves the net/http/pprof handlers under /debug/pprof/ to admins
+func (p *RESTPlugin) mountPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", p.authMiddleware(p.adminOnly(pprof.Index)))
+	mux.HandleFunc("/debug/pprof/cmdline", p.authMiddleware(p.adminOnly(pprof.Cmdline)))
+	mux.HandleFunc("/debug/pprof/profile", p.authMiddleware(p.adminOnly(pprof.Profile)))
+	mux.HandleFunc("/debug/pprof/symbol", p.authMiddleware(p.adminOnly(pprof.Symbol)))
+	mux.HandleFunc("/debug/pprof/trace", p.authMiddleware(p.adminOnly(pprof.Trace)))
+}
+
+// adminOnly rejects requests from clients without the admin role
+func (p *RESTPlugin) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p.principal(r).Role != plugin.RoleAdmin {
+			p.sendError(w, http.StatusForbidden, "Profiling requires the admin role")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bicycle/internal/testutil"
+)
+
+func TestRuntimeStatsPlausible(t *testing.T) {
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext()
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		p.handleRuntime(w, httptest.NewRequest(http.MethodGet, "/api/debug/runtime", nil))
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusForbidden {
+		t.Errorf("without the admin role = %d, want 403", w.Code)
+	}
+
+	p.authToken = "secret"
+	w := get()
+	var stats RuntimeStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if stats.Goroutines < 1 || stats.NumCPU < 1 || stats.GoVersion == "" {
+		t.Errorf("stats = %+v, want goroutines, CPUs and the Go version", stats)
+	}
+	if stats.HeapAlloc == 0 || stats.HeapSys < stats.HeapAlloc || stats.Sys == 0 || stats.TotalAlloc < stats.HeapAlloc {
+		t.Errorf("memory stats = %+v, want plausible non-zero values", stats)
+	}
+}
+
+func TestPprofOnlyMountedWhenEnabled(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		socket := filepath.Join(t.TempDir(), "rest.sock")
+		broker := testutil.NewBroker()
+		p := NewRESTPlugin()
+		if err := p.Start(testutil.NewContext(
+			testutil.WithBroker(broker),
+			testutil.WithPluginSetting("rest", "unix_socket", socket),
+			testutil.WithPluginSetting("rest", "auth_token", "secret"),
+			testutil.WithPluginSetting("rest", "enable_pprof", enabled),
+		), broker); err != nil {
+			t.Fatalf("Start: %v", err)
+		}
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}}
+		get := func(token string) int {
+			req, _ := http.NewRequest(http.MethodGet, "http://rest/debug/pprof/", nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("GET /debug/pprof/: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		want := http.StatusNotFound
+		if enabled {
+			want = http.StatusOK
+			if code := get(""); code != http.StatusUnauthorized {
+				t.Errorf("pprof without the token = %d, want 401", code)
+			}
+		}
+		if code := get("secret"); code != want {
+			t.Errorf("enable_pprof %v: pprof = %d, want %d", enabled, code, want)
+		}
+		p.Stop(context.Background())
+	}
+}
+
+func TestServesOverUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "rest.sock")
+	broker := testutil.NewBroker()
+	p := NewRESTPlugin()
+	if err := p.Start(testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("rest", "unix_socket", socket),
+		testutil.WithPluginSetting("rest", "unix_socket_mode", "0660"),
+	), broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	if info, err := os.Stat(socket); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("socket file = %v, %v, want mode 0660", info, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://rest/api/health")
+	if err != nil {
+		t.Fatalf("GET /api/health: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /api/health = %d, want 200", resp.StatusCode)
+	}
+	client.CloseIdleConnections()
+
+	p.Stop(context.Background())
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket file still there after Stop: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"bicycle/internal/clock"
+)
+
+// idempotencyHeader is the request header carrying a client-chosen idempotency key
+const idempotencyHeader = "Idempotency-Key"
+
+// idempotentResponse is a stored response for an idempotency key
+type idempotentResponse struct {
+	bodyHash    [sha256.Size]byte
+	pending     bool // the first request is still being handled
+	status      int
+	contentType string
+	disposition string
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore remembers responses by idempotency key for a TTL
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	clock   clock.Clock
+	entries map[string]*idempotentResponse
+}
+
+// newIdempotencyStore creates a store keeping responses for ttl
+func newIdempotencyStore(ttl time.Duration, c clock.Clock) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		clock:   c,
+		entries: make(map[string]*idempotentResponse),
+	}
+}
+
+// pruneLocked drops expired entries
+// Caller must hold s.mu
+func (s *idempotencyStore) pruneLocked(now time.Time) {
+	for key, entry := range s.entries {
+		if !entry.pending && now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// forgetLocked drops the entry for key if it is still entry
+// Caller must hold s.mu
+func (s *idempotencyStore) forgetLocked(key string, entry *idempotentResponse) {
+	if s.entries[key] == entry {
+		delete(s.entries, key)
+	}
+}
+
+// cacheable reports whether a response with status is replayed for retries
+// Server errors are often transient, so a retry runs the request again
+func cacheable(status int) bool {
+	return (status >= 200 && status < 300) || (status >= 400 && status < 500)
+}
+
+// recordingWriter captures a response while writing it through
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code
+func (w *recordingWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the body
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotencyMiddleware replays the stored response when a request repeats an
+// Idempotency-Key, so client retries don't repeat side effects. Reusing a key
+// with a different body, or while the first request is running, is a conflict.
+// Only 2xx and 4xx responses are kept; after a server error or a panic the
+// key can be retried. Streamed task requests are passed through uncached
+func (p *RESTPlugin) idempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.URL.Query().Get("stream") == "true" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			p.sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+
+		// Keys are scoped to the caller and the endpoint, so one client can't
+		// replay another's response by guessing its key
+		storeKey := p.principal(r).Source + "|" + r.URL.Path + "|" + key
+		store := p.idempotency
+		now := store.clock.Now()
+
+		store.mu.Lock()
+		store.pruneLocked(now)
+		if entry, exists := store.entries[storeKey]; exists {
+			store.mu.Unlock()
+
+			switch {
+			case entry.bodyHash != hash:
+				p.sendError(w, http.StatusConflict, "Idempotency-Key reused with a different request body")
+			case entry.pending:
+				p.sendError(w, http.StatusConflict, "Request with this Idempotency-Key is still in progress")
+			default:
+				w.Header().Set("Content-Type", entry.contentType)
+				if entry.disposition != "" {
+					w.Header().Set("Content-Disposition", entry.disposition)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(entry.status)
+				w.Write(entry.body)
+			}
+			return
+		}
+		entry := &idempotentResponse{bodyHash: hash, pending: true}
+		store.entries[storeKey] = entry
+		store.mu.Unlock()
+
+		// A panicking handler (recovered by net/http) must not leave the key
+		// pending for good
+		completed := false
+		defer func() {
+			if !completed {
+				store.mu.Lock()
+				store.forgetLocked(storeKey, entry)
+				store.mu.Unlock()
+			}
+		}()
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		completed = true
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if !cacheable(rec.status) {
+			store.forgetLocked(storeKey, entry)
+			return
+		}
+		entry.pending = false
+		entry.status = rec.status
+		entry.contentType = rec.Header().Get("Content-Type")
+		entry.disposition = rec.Header().Get("Content-Disposition")
+		entry.body = rec.body.Bytes()
+		entry.expires = store.clock.Now().Add(store.ttl)
+	}
+}
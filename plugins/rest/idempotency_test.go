@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/clock"
+)
+
+// countingHandler answers with how many requests it has handled
+func countingHandler(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", *calls)))
+	}
+}
+
+// idempotentRequest sends a POST with an Idempotency-Key from remoteAddr
+func idempotentRequest(handler http.HandlerFunc, remoteAddr, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	r.Header.Set(idempotencyHeader, key)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func newIdempotentPlugin() *RESTPlugin {
+	p := NewRESTPlugin()
+	p.idempotency = newIdempotencyStore(time.Hour, clock.Real())
+	return p
+}
+
+func TestIdempotencyReplaysForSameClient(t *testing.T) {
+	p := newIdempotentPlugin()
+	calls := 0
+	handler := p.idempotencyMiddleware(countingHandler(&calls))
+
+	first := idempotentRequest(handler, "10.0.0.1:1000", "k1", `{"type":"echo"}`)
+	retry := idempotentRequest(handler, "10.0.0.1:2000", "k1", `{"type":"echo"}`)
+
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry got %q (replayed %q), want the first response", retry.Body, retry.Header().Get("Idempotent-Replayed"))
+	}
+
+	conflict := idempotentRequest(handler, "10.0.0.1:3000", "k1", `{"type":"other"}`)
+	if conflict.Code != http.StatusConflict {
+		t.Errorf("reuse with another body = %d, want 409", conflict.Code)
+	}
+}
+
+func TestIdempotencyKeysAreScopedToClient(t *testing.T) {
+	p := newIdempotentPlugin()
+	calls := 0
+	handler := p.idempotencyMiddleware(countingHandler(&calls))
+
+	first := idempotentRequest(handler, "10.0.0.1:1000", "k1", `{"type":"echo"}`)
+	other := idempotentRequest(handler, "10.0.0.2:1000", "k1", `{"type":"echo"}`)
+
+	if calls != 2 {
+		t.Errorf("handler ran %d times, want once per client", calls)
+	}
+	if other.Header().Get("Idempotent-Replayed") != "" || other.Body.String() == first.Body.String() {
+		t.Error("a second client got the first client's stored response")
+	}
+
+	// Another client's key with a different body is not a conflict either
+	if w := idempotentRequest(handler, "10.0.0.3:1000", "k1", `{"type":"other"}`); w.Code != http.StatusOK {
+		t.Errorf("another client's request = %d, want 200", w.Code)
+	}
+}
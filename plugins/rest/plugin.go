@@ -3,12 +3,22 @@ package rest
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"bicycle/cmd"
+	"bicycle/internal/clock"
 	"bicycle/internal/config"
+	"bicycle/internal/listener"
 	"bicycle/plugin"
 )
 
@@ -24,12 +34,29 @@ type RESTPlugin struct {
 	ctx    context.Context
 	server *http.Server
 	authToken string
+	idempotency *idempotencyStore
+
+	// clock times idempotency keys (replaceable for testing)
+	clock clock.Clock
+
+	// inlineArtifactBytes is the largest artifact embedded in a JSON response
+	// instead of being sent as a download
+	inlineArtifactBytes int
+
+	// running is claimed when Start begins (and released if it fails) and
+	// cleared by Stop, so a second Start doesn't bind the port again and a
+	// second Stop does nothing
+	running atomic.Bool
 }
 
+// defaultInlineArtifactBytes is the default for the inline_artifact_bytes setting
+const defaultInlineArtifactBytes = 4096
+
 // CommandRequest represents a command request
 type CommandRequest struct {
 	Command string   `json:"command"`
 	Args    []string `json:"args,omitempty"`
+	DryRun  bool     `json:"dry_run,omitempty"`
 }
 
 // CommandResponse represents a command response
@@ -37,7 +64,97 @@ type CommandResponse struct {
 	Success bool        `json:"success"`
 	Output  string      `json:"output,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	DryRun  bool        `json:"dry_run,omitempty"`
 	Error   string      `json:"error,omitempty"`
+
+	Artifact *ArtifactResponse `json:"artifact,omitempty"`
+}
+
+// ArtifactResponse is a small artifact embedded in a command response
+type ArtifactResponse struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+	Encoding    string `json:"encoding,omitempty"` // "base64" for non-text content
+}
+
+// TaskRequest represents a task submission request
+type TaskRequest struct {
+	Type     string                 `json:"type"`
+	Input    interface{}            `json:"input"`
+	Encoding string                 `json:"encoding,omitempty"` // "base64" when input is binary data
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Tags     map[string]string      `json:"tags,omitempty"`
+
+	// OrderingKey runs tasks sharing it one at a time, in submission order
+	OrderingKey string `json:"ordering_key,omitempty"`
+}
+
+// TaskListResponse lists recent task results
+type TaskListResponse struct {
+	Tasks []*plugin.TaskResult `json:"tasks"`
+}
+
+// DeadLetterResponse lists tasks that failed every attempt
+type DeadLetterResponse struct {
+	Tasks []*plugin.DeadLetter `json:"tasks"`
+}
+
+// PublishRequest represents a request to publish a broker message
+type PublishRequest struct {
+	Topic    string                 `json:"topic"`
+	Payload  string                 `json:"payload"`
+	Encoding string                 `json:"encoding,omitempty"` // "base64" when payload is binary data
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TaskResponse represents a task submission response
+type TaskResponse struct {
+	Success bool   `json:"success"`
+	TaskID  string `json:"task_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// TaskEvent is the data of a server-sent task event
+type TaskEvent struct {
+	TaskID   string                 `json:"task_id"`
+	Payload  interface{}            `json:"payload,omitempty"`
+	Encoding string                 `json:"encoding,omitempty"` // "base64" when payload is binary data
+	Source   string                 `json:"source,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// settingsUpdater is the part of the daemon used to change plugin settings
+type settingsUpdater interface {
+	UpdatePluginSettings(name string, patch map[string]interface{}, persist bool) (map[string]interface{}, error)
+}
+
+// shutdownRequester is the part of the daemon used to stop it
+type shutdownRequester interface {
+	RequestShutdown(reason string)
+}
+
+// taskRunner is the part of the daemon used to submit and cancel tasks
+type taskRunner interface {
+	ExecuteTask(ctx context.Context, task *plugin.Task) error
+	CancelTask(ctx context.Context, taskID string) error
+}
+
+// taskLister is the part of the daemon that lists recent task results
+type taskLister interface {
+	TaskResults(tags ...string) []*plugin.TaskResult
+}
+
+// deadLetterLister is the part of the daemon that lists dead-lettered tasks
+type deadLetterLister interface {
+	DeadLetters() []*plugin.DeadLetter
+}
+
+// CommandInfo describes a command the caller can run
+type CommandInfo struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 // StatusResponse represents a status response
@@ -48,7 +165,12 @@ type StatusResponse struct {
 
 // NewRESTPlugin creates a new REST API plugin
 func NewRESTPlugin() *RESTPlugin {
-	return &RESTPlugin{}
+	return &RESTPlugin{clock: clock.Real()}
+}
+
+// SetClock sets the clock used to expire idempotency keys
+func (p *RESTPlugin) SetClock(c clock.Clock) {
+	p.clock = c
 }
 
 // Name returns the plugin name
@@ -56,6 +178,26 @@ func (p *RESTPlugin) Name() string {
 	return "rest"
 }
 
+// Labels returns the plugin's label groups
+func (p *RESTPlugin) Labels() []string {
+	return []string{"api"}
+}
+
+// SettingsSchema lists the settings the plugin reads
+func (p *RESTPlugin) SettingsSchema() map[string]plugin.SettingKind {
+	return map[string]plugin.SettingKind{
+		"port":                  plugin.SettingInt,
+		"host":                  plugin.SettingString,
+		"unix_socket":           plugin.SettingString,
+		"unix_socket_mode":      plugin.SettingString,
+		"auth_token":            plugin.SettingString,
+		"idempotency_ttl":       plugin.SettingInt,
+		"inline_artifact_bytes": plugin.SettingInt,
+		"enable_pprof":          plugin.SettingBool,
+		"command_timeout":       plugin.SettingInt,
+	}
+}
+
 // CheckRequirements validates plugin requirements
 func (p *RESTPlugin) CheckRequirements(ctx context.Context) error {
 	checker := plugin.NewRequirementChecker("rest")
@@ -76,42 +218,89 @@ func (p *RESTPlugin) Extensions() []plugin.Extension {
 }
 
 // Start initializes the REST API server
-func (p *RESTPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+func (p *RESTPlugin) Start(ctx context.Context, broker plugin.MessageBroker) (err error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	defer func() {
+		if err != nil {
+			p.running.Store(false)
+		}
+	}()
+
 	p.broker = broker
 	p.ctx = ctx
 	p.router = cmd.NewRouter()
 
 	// Get configuration
-	port := 8081
-	host := "0.0.0.0"
+	listen := listener.Config{Host: "0.0.0.0", Port: 8081}
+	idempotencyTTL := time.Hour
+	enablePprof := false
+	p.inlineArtifactBytes = defaultInlineArtifactBytes
 
 	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		p.router.SetTimeout(time.Duration(cfg.CommandTimeoutFor("rest")) * time.Second)
 		if portVal, ok := cfg.GetPluginSettingInt("rest", "port"); ok {
-			port = portVal
+			listen.Port = portVal
 		}
 		if hostVal, ok := cfg.GetPluginSettingString("rest", "host"); ok {
-			host = hostVal
+			listen.Host = hostVal
+		}
+		listen.UnixSocket, _ = cfg.GetPluginSettingString("rest", "unix_socket")
+		if modeVal, ok := cfg.GetPluginSettingString("rest", "unix_socket_mode"); ok {
+			mode, err := listener.ParseSocketMode(modeVal)
+			if err != nil {
+				return plugin.NewConfigError("rest", "unix_socket_mode", err.Error())
+			}
+			listen.SocketMode = mode
 		}
 		if token, ok := cfg.GetPluginSettingString("rest", "auth_token"); ok {
 			p.authToken = token
 		}
+		if ttl, ok := cfg.GetPluginSettingInt("rest", "idempotency_ttl"); ok {
+			idempotencyTTL = time.Duration(ttl) * time.Second
+		}
+		if n, ok := cfg.GetPluginSettingInt("rest", "inline_artifact_bytes"); ok {
+			p.inlineArtifactBytes = n
+		}
+		enablePprof, _ = cfg.GetPluginSettingBool("rest", "enable_pprof")
 	}
+	p.idempotency = newIdempotencyStore(idempotencyTTL, p.clock)
 
 	// Setup HTTP server
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/command", p.authMiddleware(p.handleCommand))
+	mux.HandleFunc("/api/command", p.authMiddleware(p.readyMiddleware(p.idempotencyMiddleware(p.handleCommand))))
+	mux.HandleFunc("/api/tasks", p.authMiddleware(p.readyMiddleware(p.idempotencyMiddleware(p.handleTasks))))
+	mux.HandleFunc("/api/tasks/deadletter", p.authMiddleware(p.handleDeadLetters))
+	mux.HandleFunc("/api/publish", p.authMiddleware(p.readyMiddleware(p.handlePublish)))
 	mux.HandleFunc("/api/status", p.authMiddleware(p.handleStatus))
+	mux.HandleFunc("/api/whoami", p.authMiddleware(p.handleWhoami))
+	mux.HandleFunc("/api/topics", p.authMiddleware(p.handleTopics))
+	mux.HandleFunc("/api/commands", p.authMiddleware(p.handleCommands))
+	mux.HandleFunc("/api/deps", p.authMiddleware(p.handleDeps))
+	mux.HandleFunc("/api/plugins/{name}/check", p.authMiddleware(p.handleCheck))
+	mux.HandleFunc("/api/config/plugins/{name}/settings", p.authMiddleware(p.readyMiddleware(p.handlePluginSettings)))
+	mux.HandleFunc("/api/shutdown", p.authMiddleware(p.handleShutdown))
+	mux.HandleFunc("/api/debug/runtime", p.authMiddleware(p.handleRuntime))
 	mux.HandleFunc("/api/health", p.handleHealth)
+	if enablePprof {
+		p.mountPprof(mux)
+	}
+
+	// Bind before returning, so an address in use fails the start
+	ln, err := listener.Listen(listen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", listen, err)
+	}
 
 	p.server = &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", host, port),
-		Handler: mux,
+		Handler: correlationMiddleware(mux),
 	}
 
 	// Start server
 	go func() {
-		log.Printf("[REST] Starting server on %s:%d", host, port)
-		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("[REST] Starting server on %s", listen)
+		if err := p.server.Serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Printf("[REST] Server error: %v", err)
 		}
 	}()
@@ -122,6 +311,10 @@ func (p *RESTPlugin) Start(ctx context.Context, broker plugin.MessageBroker) err
 
 // Stop shuts down the REST API server
 func (p *RESTPlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
+
 	if p.server != nil {
 		if err := p.server.Shutdown(ctx); err != nil {
 			log.Printf("[REST] Error shutting down server: %v", err)
@@ -132,6 +325,30 @@ func (p *RESTPlugin) Stop(ctx context.Context) error {
 	return nil
 }
 
+// correlationHeader carries the id used to trace a request across plugins
+const correlationHeader = "X-Correlation-ID"
+
+// correlationMiddleware makes sure every request has a correlation id, taking
+// the client's X-Correlation-ID or generating one, and echoes it in the response
+func correlationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationHeader)
+		if id == "" {
+			id = plugin.NewCorrelationID()
+			r.Header.Set(correlationHeader, id)
+		}
+		w.Header().Set(correlationHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestContext returns the plugin context carrying the request's principal
+// and correlation id
+func (p *RESTPlugin) requestContext(r *http.Request) context.Context {
+	ctx := plugin.WithPrincipal(p.ctx, p.principal(r))
+	return plugin.WithCorrelationID(ctx, r.Header.Get(correlationHeader))
+}
+
 // authMiddleware adds optional authentication
 func (p *RESTPlugin) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -150,6 +367,19 @@ func (p *RESTPlugin) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// readyMiddleware answers 503 until the daemon has finished starting
+func (p *RESTPlugin) readyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !plugin.IsReady(p.ctx) {
+			w.Header().Set("Retry-After", "1")
+			p.sendError(w, http.StatusServiceUnavailable, "Daemon is starting, try again shortly")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
 // handleCommand processes command requests
 func (p *RESTPlugin) handleCommand(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -164,10 +394,14 @@ func (p *RESTPlugin) handleCommand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[REST] Command request: %s %v", req.Command, req.Args)
+	// Execute command on behalf of the client address
+	ctx := p.requestContext(r)
+	if req.DryRun {
+		ctx = plugin.WithDryRun(ctx)
+	}
+	plugin.Logf(ctx, "[REST] Command request: %s %v", req.Command, req.Args)
 
-	// Execute command
-	result, err := p.router.Route(p.ctx, req.Command)
+	result, err := p.router.Route(ctx, req.Command)
 	if err != nil {
 		p.sendJSON(w, CommandResponse{
 			Success: false,
@@ -181,12 +415,19 @@ func (p *RESTPlugin) handleCommand(w http.ResponseWriter, r *http.Request) {
 		Success: true,
 	}
 
+	if result != nil && result.Artifact != nil && len(result.Artifact.Data) > p.inlineArtifactBytes {
+		p.sendArtifact(w, result.Artifact)
+		return
+	}
+
 	if result != nil {
 		response.Output = result.Output
+		response.Artifact = inlineArtifact(result.Artifact)
 		response.Data = result.Data
+		response.DryRun = result.DryRun
 
 		// Broadcast if requested
-		if result.Broadcast {
+		if result.Broadcast && !result.DryRun {
 			p.broker.Publish(p.ctx, plugin.Message{
 				Topic:   "notification",
 				Payload: result.Output,
@@ -198,6 +439,265 @@ func (p *RESTPlugin) handleCommand(w http.ResponseWriter, r *http.Request) {
 	p.sendJSON(w, response)
 }
 
+// sendArtifact serves an artifact as a file download
+func (p *RESTPlugin) sendArtifact(w http.ResponseWriter, artifact *plugin.Artifact) {
+	filename := artifact.Filename
+	if filename == "" {
+		filename = "artifact"
+	}
+	w.Header().Set("Content-Type", artifactContentType(artifact))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Content-Length", strconv.Itoa(len(artifact.Data)))
+	if _, err := w.Write(artifact.Data); err != nil {
+		log.Printf("[REST] Error writing artifact %s: %v", filename, err)
+	}
+}
+
+// inlineArtifact converts an artifact for embedding in a JSON response
+func inlineArtifact(artifact *plugin.Artifact) *ArtifactResponse {
+	if artifact == nil {
+		return nil
+	}
+	contentType := artifactContentType(artifact)
+	response := &ArtifactResponse{
+		Filename:    artifact.Filename,
+		ContentType: contentType,
+	}
+	if strings.HasPrefix(contentType, "text/") && utf8.Valid(artifact.Data) {
+		response.Data = string(artifact.Data)
+	} else {
+		response.Data, response.Encoding = plugin.EncodePayload(artifact.Data)
+	}
+	return response
+}
+
+// artifactContentType returns an artifact's MIME type
+func artifactContentType(artifact *plugin.Artifact) string {
+	if artifact.ContentType == "" {
+		return "application/octet-stream"
+	}
+	return artifact.ContentType
+}
+
+// handleTasks submits a task for execution
+// With ?stream=true the response becomes a server-sent event stream of the
+// task's progress that ends with its final result
+func (p *RESTPlugin) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		p.listTasks(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Parse request
+	var req TaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		p.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Type == "" {
+		p.sendError(w, http.StatusBadRequest, "Task type required")
+		return
+	}
+	for key := range req.Tags {
+		if key == "" || strings.Contains(key, ":") {
+			p.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tag key %q", key))
+			return
+		}
+	}
+
+	runner, ok := p.ctx.Value("daemon").(taskRunner)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Task execution not available")
+		return
+	}
+
+	// Binary input arrives base64-encoded and is passed to the executor as []byte
+	input := req.Input
+	if req.Encoding != "" {
+		text, ok := req.Input.(string)
+		if !ok {
+			p.sendError(w, http.StatusBadRequest, "Encoded input must be a string")
+			return
+		}
+		decoded, err := plugin.DecodePayload(text, req.Encoding)
+		if err != nil {
+			p.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		input = decoded
+	}
+
+	task := &plugin.Task{
+		ID:      fmt.Sprintf("rest-%d", time.Now().UnixNano()),
+		Type:    req.Type,
+		Input:   input,
+		Options:     req.Options,
+		Tags:        req.Tags,
+		OrderingKey: req.OrderingKey,
+	}
+
+	ctx := p.requestContext(r)
+	task.CorrelationID = plugin.CorrelationID(ctx)
+	plugin.Logf(ctx, "[REST] Task request: %s (ID: %s)", task.Type, task.ID)
+
+	if r.URL.Query().Get("stream") == "true" {
+		p.streamTask(w, r, runner, task)
+		return
+	}
+
+	if err := runner.ExecuteTask(ctx, task); err != nil {
+		p.sendJSON(w, TaskResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	p.sendJSON(w, TaskResponse{
+		Success: true,
+		TaskID:  task.ID,
+	})
+}
+
+// listTasks lists recent task results, newest first, keeping those with
+// every ?tag=key:value given
+func (p *RESTPlugin) listTasks(w http.ResponseWriter, r *http.Request) {
+	lister, ok := p.ctx.Value("daemon").(taskLister)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Task results not available")
+		return
+	}
+
+	tags := r.URL.Query()["tag"]
+	for _, tag := range tags {
+		if _, _, ok := plugin.ParseTag(tag); !ok {
+			p.sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid tag %q (want key:value)", tag))
+			return
+		}
+	}
+
+	tasks := lister.TaskResults(tags...)
+	if tasks == nil {
+		tasks = []*plugin.TaskResult{}
+	}
+	p.sendJSON(w, TaskListResponse{Tasks: tasks})
+}
+
+// handleDeadLetters lists the tasks that failed every attempt, newest first
+// Admin only, since the tasks carry their full input and options
+func (p *RESTPlugin) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if p.principal(r).Role != plugin.RoleAdmin {
+		p.sendError(w, http.StatusForbidden, "Dead letters require the admin role")
+		return
+	}
+
+	lister, ok := p.ctx.Value("daemon").(deadLetterLister)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Dead letters not available")
+		return
+	}
+	p.sendJSON(w, DeadLetterResponse{Tasks: lister.DeadLetters()})
+}
+
+// streamTask submits a task and streams its events as server-sent events
+func (p *RESTPlugin) streamTask(w http.ResponseWriter, r *http.Request, runner taskRunner, task *plugin.Task) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		p.sendError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// Subscribe before submitting so no early events are missed
+	subID := "rest-stream-" + task.ID
+	events := p.broker.Subscribe(subID, 100, "task.progress", "response", "notification")
+	defer p.broker.Unsubscribe(subID)
+
+	if err := runner.ExecuteTask(p.requestContext(r), task); err != nil {
+		p.sendJSON(w, TaskResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	p.writeEvent(w, "task.created", TaskEvent{TaskID: task.ID})
+	flusher.Flush()
+
+	cancelOnDisconnect := r.URL.Query().Get("cancel_on_disconnect") == "true"
+
+	for {
+		select {
+		case msg, ok := <-events:
+			if !ok {
+				return
+			}
+			if msg.Metadata["task_id"] != task.ID {
+				continue
+			}
+
+			// Task failures arrive as notifications carrying an error
+			event := msg.Topic
+			if msg.Topic == "notification" {
+				if _, failed := msg.Metadata["error"]; !failed {
+					continue
+				}
+				event = "error"
+			}
+
+			// encoding/json base64-encodes []byte payloads
+			var encoding string
+			if _, binary := msg.Payload.([]byte); binary {
+				encoding = plugin.EncodingBase64
+			}
+
+			p.writeEvent(w, event, TaskEvent{
+				TaskID:   task.ID,
+				Payload:  msg.Payload,
+				Encoding: encoding,
+				Source:   msg.Source,
+				Metadata: msg.Metadata,
+			})
+			flusher.Flush()
+
+			if event == "response" || event == "error" {
+				return
+			}
+
+		case <-r.Context().Done():
+			plugin.Logf(p.requestContext(r), "[REST] Stream client disconnected (task: %s)", task.ID)
+			if cancelOnDisconnect {
+				if err := runner.CancelTask(p.ctx, task.ID); err != nil {
+					log.Printf("[REST] Error cancelling task %s: %v", task.ID, err)
+				}
+			}
+			return
+		}
+	}
+}
+
+// writeEvent writes a single server-sent event
+func (p *RESTPlugin) writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[REST] Error encoding event: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
 // handleStatus returns daemon status
 func (p *RESTPlugin) handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -221,6 +721,253 @@ func (p *RESTPlugin) handleStatus(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handlePublish publishes a message to the broker with source "rest"
+// The broker's publish authorizer decides which topics are allowed
+func (p *RESTPlugin) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req PublishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		p.sendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Topic == "" {
+		p.sendError(w, http.StatusBadRequest, "Topic is required")
+		return
+	}
+
+	payload, err := plugin.DecodePayload(req.Payload, req.Encoding)
+	if err != nil {
+		p.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msg := plugin.Message{
+		Topic:    req.Topic,
+		Payload:  payload,
+		Source:   "rest",
+		Metadata: req.Metadata,
+	}
+	if req.Encoding != "" {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]interface{})
+		}
+		msg.Metadata[plugin.MetadataEncoding] = req.Encoding
+	}
+
+	ctx := p.requestContext(r)
+	plugin.Logf(ctx, "[REST] Publish request: %s", req.Topic)
+	if err := p.broker.Publish(ctx, msg); err != nil {
+		if errors.Is(err, plugin.ErrPublishUnauthorized) {
+			p.sendError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, plugin.ErrMessageLoop) {
+			p.sendError(w, http.StatusConflict, err.Error())
+			return
+		}
+		p.sendError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	p.sendJSON(w, map[string]bool{"success": true})
+}
+
+// handleTopics returns the broker's subscriber count per topic
+func (p *RESTPlugin) handleTopics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	daemon, ok := p.ctx.Value("daemon").(cmd.TopicLister)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Topics not available")
+		return
+	}
+
+	p.sendJSON(w, daemon.TopicSubscriberCounts())
+}
+
+// handleDeps returns the plugin dependency graph and start order
+func (p *RESTPlugin) handleDeps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	daemon, ok := p.ctx.Value("daemon").(cmd.DependencyInspector)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Dependencies not available")
+		return
+	}
+
+	p.sendJSON(w, daemon.DependencyGraph())
+}
+
+// handleCheck runs a plugin's requirement checks without starting it
+func (p *RESTPlugin) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if p.principal(r).Role != plugin.RoleAdmin {
+		p.sendError(w, http.StatusForbidden, "Checking requirements requires the admin role")
+		return
+	}
+
+	daemon, ok := p.ctx.Value("daemon").(cmd.RequirementInspector)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Requirement checks not available")
+		return
+	}
+
+	report, err := daemon.CheckPlugin(r.PathValue("name"))
+	if err != nil {
+		p.sendError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	p.sendJSON(w, report)
+}
+
+// handleCommands lists the commands the caller's role can run in the current mode
+func (p *RESTPlugin) handleCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	mode, ok := p.ctx.Value("mode").(plugin.Mode)
+	if !ok {
+		mode = plugin.ModeDaemon
+	}
+
+	commands := cmd.GetRegistry().ListCommands(mode, p.principal(r).Role)
+	infos := make([]CommandInfo, 0, len(commands))
+	for _, c := range commands {
+		infos = append(infos, CommandInfo{
+			Name:        c.Name,
+			Usage:       c.Usage,
+			Description: c.Description,
+		})
+	}
+
+	p.sendJSON(w, infos)
+}
+
+// handlePluginSettings merges a JSON object into a plugin's settings and
+// applies them; ?persist=true also saves them to the config file. Admin only
+func (p *RESTPlugin) handlePluginSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if p.principal(r).Role != plugin.RoleAdmin {
+		p.sendError(w, http.StatusForbidden, "Changing settings requires the admin role")
+		return
+	}
+
+	name := r.PathValue("name")
+	if name == "rest" {
+		// Restarting would wait on this very request
+		p.sendError(w, http.StatusBadRequest, "The REST plugin's settings can't be changed over REST")
+		return
+	}
+
+	var patch map[string]interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil || patch == nil {
+		p.sendError(w, http.StatusBadRequest, "Request body must be a JSON object")
+		return
+	}
+
+	daemon, ok := p.ctx.Value("daemon").(settingsUpdater)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Settings updates not available")
+		return
+	}
+
+	persist := r.URL.Query().Get("persist") == "true"
+	plugin.Logf(p.requestContext(r), "[REST] Settings update for plugin %s (persist: %v)", name, persist)
+	if settings, err := daemon.UpdatePluginSettings(name, patch, persist); err != nil {
+		code := http.StatusUnprocessableEntity
+		switch {
+		case errors.Is(err, plugin.ErrInvalidSettings):
+			code = http.StatusBadRequest
+		case settings != nil:
+			code = http.StatusInternalServerError // Applied, but saving failed
+		}
+		p.sendError(w, code, err.Error())
+		return
+	}
+
+	p.sendJSON(w, map[string]interface{}{"success": true, "plugin": name})
+}
+
+// handleShutdown acknowledges the request, then stops the daemon the same
+// way SIGTERM does
+func (p *RESTPlugin) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	principal := p.principal(r)
+	if principal.Role != plugin.RoleAdmin {
+		p.sendError(w, http.StatusForbidden, "Shutting down requires the admin role")
+		return
+	}
+	if r.URL.Query().Get("confirm") != "true" {
+		p.sendError(w, http.StatusBadRequest, "Add ?confirm=true to shut down the daemon")
+		return
+	}
+
+	daemon, ok := p.ctx.Value("daemon").(shutdownRequester)
+	if !ok {
+		p.sendError(w, http.StatusServiceUnavailable, "Shutdown not available")
+		return
+	}
+
+	// Send the acknowledgement before the server starts shutting down
+	w.Header().Set("Connection", "close")
+	p.sendJSON(w, map[string]interface{}{"success": true, "message": "Daemon shutting down"})
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	plugin.Logf(p.requestContext(r), "[REST] Shutdown requested by %s", principal.Source)
+	daemon.RequestShutdown(fmt.Sprintf("POST /api/shutdown from %s", principal.Source))
+}
+
+// handleWhoami returns the principal the server sees for the request
+func (p *RESTPlugin) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		p.sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	p.sendJSON(w, p.principal(r))
+}
+
+// principal identifies the client of a request that passed authMiddleware
+// Clients that presented the configured auth token are admins
+func (p *RESTPlugin) principal(r *http.Request) plugin.Principal {
+	role := plugin.RoleUser
+	if p.authToken != "" {
+		role = plugin.RoleAdmin
+	}
+
+	ip := clientIP(r)
+	return plugin.Principal{
+		Source: "rest:" + ip,
+		UserID: ip,
+		Role:   role,
+	}
+}
+
 // handleHealth returns health check
 func (p *RESTPlugin) handleHealth(w http.ResponseWriter, r *http.Request) {
 	p.sendJSON(w, map[string]string{
@@ -244,3 +991,12 @@ func (p *RESTPlugin) sendError(w http.ResponseWriter, code int, message string)
 		"error": message,
 	})
 }
+
+// clientIP returns the remote IP of a request without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
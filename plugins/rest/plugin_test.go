@@ -0,0 +1,251 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestStopTwice(t *testing.T) {
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("rest", "unix_socket", filepath.Join(t.TempDir(), "rest.sock")),
+	)
+
+	p := NewRESTPlugin()
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := p.Start(ctx, broker); err != nil {
+		t.Errorf("second Start: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	if err := NewRESTPlugin().Stop(context.Background()); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
+
+func TestWhoamiReportsRequestPrincipal(t *testing.T) {
+	for _, tt := range []struct {
+		token string
+		role  plugin.Role
+	}{
+		{"", plugin.RoleUser},
+		{"secret", plugin.RoleAdmin},
+	} {
+		p := NewRESTPlugin()
+		p.authToken = tt.token
+
+		r := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+		r.RemoteAddr = "10.0.0.7:5000"
+		w := httptest.NewRecorder()
+		p.handleWhoami(w, r)
+
+		var got plugin.Principal
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		want := plugin.Principal{Source: "rest:10.0.0.7", UserID: "10.0.0.7", Role: tt.role}
+		if got != want {
+			t.Errorf("token %q: principal = %+v, want %+v", tt.token, got, want)
+		}
+	}
+}
+
+func TestTaskCarriesRequestCorrelationID(t *testing.T) {
+	d := testutil.NewDaemon()
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(d))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(`{"type":"echo","input":"hi"}`))
+	r.Header.Set(correlationHeader, "req-42")
+	p.handleTasks(httptest.NewRecorder(), r)
+
+	tasks := d.Tasks()
+	if len(tasks) != 1 || tasks[0].CorrelationID != "req-42" {
+		t.Errorf("tasks = %+v, want one carrying correlation id req-42", tasks)
+	}
+}
+
+func TestCommandListFilteredByRole(t *testing.T) {
+	for _, tt := range []struct {
+		token      string
+		wantBroker bool
+	}{
+		{"", false},
+		{"secret", true},
+	} {
+		p := NewRESTPlugin()
+		p.ctx = testutil.NewContext()
+		p.authToken = tt.token
+
+		w := httptest.NewRecorder()
+		p.handleCommands(w, httptest.NewRequest(http.MethodGet, "/api/commands", nil))
+
+		var infos []CommandInfo
+		if err := json.NewDecoder(w.Body).Decode(&infos); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		listed := make(map[string]bool)
+		for _, info := range infos {
+			listed[info.Name] = true
+		}
+		if !listed["whoami"] {
+			t.Errorf("token %q: whoami not listed", tt.token)
+		}
+		if listed["broker"] != tt.wantBroker {
+			t.Errorf("token %q: admin-only /broker listed = %v, want %v", tt.token, listed["broker"], tt.wantBroker)
+		}
+	}
+}
+
+// dependencyInspector serves a fixed dependency graph
+type dependencyInspector plugin.DependencyGraph
+
+func (i dependencyInspector) DependencyGraph() plugin.DependencyGraph {
+	return plugin.DependencyGraph(i)
+}
+
+func TestDepsReturnsGraph(t *testing.T) {
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(dependencyInspector{
+		Plugins: []plugin.PluginDependencies{
+			{Name: "app", DependsOn: []string{"store"}},
+			{Name: "store"},
+			{Name: "orphan", DependsOn: []string{"ghost"}, Problem: "missing dependency: ghost"},
+		},
+		StartOrder: []string{"store", "app"},
+	}))
+
+	w := httptest.NewRecorder()
+	p.handleDeps(w, httptest.NewRequest(http.MethodGet, "/api/deps", nil))
+
+	var graph plugin.DependencyGraph
+	if err := json.NewDecoder(w.Body).Decode(&graph); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got := strings.Join(graph.StartOrder, ","); got != "store,app" {
+		t.Errorf("start_order = %s, want store,app", got)
+	}
+	if len(graph.Plugins) != 3 || graph.Plugins[2].Problem != "missing dependency: ghost" {
+		t.Errorf("plugins = %+v, want orphan's missing dependency reported", graph.Plugins)
+	}
+}
+
+// requirementInspector serves a fixed requirement report for one plugin
+type requirementInspector plugin.RequirementReport
+
+func (i requirementInspector) CheckPlugin(name string) (*plugin.RequirementReport, error) {
+	if name != i.Plugin {
+		return nil, fmt.Errorf("unknown plugin: %s", name)
+	}
+	report := plugin.RequirementReport(i)
+	return &report, nil
+}
+
+func TestCheckReturnsReport(t *testing.T) {
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(requirementInspector{
+		Plugin:  "mixed",
+		Results: []plugin.RequirementResult{{Name: "token", Required: true, Error: "not set"}},
+	}))
+	p.authToken = "secret"
+
+	check := func(name string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/plugins/"+name+"/check", nil)
+		r.SetPathValue("name", name)
+		w := httptest.NewRecorder()
+		p.handleCheck(w, r)
+		return w
+	}
+
+	w := check("mixed")
+	var report plugin.RequirementReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if report.Passed || len(report.Results) != 1 || report.Results[0].Error != "not set" {
+		t.Errorf("report = %+v, want the failed token requirement", report)
+	}
+	if w := check("nonexistent"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown plugin = %d, want 404", w.Code)
+	}
+}
+
+// taggedLister records the tags it is asked for and returns results
+type taggedLister struct {
+	asked   [][]string
+	results []*plugin.TaskResult
+}
+
+func (l *taggedLister) TaskResults(tags ...string) []*plugin.TaskResult {
+	l.asked = append(l.asked, tags)
+	return l.results
+}
+
+func TestListTasksFiltersByTag(t *testing.T) {
+	lister := &taggedLister{results: []*plugin.TaskResult{{ID: "t1", Tags: map[string]string{"project": "x"}}}}
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(lister))
+
+	r := httptest.NewRequest(http.MethodGet, "/api/tasks?tag=project:x&tag=user:ann", nil)
+	w := httptest.NewRecorder()
+	p.handleTasks(w, r)
+
+	var resp TaskListResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Tasks) != 1 || resp.Tasks[0].Tags["project"] != "x" {
+		t.Errorf("tasks = %+v, want t1 with its tags", resp.Tasks)
+	}
+	if len(lister.asked) != 1 || strings.Join(lister.asked[0], ",") != "project:x,user:ann" {
+		t.Errorf("asked for tags %v, want [project:x user:ann]", lister.asked)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/tasks?tag=project", nil)
+	w = httptest.NewRecorder()
+	p.handleTasks(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("malformed tag = %d, want 400", w.Code)
+	}
+}
+
+func TestSubmittedTaskCarriesTags(t *testing.T) {
+	daemon := testutil.NewDaemon()
+	p := NewRESTPlugin()
+	p.ctx = testutil.NewContext(testutil.WithDaemon(daemon))
+
+	submit := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/api/tasks", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		p.handleTasks(w, r)
+		return w.Code
+	}
+
+	if code := submit(`{"type":"work","tags":{"project":"x"}}`); code != http.StatusOK {
+		t.Fatalf("submit = %d, want 200", code)
+	}
+	if tasks := daemon.Tasks(); len(tasks) != 1 || tasks[0].Tags["project"] != "x" {
+		t.Errorf("submitted tasks = %v, want one tagged project:x", tasks)
+	}
+	if code := submit(`{"type":"work","tags":{"a:b":"x"}}`); code != http.StatusBadRequest {
+		t.Errorf("tag key with a colon = %d, want 400", code)
+	}
+}
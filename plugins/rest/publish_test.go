@@ -0,0 +1,38 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bicycle/daemon"
+	"bicycle/internal/testutil"
+)
+
+func TestPublishRefusedForReservedTopic(t *testing.T) {
+	broker := daemon.NewBroker()
+	broker.SetPublishAuthorizer(daemon.ReservedTopicsAuthorizer(map[string][]string{"daemon.event": {"daemon"}}))
+	events := broker.Subscribe("events", 10, "daemon.event", "chat")
+
+	p := NewRESTPlugin()
+	p.broker = broker
+	p.ctx = testutil.NewContext(testutil.WithBroker(broker))
+
+	publish := func(topic string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/publish", strings.NewReader(`{"topic":"`+topic+`","payload":"hi"}`))
+		w := httptest.NewRecorder()
+		p.handlePublish(w, r)
+		return w
+	}
+
+	if w := publish("daemon.event"); w.Code != http.StatusForbidden {
+		t.Errorf("publishing to daemon.event = %d %s, want 403", w.Code, w.Body)
+	}
+	if w := publish("chat"); w.Code != http.StatusOK {
+		t.Errorf("publishing to chat = %d %s, want 200", w.Code, w.Body)
+	}
+	if len(events) != 1 {
+		t.Errorf("%d messages delivered, want only the chat message", len(events))
+	}
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bicycle/internal/testutil"
+)
+
+func TestShutdownEndpoint(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		token    string
+		query    string
+		wantCode int
+	}{
+		{"non-admin", "", "?confirm=true", http.StatusForbidden},
+		{"unconfirmed", "secret", "", http.StatusBadRequest},
+		{"confirmed", "secret", "?confirm=true", http.StatusOK},
+	} {
+		d := testutil.NewDaemon()
+		p := NewRESTPlugin()
+		p.ctx = testutil.NewContext(testutil.WithDaemon(d))
+		p.authToken = tt.token
+
+		w := httptest.NewRecorder()
+		p.handleShutdown(w, httptest.NewRequest(http.MethodPost, "/api/shutdown"+tt.query, nil))
+
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: status = %d %s, want %d", tt.name, w.Code, w.Body, tt.wantCode)
+			continue
+		}
+		reasons := d.ShutdownReasons()
+		if tt.wantCode != http.StatusOK {
+			if len(reasons) != 0 {
+				t.Errorf("%s: daemon asked to shut down: %q", tt.name, reasons)
+			}
+			continue
+		}
+
+		var body struct {
+			Success bool `json:"success"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || !body.Success {
+			t.Errorf("%s: response = %+v, %v, want success", tt.name, body, err)
+		}
+		if !w.Flushed {
+			t.Errorf("%s: acknowledgement not flushed before shutdown", tt.name)
+		}
+		if len(reasons) != 1 {
+			t.Errorf("%s: shutdown reasons = %q, want one", tt.name, reasons)
+		}
+	}
+}
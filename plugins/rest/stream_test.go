@@ -0,0 +1,179 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// progressRunner is a fake daemon whose tasks report progress at 50% and
+// 100% and then respond, unless hang is set
+type progressRunner struct {
+	broker plugin.MessageBroker
+	hang   bool
+
+	mu        sync.Mutex
+	submitted chan string
+	cancelled []string
+}
+
+func newProgressRunner(broker plugin.MessageBroker) *progressRunner {
+	return &progressRunner{broker: broker, submitted: make(chan string, 1)}
+}
+
+func (r *progressRunner) ExecuteTask(ctx context.Context, task *plugin.Task) error {
+	r.submitted <- task.ID
+	if r.hang {
+		return nil
+	}
+
+	// Another task's progress must not reach the stream
+	r.broker.Publish(ctx, plugin.Message{Topic: "task.progress", Metadata: map[string]interface{}{"task_id": "other"}})
+	for _, progress := range []int{50, 100} {
+		r.broker.Publish(ctx, plugin.Message{
+			Topic:    "task.progress",
+			Source:   "fake",
+			Metadata: map[string]interface{}{"task_id": task.ID, "progress": progress},
+		})
+	}
+	r.broker.Publish(ctx, plugin.Message{
+		Topic:    "response",
+		Source:   "fake",
+		Payload:  "done",
+		Metadata: map[string]interface{}{"task_id": task.ID},
+	})
+	return nil
+}
+
+func (r *progressRunner) CancelTask(ctx context.Context, taskID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancelled = append(r.cancelled, taskID)
+	return nil
+}
+
+func (r *progressRunner) Cancelled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.cancelled...)
+}
+
+// newStreamPlugin returns a plugin wired to broker and runner without
+// starting its server
+func newStreamPlugin(broker *testutil.Broker, runner *progressRunner) *RESTPlugin {
+	p := NewRESTPlugin()
+	p.broker = broker
+	p.ctx = testutil.NewContext(testutil.WithBroker(broker), testutil.WithDaemon(runner))
+	return p
+}
+
+// sseEvent is one server-sent event read back from a response
+type sseEvent struct {
+	name string
+	data TaskEvent
+}
+
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, sseEvent{name: name})
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok && len(events) > 0 {
+			if err := json.Unmarshal([]byte(data), &events[len(events)-1].data); err != nil {
+				t.Fatalf("decoding event data %q: %v", data, err)
+			}
+		}
+	}
+	return events
+}
+
+func TestStreamingTaskSendsProgressThenResponse(t *testing.T) {
+	broker := testutil.NewBroker()
+	runner := newProgressRunner(broker)
+	p := newStreamPlugin(broker, runner)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/tasks?stream=true", strings.NewReader(`{"type":"echo","input":"hi"}`))
+	w := httptest.NewRecorder()
+	p.handleTasks(w, r)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream (body %s)", ct, w.Body)
+	}
+	taskID := <-runner.submitted
+
+	events := readEvents(t, w.Body.String())
+	want := []string{"task.created", "task.progress", "task.progress", "response"}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %v, want %v", len(events), events, want)
+	}
+	for i, event := range events {
+		if event.name != want[i] {
+			t.Errorf("event %d = %s, want %s", i, event.name, want[i])
+		}
+		if event.data.TaskID != taskID {
+			t.Errorf("event %d task id = %q, want %q", i, event.data.TaskID, taskID)
+		}
+	}
+	for i, progress := range []float64{50, 100} {
+		if got := events[i+1].data.Metadata["progress"]; got != progress {
+			t.Errorf("progress event %d = %v, want %v", i, got, progress)
+		}
+	}
+	if got := events[3].data.Payload; got != "done" {
+		t.Errorf("response payload = %v, want done", got)
+	}
+	if broker.Subscribed("rest-stream-" + taskID) {
+		t.Error("stream still subscribed after the response")
+	}
+}
+
+func TestStreamingTaskCancelledOnDisconnect(t *testing.T) {
+	for _, cancelOnDisconnect := range []bool{true, false} {
+		broker := testutil.NewBroker()
+		runner := newProgressRunner(broker)
+		runner.hang = true
+		p := newStreamPlugin(broker, runner)
+
+		target := "/api/tasks?stream=true"
+		if cancelOnDisconnect {
+			target += "&cancel_on_disconnect=true"
+		}
+		reqCtx, disconnect := context.WithCancel(context.Background())
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"type":"echo"}`)).WithContext(reqCtx)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			p.handleTasks(httptest.NewRecorder(), r)
+		}()
+		taskID := <-runner.submitted
+		disconnect()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream did not end after the client disconnected")
+		}
+
+		cancelled := runner.Cancelled()
+		if cancelOnDisconnect && (len(cancelled) != 1 || cancelled[0] != taskID) {
+			t.Errorf("cancelled %v, want [%s]", cancelled, taskID)
+		}
+		if !cancelOnDisconnect && len(cancelled) != 0 {
+			t.Errorf("cancelled %v without cancel_on_disconnect", cancelled)
+		}
+	}
+}
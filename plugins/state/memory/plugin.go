@@ -32,6 +32,11 @@ func (p *MemoryStatePlugin) Name() string {
 	return "state_memory"
 }
 
+// Labels returns the plugin's label groups
+func (p *MemoryStatePlugin) Labels() []string {
+	return []string{"state"}
+}
+
 // CheckRequirements validates plugin requirements
 func (p *MemoryStatePlugin) CheckRequirements(ctx context.Context) error {
 	// Memory state has no external requirements
@@ -46,6 +51,8 @@ func (p *MemoryStatePlugin) Extensions() []plugin.Extension {
 }
 
 // Start initializes the plugin
+// Start and Stop hold no resources and the state outlives them, so unlike the
+// transports they need no guard against repeated or concurrent calls
 func (p *MemoryStatePlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
 	log.Printf("[MemoryState] Started")
 	return nil
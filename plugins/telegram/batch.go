@@ -0,0 +1,121 @@
+package telegram
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// batchSeparator joins the messages coalesced into one send
+const batchSeparator = "\n\n"
+
+// batcher coalesces messages sent to the same chat within a short window
+// into a single send, saving API calls when notifications arrive in bursts
+type batcher struct {
+	window time.Duration
+	limit  int // Most characters in one coalesced send
+
+	// send delivers text to a chat; failed reports each message in a failed send
+	send   func(chatID int64, text string) error
+	failed func(msg plugin.Message, err error)
+
+	mu      sync.Mutex
+	pending map[int64]*pendingBatch
+}
+
+// pendingBatch is the text queued for one chat
+type pendingBatch struct {
+	texts []string
+	size  int
+	msgs  []plugin.Message
+	timer *time.Timer
+}
+
+// newBatcher creates a batcher; a zero window sends every message immediately
+func newBatcher(window time.Duration, limit int, send func(int64, string) error, failed func(plugin.Message, error)) *batcher {
+	return &batcher{
+		window:  window,
+		limit:   limit,
+		send:    send,
+		failed:  failed,
+		pending: make(map[int64]*pendingBatch),
+	}
+}
+
+// add queues text for chatID, sending the chat's queue when the window
+// expires or the text would not fit in one message
+func (b *batcher) add(chatID int64, text string, msg plugin.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := len([]rune(text))
+	if b.window <= 0 || size > b.limit {
+		b.flushLocked(chatID)
+		b.deliverLocked(chatID, text, []plugin.Message{msg})
+		return
+	}
+
+	batch := b.pending[chatID]
+	if batch != nil && batch.size+len([]rune(batchSeparator))+size > b.limit {
+		b.flushLocked(chatID)
+		batch = nil
+	}
+	if batch == nil {
+		batch = &pendingBatch{}
+		batch.timer = time.AfterFunc(b.window, func() { b.flush(chatID) })
+		b.pending[chatID] = batch
+	} else {
+		batch.size += len([]rune(batchSeparator))
+	}
+	batch.texts = append(batch.texts, text)
+	batch.size += size
+	batch.msgs = append(batch.msgs, msg)
+}
+
+// sendNow sends text to chatID immediately, after anything queued for it
+func (b *batcher) sendNow(chatID int64, text string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked(chatID)
+	return b.send(chatID, text)
+}
+
+// flush sends the queue for chatID
+func (b *batcher) flush(chatID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked(chatID)
+}
+
+// flushAll sends the queues of every chat
+func (b *batcher) flushAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for chatID := range b.pending {
+		b.flushLocked(chatID)
+	}
+}
+
+// flushLocked sends the queue for chatID; the caller holds b.mu, which keeps
+// sends to a chat in order
+func (b *batcher) flushLocked(chatID int64) {
+	batch := b.pending[chatID]
+	if batch == nil {
+		return
+	}
+	delete(b.pending, chatID)
+	batch.timer.Stop()
+	b.deliverLocked(chatID, strings.Join(batch.texts, batchSeparator), batch.msgs)
+}
+
+// deliverLocked sends text and reports a failure for each message it carries
+func (b *batcher) deliverLocked(chatID int64, text string, msgs []plugin.Message) {
+	if err := b.send(chatID, text); err != nil {
+		for _, msg := range msgs {
+			b.failed(msg, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package telegram
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"bicycle/plugin"
+)
+
+// sendLog records the sends a batcher makes
+type sendLog struct {
+	mu    sync.Mutex
+	texts []string
+	sent  chan struct{}
+	err   error
+}
+
+func newSendLog() *sendLog {
+	return &sendLog{sent: make(chan struct{}, 10)}
+}
+
+func (l *sendLog) send(chatID int64, text string) error {
+	l.mu.Lock()
+	l.texts = append(l.texts, text)
+	l.mu.Unlock()
+	l.sent <- struct{}{}
+	return l.err
+}
+
+func (l *sendLog) sends() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.texts...)
+}
+
+func ignoreFailure(plugin.Message, error) {}
+
+func TestBurstCoalescedIntoOneSend(t *testing.T) {
+	log := newSendLog()
+	b := newBatcher(20*time.Millisecond, 4096, log.send, ignoreFailure)
+
+	for _, text := range []string{"Started", "Processing... 50%", "Processing... 100%"} {
+		b.add(1, text, plugin.Message{})
+	}
+
+	select {
+	case <-log.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch was not sent after its window")
+	}
+	if got := log.sends(); len(got) != 1 || got[0] != "Started\n\nProcessing... 50%\n\nProcessing... 100%" {
+		t.Errorf("sends = %q, want one coalesced send", got)
+	}
+}
+
+func TestFinalMessageFlushesImmediately(t *testing.T) {
+	log := newSendLog()
+	b := newBatcher(time.Hour, 4096, log.send, ignoreFailure)
+
+	b.add(1, "one", plugin.Message{})
+	b.add(1, "two", plugin.Message{})
+	b.add(2, "other chat", plugin.Message{})
+	if got := log.sends(); len(got) != 0 {
+		t.Fatalf("sent %q inside the window", got)
+	}
+
+	if err := b.sendNow(1, "answer"); err != nil {
+		t.Fatalf("sendNow: %v", err)
+	}
+	if got := log.sends(); len(got) != 2 || got[0] != "one\n\ntwo" || got[1] != "answer" {
+		t.Errorf("sends = %q, want the queued batch then the answer", got)
+	}
+
+	b.flushAll()
+	if got := log.sends(); len(got) != 3 || got[2] != "other chat" {
+		t.Errorf("sends after flushAll = %q, want the other chat's queue", got)
+	}
+}
+
+func TestBatchRespectsSizeLimit(t *testing.T) {
+	log := newSendLog()
+	b := newBatcher(time.Hour, 10, log.send, ignoreFailure)
+
+	// "aaaa\n\nbbbb" is exactly 10 characters; "cccc" would overflow it
+	for _, text := range []string{"aaaa", "bbbb", "cccc"} {
+		b.add(1, text, plugin.Message{})
+	}
+	b.add(1, "longer than ten", plugin.Message{})
+
+	want := []string{"aaaa\n\nbbbb", "cccc", "longer than ten"}
+	got := log.sends()
+	if len(got) != len(want) {
+		t.Fatalf("sends = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("send %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFailedBatchReportsEachMessage(t *testing.T) {
+	log := newSendLog()
+	log.err = errors.New("chat not found")
+	var failed []string
+	b := newBatcher(time.Hour, 4096, log.send, func(msg plugin.Message, err error) {
+		failed = append(failed, msg.Topic)
+	})
+
+	b.add(1, "one", plugin.Message{Topic: "first"})
+	b.add(1, "two", plugin.Message{Topic: "second"})
+	b.flush(1)
+
+	if len(failed) != 2 || failed[0] != "first" || failed[1] != "second" {
+		t.Errorf("failures reported for %v, want both messages", failed)
+	}
+}
+
+func TestZeroWindowSendsImmediately(t *testing.T) {
+	log := newSendLog()
+	b := newBatcher(0, 4096, log.send, ignoreFailure)
+
+	b.add(1, "one", plugin.Message{})
+	b.add(1, "two", plugin.Message{})
+	if got := log.sends(); len(got) != 2 {
+		t.Errorf("sends = %q, want each message sent on its own", got)
+	}
+}
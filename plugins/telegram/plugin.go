@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"bicycle/cmd"
 	"bicycle/internal/config"
@@ -21,15 +23,47 @@ func init() {
 
 // TelegramPlugin provides Telegram bot integration
 type TelegramPlugin struct {
-	bot    *tgbotapi.BotAPI
 	broker plugin.MessageBroker
 	router *cmd.Router
 	msgCh  <-chan plugin.Message
 	ctx    context.Context
 	stopCh chan struct{}
-	chatID int64 // Active chat ID for sending messages
+
+	// sessions holds one running bot per configured token
+	sessions []*session
+
+	// maxRender truncates long messages (0 = no limit)
+	maxRender int
+
+	// goodbye is sent to the active chat on shutdown (empty = none)
+	goodbye string
+
+	// drainOnStop sends buffered broker messages before stopping
+	drainOnStop bool
+
+	// handlerTimeout bounds delivering one broker message (0 = no limit)
+	handlerTimeout time.Duration
+
+	// running is claimed when Start begins (and released if it fails) and
+	// cleared by Stop, so a repeated or concurrent Start or Stop is a no-op
+	// instead of a second bot or a double close of stopCh
+	running atomic.Bool
 }
 
+const (
+	// telegramMessageLimit is the most characters Telegram accepts per message
+	telegramMessageLimit = 4096
+
+	// defaultMaxRenderChars keeps a rendered message within one Telegram message
+	defaultMaxRenderChars = 4000
+
+	// defaultGoodbyeMessage is sent to the active chat on shutdown
+	defaultGoodbyeMessage = "Daemon shutting down"
+
+	// defaultHandlerTimeout bounds delivering one broker message (in seconds)
+	defaultHandlerTimeout = 10
+)
+
 // NewTelegramPlugin creates a new Telegram plugin
 func NewTelegramPlugin() *TelegramPlugin {
 	return &TelegramPlugin{
@@ -42,22 +76,35 @@ func (p *TelegramPlugin) Name() string {
 	return "telegram"
 }
 
+// Labels returns the plugin's label groups
+func (p *TelegramPlugin) Labels() []string {
+	return []string{"chat"}
+}
+
+// SettingsSchema lists the settings the plugin reads
+func (p *TelegramPlugin) SettingsSchema() map[string]plugin.SettingKind {
+	return map[string]plugin.SettingKind{
+		"token":            plugin.SettingString,
+		"max_render_chars": plugin.SettingInt,
+		"goodbye_message":  plugin.SettingString,
+		"drain_on_stop":    plugin.SettingBool,
+		"handler_timeout":  plugin.SettingInt,
+		"batch_window_ms":  plugin.SettingInt,
+		"command_timeout":  plugin.SettingInt,
+	}
+}
+
 // CheckRequirements validates plugin requirements
 func (p *TelegramPlugin) CheckRequirements(ctx context.Context) error {
 	checker := plugin.NewRequirementChecker("telegram")
 
-	// Get token from config or environment
-	token := p.getToken(ctx)
-
-	// Require token
+	// Require a token for every bot
 	checker.AddRequired(
 		"telegram_token",
 		"Telegram bot token required",
 		func(ctx context.Context) error {
-			if token == "" {
-				return fmt.Errorf("TELEGRAM_TOKEN not set in config or environment")
-			}
-			return nil
+			_, err := loadBots(ctx)
+			return err
 		},
 	)
 
@@ -71,48 +118,82 @@ func (p *TelegramPlugin) CheckRequirements(ctx context.Context) error {
 	return checker.Check(ctx)
 }
 
-// getToken retrieves the Telegram token from config or environment
-func (p *TelegramPlugin) getToken(ctx context.Context) string {
-	// Try config first
-	if cfg, ok := ctx.Value("config").(*config.Config); ok {
-		if token, ok := cfg.GetPluginSettingString("telegram", "token"); ok && token != "" {
-			return token
-		}
-	}
-
-	// Fallback to environment variable
-	return os.Getenv("TELEGRAM_TOKEN")
-}
-
 // Extensions returns the plugin's extensions
 func (p *TelegramPlugin) Extensions() []plugin.Extension {
 	return []plugin.Extension{}
 }
 
+// Subscribe subscribes to the messages the bots relay before any plugin starts
+func (p *TelegramPlugin) Subscribe(ctx context.Context, broker plugin.MessageBroker) ([]string, error) {
+	p.msgCh = broker.Subscribe("telegram", 100, "notification", "response")
+	return []string{"telegram"}, nil
+}
+
 // Start initializes the Telegram bot
-func (p *TelegramPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+func (p *TelegramPlugin) Start(ctx context.Context, broker plugin.MessageBroker) (err error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	defer func() {
+		if err != nil {
+			p.running.Store(false)
+		}
+	}()
+
 	p.broker = broker
 	p.ctx = ctx
 	p.router = cmd.NewRouter()
+	p.stopCh = make(chan struct{}) // Stop closes it, so a restart needs a new one
 
-	// Get token
-	token := p.getToken(ctx)
+	// Bound command handling
+	p.maxRender = defaultMaxRenderChars
+	p.goodbye = defaultGoodbyeMessage
+	p.handlerTimeout = defaultHandlerTimeout * time.Second
+	var batchWindow time.Duration
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		p.router.SetTimeout(time.Duration(cfg.CommandTimeoutFor("telegram")) * time.Second)
+		if max, ok := cfg.GetPluginSettingInt("telegram", "max_render_chars"); ok {
+			p.maxRender = max
+		}
+		if goodbye, ok := cfg.GetPluginSettingString("telegram", "goodbye_message"); ok {
+			p.goodbye = goodbye
+		}
+		if drain, ok := cfg.GetPluginSettingBool("telegram", "drain_on_stop"); ok {
+			p.drainOnStop = drain
+		}
+		if timeout, ok := cfg.GetPluginSettingInt("telegram", "handler_timeout"); ok {
+			p.handlerTimeout = time.Duration(timeout) * time.Second
+		}
+		if window, ok := cfg.GetPluginSettingInt("telegram", "batch_window_ms"); ok {
+			batchWindow = time.Duration(window) * time.Millisecond
+		}
+	}
 
-	// Create bot
-	var err error
-	p.bot, err = tgbotapi.NewBotAPI(token)
+	bots, err := loadBots(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create bot: %w", err)
+		return err
 	}
 
-	log.Printf("[Telegram] Authorized on account %s", p.bot.Self.UserName)
+	// Create bots
+	p.sessions = nil
+	for _, cfg := range bots {
+		s := &session{botConfig: cfg}
+		s.bot, err = tgbotapi.NewBotAPI(cfg.token)
+		if err != nil {
+			return fmt.Errorf("failed to create bot %s: %w", cfg.name, err)
+		}
+		s.batch = newBatcher(batchWindow, telegramMessageLimit, s.sendMessage, p.reportDeliveryFailure)
+		p.sessions = append(p.sessions, s)
 
-	// Subscribe to broker messages
-	p.msgCh = broker.Subscribe("telegram", 100, "notification", "response")
+		log.Printf("[Telegram] Authorized on account %s", s.bot.Self.UserName)
+	}
 
 	// Start message handlers
 	go p.handleBrokerMessages()
-	go p.handleTelegramUpdates()
+	for _, s := range p.sessions {
+		s.receiving.Store(true)
+		go p.handleTelegramUpdates(s)
+	}
 
 	log.Printf("[Telegram] Started")
 	return nil
@@ -120,13 +201,31 @@ func (p *TelegramPlugin) Start(ctx context.Context, broker plugin.MessageBroker)
 
 // Stop shuts down the Telegram bot
 func (p *TelegramPlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
 	close(p.stopCh)
 
-	if p.bot != nil {
-		p.bot.StopReceivingUpdates()
+	// Flush notifications that were published but not yet sent
+	drained := false
+	if d, ok := p.broker.(plugin.DrainingUnsubscriber); ok && p.drainOnStop && len(p.sessions) > 0 {
+		d.UnsubscribeDrain("telegram", p.deliver)
+		drained = true
 	}
 
-	if p.broker != nil {
+	for _, s := range p.sessions {
+		// Send notifications still waiting for their batch window
+		s.batch.flushAll()
+
+		// Let the active chat know the bot is going away
+		if chatID := s.chatID.Load(); chatID != 0 && p.goodbye != "" {
+			s.sendMessage(chatID, p.goodbye)
+		}
+
+		s.bot.StopReceivingUpdates()
+	}
+
+	if p.broker != nil && !drained {
 		p.broker.Unsubscribe("telegram")
 	}
 
@@ -134,6 +233,32 @@ func (p *TelegramPlugin) Stop(ctx context.Context) error {
 	return nil
 }
 
+// HealthCheck reports whether every bot is still receiving updates
+func (p *TelegramPlugin) HealthCheck(ctx context.Context) error {
+	if len(p.sessions) == 0 {
+		return fmt.Errorf("not receiving updates")
+	}
+	for _, s := range p.sessions {
+		if !s.receiving.Load() {
+			return fmt.Errorf("%s not receiving updates", s.label())
+		}
+	}
+	return nil
+}
+
+// StatusSection reports each bot account and its active chat for the daemon status
+func (p *TelegramPlugin) StatusSection() (string, []string) {
+	var lines []string
+	for _, s := range p.sessions {
+		chat := "none"
+		if chatID := s.chatID.Load(); chatID != 0 {
+			chat = strconv.FormatInt(chatID, 10)
+		}
+		lines = append(lines, fmt.Sprintf("Bot: %s (active chat: %s)", s.label(), chat))
+	}
+	return "Telegram", lines
+}
+
 // handleBrokerMessages receives messages from the broker and sends to Telegram
 func (p *TelegramPlugin) handleBrokerMessages() {
 	for {
@@ -143,21 +268,9 @@ func (p *TelegramPlugin) handleBrokerMessages() {
 				return
 			}
 
-			// Only send if we have an active chat
-			if p.chatID == 0 {
-				continue
-			}
-
-			// Convert message to string
-			var text string
-			if str, ok := msg.Payload.(string); ok {
-				text = str
-			} else {
-				text = fmt.Sprintf("%v", msg.Payload)
-			}
-
-			// Send to Telegram
-			p.sendMessage(p.chatID, text)
+			plugin.HandleWithTimeout(p.ctx, "Telegram", p.handlerTimeout, msg, func(ctx context.Context, msg plugin.Message) {
+				p.deliver(msg)
+			})
 
 		case <-p.stopCh:
 			return
@@ -165,28 +278,120 @@ func (p *TelegramPlugin) handleBrokerMessages() {
 	}
 }
 
-// handleTelegramUpdates receives updates from Telegram
-func (p *TelegramPlugin) handleTelegramUpdates() {
+// deliver sends a broker message to its Telegram chats
+func (p *TelegramPlugin) deliver(msg plugin.Message) {
+	for _, t := range p.targets(msg) {
+		// Convert message to string, marked by its severity
+		text := severityPrefixes[plugin.SeverityOf(msg)] + cmd.Render(t.session.source(t.chatID), plugin.PayloadText(msg.Payload), p.maxRender)
+
+		// Send responses right away; notifications may wait to be batched
+		if msg.Topic != "response" {
+			t.session.batch.add(t.chatID, text, msg)
+			continue
+		}
+
+		// Send to Telegram, reporting failures for task messages
+		if err := t.session.batch.sendNow(t.chatID, text); err != nil {
+			p.reportDeliveryFailure(msg, err)
+		}
+	}
+}
+
+// severityPrefixes mark messages by severity; info messages are unmarked
+var severityPrefixes = map[plugin.Severity]string{
+	plugin.SeveritySuccess: "✅ ",
+	plugin.SeverityWarn:    "⚠️ ",
+	plugin.SeverityError:   "❌ ",
+}
+
+// target is a chat with one of the bots
+type target struct {
+	session *session
+	chatID  int64
+}
+
+// targets returns the chats a broker message should go to
+// Messages addressed to a chat go there, through the bot it talked to;
+// broadcasts go to the active chat of the bot named by MetadataBot, or of
+// every bot; messages addressed to other transports are skipped
+func (p *TelegramPlugin) targets(msg plugin.Message) []target {
+	if !plugin.AddressedTo(msg, "telegram") {
+		return nil
+	}
+
+	if replyTo := plugin.ReplyTo(msg); strings.HasPrefix(replyTo, "telegram:") {
+		name, chat := "", strings.TrimPrefix(replyTo, "telegram:")
+		if i := strings.LastIndex(chat, ":"); i >= 0 {
+			name, chat = chat[:i], chat[i+1:]
+		}
+		chatID, err := strconv.ParseInt(chat, 10, 64)
+		s := p.session(name)
+		if err != nil || s == nil {
+			log.Printf("[Telegram] Ignoring message with invalid reply_to %q", replyTo)
+			return nil
+		}
+		return []target{{session: s, chatID: chatID}}
+	}
+
+	var out []target
+	bot, _ := msg.Metadata[MetadataBot].(string)
+	for _, s := range p.sessions {
+		if bot != "" && s.name != bot {
+			continue
+		}
+		if chatID := s.chatID.Load(); chatID != 0 {
+			out = append(out, target{session: s, chatID: chatID})
+		}
+	}
+	return out
+}
+
+// session returns the bot with the given name; "" is the first bot
+func (p *TelegramPlugin) session(name string) *session {
+	for _, s := range p.sessions {
+		if s.name == name {
+			return s
+		}
+	}
+	if name == "" && len(p.sessions) > 0 {
+		return p.sessions[0]
+	}
+	return nil
+}
+
+// handleTelegramUpdates receives updates for one bot
+func (p *TelegramPlugin) handleTelegramUpdates(s *session) {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
-	updates := p.bot.GetUpdatesChan(u)
+	updates := s.bot.GetUpdatesChan(u)
+	defer s.receiving.Store(false)
 
 	for {
 		select {
-		case update := <-updates:
+		case update, ok := <-updates:
+			if !ok {
+				log.Printf("[Telegram] Update channel closed")
+				return
+			}
 			if update.Message == nil {
 				continue
 			}
 
+			// Ignore users the bot's allowlist leaves out
+			if update.Message.From == nil || !s.allows(update.Message.From.ID) {
+				log.Printf("[Telegram] Ignoring message to %s from unauthorized chat %d", s.label(), update.Message.Chat.ID)
+				continue
+			}
+
 			// Set active chat ID
-			p.chatID = update.Message.Chat.ID
+			s.chatID.Store(update.Message.Chat.ID)
 
 			// Log message
 			log.Printf("[Telegram] [%s] %s", update.Message.From.UserName, update.Message.Text)
 
 			// Process message
-			p.processMessage(update.Message)
+			p.processMessage(s, update.Message)
 
 		case <-p.stopCh:
 			return
@@ -195,20 +400,43 @@ func (p *TelegramPlugin) handleTelegramUpdates() {
 }
 
 // processMessage processes a Telegram message
-func (p *TelegramPlugin) processMessage(message *tgbotapi.Message) {
+func (p *TelegramPlugin) processMessage(s *session, message *tgbotapi.Message) {
 	text := message.Text
 
+	// Ignore blank messages
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
 	// Check if it's a command
 	if strings.HasPrefix(text, "/") {
-		// Execute command
-		result, err := p.router.Route(p.ctx, text)
+		if !plugin.IsReady(p.ctx) {
+			s.reply(message.Chat.ID, "Daemon is starting, try again shortly")
+			return
+		}
+
+		// Execute command on behalf of the chat
+		principal := plugin.Principal{
+			Source: s.source(message.Chat.ID),
+			Role:   plugin.RoleUser,
+		}
+		if message.From != nil {
+			principal.UserID = strconv.FormatInt(message.From.ID, 10)
+		}
+		ctx := plugin.WithCorrelationID(plugin.WithPrincipal(p.ctx, principal), plugin.NewCorrelationID())
+		plugin.Logf(ctx, "[Telegram] Command from chat %d: %s", message.Chat.ID, text)
+		result, err := p.router.Route(ctx, text)
 		if err != nil {
-			p.sendMessage(message.Chat.ID, fmt.Sprintf("Error: %v", err))
+			s.reply(message.Chat.ID, fmt.Sprintf("Error: %v", err))
 			return
 		}
 
 		if result != nil && result.Output != "" {
-			p.sendMessage(message.Chat.ID, result.Output)
+			output := result.Output
+			if !result.Untruncated {
+				output = cmd.Render(principal.Source, output, p.maxRender)
+			}
+			s.reply(message.Chat.ID, output)
 
 			// Broadcast if requested
 			if result.Broadcast {
@@ -226,21 +454,34 @@ func (p *TelegramPlugin) processMessage(message *tgbotapi.Message) {
 			Payload: text,
 			Source:  "telegram",
 			Metadata: map[string]interface{}{
-				"user_id":   message.From.ID,
-				"username":  message.From.UserName,
-				"chat_id":   message.Chat.ID,
+				"user_id":           message.From.ID,
+				"username":          message.From.UserName,
+				"chat_id":           message.Chat.ID,
+				MetadataBot:         s.name,
+				plugin.MetadataChat: s.source(message.Chat.ID),
 			},
 		})
 
 		// Echo confirmation
-		p.sendMessage(message.Chat.ID, "Message received")
+		s.reply(message.Chat.ID, "Message received")
 	}
 }
 
-// sendMessage sends a message to a Telegram chat
-func (p *TelegramPlugin) sendMessage(chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	if _, err := p.bot.Send(msg); err != nil {
-		log.Printf("[Telegram] Error sending message: %v", err)
+// reportDeliveryFailure publishes a delivery.failed message for task-related messages
+func (p *TelegramPlugin) reportDeliveryFailure(msg plugin.Message, err error) {
+	taskID, ok := msg.Metadata["task_id"].(string)
+	if !ok {
+		return
 	}
+
+	p.broker.Publish(p.ctx, plugin.Message{
+		Topic:   plugin.TopicDeliveryFailed,
+		Payload: err.Error(),
+		Source:  "telegram",
+		Metadata: map[string]interface{}{
+			"task_id": taskID,
+			"topic":   msg.Topic,
+			"error":   err.Error(),
+		},
+	})
 }
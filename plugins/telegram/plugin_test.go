@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestStopTwice(t *testing.T) {
+	// Starting needs a bot token and the Telegram API, so set up what Start
+	// leaves behind for Stop
+	p := NewTelegramPlugin()
+	p.running.Store(true)
+	p.stopCh = make(chan struct{})
+	p.broker = testutil.NewBroker()
+
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+	select {
+	case <-p.stopCh:
+	default:
+		t.Error("stop channel still open")
+	}
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	if err := NewTelegramPlugin().Stop(context.Background()); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
+
+// newSession returns a bot named name whose active chat is chatID
+func newSession(name string, chatID int64) *session {
+	s := &session{botConfig: botConfig{name: name}}
+	s.chatID.Store(chatID)
+	return s
+}
+
+func TestRepliesOnlyReachTheAskingChat(t *testing.T) {
+	p := NewTelegramPlugin()
+	p.sessions = []*session{newSession("", 7)}
+
+	// An /ask from chat 42 is answered there, not in the active chat 7
+	targets := p.targets(plugin.Message{
+		Topic:    "response",
+		Metadata: map[string]interface{}{plugin.MetadataReplyTo: "telegram:42"},
+	})
+	if len(targets) != 1 || targets[0].chatID != 42 {
+		t.Errorf("targets = %+v, want only chat 42", targets)
+	}
+
+	// Replies to other transports are not surfaced
+	if targets := p.targets(plugin.Message{
+		Topic:    "response",
+		Metadata: map[string]interface{}{plugin.MetadataReplyTo: "websocket:client-1"},
+	}); len(targets) != 0 {
+		t.Errorf("targets for a websocket reply = %+v, want none", targets)
+	}
+
+	// Broadcasts still go to the active chat
+	if targets := p.targets(plugin.Message{Topic: "response"}); len(targets) != 1 || targets[0].chatID != 7 {
+		t.Errorf("broadcast targets = %+v, want the active chat 7", targets)
+	}
+}
+
+func TestRepliesGoThroughTheAskingBot(t *testing.T) {
+	p := NewTelegramPlugin()
+	work, home := newSession("work", 1), newSession("home", 2)
+	p.sessions = []*session{work, home}
+
+	targets := p.targets(plugin.Message{
+		Topic:    "response",
+		Metadata: map[string]interface{}{plugin.MetadataReplyTo: home.source(42)},
+	})
+	if len(targets) != 1 || targets[0].session != home || targets[0].chatID != 42 {
+		t.Errorf("targets = %+v, want chat 42 through the home bot", targets)
+	}
+}
+
+func TestDeliveredMessagesMarkedBySeverity(t *testing.T) {
+	log := newSendLog()
+	s := newSession("", 7)
+	s.batch = newBatcher(0, 4096, log.send, ignoreFailure)
+	p := NewTelegramPlugin()
+	p.sessions = []*session{s}
+
+	tests := []struct {
+		severity plugin.Severity
+		prefix   string
+	}{
+		{plugin.SeverityError, "❌ "},
+		{plugin.SeverityWarn, "⚠️ "},
+		{plugin.SeveritySuccess, "✅ "},
+		{plugin.SeverityInfo, ""},
+	}
+	for _, tt := range tests {
+		p.deliver(plugin.Message{
+			Topic:    "response",
+			Payload:  "done",
+			Metadata: map[string]interface{}{plugin.MetadataSeverity: string(tt.severity)},
+		})
+	}
+
+	sends := log.sends()
+	if len(sends) != len(tests) {
+		t.Fatalf("sent %q, want %d messages", sends, len(tests))
+	}
+	for i, tt := range tests {
+		if sends[i] != tt.prefix+"done" {
+			t.Errorf("%s message sent as %q, want %q", tt.severity, sends[i], tt.prefix+"done")
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MetadataBot is the message metadata key naming the bot a broadcast goes
+// to; broadcasts without it go to every bot's active chat
+const MetadataBot = "telegram_bot"
+
+// botConfig is one configured bot
+type botConfig struct {
+	// name tells bots apart in reply_to and MetadataBot ("" for the single
+	// bot configured with the token setting)
+	name  string
+	token string
+
+	// allowed holds the user ids that may talk to the bot (empty = everyone)
+	allowed map[int64]bool
+}
+
+// session is a running bot with its own active chat and batching
+type session struct {
+	botConfig
+
+	bot    *tgbotapi.BotAPI
+	chatID atomic.Int64 // Active chat ID for sending messages
+
+	// receiving is set while the update loop is running
+	receiving atomic.Bool
+
+	// batch coalesces notifications sent to a chat in quick succession
+	batch *batcher
+}
+
+// loadBots reads the bots setting or, when it isn't set, a single bot from the
+// token and allowed_users settings (the token falling back to TELEGRAM_TOKEN)
+func loadBots(ctx context.Context) ([]botConfig, error) {
+	cfg, _ := ctx.Value("config").(*config.Config)
+
+	if cfg != nil {
+		if raw, ok := cfg.GetPluginSetting("telegram", "bots"); ok {
+			return parseBots(raw)
+		}
+	}
+
+	bot := botConfig{token: os.Getenv("TELEGRAM_TOKEN")}
+	if cfg != nil {
+		if token, ok := cfg.GetPluginSettingString("telegram", "token"); ok && token != "" {
+			bot.token = token
+		}
+		if raw, ok := cfg.GetPluginSetting("telegram", "allowed_users"); ok {
+			allowed, err := parseUserIDs(raw)
+			if err != nil {
+				return nil, plugin.NewConfigError("telegram", "allowed_users", err.Error())
+			}
+			bot.allowed = allowed
+		}
+	}
+	if bot.token == "" {
+		return nil, plugin.NewConfigError("telegram", "token", "not set in config or TELEGRAM_TOKEN environment variable")
+	}
+	return []botConfig{bot}, nil
+}
+
+// parseBots reads the bots setting, a list of maps with name, token and
+// allowed_users
+func parseBots(raw interface{}) ([]botConfig, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, plugin.NewConfigError("telegram", "bots", "must be a non-empty list")
+	}
+
+	var bots []botConfig
+	names := make(map[string]bool)
+	for i, item := range list {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, plugin.NewConfigError("telegram", "bots", fmt.Sprintf("bot %d must be a map", i+1))
+		}
+
+		bot := botConfig{}
+		bot.name, _ = settings["name"].(string)
+		if bot.name == "" {
+			bot.name = fmt.Sprintf("bot%d", i+1)
+		}
+		if strings.Contains(bot.name, ":") {
+			return nil, plugin.NewConfigError("telegram", "bots", fmt.Sprintf("%s: name must not contain ':'", bot.name))
+		}
+		if names[bot.name] {
+			return nil, plugin.NewConfigError("telegram", "bots", fmt.Sprintf("%s: duplicate name", bot.name))
+		}
+		names[bot.name] = true
+
+		bot.token, _ = settings["token"].(string)
+		if bot.token == "" {
+			return nil, plugin.NewConfigError("telegram", "bots", fmt.Sprintf("%s: token is required", bot.name))
+		}
+
+		allowed, err := parseUserIDs(settings["allowed_users"])
+		if err != nil {
+			return nil, plugin.NewConfigError("telegram", "bots", fmt.Sprintf("%s: allowed_users %v", bot.name, err))
+		}
+		bot.allowed = allowed
+
+		bots = append(bots, bot)
+	}
+	return bots, nil
+}
+
+// parseUserIDs reads a list of Telegram user ids (nil = everyone)
+func parseUserIDs(raw interface{}) (map[int64]bool, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a list of user ids")
+	}
+
+	allowed := make(map[int64]bool, len(list))
+	for _, item := range list {
+		switch id := item.(type) {
+		case int:
+			allowed[int64(id)] = true
+		case int64:
+			allowed[id] = true
+		case string:
+			n, err := strconv.ParseInt(id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("has an invalid user id %q", id)
+			}
+			allowed[n] = true
+		default:
+			return nil, fmt.Errorf("has an invalid user id %v", item)
+		}
+	}
+	return allowed, nil
+}
+
+// allows reports whether a user may talk to the bot
+func (s *session) allows(userID int64) bool {
+	return len(s.allowed) == 0 || s.allowed[userID]
+}
+
+// source returns the principal source for a chat with this bot, which is
+// also the reply_to that routes messages back to it: "telegram:12345" for
+// the single bot, "telegram:<name>:12345" for a named one
+func (s *session) source(chatID int64) string {
+	if s.name == "" {
+		return fmt.Sprintf("telegram:%d", chatID)
+	}
+	return fmt.Sprintf("telegram:%s:%d", s.name, chatID)
+}
+
+// label names the bot in logs and status
+func (s *session) label() string {
+	if s.bot != nil {
+		return "@" + s.bot.Self.UserName
+	}
+	return s.name
+}
+
+// reply answers a chat right away, after notifications queued for it
+func (s *session) reply(chatID int64, text string) error {
+	return s.batch.sendNow(chatID, text)
+}
+
+// sendMessage sends a message to a Telegram chat, splitting text longer than
+// Telegram's message limit into several messages
+func (s *session) sendMessage(chatID int64, text string) error {
+	runes := []rune(text)
+	for len(runes) > telegramMessageLimit {
+		if err := s.sendChunk(chatID, string(runes[:telegramMessageLimit])); err != nil {
+			return err
+		}
+		runes = runes[telegramMessageLimit:]
+	}
+	return s.sendChunk(chatID, string(runes))
+}
+
+// sendChunk sends a single message to a Telegram chat
+func (s *session) sendChunk(chatID int64, text string) error {
+	msg := tgbotapi.NewMessage(chatID, text)
+	if _, err := s.bot.Send(msg); err != nil {
+		log.Printf("[Telegram] Error sending message via %s: %v", s.label(), err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// twoBots is a bots setting for a work bot open to user 1 and a home bot
+// open to everyone
+var twoBots = []interface{}{
+	map[string]interface{}{"name": "work", "token": "work-token", "allowed_users": []interface{}{1, "2"}},
+	map[string]interface{}{"name": "home", "token": "home-token"},
+}
+
+func TestLoadBotsKeepsAllowlistsApart(t *testing.T) {
+	bots, err := loadBots(testutil.NewContext(testutil.WithPluginSetting("telegram", "bots", twoBots)))
+	if err != nil {
+		t.Fatalf("loadBots: %v", err)
+	}
+	if len(bots) != 2 || bots[0].name != "work" || bots[1].name != "home" || bots[1].token != "home-token" {
+		t.Fatalf("bots = %+v, want work and home", bots)
+	}
+
+	work, home := &session{botConfig: bots[0]}, &session{botConfig: bots[1]}
+	for _, tt := range []struct {
+		s      *session
+		userID int64
+		want   bool
+	}{
+		{work, 1, true},
+		{work, 2, true},
+		{work, 3, false},
+		{home, 3, true},
+	} {
+		if got := tt.s.allows(tt.userID); got != tt.want {
+			t.Errorf("%s allows user %d = %v, want %v", tt.s.name, tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidBotsRejected(t *testing.T) {
+	for _, tt := range []struct {
+		bots interface{}
+		want string
+	}{
+		{[]interface{}{}, "must be a non-empty list"},
+		{[]interface{}{map[string]interface{}{"name": "a"}}, "a: token is required"},
+		{[]interface{}{map[string]interface{}{"name": "a:b", "token": "t"}}, "must not contain ':'"},
+		{[]interface{}{
+			map[string]interface{}{"name": "a", "token": "t"},
+			map[string]interface{}{"name": "a", "token": "u"},
+		}, "a: duplicate name"},
+		{[]interface{}{map[string]interface{}{"token": "t", "allowed_users": []interface{}{"x"}}}, "invalid user id"},
+	} {
+		_, err := loadBots(testutil.NewContext(testutil.WithPluginSetting("telegram", "bots", tt.bots)))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("bots %v: err = %v, want %q", tt.bots, err, tt.want)
+		}
+	}
+}
+
+func TestBroadcastsRouteByBot(t *testing.T) {
+	p := NewTelegramPlugin()
+	work, home := newSession("work", 10), newSession("home", 20)
+	p.sessions = []*session{work, home}
+
+	// A broadcast naming a bot reaches only that bot's active chat
+	targets := p.targets(plugin.Message{Topic: "notification", Metadata: map[string]interface{}{MetadataBot: "home"}})
+	if len(targets) != 1 || targets[0].session != home || targets[0].chatID != 20 {
+		t.Errorf("targets = %+v, want home's chat 20", targets)
+	}
+
+	// Without one it reaches every bot
+	if targets := p.targets(plugin.Message{Topic: "notification"}); len(targets) != 2 {
+		t.Errorf("targets = %+v, want both bots' chats", targets)
+	}
+
+	// A chat with the same id on the other bot is a different chat
+	if got, want := work.source(5), "telegram:work:5"; got != want {
+		t.Errorf("work source = %s, want %s", got, want)
+	}
+	targets = p.targets(plugin.Message{Topic: "response", Metadata: map[string]interface{}{plugin.MetadataReplyTo: work.source(5)}})
+	if len(targets) != 1 || targets[0].session != work {
+		t.Errorf("reply targets = %+v, want work's chat 5", targets)
+	}
+}
@@ -0,0 +1,170 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// renderData renders a command result's structured Data for the chat:
+// a list of strings as bullets, a map as aligned key/value rows, and a list
+// of maps or of string rows as a table. Other values are formatted with %v
+func renderData(data interface{}) string {
+	if data == nil {
+		return ""
+	}
+	if s, ok := data.(string); ok {
+		return s
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			return renderKeyValues(v)
+		}
+	case reflect.Slice, reflect.Array:
+		if rows, ok := tableRows(v); ok {
+			return renderTable(rows)
+		}
+		if items, ok := stringItems(v); ok {
+			return renderList(items)
+		}
+	}
+	return fmt.Sprintf("%v", data)
+}
+
+// renderList renders items as a bulleted list
+func renderList(items []string) string {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = "• " + item
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderKeyValues renders a string-keyed map as rows sorted by key, with the
+// values lined up
+func renderKeyValues(m reflect.Value) string {
+	keys := make([]string, 0, m.Len())
+	width := 0
+	for _, key := range m.MapKeys() {
+		keys = append(keys, key.String())
+		width = max(width, lipgloss.Width(key.String()))
+	}
+	sort.Strings(keys)
+
+	keyStyle := lipgloss.NewStyle().Bold(true).Width(width)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		value := m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key()))
+		lines[i] = keyStyle.Render(key) + "  " + fmt.Sprint(value.Interface())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTable renders rows as a bordered table, the first row being the header
+func renderTable(rows [][]string) string {
+	t := table.New().
+		Border(lipgloss.NormalBorder()).
+		Headers(rows[0]...).
+		Rows(rows[1:]...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle().Padding(0, 1)
+			if row == table.HeaderRow {
+				return style.Bold(true)
+			}
+			return style
+		})
+	return t.String()
+}
+
+// stringItems returns the elements of a list whose elements are all strings
+func stringItems(v reflect.Value) ([]string, bool) {
+	items := make([]string, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		s, ok := v.Index(i).Interface().(string)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, s)
+	}
+	return items, true
+}
+
+// tableRows returns the header and rows of tabular data: a non-empty list of
+// string-keyed maps (the header being their keys, sorted) or of string lists
+// (the first being the header)
+func tableRows(v reflect.Value) ([][]string, bool) {
+	if v.Len() == 0 {
+		return nil, false
+	}
+
+	elems := make([]reflect.Value, v.Len())
+	for i := range elems {
+		elem := v.Index(i)
+		for elem.Kind() == reflect.Interface || elem.Kind() == reflect.Pointer {
+			if elem.IsNil() {
+				return nil, false
+			}
+			elem = elem.Elem()
+		}
+		elems[i] = elem
+	}
+
+	switch elems[0].Kind() {
+	case reflect.Map:
+		seen := make(map[string]bool)
+		var header []string
+		for _, elem := range elems {
+			if elem.Kind() != reflect.Map || elem.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			for _, key := range elem.MapKeys() {
+				if !seen[key.String()] {
+					seen[key.String()] = true
+					header = append(header, key.String())
+				}
+			}
+		}
+		sort.Strings(header)
+
+		rows := [][]string{header}
+		for _, elem := range elems {
+			row := make([]string, len(header))
+			for i, key := range header {
+				if value := elem.MapIndex(reflect.ValueOf(key).Convert(elem.Type().Key())); value.IsValid() {
+					row[i] = fmt.Sprint(value.Interface())
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows, true
+
+	case reflect.Slice, reflect.Array:
+		var rows [][]string
+		for _, elem := range elems {
+			if elem.Kind() != reflect.Slice && elem.Kind() != reflect.Array {
+				return nil, false
+			}
+			row, ok := stringItems(elem)
+			if !ok {
+				return nil, false
+			}
+			rows = append(rows, row)
+		}
+		return rows, true
+	}
+	return nil, false
+}
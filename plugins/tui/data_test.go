@@ -0,0 +1,55 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderData(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"nil", nil, ""},
+		{"string", "plain", "plain"},
+		{"list", []string{"alpha", "beta"}, "• alpha\n• beta"},
+		{"list of interfaces", []interface{}{"alpha", "beta"}, "• alpha\n• beta"},
+		{
+			"map",
+			map[string]interface{}{"uptime": "5m", "tasks": 3, "id": "x"},
+			"id      x\ntasks   3\nuptime  5m",
+		},
+		{
+			"table",
+			[][]string{{"name", "state"}, {"llm", "idle"}},
+			strings.Join([]string{
+				"┌──────┬───────┐",
+				"│ name │ state │",
+				"├──────┼───────┤",
+				"│ llm  │ idle  │",
+				"└──────┴───────┘",
+			}, "\n"),
+		},
+		{
+			"list of maps",
+			[]map[string]interface{}{{"name": "llm", "state": "idle"}, {"name": "tui"}},
+			strings.Join([]string{
+				"┌──────┬───────┐",
+				"│ name │ state │",
+				"├──────┼───────┤",
+				"│ llm  │ idle  │",
+				"│ tui  │       │",
+				"└──────┴───────┘",
+			}, "\n"),
+		},
+		{"unknown", 42, "42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderData(tt.data); got != tt.want {
+				t.Errorf("renderData(%v) =\n%s\nwant\n%s", tt.data, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"bicycle/cmd"
+	"bicycle/internal/config"
 	"bicycle/plugin"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -18,18 +22,55 @@ func init() {
 	plugin.Register(NewTUIPlugin())
 }
 
+// defaultMaxRenderChars is the default limit on a single rendered message
+const defaultMaxRenderChars = 10000
+
+// startupGrace is how long Start waits for the program to fail before
+// considering it running
+const startupGrace = 200 * time.Millisecond
+
+// program is the part of a bubbletea program the plugin drives
+type program interface {
+	Run() (tea.Model, error)
+	Send(msg tea.Msg)
+	Quit()
+}
+
+// shutdownRequester is the part of the daemon used to stop when the TUI dies
+type shutdownRequester interface {
+	RequestShutdown(reason string)
+}
+
 // TUIPlugin provides a terminal user interface
 type TUIPlugin struct {
-	program *tea.Program
-	model   *model
-	broker  plugin.MessageBroker
-	msgCh   <-chan plugin.Message
-	ctx     context.Context
+	program  program
+	model    *model
+	broker   plugin.MessageBroker
+	msgCh    <-chan plugin.Message
+	ctx      context.Context
+	stopping atomic.Bool
+
+	// maxRender truncates long messages (0 = no limit)
+	maxRender int
+
+	// showData shows command results' structured Data under their output
+	showData bool
+
+	// newProgram creates the program to run (replaceable for testing)
+	newProgram func(m tea.Model) program
+
+	// running is claimed when Start begins (and released if it fails) and
+	// cleared by Stop, making repeated or concurrent calls no-ops
+	running atomic.Bool
 }
 
 // NewTUIPlugin creates a new TUI plugin
 func NewTUIPlugin() *TUIPlugin {
-	return &TUIPlugin{}
+	return &TUIPlugin{
+		newProgram: func(m tea.Model) program {
+			return tea.NewProgram(m, tea.WithAltScreen())
+		},
+	}
 }
 
 // Name returns the plugin name
@@ -37,6 +78,20 @@ func (p *TUIPlugin) Name() string {
 	return "tui"
 }
 
+// Labels returns the plugin's label groups
+func (p *TUIPlugin) Labels() []string {
+	return []string{"ui"}
+}
+
+// SettingsSchema lists the settings the plugin reads
+func (p *TUIPlugin) SettingsSchema() map[string]plugin.SettingKind {
+	return map[string]plugin.SettingKind{
+		"max_render_chars": plugin.SettingInt,
+		"show_data":        plugin.SettingBool,
+		"command_timeout":  plugin.SettingInt,
+	}
+}
+
 // CheckRequirements validates plugin requirements
 func (p *TUIPlugin) CheckRequirements(ctx context.Context) error {
 	checker := plugin.NewRequirementChecker("tui")
@@ -56,42 +111,117 @@ func (p *TUIPlugin) Extensions() []plugin.Extension {
 	return []plugin.Extension{}
 }
 
+// Subscribe subscribes to the messages the TUI shows before any plugin starts
+func (p *TUIPlugin) Subscribe(ctx context.Context, broker plugin.MessageBroker) ([]string, error) {
+	p.msgCh = broker.Subscribe("tui", 100, "notification", "chat", "response", "task.progress")
+	return []string{"tui"}, nil
+}
+
 // Start initializes the TUI
-func (p *TUIPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+func (p *TUIPlugin) Start(ctx context.Context, broker plugin.MessageBroker) (err error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	defer func() {
+		if err != nil {
+			p.running.Store(false)
+		}
+	}()
+
 	p.broker = broker
 	p.ctx = ctx
 
-	// Subscribe to messages
-	p.msgCh = broker.Subscribe("tui", 100, "notification", "chat", "response")
+	p.maxRender = defaultMaxRenderChars
+	p.showData = true
+	commandTimeout := 0 // The local operator's commands aren't limited by default
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		if max, ok := cfg.GetPluginSettingInt("tui", "max_render_chars"); ok {
+			p.maxRender = max
+		}
+		if show, ok := cfg.GetPluginSettingBool("tui", "show_data"); ok {
+			p.showData = show
+		}
+		if timeout, ok := cfg.GetPluginSettingInt("tui", "command_timeout"); ok && timeout >= 0 {
+			commandTimeout = timeout
+		}
+	}
 
-	// Create model
-	p.model = newModel(ctx, broker)
+	// Create model; messages watched with /subscribe are shown in the chat
+	p.model = newModel(cmd.WithMessageSink(ctx, p.showWatched), broker)
+	p.model.maxRender = p.maxRender
+	p.model.showData = p.showData
+	p.model.router.SetTimeout(time.Duration(commandTimeout) * time.Second)
 
-	// Start bubbletea program
-	p.program = tea.NewProgram(p.model, tea.WithAltScreen())
+	// Start bubbletea program; the model sends command output through it
+	p.program = p.newProgram(p.model)
+	p.model.ctx = context.WithValue(p.model.ctx, "program", p.program)
+	p.stopping.Store(false)
 
 	// Handle incoming messages in background
 	go p.handleMessages()
 
 	// Run TUI (this blocks)
+	errCh := make(chan error, 1)
 	go func() {
-		if _, err := p.program.Run(); err != nil {
-			log.Printf("[TUI] Error running program: %v", err)
-		}
+		_, err := p.program.Run()
+		errCh <- err
 	}()
 
+	// A program that can't take over the terminal fails right away
+	select {
+	case err := <-errCh:
+		if err == nil {
+			err = fmt.Errorf("program exited immediately")
+		}
+		p.requestShutdown(ctx, fmt.Sprintf("TUI failed to start: %v", err))
+		return fmt.Errorf("requirement check(s) failed: terminal: %w", err)
+	case <-time.After(startupGrace):
+	}
+
+	go p.watchProgram(errCh)
+
 	log.Printf("[TUI] Started")
 	return nil
 }
 
+// watchProgram shuts the daemon down when the program ends on its own, since
+// an interactive daemon without its UI can't be used
+func (p *TUIPlugin) watchProgram(errCh <-chan error) {
+	err := <-errCh
+	if p.stopping.Load() {
+		return
+	}
+
+	reason := "TUI exited"
+	if err != nil {
+		log.Printf("[TUI] Error running program: %v", err)
+		reason = fmt.Sprintf("TUI failed: %v", err)
+	}
+	p.requestShutdown(p.ctx, reason)
+}
+
+// requestShutdown asks the daemon to shut down
+func (p *TUIPlugin) requestShutdown(ctx context.Context, reason string) {
+	if d, ok := ctx.Value("daemon").(shutdownRequester); ok {
+		d.RequestShutdown(reason)
+	}
+}
+
 // Stop shuts down the TUI
 func (p *TUIPlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
+	p.stopping.Store(true)
 	if p.program != nil {
 		p.program.Quit()
 	}
 
 	if p.broker != nil {
 		p.broker.Unsubscribe("tui")
+		if len(cmd.WatchedTopics("tui")) > 0 {
+			p.broker.Unsubscribe(cmd.WatchSubscriptionID("tui"))
+		}
 	}
 
 	log.Printf("[TUI] Stopped")
@@ -107,20 +237,13 @@ func (p *TUIPlugin) handleMessages() {
 				return
 			}
 
-			// Convert message to string
-			var text string
-			if str, ok := msg.Payload.(string); ok {
-				text = str
-			} else {
-				text = fmt.Sprintf("%v", msg.Payload)
+			// Skip replies meant for other transports
+			if !plugin.AddressedTo(msg, "tui") {
+				continue
 			}
 
-			// Send to bubbletea model
-			if p.program != nil {
-				p.program.Send(incomingMessageMsg{
-					source: msg.Source,
-					text:   text,
-				})
+			if modelMsg := p.toModelMsg(msg); modelMsg != nil && p.program != nil {
+				p.program.Send(modelMsg)
 			}
 
 		case <-p.ctx.Done():
@@ -129,27 +252,111 @@ func (p *TUIPlugin) handleMessages() {
 	}
 }
 
+// showWatched shows a message received on a topic watched with /subscribe
+func (p *TUIPlugin) showWatched(msg plugin.Message) {
+	if p.program == nil {
+		return
+	}
+	p.program.Send(incomingMessageMsg{
+		source: fmt.Sprintf("%s@%s", msg.Topic, msg.Source),
+		text:   cmd.Render("tui", plugin.PayloadText(msg.Payload), p.maxRender),
+	})
+}
+
+// toModelMsg converts a broker message to a bubbletea message
+// Progress for tasks submitted from the TUI drives the progress line; other
+// task progress is ignored
+func (p *TUIPlugin) toModelMsg(msg plugin.Message) tea.Msg {
+	taskID, _ := msg.Metadata["task_id"].(string)
+	correlationID, _ := msg.Metadata[plugin.MetadataCorrelationID].(string)
+
+	if msg.Topic == "task.progress" {
+		if plugin.ReplyTo(msg) != "tui" {
+			return nil
+		}
+		percent, _ := msg.Metadata["progress"].(int)
+		return taskProgressMsg{
+			taskID:        taskID,
+			correlationID: correlationID,
+			percent:       percent,
+			text:          plugin.PayloadText(msg.Payload),
+		}
+	}
+
+	return incomingMessageMsg{
+		source:        msg.Source,
+		text:          cmd.Render("tui", plugin.PayloadText(msg.Payload), p.maxRender),
+		severity:      plugin.SeverityOf(msg),
+		taskID:        taskID,
+		correlationID: correlationID,
+	}
+}
+
 // model represents the bubbletea model
 type model struct {
-	ctx      context.Context
-	broker   plugin.MessageBroker
-	router   *cmd.Router
-	messages []message
-	input    string
-	width    int
-	height   int
+	ctx       context.Context
+	broker    plugin.MessageBroker
+	router    *cmd.Router
+	maxRender int
+	showData  bool
+	messages  []message
+	input     string
+	width     int
+	height    int
+
+	// active is the task submitted from the TUI that is still running
+	active *activeTask
+}
+
+// activeTask tracks the progress line for a running task
+type activeTask struct {
+	id            string
+	correlationID string
+	percent       int
+	text          string
+	frame         int
 }
 
 // message represents a chat message
 type message struct {
-	source string
-	text   string
+	source   string
+	text     string
+	severity plugin.Severity
 }
 
 // incomingMessageMsg is a bubbletea message for incoming broker messages
 type incomingMessageMsg struct {
-	source string
-	text   string
+	source   string
+	text     string
+	severity plugin.Severity
+
+	// taskID and correlationID are set for task messages
+	taskID        string
+	correlationID string
+}
+
+// taskProgressMsg is a bubbletea message for a task.progress update
+type taskProgressMsg struct {
+	taskID        string
+	correlationID string
+	percent       int
+	text          string
+}
+
+// spinnerTickMsg advances the progress line's spinner
+type spinnerTickMsg struct{}
+
+// spinnerFrames are the progress line's spinner animation
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// spinnerInterval is the time between spinner frames
+const spinnerInterval = 100 * time.Millisecond
+
+// spinnerTick schedules the next spinner frame
+func spinnerTick() tea.Cmd {
+	return tea.Tick(spinnerInterval, func(time.Time) tea.Msg {
+		return spinnerTickMsg{}
+	})
 }
 
 // newModel creates a new bubbletea model
@@ -177,7 +384,7 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 
 		case tea.KeyEnter:
-			if m.input != "" {
+			if strings.TrimSpace(m.input) != "" {
 				// Add user message
 				m.messages = append(m.messages, message{
 					source: "you",
@@ -201,12 +408,40 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case incomingMessageMsg:
+		if m.active != nil && msg.taskID == m.active.id {
+			// The final answer replaces the progress line
+			m.active = nil
+		} else if m.active != nil && msg.taskID == "" && msg.correlationID != "" && msg.correlationID == m.active.correlationID {
+			// The progress line already shows this task's notifications
+			return m, nil
+		}
+
 		// Add message from broker
 		m.messages = append(m.messages, message{
-			source: msg.source,
-			text:   msg.text,
+			source:   msg.source,
+			text:     msg.text,
+			severity: msg.severity,
 		})
 
+	case taskProgressMsg:
+		// The first update for a task starts the spinner
+		starting := m.active == nil || m.active.id != msg.taskID
+		if starting {
+			m.active = &activeTask{id: msg.taskID, correlationID: msg.correlationID}
+		}
+		m.active.percent = msg.percent
+		m.active.text = msg.text
+		if starting {
+			return m, spinnerTick()
+		}
+
+	case spinnerTickMsg:
+		if m.active == nil {
+			return m, nil
+		}
+		m.active.frame = (m.active.frame + 1) % len(spinnerFrames)
+		return m, spinnerTick()
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -229,17 +464,37 @@ func (m *model) processCommand(input string) {
 	}
 
 	// Execute command
-	result, err := m.router.Route(m.ctx, input)
+	// The local terminal user is the operator
+	ctx := plugin.WithPrincipal(m.ctx, plugin.Principal{
+		Source: "tui",
+		UserID: os.Getenv("USER"),
+		Role:   plugin.RoleAdmin,
+	})
+	result, err := m.router.Route(ctx, input)
 	if err != nil {
 		m.addMessage("error", fmt.Sprintf("Error: %v", err))
 		return
 	}
 
-	if result != nil && result.Output != "" {
-		m.addMessage("system", result.Output)
+	if result == nil {
+		return
+	}
+
+	output := result.Output
+	if m.showData {
+		if data := renderData(result.Data); data != "" {
+			output = strings.TrimPrefix(output+"\n"+data, "\n")
+		}
+	}
+
+	if output != "" {
+		if !result.Untruncated {
+			output = cmd.Render("tui", output, m.maxRender)
+		}
+		m.addMessage("system", output)
 
 		// Broadcast if requested
-		if result.Broadcast {
+		if result.Broadcast && result.Output != "" {
 			m.broker.Publish(m.ctx, plugin.Message{
 				Topic:   "notification",
 				Payload: result.Output,
@@ -252,7 +507,7 @@ func (m *model) processCommand(input string) {
 // addMessage adds a message to the chat
 func (m *model) addMessage(source, text string) {
 	// Send via program to ensure thread-safety
-	if p, ok := m.ctx.Value("program").(*tea.Program); ok {
+	if p, ok := m.ctx.Value("program").(program); ok {
 		p.Send(incomingMessageMsg{source: source, text: text})
 	}
 }
@@ -293,6 +548,9 @@ func (m *model) View() string {
 
 	// Messages (show last N messages that fit)
 	availableHeight := m.height - 6 // Reserve space for title and input
+	if m.active != nil {
+		availableHeight-- // ...and the progress line
+	}
 	if availableHeight < 1 {
 		availableHeight = 10
 	}
@@ -318,13 +576,19 @@ func (m *model) View() string {
 			style = errorStyle
 		default:
 			prefix = fmt.Sprintf("[%s]: ", msg.source)
-			style = messageStyle
+			style = severityStyle(msg.severity)
 		}
 
 		s.WriteString(messageStyle.Render(style.Render(prefix) + msg.text))
 		s.WriteString("\n")
 	}
 
+	// Progress line for the running task
+	if line := m.progressLine(); line != "" {
+		s.WriteString(messageStyle.Render(systemStyle.Render(line)))
+		s.WriteString("\n")
+	}
+
 	// Input
 	s.WriteString("\n")
 	s.WriteString(inputStyle.Render("> " + m.input))
@@ -335,3 +599,30 @@ func (m *model) View() string {
 
 	return s.String()
 }
+
+// severityStyle returns the style for a broker message's source prefix
+func severityStyle(severity plugin.Severity) lipgloss.Style {
+	style := lipgloss.NewStyle().Padding(0, 2)
+	switch severity {
+	case plugin.SeverityError:
+		return style.Foreground(lipgloss.Color("196")).Bold(true)
+	case plugin.SeverityWarn:
+		return style.Foreground(lipgloss.Color("214")).Bold(true)
+	case plugin.SeveritySuccess:
+		return style.Foreground(lipgloss.Color("42"))
+	}
+	return style
+}
+
+// progressLine renders the running task's spinner and percent, or "" when
+// no task is running
+func (m *model) progressLine() string {
+	if m.active == nil {
+		return ""
+	}
+	line := fmt.Sprintf("%s %s %d%%", spinnerFrames[m.active.frame], m.active.id, m.active.percent)
+	if m.active.text != "" {
+		line += " - " + m.active.text
+	}
+	return line
+}
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"context"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestStopTwice(t *testing.T) {
+	// Starting takes over the terminal, so set up what Start leaves behind
+	// for Stop
+	broker := testutil.NewBroker()
+	p := NewTUIPlugin()
+	p.running.Store(true)
+	p.broker = broker
+	broker.Subscribe("tui", 1)
+
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+	if broker.Subscribed("tui") {
+		t.Error("still subscribed after stopping")
+	}
+}
+
+func TestSeverityStyles(t *testing.T) {
+	tests := []struct {
+		severity plugin.Severity
+		color    lipgloss.TerminalColor
+		bold     bool
+	}{
+		{plugin.SeverityError, lipgloss.Color("196"), true},
+		{plugin.SeverityWarn, lipgloss.Color("214"), true},
+		{plugin.SeveritySuccess, lipgloss.Color("42"), false},
+		{plugin.SeverityInfo, lipgloss.NoColor{}, false},
+	}
+	for _, tt := range tests {
+		style := severityStyle(tt.severity)
+		if got := style.GetForeground(); got != tt.color {
+			t.Errorf("%s foreground = %v, want %v", tt.severity, got, tt.color)
+		}
+		if got := style.GetBold(); got != tt.bold {
+			t.Errorf("%s bold = %v, want %v", tt.severity, got, tt.bold)
+		}
+	}
+}
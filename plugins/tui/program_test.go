@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// fakeProgram stands in for a bubbletea program; Run returns err once exit
+// is closed
+type fakeProgram struct {
+	err  error
+	exit chan struct{}
+}
+
+func (f *fakeProgram) Run() (tea.Model, error) {
+	<-f.exit
+	return nil, f.err
+}
+
+func (f *fakeProgram) Send(msg tea.Msg) {}
+func (f *fakeProgram) Quit()            {}
+
+// startWithProgram starts a TUI plugin running prog in interactive mode
+// under a fake daemon
+func startWithProgram(prog *fakeProgram) (*TUIPlugin, *testutil.Daemon, error) {
+	broker := testutil.NewBroker()
+	d := testutil.NewDaemon()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithDaemon(d),
+		testutil.WithMode(plugin.ModeInteractive),
+	)
+
+	p := NewTUIPlugin()
+	p.newProgram = func(m tea.Model) program { return prog }
+	p.Subscribe(ctx, broker)
+	return p, d, p.Start(ctx, broker)
+}
+
+func TestProgramFailingAtStartIsSurfaced(t *testing.T) {
+	prog := &fakeProgram{err: errors.New("could not open a new TTY"), exit: make(chan struct{})}
+	close(prog.exit)
+
+	p, d, err := startWithProgram(prog)
+	if err == nil || !strings.Contains(err.Error(), "could not open a new TTY") {
+		t.Fatalf("Start = %v, want the program's error", err)
+	}
+	if reasons := d.ShutdownReasons(); len(reasons) != 1 || !strings.Contains(reasons[0], "TUI failed to start") {
+		t.Errorf("shutdown reasons = %v, want the start failure", reasons)
+	}
+	if p.running.Load() {
+		t.Error("plugin still marked running after a failed start")
+	}
+}
+
+func TestProgramFailingLaterShutsDownDaemon(t *testing.T) {
+	prog := &fakeProgram{err: errors.New("terminal lost"), exit: make(chan struct{})}
+
+	p, d, err := startWithProgram(prog)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer p.Stop(context.Background())
+
+	close(prog.exit)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(d.ShutdownReasons()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if reasons := d.ShutdownReasons(); len(reasons) != 1 || reasons[0] != "TUI failed: terminal lost" {
+		t.Errorf("shutdown reasons = %v, want the program failure", reasons)
+	}
+}
+
+func TestStoppingProgramDoesNotShutDownDaemon(t *testing.T) {
+	prog := &fakeProgram{exit: make(chan struct{})}
+
+	p, d, err := startWithProgram(prog)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	p.Stop(context.Background())
+	close(prog.exit)
+
+	time.Sleep(20 * time.Millisecond)
+	if reasons := d.ShutdownReasons(); len(reasons) != 0 {
+		t.Errorf("shutdown reasons = %v, want none after Stop", reasons)
+	}
+}
@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// taskMessage is a broker message about task t1, addressed to replyTo
+func taskMessage(topic, replyTo string, payload interface{}, metadata map[string]interface{}) plugin.Message {
+	msg := plugin.Message{
+		Topic:   topic,
+		Source:  "echo",
+		Payload: payload,
+		Metadata: map[string]interface{}{
+			"task_id":                    "t1",
+			plugin.MetadataCorrelationID: "c1",
+			plugin.MetadataReplyTo:       replyTo,
+		},
+	}
+	for key, value := range metadata {
+		msg.Metadata[key] = value
+	}
+	return msg
+}
+
+func TestProgressLineUpdatesThenFinalizes(t *testing.T) {
+	p := NewTUIPlugin()
+	m := newModel(context.Background(), testutil.NewBroker())
+	feed := func(msg plugin.Message) {
+		t.Helper()
+		modelMsg := p.toModelMsg(msg)
+		if modelMsg == nil {
+			t.Fatalf("%s message was dropped", msg.Topic)
+		}
+		m.Update(modelMsg)
+	}
+
+	feed(taskMessage("task.progress", "tui", "Working", map[string]interface{}{"progress": 50}))
+	if line := m.progressLine(); !strings.HasSuffix(line, "t1 50% - Working") {
+		t.Errorf("progress line = %q, want t1 at 50%%", line)
+	}
+	before := len(m.messages)
+
+	// Updates change the line in place rather than adding messages
+	feed(taskMessage("task.progress", "tui", "", map[string]interface{}{"progress": 100}))
+	if line := m.progressLine(); !strings.HasSuffix(line, "t1 100%") {
+		t.Errorf("progress line = %q, want t1 at 100%%", line)
+	}
+	m.Update(spinnerTickMsg{})
+	if line := m.progressLine(); !strings.HasPrefix(line, spinnerFrames[1]) {
+		t.Errorf("progress line after a tick = %q, want spinner frame %s", line, spinnerFrames[1])
+	}
+
+	// The task's own notifications are folded into the progress line
+	feed(plugin.Message{
+		Topic:    "notification",
+		Payload:  "Started task: echo",
+		Metadata: map[string]interface{}{plugin.MetadataCorrelationID: "c1"},
+	})
+	if len(m.messages) != before {
+		t.Errorf("messages grew from %d to %d while the task ran", before, len(m.messages))
+	}
+
+	// The final answer replaces the progress line
+	feed(taskMessage("response", "tui", "hello", nil))
+	if line := m.progressLine(); line != "" {
+		t.Errorf("progress line after the response = %q, want none", line)
+	}
+	if last := m.messages[len(m.messages)-1]; last.text != "hello" {
+		t.Errorf("last message = %q, want the answer hello", last.text)
+	}
+}
+
+func TestProgressForOtherTransportsIgnored(t *testing.T) {
+	p := NewTUIPlugin()
+
+	for _, replyTo := range []string{"", "telegram:42"} {
+		msg := taskMessage("task.progress", replyTo, "", map[string]interface{}{"progress": 50})
+		if modelMsg := p.toModelMsg(msg); modelMsg != nil {
+			t.Errorf("progress addressed to %q became %#v, want it ignored", replyTo, modelMsg)
+		}
+	}
+}
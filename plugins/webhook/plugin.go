@@ -0,0 +1,325 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"bicycle/internal/config"
+	"bicycle/plugin"
+)
+
+// init registers the webhook plugin
+func init() {
+	plugin.Register(NewWebhookPlugin())
+}
+
+// defaultTimeout bounds one webhook request (in seconds)
+const defaultTimeout = 10
+
+// defaultContentType is sent for endpoints that don't set content_type
+const defaultContentType = "application/json"
+
+// WebhookPlugin posts broker messages to HTTP endpoints
+type WebhookPlugin struct {
+	broker    plugin.MessageBroker
+	ctx       context.Context
+	msgCh     <-chan plugin.Message
+	stopCh    chan struct{}
+	client    *http.Client
+	endpoints []*endpoint
+
+	// running is claimed when Start begins (and released if it fails) and
+	// cleared by Stop, so Stop closes stopCh only once
+	running atomic.Bool
+}
+
+// endpoint is one configured webhook target
+type endpoint struct {
+	name        string
+	url         string
+	topics      []string
+	contentType string
+
+	// body renders the request body (nil = the message as JSON)
+	body *template.Template
+}
+
+// TemplateData is what an endpoint's template renders
+type TemplateData struct {
+	Topic     string
+	Payload   interface{}
+	Text      string // Payload as text
+	Source    string
+	Metadata  map[string]interface{}
+	Timestamp time.Time
+}
+
+// templateFuncs are available to endpoint templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value, e.g. {"text": {{json .Text}}} for a quoted string
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// NewWebhookPlugin creates a new webhook plugin
+func NewWebhookPlugin() *WebhookPlugin {
+	return &WebhookPlugin{}
+}
+
+// Name returns the plugin name
+func (p *WebhookPlugin) Name() string {
+	return "webhook"
+}
+
+// Labels returns the plugin's label groups
+func (p *WebhookPlugin) Labels() []string {
+	return []string{"integration"}
+}
+
+// ValidateConfig checks the endpoints setting before the plugin starts
+func (p *WebhookPlugin) ValidateConfig(ctx context.Context) error {
+	var raw interface{}
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		raw, _ = cfg.GetPluginSetting("webhook", "endpoints")
+	}
+	_, err := parseEndpoints(raw)
+	return err
+}
+
+// CheckRequirements validates plugin requirements
+// The endpoints are checked by ValidateConfig
+func (p *WebhookPlugin) CheckRequirements(ctx context.Context) error {
+	return nil
+}
+
+// Extensions returns the plugin's extensions
+func (p *WebhookPlugin) Extensions() []plugin.Extension {
+	return []plugin.Extension{}
+}
+
+// Subscribe parses the endpoints and subscribes to their topics before any
+// plugin starts
+// An endpoint with a missing URL or an invalid template fails the start
+func (p *WebhookPlugin) Subscribe(ctx context.Context, broker plugin.MessageBroker) ([]string, error) {
+	var raw interface{}
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		raw, _ = cfg.GetPluginSetting("webhook", "endpoints")
+	}
+
+	endpoints, err := parseEndpoints(raw)
+	if err != nil {
+		return nil, err
+	}
+	p.endpoints = endpoints
+
+	// Subscribe to every topic an endpoint wants
+	var topics []string
+	for _, ep := range endpoints {
+		for _, topic := range ep.topics {
+			if !contains(topics, topic) {
+				topics = append(topics, topic)
+			}
+		}
+	}
+	p.msgCh = broker.Subscribe("webhook", 100, topics...)
+	return []string{"webhook"}, nil
+}
+
+// Start begins posting the messages Subscribe receives
+func (p *WebhookPlugin) Start(ctx context.Context, broker plugin.MessageBroker) (err error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	defer func() {
+		if err != nil {
+			p.running.Store(false)
+		}
+	}()
+
+	p.broker = broker
+	p.ctx = ctx
+	p.stopCh = make(chan struct{})
+
+	timeout := defaultTimeout
+	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		if t, ok := cfg.GetPluginSettingInt("webhook", "timeout"); ok {
+			timeout = t
+		}
+	}
+	p.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	go p.handleBrokerMessages()
+
+	log.Printf("[Webhook] Started (%d endpoint(s))", len(p.endpoints))
+	return nil
+}
+
+// Stop stops posting messages
+func (p *WebhookPlugin) Stop(ctx context.Context) error {
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
+	}
+	close(p.stopCh)
+	if p.broker != nil {
+		p.broker.Unsubscribe("webhook")
+	}
+
+	log.Printf("[Webhook] Stopped")
+	return nil
+}
+
+// handleBrokerMessages posts each message to the endpoints that want it
+func (p *WebhookPlugin) handleBrokerMessages() {
+	for {
+		select {
+		case msg, ok := <-p.msgCh:
+			if !ok {
+				return
+			}
+			for _, ep := range p.endpoints {
+				if ep.wants(msg.Topic) {
+					p.post(ep, msg)
+				}
+			}
+
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// post sends a message to an endpoint
+func (p *WebhookPlugin) post(ep *endpoint, msg plugin.Message) {
+	body, err := ep.render(msg, time.Now())
+	if err != nil {
+		log.Printf("[Webhook] Error rendering body for %s: %v", ep.name, err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[Webhook] Error creating request for %s: %v", ep.name, err)
+		return
+	}
+	req.Header.Set("Content-Type", ep.contentType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Printf("[Webhook] Error posting to %s: %v", ep.name, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("[Webhook] %s returned %s for topic %s", ep.name, resp.Status, msg.Topic)
+	}
+}
+
+// wants reports whether the endpoint receives messages on topic
+func (ep *endpoint) wants(topic string) bool {
+	return contains(ep.topics, topic) || contains(ep.topics, "*")
+}
+
+// render builds the request body for a message
+func (ep *endpoint) render(msg plugin.Message, now time.Time) ([]byte, error) {
+	data := TemplateData{
+		Topic:     msg.Topic,
+		Payload:   msg.Payload,
+		Text:      plugin.PayloadText(msg.Payload),
+		Source:    msg.Source,
+		Metadata:  msg.Metadata,
+		Timestamp: now,
+	}
+
+	if ep.body == nil {
+		return json.Marshal(map[string]interface{}{
+			"topic":     data.Topic,
+			"payload":   data.Payload,
+			"source":    data.Source,
+			"metadata":  data.Metadata,
+			"timestamp": data.Timestamp,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := ep.body.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseEndpoints reads the endpoints setting, a list of maps with name, url,
+// topics, template and content_type
+func parseEndpoints(raw interface{}) ([]*endpoint, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, plugin.NewConfigError("webhook", "endpoints", "must be a non-empty list")
+	}
+
+	var endpoints []*endpoint
+	for i, item := range list {
+		settings, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, plugin.NewConfigError("webhook", "endpoints", fmt.Sprintf("endpoint %d must be a map", i+1))
+		}
+
+		ep := &endpoint{contentType: defaultContentType}
+		ep.name, _ = settings["name"].(string)
+		if ep.name == "" {
+			ep.name = fmt.Sprintf("endpoint %d", i+1)
+		}
+		ep.url, _ = settings["url"].(string)
+		if ep.url == "" {
+			return nil, plugin.NewConfigError("webhook", "endpoints", fmt.Sprintf("%s: url is required", ep.name))
+		}
+		if contentType, ok := settings["content_type"].(string); ok && contentType != "" {
+			ep.contentType = contentType
+		}
+
+		switch topics := settings["topics"].(type) {
+		case nil:
+			ep.topics = []string{"notification"}
+		case []interface{}:
+			for _, t := range topics {
+				topic, ok := t.(string)
+				if !ok || topic == "" {
+					return nil, plugin.NewConfigError("webhook", "endpoints", fmt.Sprintf("%s: topics must be strings", ep.name))
+				}
+				ep.topics = append(ep.topics, topic)
+			}
+		default:
+			return nil, plugin.NewConfigError("webhook", "endpoints", fmt.Sprintf("%s: topics must be a list", ep.name))
+		}
+
+		if text, ok := settings["template"].(string); ok && strings.TrimSpace(text) != "" {
+			tmpl, err := template.New(ep.name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return nil, plugin.NewConfigError("webhook", "endpoints", fmt.Sprintf("%s: invalid template: %v", ep.name, err))
+			}
+			ep.body = tmpl
+		}
+
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+func TestStopTwice(t *testing.T) {
+	broker := testutil.NewBroker()
+	p := NewWebhookPlugin()
+	if err := p.Start(testutil.NewContext(testutil.WithBroker(broker)), broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+}
+
+// posted is a request received by a test endpoint
+type posted struct {
+	contentType string
+	body        string
+}
+
+// startWebhook starts the plugin with the given endpoints on a fake broker,
+// subscribing first as the daemon does
+func startWebhook(t *testing.T, endpoints []interface{}) (*WebhookPlugin, *testutil.Broker) {
+	t.Helper()
+
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("webhook", "endpoints", endpoints),
+	)
+	p := NewWebhookPlugin()
+	if _, err := p.Subscribe(ctx, broker); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { p.Stop(context.Background()) })
+	return p, broker
+}
+
+func TestSlackTemplateRendersBody(t *testing.T) {
+	requests := make(chan posted, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- posted{contentType: r.Header.Get("Content-Type"), body: string(body)}
+	}))
+	defer server.Close()
+
+	_, broker := startWebhook(t, []interface{}{map[string]interface{}{
+		"name":         "slack",
+		"url":          server.URL,
+		"topics":       []interface{}{"notification"},
+		"content_type": "application/json; charset=utf-8",
+		"template":     `{"text": {{json .Text}}, "username": "{{.Source}}", "channel": "{{.Metadata.channel}}"}`,
+	}})
+	broker.Publish(context.Background(), plugin.Message{
+		Topic:    "notification",
+		Payload:  `Build "42" failed`,
+		Source:   "ci",
+		Metadata: map[string]interface{}{"channel": "#builds"},
+	})
+
+	select {
+	case req := <-requests:
+		want := `{"text": "Build \"42\" failed", "username": "ci", "channel": "#builds"}`
+		if req.body != want {
+			t.Errorf("body = %s, want %s", req.body, want)
+		}
+		if req.contentType != "application/json; charset=utf-8" {
+			t.Errorf("content type = %q, want the endpoint's", req.contentType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing posted")
+	}
+}
+
+func TestInvalidTemplateFailsStart(t *testing.T) {
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(testutil.WithPluginSetting("webhook", "endpoints", []interface{}{
+		map[string]interface{}{"name": "broken", "url": "http://example.invalid", "template": "{{.Text"},
+	}))
+
+	_, err := NewWebhookPlugin().Subscribe(ctx, broker)
+	if err == nil || !strings.Contains(err.Error(), "broken: invalid template") {
+		t.Errorf("Subscribe = %v, want an invalid template error", err)
+	}
+}
+
+func TestDefaultBodyIsMessageJSON(t *testing.T) {
+	ep := &endpoint{name: "raw"}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	body, err := ep.render(plugin.Message{Topic: "notification", Payload: "hi", Source: "tui"}, now)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %s is not JSON: %v", body, err)
+	}
+	if got["topic"] != "notification" || got["payload"] != "hi" || got["source"] != "tui" || got["timestamp"] != "2026-01-02T03:04:05Z" {
+		t.Errorf("body = %s, want the message as JSON", body)
+	}
+}
@@ -0,0 +1,108 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// allBytes returns every byte value, which a string conversion would mangle
+func allBytes() []byte {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestBinaryPayloadRoundTrip(t *testing.T) {
+	broker := testutil.NewBroker()
+	p := newDeliveryPlugin(broker)
+	server := httptest.NewServer(http.HandlerFunc(p.handleWebSocket))
+	defer server.Close()
+
+	conn, _, err := dial(t, server, ProtocolV1)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	var welcome WSMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("reading welcome: %v", err)
+	}
+
+	// Client to broker: the base64 text arrives as the original bytes
+	data := allBytes()
+	text, encoding := plugin.EncodePayload(data)
+	if err := conn.WriteJSON(WSMessage{Type: "chat", Payload: text, Encoding: encoding}); err != nil {
+		t.Fatalf("sending chat: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(broker.PublishedOn("chat")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	chats := broker.PublishedOn("chat")
+	if len(chats) != 1 {
+		t.Fatalf("published %d chat messages, want 1", len(chats))
+	}
+	if got, ok := chats[0].Payload.([]byte); !ok || !bytes.Equal(got, data) {
+		t.Errorf("published payload = %v, want the original bytes", chats[0].Payload)
+	}
+	if got := chats[0].Metadata[plugin.MetadataEncoding]; got != plugin.EncodingBase64 {
+		t.Errorf("encoding metadata = %v, want %s", got, plugin.EncodingBase64)
+	}
+
+	// Broker to client: a []byte payload goes out base64-encoded
+	p.deliver(context.Background(), plugin.Message{Topic: "response", Payload: data})
+	var reply WSMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+	if reply.Encoding != plugin.EncodingBase64 {
+		t.Fatalf("reply encoding = %q, want %s", reply.Encoding, plugin.EncodingBase64)
+	}
+	decoded, err := plugin.DecodePayload(reply.Payload, reply.Encoding)
+	if err != nil {
+		t.Fatalf("DecodePayload: %v", err)
+	}
+	if !bytes.Equal(decoded.([]byte), data) {
+		t.Errorf("client got %v, want the original bytes", decoded)
+	}
+}
+
+func TestInvalidBase64ChatRejected(t *testing.T) {
+	broker := testutil.NewBroker()
+	p := newDeliveryPlugin(broker)
+	server := httptest.NewServer(http.HandlerFunc(p.handleWebSocket))
+	defer server.Close()
+
+	conn, _, err := dial(t, server)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	var welcome WSMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("reading welcome: %v", err)
+	}
+
+	if err := conn.WriteJSON(WSMessage{Type: "chat", Payload: "not base64!", Encoding: plugin.EncodingBase64}); err != nil {
+		t.Fatalf("sending chat: %v", err)
+	}
+	var reply WSMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+	if reply.Type != "error" {
+		t.Errorf("reply = %+v, want an error", reply)
+	}
+	if chats := broker.PublishedOn("chat"); len(chats) != 0 {
+		t.Errorf("published %v, want nothing for an invalid payload", chats)
+	}
+}
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bicycle/cmd"
+	"bicycle/plugin"
+
+	"github.com/gorilla/websocket"
+)
+
+// slowStarted and slowRelease let a test hold /test-slow mid-command;
+// each release lets one run finish
+var (
+	slowStarted = make(chan struct{}, 1)
+	slowRelease = make(chan struct{})
+)
+
+func init() {
+	cmd.Register(&plugin.Command{
+		Name:        "test-deadline",
+		Description: "Reports how long it was given to run",
+		Hidden:      true,
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				return &plugin.CommandResult{Output: "none"}, nil
+			}
+			return &plugin.CommandResult{Output: time.Until(deadline).Round(time.Second).String()}, nil
+		},
+	})
+	cmd.Register(&plugin.Command{
+		Name:        "test-slow",
+		Description: "Blocks until the test releases it",
+		Hidden:      true,
+		Handler: func(ctx context.Context, args []string) (*plugin.CommandResult, error) {
+			slowStarted <- struct{}{}
+			select {
+			case <-slowRelease:
+				return &plugin.CommandResult{Output: "slow done"}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	})
+}
+
+func TestSlowCommandDoesNotBlockPings(t *testing.T) {
+	_, socket := startOnSocket(t, map[string]interface{}{"command_queue": 1})
+	conn := dialSocket(t, socket)
+
+	pongs := make(chan struct{}, 1)
+	conn.SetPongHandler(func(string) error {
+		pongs <- struct{}{}
+		return nil
+	})
+	replies := make(chan WSMessage, 10)
+	go func() {
+		defer close(replies)
+		for {
+			var msg WSMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			replies <- msg
+		}
+	}()
+
+	send := func(msg WSMessage) {
+		t.Helper()
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("WriteJSON: %v", err)
+		}
+	}
+	reply := func() WSMessage {
+		t.Helper()
+		select {
+		case msg := <-replies:
+			return msg
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reply from the server")
+			return WSMessage{}
+		}
+	}
+
+	send(WSMessage{Type: "command", Payload: "/test-slow"})
+	select {
+	case <-slowStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow command never started")
+	}
+
+	// The command is still running, but the connection answers pings
+	if err := conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(5*time.Second)); err != nil {
+		t.Fatalf("WriteControl: %v", err)
+	}
+	select {
+	case <-pongs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ping not answered while a command was running")
+	}
+
+	// One command may wait behind the running one; the next is turned away
+	send(WSMessage{Type: "command", Payload: "/test-slow"})
+	send(WSMessage{Type: "command", Payload: "/test-slow"})
+	if msg := reply(); msg.Type != "error" || !strings.Contains(msg.Payload, "Too many commands") {
+		t.Errorf("reply to the third command = %s %q, want a queue full error", msg.Type, msg.Payload)
+	}
+
+	slowRelease <- struct{}{}
+	<-slowStarted
+	slowRelease <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if msg := reply(); msg.Type != "response" || msg.Payload != "slow done" {
+			t.Errorf("reply %d = %s %q, want the slow command's response", i+1, msg.Type, msg.Payload)
+		}
+	}
+}
+
+func TestCommandTimeoutFromConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		want     string
+	}{
+		{"global default", nil, "30s"},
+		{"websocket override", map[string]interface{}{"command_timeout": 3}, "3s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, socket := startOnSocket(t, tt.settings)
+			conn := dialSocket(t, socket)
+
+			if err := conn.WriteJSON(WSMessage{Type: "command", Payload: "/test-deadline"}); err != nil {
+				t.Fatalf("WriteJSON: %v", err)
+			}
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			var reply WSMessage
+			if err := conn.ReadJSON(&reply); err != nil {
+				t.Fatalf("reading reply: %v", err)
+			}
+			if reply.Type != "response" || reply.Payload != tt.want {
+				t.Errorf("reply = %s %q, want a %s deadline", reply.Type, reply.Payload, tt.want)
+			}
+		})
+	}
+}
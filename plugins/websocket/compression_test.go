@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// countingConn counts the bytes read off the wire, before decompression
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// dialCompressed dials the socket offering permessage-deflate and returns
+// the connection with a counter of the raw bytes it has read
+func dialCompressed(t *testing.T, socket string) (*websocket.Conn, *atomic.Int64) {
+	t.Helper()
+
+	read := new(atomic.Int64)
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			if err != nil {
+				return nil, err
+			}
+			return countingConn{conn, read}, nil
+		},
+	}
+	conn, resp, err := dialer.Dial("ws://localhost/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("extensions = %q, want permessage-deflate negotiated", ext)
+	}
+
+	var welcome WSMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("reading welcome: %v", err)
+	}
+	return conn, read
+}
+
+// received broadcasts msg and returns it as the client read it with the
+// raw bytes that carried it
+func received(t *testing.T, p *WebSocketPlugin, conn *websocket.Conn, read *atomic.Int64, msg WSMessage) (WSMessage, int64) {
+	t.Helper()
+
+	read.Store(0)
+	if failed := p.broadcast(msg, time.Time{}); failed != 0 {
+		t.Fatalf("broadcast failed to %d client(s)", failed)
+	}
+	var got WSMessage
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatalf("reading broadcast: %v", err)
+	}
+	return got, read.Load()
+}
+
+func TestLargeBroadcastsCompressed(t *testing.T) {
+	p, socket := startOnSocket(t, map[string]interface{}{"compression": true, "compression_threshold": 256})
+	conn, read := dialCompressed(t, socket)
+
+	large := WSMessage{Type: "notification", Payload: strings.Repeat("all work and no play ", 1000)}
+	got, wire := received(t, p, conn, read, large)
+	if got.Payload != large.Payload {
+		t.Fatalf("large payload garbled after decompression (%d bytes, want %d)", len(got.Payload), len(large.Payload))
+	}
+	if wire >= int64(len(large.Payload))/4 {
+		t.Errorf("large payload took %d bytes on the wire for %d bytes, want it compressed", wire, len(large.Payload))
+	}
+
+	small := WSMessage{Type: "notification", Payload: "short"}
+	got, wire = received(t, p, conn, read, small)
+	if got.Payload != small.Payload {
+		t.Fatalf("small payload = %q, want %q", got.Payload, small.Payload)
+	}
+	if wire < int64(len(`"payload":"short"`)) {
+		t.Errorf("small payload took %d bytes on the wire, want it sent uncompressed", wire)
+	}
+}
+
+func TestCompressionOffByDefault(t *testing.T) {
+	_, socket := startOnSocket(t, nil)
+
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}
+	conn, resp, err := dialer.Dial("ws://localhost/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Errorf("extensions = %q, want none negotiated without websocket.compression", ext)
+	}
+}
+
+func TestConcurrentCompressedWrites(t *testing.T) {
+	p, socket := startOnSocket(t, map[string]interface{}{"compression": true, "compression_threshold": 64})
+	conn, _ := dialCompressed(t, socket)
+
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.broadcast(WSMessage{Type: "notification", Payload: strings.Repeat(string(rune('a'+i)), 2048)}, time.Time{})
+		}(i)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < writers; i++ {
+		var got WSMessage
+		if err := conn.ReadJSON(&got); err != nil {
+			t.Fatalf("reading broadcast %d: %v", i, err)
+		}
+		if len(got.Payload) != 2048 || strings.Trim(got.Payload, got.Payload[:1]) != "" {
+			t.Fatalf("broadcast %d garbled: %.40q...", i, got.Payload)
+		}
+		seen[got.Payload[:1]] = true
+	}
+	wg.Wait()
+	if len(seen) != writers {
+		t.Errorf("got %d distinct broadcasts, want %d", len(seen), writers)
+	}
+}
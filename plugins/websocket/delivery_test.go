@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+)
+
+// newDeliveryPlugin returns a plugin with no clients, wired to broker
+// without starting its server
+func newDeliveryPlugin(broker *testutil.Broker) *WebSocketPlugin {
+	p := NewWebSocketPlugin()
+	p.broker = broker
+	p.ctx = testutil.NewContext(testutil.WithBroker(broker))
+	return p
+}
+
+func TestUndeliverableReplyReportsFailure(t *testing.T) {
+	broker := testutil.NewBroker()
+	p := newDeliveryPlugin(broker)
+
+	p.deliver(context.Background(), plugin.Message{
+		Topic:   "response",
+		Payload: "done",
+		Metadata: map[string]interface{}{
+			"task_id":              "t1",
+			plugin.MetadataReplyTo: "websocket:10.0.0.1:4000",
+		},
+	})
+
+	failures := broker.PublishedOn(plugin.TopicDeliveryFailed)
+	if len(failures) != 1 {
+		t.Fatalf("published %d delivery failures, want 1", len(failures))
+	}
+	msg := failures[0]
+	if msg.Metadata["task_id"] != "t1" || msg.Metadata["topic"] != "response" || msg.Source != "websocket" {
+		t.Errorf("delivery failure = %+v, want task t1's response from websocket", msg)
+	}
+	if msg.Metadata["error"] == "" {
+		t.Error("delivery failure carries no error")
+	}
+}
+
+func TestUndeliverableMessageWithoutTaskIsNotReported(t *testing.T) {
+	broker := testutil.NewBroker()
+	p := newDeliveryPlugin(broker)
+
+	p.deliver(context.Background(), plugin.Message{
+		Topic:    "notification",
+		Payload:  "hello",
+		Metadata: map[string]interface{}{plugin.MetadataReplyTo: "websocket:10.0.0.1:4000"},
+	})
+
+	if failures := broker.PublishedOn(plugin.TopicDeliveryFailed); len(failures) != 0 {
+		t.Errorf("published %v, want no delivery failure without a task id", failures)
+	}
+}
@@ -3,13 +3,19 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"bicycle/cmd"
 	"bicycle/internal/config"
+	"bicycle/internal/listener"
 	"bicycle/plugin"
 
 	"github.com/gorilla/websocket"
@@ -27,23 +33,90 @@ type WebSocketPlugin struct {
 	msgCh   <-chan plugin.Message
 	ctx     context.Context
 	server  *http.Server
-	clients map[*websocket.Conn]bool
+	clients map[*websocket.Conn]*sync.Mutex // Each client's write lock
 	mu      sync.RWMutex
 	upgrader websocket.Upgrader
+
+	// subscriptions holds the extra topic patterns each client opted into
+	subscriptions map[*websocket.Conn][]string
+
+	// goodbye is sent to clients on shutdown (empty = none)
+	goodbye string
+
+	// handlerTimeout bounds delivering one broker message (0 = no limit)
+	handlerTimeout time.Duration
+
+	// writeMu serializes broker deliveries, which may overlap when one overruns
+	writeMu sync.Mutex
+
+	// serverFailed is set when the HTTP server stops on its own
+	serverFailed atomic.Bool
+
+	// deliveryStopped is set when the broker message loop ends before Stop
+	deliveryStopped atomic.Bool
+
+	// stopping is set by Stop, so loops ending then don't count as failures
+	stopping atomic.Bool
+
+	// compression enables permessage-deflate for clients that offer it
+	compression bool
+
+	// compressionThreshold is the smallest message compressed (in bytes)
+	compressionThreshold int
+
+	// commandQueue is how many commands each client may have waiting to run
+	commandQueue int
+
+	// running is claimed when Start begins (and released if it fails) and
+	// cleared by Stop; repeated or concurrent calls to either are no-ops
+	running atomic.Bool
+}
+
+// defaultGoodbyeMessage is the notification sent to clients on shutdown
+const defaultGoodbyeMessage = "Daemon shutting down"
+
+// closeWriteTimeout bounds writing the close frame to each client
+const closeWriteTimeout = time.Second
+
+// defaultHandlerTimeout bounds delivering one broker message (in seconds)
+const defaultHandlerTimeout = 10
+
+// defaultCompressionThreshold is the smallest message worth compressing (in bytes)
+const defaultCompressionThreshold = 1024
+
+// defaultCommandQueue is how many commands a client may have waiting to run
+const defaultCommandQueue = 16
+
+// ProtocolV1 is the subprotocol for the initial WebSocket message schema
+const ProtocolV1 = "bicycle.v1"
+
+// supportedProtocols lists the subprotocols the server accepts, newest first
+var supportedProtocols = []string{ProtocolV1}
+
+// optInTopics are delivered only to clients that subscribed to them
+var optInTopics = []string{
+	plugin.TopicTaskStarted,
+	plugin.TopicTaskProgress,
+	plugin.TopicTaskCompleted,
+	plugin.TopicTaskFailed,
+	plugin.TopicTaskRetrying,
 }
 
 // WSMessage represents a WebSocket message
 type WSMessage struct {
-	Type    string                 `json:"type"`    // "command", "chat", "notification"
-	Payload string                 `json:"payload"` // Message content
-	Data    map[string]interface{} `json:"data,omitempty"`
+	Type     string                 `json:"type"`               // "command", "chat", "subscribe", "unsubscribe", "notification"
+	Payload  string                 `json:"payload"`            // Message content
+	Encoding string                 `json:"encoding,omitempty"` // "base64" for binary payloads
+	Data     map[string]interface{} `json:"data,omitempty"`
 }
 
 // NewWebSocketPlugin creates a new WebSocket plugin
 func NewWebSocketPlugin() *WebSocketPlugin {
 	return &WebSocketPlugin{
-		clients: make(map[*websocket.Conn]bool),
+		clients:       make(map[*websocket.Conn]*sync.Mutex),
+		subscriptions: make(map[*websocket.Conn][]string),
 		upgrader: websocket.Upgrader{
+			Subprotocols: supportedProtocols,
 			CheckOrigin: func(r *http.Request) bool {
 				// TODO: Add origin checking for security
 				return true
@@ -57,6 +130,27 @@ func (p *WebSocketPlugin) Name() string {
 	return "websocket"
 }
 
+// Labels returns the plugin's label groups
+func (p *WebSocketPlugin) Labels() []string {
+	return []string{"api"}
+}
+
+// SettingsSchema lists the settings the plugin reads
+func (p *WebSocketPlugin) SettingsSchema() map[string]plugin.SettingKind {
+	return map[string]plugin.SettingKind{
+		"port":                  plugin.SettingInt,
+		"host":                  plugin.SettingString,
+		"unix_socket":           plugin.SettingString,
+		"unix_socket_mode":      plugin.SettingString,
+		"goodbye_message":       plugin.SettingString,
+		"handler_timeout":       plugin.SettingInt,
+		"compression":           plugin.SettingBool,
+		"compression_threshold": plugin.SettingInt,
+		"command_queue":         plugin.SettingInt,
+		"command_timeout":       plugin.SettingInt,
+	}
+}
+
 // CheckRequirements validates plugin requirements
 func (p *WebSocketPlugin) CheckRequirements(ctx context.Context) error {
 	checker := plugin.NewRequirementChecker("websocket")
@@ -76,22 +170,77 @@ func (p *WebSocketPlugin) Extensions() []plugin.Extension {
 	return []plugin.Extension{}
 }
 
+// Subscribe subscribes to the messages sent to clients before any plugin starts
+func (p *WebSocketPlugin) Subscribe(ctx context.Context, broker plugin.MessageBroker) ([]string, error) {
+	topics := append([]string{"notification", "response"}, optInTopics...)
+	p.msgCh = broker.Subscribe("websocket", 100, topics...)
+	return []string{"websocket"}, nil
+}
+
 // Start initializes the WebSocket server
-func (p *WebSocketPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error {
+func (p *WebSocketPlugin) Start(ctx context.Context, broker plugin.MessageBroker) (err error) {
+	if !p.running.CompareAndSwap(false, true) {
+		return nil // Already started, or starting
+	}
+	defer func() {
+		if err != nil {
+			p.running.Store(false)
+		}
+	}()
+
 	p.broker = broker
 	p.ctx = ctx
 	p.router = cmd.NewRouter()
 
-	// Get port from config
-	port := 8080
+	// Get the listen address from config (every interface by default)
+	listen := listener.Config{Port: 8080}
+	p.goodbye = defaultGoodbyeMessage
+	p.handlerTimeout = defaultHandlerTimeout * time.Second
+	p.compression = false
+	p.compressionThreshold = defaultCompressionThreshold
+	p.commandQueue = defaultCommandQueue
 	if cfg, ok := ctx.Value("config").(*config.Config); ok {
+		p.router.SetTimeout(time.Duration(cfg.CommandTimeoutFor("websocket")) * time.Second)
 		if portVal, ok := cfg.GetPluginSettingInt("websocket", "port"); ok {
-			port = portVal
+			listen.Port = portVal
+		}
+		listen.Host, _ = cfg.GetPluginSettingString("websocket", "host")
+		listen.UnixSocket, _ = cfg.GetPluginSettingString("websocket", "unix_socket")
+		if modeVal, ok := cfg.GetPluginSettingString("websocket", "unix_socket_mode"); ok {
+			mode, err := listener.ParseSocketMode(modeVal)
+			if err != nil {
+				return plugin.NewConfigError("websocket", "unix_socket_mode", err.Error())
+			}
+			listen.SocketMode = mode
+		}
+		if goodbye, ok := cfg.GetPluginSettingString("websocket", "goodbye_message"); ok {
+			p.goodbye = goodbye
+		}
+		if timeout, ok := cfg.GetPluginSettingInt("websocket", "handler_timeout"); ok {
+			p.handlerTimeout = time.Duration(timeout) * time.Second
+		}
+		if compression, ok := cfg.GetPluginSettingBool("websocket", "compression"); ok {
+			p.compression = compression
+		}
+		if threshold, ok := cfg.GetPluginSettingInt("websocket", "compression_threshold"); ok {
+			p.compressionThreshold = threshold
 		}
+		if queue, ok := cfg.GetPluginSettingInt("websocket", "command_queue"); ok && queue > 0 {
+			p.commandQueue = queue
+		}
+	}
+	p.upgrader.EnableCompression = p.compression
+
+	// Bind here rather than in the server goroutine, so an address in use
+	// fails the start
+	ln, err := listener.Listen(listen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", listen, err)
 	}
 
-	// Subscribe to broker messages
-	p.msgCh = broker.Subscribe("websocket", 100, "notification", "response")
+	p.serverFailed.Store(false)
+	p.deliveryStopped.Store(false)
+	p.stopping.Store(false)
 
 	// Start broker message handler
 	go p.handleBrokerMessages()
@@ -101,15 +250,15 @@ func (p *WebSocketPlugin) Start(ctx context.Context, broker plugin.MessageBroker
 	mux.HandleFunc("/ws", p.handleWebSocket)
 
 	p.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
 		Handler: mux,
 	}
 
 	// Start server
 	go func() {
-		log.Printf("[WebSocket] Starting server on port %d", port)
-		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("[WebSocket] Starting server on %s", listen)
+		if err := p.server.Serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Printf("[WebSocket] Server error: %v", err)
+			p.serverFailed.Store(true)
 		}
 	}()
 
@@ -119,13 +268,21 @@ func (p *WebSocketPlugin) Start(ctx context.Context, broker plugin.MessageBroker
 
 // Stop shuts down the WebSocket server
 func (p *WebSocketPlugin) Stop(ctx context.Context) error {
-	// Close all client connections
-	p.mu.Lock()
-	for conn := range p.clients {
-		conn.Close()
+	if !p.running.CompareAndSwap(true, false) {
+		return nil // Not started, or already stopped
 	}
-	p.clients = make(map[*websocket.Conn]bool)
+	p.stopping.Store(true)
+
+	// Say goodbye and close all client connections cleanly, outside p.mu
+	// since a client's write lock may be held by a slow write
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = make(map[*websocket.Conn]*sync.Mutex)
+	p.subscriptions = make(map[*websocket.Conn][]string)
 	p.mu.Unlock()
+	for conn, lock := range clients {
+		p.closeClient(conn, lock)
+	}
 
 	// Shutdown server
 	if p.server != nil {
@@ -143,8 +300,55 @@ func (p *WebSocketPlugin) Stop(ctx context.Context) error {
 	return nil
 }
 
+// HealthCheck reports whether the server and broker message loop are running
+func (p *WebSocketPlugin) HealthCheck(ctx context.Context) error {
+	if p.serverFailed.Load() {
+		return fmt.Errorf("server stopped")
+	}
+	if p.deliveryStopped.Load() {
+		return fmt.Errorf("broker message loop stopped")
+	}
+	return nil
+}
+
+// closeClient sends the goodbye notification and a normal close frame, then
+// closes the connection
+func (p *WebSocketPlugin) closeClient(conn *websocket.Conn, lock *sync.Mutex) {
+	if p.goodbye != "" {
+		msg := WSMessage{
+			Type:    "notification",
+			Payload: p.goodbye,
+		}
+		if err := p.write(conn, lock, msg, time.Now().Add(closeWriteTimeout)); err != nil {
+			log.Printf("[WebSocket] Write error: %v", err)
+		}
+	}
+
+	frame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "daemon shutting down")
+	if err := conn.WriteControl(websocket.CloseMessage, frame, time.Now().Add(closeWriteTimeout)); err != nil {
+		log.Printf("[WebSocket] Error sending close frame: %v", err)
+	}
+	conn.Close()
+}
+
+// StatusSection reports connected clients for the daemon status
+func (p *WebSocketPlugin) StatusSection() (string, []string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return "WebSocket", []string{fmt.Sprintf("Connected clients: %d", len(p.clients))}
+}
+
 // handleWebSocket handles WebSocket connections
 func (p *WebSocketPlugin) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Reject clients that only speak protocol versions we don't support
+	// Clients that request no subprotocol get the default version
+	if requested := websocket.Subprotocols(r); len(requested) > 0 && !supportsAny(requested) {
+		log.Printf("[WebSocket] Rejected client %s: unsupported subprotocol(s) %v", r.RemoteAddr, requested)
+		http.Error(w, fmt.Sprintf("unsupported subprotocol (supported: %v)", supportedProtocols), http.StatusBadRequest)
+		return
+	}
+
 	// Upgrade connection
 	conn, err := p.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -154,15 +358,21 @@ func (p *WebSocketPlugin) handleWebSocket(w http.ResponseWriter, r *http.Request
 
 	// Register client
 	p.mu.Lock()
-	p.clients[conn] = true
+	p.clients[conn] = &sync.Mutex{}
 	p.mu.Unlock()
 
-	log.Printf("[WebSocket] Client connected from %s", r.RemoteAddr)
+	protocol := conn.Subprotocol()
+	if protocol == "" {
+		protocol = ProtocolV1
+	}
+
+	log.Printf("[WebSocket] Client connected from %s (protocol: %s)", r.RemoteAddr, protocol)
 
 	// Send welcome message
 	p.sendToClient(conn, WSMessage{
 		Type:    "notification",
 		Payload: "Connected to Bicycle daemon",
+		Data:    map[string]interface{}{"protocol": protocol},
 	})
 
 	// Handle client messages
@@ -175,11 +385,19 @@ func (p *WebSocketPlugin) handleClientMessages(conn *websocket.Conn) {
 		// Unregister client
 		p.mu.Lock()
 		delete(p.clients, conn)
+		delete(p.subscriptions, conn)
 		p.mu.Unlock()
 		conn.Close()
 		log.Printf("[WebSocket] Client disconnected")
 	}()
 
+	// Commands run on their own goroutine, so a slow one doesn't stop us
+	// reading the client's next messages (and answering its pings)
+	commands := make(chan string, p.commandQueue)
+	done := make(chan struct{})
+	defer close(done)
+	go p.runCommands(conn, commands, done)
+
 	for {
 		var msg WSMessage
 		err := conn.ReadJSON(&msg)
@@ -190,15 +408,32 @@ func (p *WebSocketPlugin) handleClientMessages(conn *websocket.Conn) {
 			break
 		}
 
-		log.Printf("[WebSocket] Received: type=%s, payload=%s", msg.Type, msg.Payload)
+		if msg.Encoding == "" {
+			log.Printf("[WebSocket] Received: type=%s, payload=%s", msg.Type, msg.Payload)
+		} else {
+			log.Printf("[WebSocket] Received: type=%s, %s payload (%d chars)", msg.Type, msg.Encoding, len(msg.Payload))
+		}
 
 		// Process message based on type
 		switch msg.Type {
 		case "command":
-			p.handleCommand(conn, msg.Payload)
+			select {
+			case commands <- msg.Payload:
+			default:
+				p.sendToClient(conn, WSMessage{
+					Type:    "error",
+					Payload: "Too many commands waiting, try again shortly",
+				})
+			}
 
 		case "chat":
-			p.handleChat(msg.Payload)
+			p.handleChat(conn, msg)
+
+		case "subscribe":
+			p.handleSubscribe(conn, msg.Payload, true)
+
+		case "unsubscribe":
+			p.handleSubscribe(conn, msg.Payload, false)
 
 		default:
 			p.sendToClient(conn, WSMessage{
@@ -209,9 +444,46 @@ func (p *WebSocketPlugin) handleClientMessages(conn *websocket.Conn) {
 	}
 }
 
+// runCommands runs a client's queued commands one at a time, in the order
+// they arrived, until done is closed; commands still queued then are dropped
+func (p *WebSocketPlugin) runCommands(conn *websocket.Conn, commands <-chan string, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case command := <-commands:
+			p.handleCommand(conn, command)
+		}
+	}
+}
+
 // handleCommand processes a command from WebSocket
 func (p *WebSocketPlugin) handleCommand(conn *websocket.Conn, command string) {
-	result, err := p.router.Route(p.ctx, command)
+	if !plugin.IsReady(p.ctx) {
+		p.sendToClient(conn, WSMessage{
+			Type:    "error",
+			Payload: "Daemon is starting, try again shortly",
+		})
+		return
+	}
+
+	// Replies go to this connection, but rate limits follow the client's
+	// host, so reconnecting doesn't reset them
+	addr := conn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ctx := plugin.WithPrincipal(p.ctx, plugin.Principal{
+		Source:   "websocket:" + addr,
+		UserID:   addr,
+		Role:     plugin.RoleUser,
+		LimitKey: "websocket:" + host,
+	})
+	ctx = plugin.WithCorrelationID(ctx, plugin.NewCorrelationID())
+	plugin.Logf(ctx, "[WebSocket] Command from %s: %s", conn.RemoteAddr(), command)
+
+	result, err := p.router.Route(ctx, command)
 	if err != nil {
 		p.sendToClient(conn, WSMessage{
 			Type:    "error",
@@ -238,55 +510,312 @@ func (p *WebSocketPlugin) handleCommand(conn *websocket.Conn, command string) {
 	}
 }
 
+// handleSubscribe adds or removes a topic pattern for a client
+// Patterns are a topic name, a prefix ending in ".*" (e.g. "task.*") or "*"
+func (p *WebSocketPlugin) handleSubscribe(conn *websocket.Conn, pattern string, subscribe bool) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		p.sendToClient(conn, WSMessage{
+			Type:    "error",
+			Payload: "Topic pattern required",
+		})
+		return
+	}
+
+	p.mu.Lock()
+	patterns := p.subscriptions[conn]
+	kept := make([]string, 0, len(patterns)+1)
+	for _, existing := range patterns {
+		if existing != pattern {
+			kept = append(kept, existing)
+		}
+	}
+	if subscribe {
+		kept = append(kept, pattern)
+	}
+	p.subscriptions[conn] = kept
+	p.mu.Unlock()
+
+	p.sendToClient(conn, WSMessage{
+		Type:    "response",
+		Payload: fmt.Sprintf("Subscribed to: %s", strings.Join(kept, ", ")),
+		Data:    map[string]interface{}{"subscriptions": kept},
+	})
+}
+
 // handleChat processes a chat message from WebSocket
-func (p *WebSocketPlugin) handleChat(text string) {
-	// Publish to broker
-	p.broker.Publish(p.ctx, plugin.Message{
+// Binary chat payloads arrive base64-encoded and are published as []byte
+func (p *WebSocketPlugin) handleChat(conn *websocket.Conn, wsMsg WSMessage) {
+	payload, err := plugin.DecodePayload(wsMsg.Payload, wsMsg.Encoding)
+	if err != nil {
+		p.sendToClient(conn, WSMessage{
+			Type:    "error",
+			Payload: err.Error(),
+		})
+		return
+	}
+
+	msg := plugin.Message{
 		Topic:   "chat",
-		Payload: text,
+		Payload: payload,
 		Source:  "websocket",
-	})
+	}
+
+	if wsMsg.Encoding != "" {
+		msg.Metadata = map[string]interface{}{plugin.MetadataEncoding: wsMsg.Encoding}
+	} else if strings.TrimSpace(wsMsg.Payload) == "" {
+		// Ignore blank messages
+		return
+	}
+
+	// A client relaying a message it received passes its via list back, so
+	// the broker can refuse messages that have come full circle
+	if via, ok := wsMsg.Data[plugin.MetadataVia]; ok {
+		if msg.Metadata == nil {
+			msg.Metadata = make(map[string]interface{})
+		}
+		msg.Metadata[plugin.MetadataVia] = via
+	}
+
+	// Publish to broker
+	if err := p.broker.Publish(p.ctx, msg); errors.Is(err, plugin.ErrMessageLoop) {
+		p.sendToClient(conn, WSMessage{
+			Type:    "error",
+			Payload: err.Error(),
+		})
+	}
 }
 
 // handleBrokerMessages receives messages from the broker and broadcasts to clients
 func (p *WebSocketPlugin) handleBrokerMessages() {
+	defer func() {
+		if !p.stopping.Load() {
+			p.deliveryStopped.Store(true)
+		}
+	}()
+
 	for msg := range p.msgCh {
-		// Convert message to WSMessage
-		var text string
-		if str, ok := msg.Payload.(string); ok {
-			text = str
-		} else {
-			text = fmt.Sprintf("%v", msg.Payload)
+		// Skip replies meant for other transports; opt-in topics are for
+		// monitoring every task, so they aren't filtered
+		if !isOptIn(msg.Topic) && !plugin.AddressedTo(msg, "websocket") {
+			continue
 		}
 
-		wsMsg := WSMessage{
-			Type:    msg.Topic,
-			Payload: text,
+		plugin.HandleWithTimeout(p.ctx, "WebSocket", p.handlerTimeout, msg, p.deliver)
+	}
+}
+
+// deliver sends a broker message to its client, or to all clients
+// Writes stop at the context's deadline so an overrun delivery ends promptly
+func (p *WebSocketPlugin) deliver(ctx context.Context, msg plugin.Message) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	// An earlier overrun may have held the lock past this delivery's deadline
+	if ctx.Err() != nil {
+		p.reportDeliveryFailure(msg, ctx.Err())
+		return
+	}
+	deadline, _ := ctx.Deadline()
+
+	// Convert message to WSMessage, base64-encoding binary payloads
+	text, encoding := plugin.EncodePayload(msg.Payload)
+
+	wsMsg := WSMessage{
+		Type:     msg.Topic,
+		Payload:  text,
+		Encoding: encoding,
+		Data:     map[string]interface{}{plugin.MetadataSeverity: string(plugin.SeverityOf(msg))},
+	}
+	if via := plugin.Via(msg); len(via) > 0 {
+		wsMsg.Data[plugin.MetadataVia] = via
+	}
+
+	// Task events go to subscribed clients, with their metadata and result
+	if isOptIn(msg.Topic) {
+		wsMsg.Data = taskEventData(msg)
+		p.sendToSubscribers(msg.Topic, wsMsg, deadline)
+		return
+	}
+
+	// Send replies to the client that asked, everything else to all clients
+	if replyTo := plugin.ReplyTo(msg); replyTo != "" && replyTo != "websocket" {
+		if !p.sendTo(strings.TrimPrefix(replyTo, "websocket:"), wsMsg, deadline) {
+			p.reportDeliveryFailure(msg, fmt.Errorf("client %s not connected", replyTo))
 		}
+		return
+	}
 
-		// Broadcast to all clients
-		p.broadcast(wsMsg)
+	// Broadcast to all clients, reporting failures for task messages
+	if failed := p.broadcast(wsMsg, deadline); failed > 0 {
+		p.reportDeliveryFailure(msg, fmt.Errorf("write failed for %d client(s)", failed))
 	}
 }
 
-// sendToClient sends a message to a specific client
+// reportDeliveryFailure publishes a delivery.failed message for task-related messages
+func (p *WebSocketPlugin) reportDeliveryFailure(msg plugin.Message, err error) {
+	taskID, ok := msg.Metadata["task_id"].(string)
+	if !ok {
+		return
+	}
+
+	p.broker.Publish(p.ctx, plugin.Message{
+		Topic:   plugin.TopicDeliveryFailed,
+		Payload: err.Error(),
+		Source:  "websocket",
+		Metadata: map[string]interface{}{
+			"task_id": taskID,
+			"topic":   msg.Topic,
+			"error":   err.Error(),
+		},
+	})
+}
+
+// sendToClient sends a message to a specific client, giving up after
+// handlerTimeout so a stalled client doesn't hold its write lock
 func (p *WebSocketPlugin) sendToClient(conn *websocket.Conn, msg WSMessage) {
-	if err := conn.WriteJSON(msg); err != nil {
+	p.mu.RLock()
+	lock, ok := p.clients[conn]
+	p.mu.RUnlock()
+	if !ok {
+		return // Disconnected
+	}
+
+	var deadline time.Time
+	if p.handlerTimeout > 0 {
+		deadline = time.Now().Add(p.handlerTimeout)
+	}
+	if err := p.write(conn, lock, msg, deadline); err != nil {
 		log.Printf("[WebSocket] Write error: %v", err)
 	}
 }
 
-// broadcast sends a message to all connected clients
-func (p *WebSocketPlugin) broadcast(msg WSMessage) {
-	p.mu.RLock()
-	defer p.mu.RUnlock()
+// write sends a message to a client under its write lock, giving up at
+// deadline (zero = none)
+// Callers must not hold p.mu, since waiting for the write lock can take up
+// to another write's deadline
+// With compression on, messages of at least compressionThreshold bytes are
+// compressed if the client negotiated permessage-deflate
+func (p *WebSocketPlugin) write(conn *websocket.Conn, lock *sync.Mutex, msg WSMessage, deadline time.Time) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	conn.EnableWriteCompression(p.compression && len(data) >= p.compressionThreshold)
+	conn.SetWriteDeadline(deadline)
+	defer conn.SetWriteDeadline(time.Time{})
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
 
+// sendTo sends a message to the client connected from addr, giving up at
+// deadline (zero = none)
+// Returns false if no such client is connected or the write failed
+func (p *WebSocketPlugin) sendTo(addr string, msg WSMessage, deadline time.Time) bool {
+	for conn, lock := range p.clientLocks(func(conn *websocket.Conn) bool {
+		return conn.RemoteAddr().String() == addr
+	}) {
+		if err := p.write(conn, lock, msg, deadline); err != nil {
+			log.Printf("[WebSocket] Write error: %v", err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// sendToSubscribers sends a message to the clients subscribed to topic,
+// giving up on each at deadline (zero = none)
+func (p *WebSocketPlugin) sendToSubscribers(topic string, msg WSMessage, deadline time.Time) {
+	for conn, lock := range p.clientLocks(func(conn *websocket.Conn) bool {
+		return matchesAny(p.subscriptions[conn], topic)
+	}) {
+		if err := p.write(conn, lock, msg, deadline); err != nil {
+			log.Printf("[WebSocket] Write error: %v", err)
+		}
+	}
+}
+
+// broadcast sends a message to all connected clients, giving up on each at
+// deadline (zero = none)
+// Returns the number of clients the write failed for
+func (p *WebSocketPlugin) broadcast(msg WSMessage, deadline time.Time) int {
 	data, _ := json.Marshal(msg)
 	log.Printf("[WebSocket] Broadcasting: %s", string(data))
 
-	for conn := range p.clients {
-		if err := conn.WriteJSON(msg); err != nil {
+	failed := 0
+	for conn, lock := range p.clientLocks(nil) {
+		if err := p.write(conn, lock, msg, deadline); err != nil {
 			log.Printf("[WebSocket] Broadcast error: %v", err)
+			failed++
 		}
 	}
+	return failed
+}
+
+// clientLocks returns the connected clients that want reports true for (nil =
+// all) with their write locks, copied under p.mu so writing to them doesn't
+// block Stop or other deliveries
+func (p *WebSocketPlugin) clientLocks(want func(conn *websocket.Conn) bool) map[*websocket.Conn]*sync.Mutex {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	clients := make(map[*websocket.Conn]*sync.Mutex, len(p.clients))
+	for conn, lock := range p.clients {
+		if want == nil || want(conn) {
+			clients[conn] = lock
+		}
+	}
+	return clients
+}
+
+// supportsAny checks if any of the requested subprotocols is supported
+func supportsAny(requested []string) bool {
+	for _, r := range requested {
+		for _, s := range supportedProtocols {
+			if r == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isOptIn checks if a topic is only delivered to subscribed clients
+func isOptIn(topic string) bool {
+	for _, t := range optInTopics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny checks if a topic matches any of the patterns
+func matchesAny(patterns []string, topic string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == topic {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasSuffix(prefix, ".") && strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// taskEventData builds the data of a task event frame: the message metadata
+// plus the task result for completed and failed tasks
+func taskEventData(msg plugin.Message) map[string]interface{} {
+	data := make(map[string]interface{}, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		data[k] = v
+	}
+	if result, ok := msg.Payload.(*plugin.TaskResult); ok {
+		data["result"] = result
+	}
+	return data
 }
@@ -0,0 +1,36 @@
+package websocket
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"bicycle/internal/testutil"
+)
+
+func TestStopTwice(t *testing.T) {
+	broker := testutil.NewBroker()
+	ctx := testutil.NewContext(
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("websocket", "unix_socket", filepath.Join(t.TempDir(), "websocket.sock")),
+	)
+
+	p := NewWebSocketPlugin()
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := p.Start(ctx, broker); err != nil {
+		t.Errorf("second Start: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.Stop(context.Background()); err != nil {
+			t.Errorf("Stop %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestStopBeforeStart(t *testing.T) {
+	if err := NewWebSocketPlugin().Stop(context.Background()); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// dial connects to server asking for protocols
+func dial(t *testing.T, server *httptest.Server, protocols ...string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	dialer := websocket.Dialer{Subprotocols: protocols}
+	return dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+}
+
+func TestSubprotocolNegotiation(t *testing.T) {
+	p := NewWebSocketPlugin()
+	server := httptest.NewServer(http.HandlerFunc(p.handleWebSocket))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		requested []string
+		want      string
+	}{
+		{"supported", []string{ProtocolV1}, ProtocolV1},
+		{"supported among unknown", []string{"bicycle.v9", ProtocolV1}, ProtocolV1},
+		{"none requested", nil, ProtocolV1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, _, err := dial(t, server, tt.requested...)
+			if err != nil {
+				t.Fatalf("Dial: %v", err)
+			}
+			defer conn.Close()
+
+			if len(tt.requested) > 0 && conn.Subprotocol() != tt.want {
+				t.Errorf("negotiated %q, want %q", conn.Subprotocol(), tt.want)
+			}
+			var welcome WSMessage
+			if err := conn.ReadJSON(&welcome); err != nil {
+				t.Fatalf("reading welcome: %v", err)
+			}
+			if got := welcome.Data["protocol"]; got != tt.want {
+				t.Errorf("welcome protocol = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsupportedSubprotocolRejected(t *testing.T) {
+	p := NewWebSocketPlugin()
+	server := httptest.NewServer(http.HandlerFunc(p.handleWebSocket))
+	defer server.Close()
+
+	conn, resp, err := dial(t, server, "bicycle.v9")
+	if err == nil {
+		conn.Close()
+		t.Fatal("Dial succeeded with only an unsupported subprotocol")
+	}
+	if resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("response = %v, want 400 Bad Request", resp)
+	}
+}
@@ -0,0 +1,119 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"bicycle/internal/testutil"
+
+	"github.com/gorilla/websocket"
+)
+
+// startOnSocket starts a plugin listening on a unix socket with the given
+// extra settings and returns it with the socket's path
+func startOnSocket(t *testing.T, settings map[string]interface{}) (*WebSocketPlugin, string) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "websocket.sock")
+	broker := testutil.NewBroker()
+	opts := []testutil.ContextOption{
+		testutil.WithBroker(broker),
+		testutil.WithPluginSetting("websocket", "unix_socket", socket),
+	}
+	for key, value := range settings {
+		opts = append(opts, testutil.WithPluginSetting("websocket", key, value))
+	}
+	ctx := testutil.NewContext(opts...)
+
+	// Subscribe before starting, as the daemon does
+	p := NewWebSocketPlugin()
+	if _, err := p.Subscribe(ctx, broker); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := p.Start(ctx, broker); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { p.Stop(context.Background()) })
+	return p, socket
+}
+
+// dialSocket connects a client to the plugin listening on socket and reads
+// its welcome message
+func dialSocket(t *testing.T, socket string) *websocket.Conn {
+	t.Helper()
+
+	dialer := websocket.Dialer{
+		NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}
+	conn, _, err := dialer.Dial("ws://localhost/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	var welcome WSMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatalf("reading welcome: %v", err)
+	}
+	return conn
+}
+
+// readUntilClose reads messages until the connection closes and returns them
+// with the close error
+func readUntilClose(t *testing.T, conn *websocket.Conn) ([]WSMessage, *websocket.CloseError) {
+	t.Helper()
+
+	var msgs []WSMessage
+	for {
+		var msg WSMessage
+		err := conn.ReadJSON(&msg)
+		if err == nil {
+			msgs = append(msgs, msg)
+			continue
+		}
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("read error = %v, want a close frame", err)
+		}
+		return msgs, closeErr
+	}
+}
+
+func TestStopSendsGoodbyeAndNormalClose(t *testing.T) {
+	p, socket := startOnSocket(t, map[string]interface{}{"goodbye_message": "Back soon"})
+	conn := dialSocket(t, socket)
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	msgs, closeErr := readUntilClose(t, conn)
+	if len(msgs) != 1 || msgs[0].Type != "notification" || msgs[0].Payload != "Back soon" {
+		t.Errorf("messages before close = %+v, want the Back soon notification", msgs)
+	}
+	if closeErr.Code != websocket.CloseNormalClosure {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseNormalClosure)
+	}
+}
+
+func TestEmptyGoodbyeOnlyCloses(t *testing.T) {
+	p, socket := startOnSocket(t, map[string]interface{}{"goodbye_message": ""})
+	conn := dialSocket(t, socket)
+
+	if err := p.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	msgs, closeErr := readUntilClose(t, conn)
+	if len(msgs) != 0 {
+		t.Errorf("messages before close = %+v, want none", msgs)
+	}
+	if closeErr.Code != websocket.CloseNormalClosure {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseNormalClosure)
+	}
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bicycle/internal/testutil"
+	"bicycle/plugin"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscribe asks for a topic pattern and waits for the confirmation
+func subscribe(t *testing.T, conn *websocket.Conn, pattern string) {
+	t.Helper()
+
+	if err := conn.WriteJSON(WSMessage{Type: "subscribe", Payload: pattern}); err != nil {
+		t.Fatalf("subscribing to %s: %v", pattern, err)
+	}
+	var reply WSMessage
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != "response" {
+		t.Fatalf("subscribe reply = %+v, %v, want a response", reply, err)
+	}
+}
+
+// readEvent reads the next message, failing the test if none arrives in time
+func readEvent(t *testing.T, conn *websocket.Conn) WSMessage {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var msg WSMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading event: %v", err)
+	}
+	return msg
+}
+
+func TestSubscribedClientReceivesTaskEvents(t *testing.T) {
+	p, socket := startOnSocket(t, nil)
+	monitor := dialSocket(t, socket)
+	completions := dialSocket(t, socket)
+	subscribe(t, monitor, "task.*")
+	subscribe(t, completions, plugin.TopicTaskCompleted)
+
+	// A run as the daemon publishes it, addressed to another transport
+	metadata := map[string]interface{}{"task_id": "t1", "type": "echo", plugin.MetadataReplyTo: "telegram:42"}
+	result := &plugin.TaskResult{ID: "t1", Output: "hi"}
+	broker := p.broker.(*testutil.Broker)
+	for _, msg := range []plugin.Message{
+		{Topic: plugin.TopicTaskStarted, Payload: "Started task: echo", Metadata: metadata},
+		{Topic: plugin.TopicTaskProgress, Metadata: map[string]interface{}{"task_id": "t1", "progress": 50}},
+		{Topic: plugin.TopicTaskCompleted, Payload: result, Metadata: metadata},
+	} {
+		if err := broker.Publish(context.Background(), msg); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	for _, want := range []string{plugin.TopicTaskStarted, plugin.TopicTaskProgress, plugin.TopicTaskCompleted} {
+		event := readEvent(t, monitor)
+		if event.Type != want || event.Data["task_id"] != "t1" {
+			t.Fatalf("monitor got %+v, want %s for t1", event, want)
+		}
+		if want == plugin.TopicTaskProgress && event.Data["progress"] != float64(50) {
+			t.Errorf("progress = %v, want 50", event.Data["progress"])
+		}
+		if want == plugin.TopicTaskCompleted {
+			if got, _ := event.Data["result"].(map[string]interface{}); got == nil || got["output"] != "hi" {
+				t.Errorf("completed result = %v, want output hi", event.Data["result"])
+			}
+		}
+	}
+
+	// A client subscribed to one topic only gets that topic
+	if event := readEvent(t, completions); event.Type != plugin.TopicTaskCompleted {
+		t.Errorf("completions client got %+v first, want %s", event, plugin.TopicTaskCompleted)
+	}
+}
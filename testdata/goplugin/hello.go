@@ -0,0 +1,22 @@
+// Command goplugin is a Go plugin built by the plugin-dir tests
+package main
+
+import (
+	"context"
+
+	"bicycle/plugin"
+)
+
+// helloPlugin does nothing; the tests only look for it in the registry
+type helloPlugin struct{}
+
+func (helloPlugin) Name() string                                                 { return "hello-so" }
+func (helloPlugin) CheckRequirements(ctx context.Context) error                  { return nil }
+func (helloPlugin) Extensions() []plugin.Extension                               { return nil }
+func (helloPlugin) Start(ctx context.Context, broker plugin.MessageBroker) error { return nil }
+func (helloPlugin) Stop(ctx context.Context) error                               { return nil }
+
+// Register adds the plugin to the registry
+func Register() {
+	plugin.Register(helloPlugin{})
+}